@@ -1,27 +1,246 @@
 package app
 
 import (
+	"context"
+	"fmt"
 	grpcapp "gRPC/internal/app/grpc"
+	httpapp "gRPC/internal/app/http"
+	"gRPC/internal/config"
+	authgrpc "gRPC/internal/grpc/auth"
+	"gRPC/internal/grpc/interceptors"
+	"gRPC/internal/kv"
+	kvmemory "gRPC/internal/kv/memory"
+	kvredis "gRPC/internal/kv/redis"
+	"gRPC/internal/lib/clock"
+	"gRPC/internal/lib/email"
+	"gRPC/internal/lib/password"
+	"gRPC/internal/lib/sms"
+	"gRPC/internal/lib/webauthn"
+	"gRPC/internal/services/admin"
+	"gRPC/internal/services/audit"
 	"gRPC/internal/services/auth"
-	"gRPC/internal/storage/postgres"
+	"gRPC/internal/services/webhooks"
+	"gRPC/internal/storage"
+	"gRPC/internal/storage/driver"
+	"google.golang.org/grpc"
 	"log/slog"
-	"time"
+	"net/http"
 )
 
 type App struct {
-	GRPCServer *grpcapp.App
+	GRPCServer        *grpcapp.App
+	HTTPServer        *httpapp.App
+	AuditCheckpointer *audit.Checkpointer
+	WebhookDispatcher *webhooks.Dispatcher
+	// EventBus feeds event watchers, closing it ends their streams
+	EventBus *auth.EventBus
 }
 
-func New(log *slog.Logger, grpcPort int, storagePath string, tokenTTL time.Duration) *App {
-	storage, err := postgres.New(storagePath)
+type options struct {
+	storage     storage.Storage
+	clock       clock.Clock
+	emailSender auth.EmailSender
+	smsSender   auth.SMSSender
+	kv          kv.Store
+}
+
+// Option replaces dependency which is otherwise built from config
+type Option func(o *options)
+
+// WithStorage makes app use given storage instead of opening cfg.StorageDriver
+func WithStorage(s storage.Storage) Option {
+	return func(o *options) {
+		o.storage = s
+	}
+}
+
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
+	}
+}
+
+func WithEmailSender(sender auth.EmailSender) Option {
+	return func(o *options) {
+		o.emailSender = sender
+	}
+}
+
+func WithSMSSender(sender auth.SMSSender) Option {
+	return func(o *options) {
+		o.smsSender = sender
+	}
+}
+
+func WithKV(store kv.Store) Option {
+	return func(o *options) {
+		o.kv = store
+	}
+}
+
+func New(log *slog.Logger, cfg *config.Config, opts ...Option) *App {
+	o := options{clock: clock.Real{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.storage == nil {
+		s, err := driver.Open(cfg.StorageDriver, cfg.StoragePath)
+		if err != nil {
+			panic(err)
+		}
+
+		if m, ok := s.(driver.Migratable); ok && cfg.AutoMigrate {
+			applied, err := m.Migrator().Up(context.Background())
+			if err != nil {
+				panic(err)
+			}
+
+			log.Info("migrations applied", slog.Int("count", len(applied)))
+		}
+
+		o.storage = s
+	}
+
+	if o.emailSender == nil {
+		o.emailSender = newEmailSender(log, cfg.Email)
+	}
+
+	if o.smsSender == nil {
+		o.smsSender = newSMSSender(log, cfg.SMS)
+	}
+
+	if o.kv == nil {
+		o.kv = newKV(cfg.KV, o.clock)
+	}
+
+	// only rate limiting may be given up while the store is down, one-time codes fail closed
+	rateLimits := o.kv
+	if cfg.KV.FailOpen {
+		rateLimits = kv.FailOpen(log, o.kv)
+	}
+
+	storage := o.storage
+
+	hasher, err := password.FromConfig(cfg.Password)
+	if err != nil {
+		panic(err)
+	}
+
+	policy, err := password.PolicyFromConfig(cfg.Password)
+	if err != nil {
+		panic(err)
+	}
+
+	eventBus := auth.NewEventBus(storage)
+	webhookService := webhooks.New(log, storage, o.clock,
+		webhooks.WithHTTPClient(&http.Client{Timeout: cfg.Webhooks.Timeout}),
+		webhooks.WithMaxAttempts(cfg.Webhooks.MaxAttempts),
+	)
+	auditService := audit.New(log, storage, o.clock,
+		audit.WithPublisher(eventBus),
+		audit.WithPublisher(webhookService),
+	)
+
+	authService := auth.New(log, storage, storage, storage, storage, storage, auditService, cfg.TokenTTL,
+		auth.WithClock(o.clock),
+		auth.WithEmailSender(o.emailSender),
+		auth.WithKV(o.kv),
+		auth.WithRateLimits(rateLimits),
+		auth.WithEventBus(eventBus),
+		auth.WithPasswordHasher(hasher),
+		auth.WithPasswordPolicy(policy),
+		auth.WithPasswordHistory(cfg.Password.History),
+		auth.WithPasswordExpiry(cfg.Password.Expiry.MaxAge, cfg.Password.Expiry.Apps...),
+		auth.WithOpenID(cfg.OIDC.Issuer, storage),
+		auth.WithServiceAccounts(storage),
+		auth.WithPasswordless(cfg.Passwordless.LinkURL, cfg.Passwordless.TTL),
+		auth.WithPasskeys(webauthn.RelyingParty{
+			ID:      cfg.WebAuthn.RPID,
+			Name:    cfg.WebAuthn.RPName,
+			Origins: cfg.WebAuthn.Origins,
+		}, storage, cfg.WebAuthn.Timeout),
+		auth.WithSMS(o.smsSender, storage, cfg.SMS.CodeTTL),
+	)
+	adminService := admin.New(log, storage, storage, storage, storage, auditService, admin.WithClock(o.clock))
+
+	stepUpPolicy, err := newStepUpPolicy(cfg.StepUp)
 	if err != nil {
 		panic(err)
 	}
 
-	authService := auth.New(log, storage, storage, storage, storage, tokenTTL)
-	grpcApp := grpcapp.New(log, authService, grpcPort)
+	// the same servers and interceptors are used by gRPC server and HTTP gateway
+	servers := authgrpc.NewServers(authService, auditService, webhookService, adminService)
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		interceptors.Recovery(log),
+		interceptors.Logging(log),
+		interceptors.Timeout(cfg.GRPC.Timeout),
+		interceptors.RequireAuth(stepUpPolicy, servers.StepUp),
+	}
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		interceptors.RequireAuthStream(stepUpPolicy, servers.StepUp),
+	}
+
+	grpcApp := grpcapp.New(log, authService, servers, cfg.GRPC.Port,
+		grpc.ChainUnaryInterceptor(unaryInterceptors...), grpc.ChainStreamInterceptor(streamInterceptors...))
+	httpApp := httpapp.New(log, authService, servers, cfg.HTTP, cfg.OIDC,
+		interceptors.Chain(unaryInterceptors...), interceptors.ChainStream(streamInterceptors...))
 
 	return &App{
-		GRPCServer: grpcApp,
+		GRPCServer:        grpcApp,
+		HTTPServer:        httpApp,
+		AuditCheckpointer: audit.NewCheckpointer(log, auditService, cfg.Audit.CheckpointInterval),
+		WebhookDispatcher: webhooks.NewDispatcher(log, webhookService, cfg.Webhooks.PollInterval),
+		EventBus:          eventBus,
+	}
+}
+
+func newEmailSender(log *slog.Logger, cfg config.EmailConfig) auth.EmailSender {
+	switch cfg.Driver {
+	case config.EmailDriverLog:
+		return email.NewLogSender(log)
+	default:
+		return email.NewSMTPSender(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From)
+	}
+}
+
+func newSMSSender(log *slog.Logger, cfg config.SMSConfig) auth.SMSSender {
+	switch cfg.Driver {
+	case config.SMSDriverFile:
+		return sms.NewFileSender(cfg.Path)
+	case config.SMSDriverHTTP:
+		return sms.NewHTTPSender(cfg.URL, cfg.Token, cfg.From, cfg.Timeout)
+	default:
+		return sms.NewLogSender(log)
+	}
+}
+
+// newStepUpPolicy returns requirements of methods configured to need step-up authentication
+func newStepUpPolicy(cfg config.StepUpConfig) (map[string]interceptors.AuthRequirement, error) {
+	policy := make(map[string]interceptors.AuthRequirement, len(cfg.Methods))
+
+	for method, rule := range cfg.Methods {
+		if rule.MinACR != "" && !auth.IsACR(rule.MinACR) {
+			return nil, fmt.Errorf("step_up: unknown min_acr %q of method %s", rule.MinACR, method)
+		}
+
+		policy[method] = interceptors.AuthRequirement{MaxAge: rule.MaxAge, MinACR: rule.MinACR}
+	}
+
+	return policy, nil
+}
+
+func newKV(cfg config.KVConfig, clk clock.Clock) kv.Store {
+	switch cfg.Driver {
+	case config.KVDriverMemory:
+		return kvmemory.New(clk)
+	default:
+		return kvredis.New(kvredis.Options{
+			Addr:     cfg.Addr,
+			Password: cfg.Password,
+			DB:       cfg.DB,
+			TLS:      cfg.TLS,
+			PoolSize: cfg.PoolSize,
+		})
 	}
 }
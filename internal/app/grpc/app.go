@@ -20,10 +20,14 @@ func (a *App) MustRun() {
 	}
 }
 
-func New(log *slog.Logger, authService authgrpc.Auth, port int) *App {
-	grpcServer := grpc.NewServer()
+// New returns gRPC server of Auth and ExtensionsService, opts configure it, e.g. its interceptors
+func New(
+	log *slog.Logger, authService authgrpc.Auth, servers *authgrpc.Servers, port int, opts ...grpc.ServerOption,
+) *App {
+	grpcServer := grpc.NewServer(opts...)
 
 	authgrpc.Register(grpcServer, authService)
+	authgrpc.RegisterExtensions(grpcServer, servers)
 	return &App{
 		log:        log,
 		grpcServer: grpcServer,
@@ -46,13 +50,18 @@ func (a *App) Run() error {
 
 	log.Info("Server is running", slog.String("addr", l.Addr().String()))
 
-	if err = a.grpcServer.Serve(l); err != nil {
+	if err = a.Serve(l); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
+// Serve serves gRPC on given listener, tests use it with in-memory listener
+func (a *App) Serve(l net.Listener) error {
+	return a.grpcServer.Serve(l)
+}
+
 func (a *App) Stop() {
 	const op = "App is not running anymore"
 
@@ -0,0 +1,225 @@
+package httpapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gRPC/internal/config"
+	authgrpc "gRPC/internal/grpc/auth"
+	authhttp "gRPC/internal/http/auth"
+	"gRPC/internal/http/gateway"
+	oauthhttp "gRPC/internal/http/oauth"
+	"gRPC/internal/lib/sl"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shutdownTimeout is how long Stop waits for in-flight requests
+const shutdownTimeout = 10 * time.Second
+
+type App struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	port       int
+}
+
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic(err)
+	}
+}
+
+// AuthService is everything the gateway serves besides servers of ExtensionsService
+type AuthService interface {
+	authgrpc.Auth
+	oauthhttp.Authorizer
+}
+
+// New returns HTTP gateway serving Auth RPCs as JSON endpoints.
+//
+// interceptor is applied to every unary call and streamInterceptor to every streaming one,
+// the same way gRPC server applies them.
+func New(
+	log *slog.Logger,
+	authService AuthService,
+	servers *authgrpc.Servers,
+	cfg config.HTTPConfig,
+	oidc config.OIDCConfig,
+	interceptor grpc.UnaryServerInterceptor,
+	streamInterceptor grpc.StreamServerInterceptor,
+) *App {
+	mux := http.NewServeMux()
+
+	authhttp.Register(mux, authgrpc.NewServer(authService), interceptor)
+	authhttp.RegisterPasswords(mux, servers.Passwords, interceptor)
+	authhttp.RegisterPasswordless(mux, servers.Passwordless, interceptor)
+	authhttp.RegisterPasskeys(mux, servers.Passkeys, interceptor)
+	authhttp.RegisterPhones(mux, servers.Phones, interceptor)
+	authhttp.RegisterStepUp(mux, servers.StepUp, interceptor)
+	authhttp.RegisterSessions(mux, servers.Sessions, interceptor)
+	authhttp.RegisterAudit(mux, servers.Audit, interceptor, streamInterceptor)
+	authhttp.RegisterEvents(mux, servers.Events, streamInterceptor)
+	authhttp.RegisterWebhooks(mux, servers.Webhooks, interceptor)
+	authhttp.RegisterUserInfo(mux, servers.UserInfo, interceptor)
+	authhttp.RegisterServiceAccounts(mux, servers.ServiceAccounts, interceptor)
+	authhttp.RegisterDevice(mux, servers.Device, interceptor)
+	authhttp.RegisterTokens(mux, servers.Tokens, interceptor)
+	authhttp.RegisterImpersonation(mux, servers.Impersonation, interceptor)
+	oauthhttp.Register(mux, log, authService, oidc.Issuer)
+
+	handler := recovery(log, logging(log, cors(cfg.CORS, mux)))
+
+	return &App{
+		log: log,
+		httpServer: &http.Server{
+			Addr:         fmt.Sprintf(":%d", cfg.Port),
+			Handler:      handler,
+			ReadTimeout:  cfg.Timeout,
+			WriteTimeout: cfg.Timeout,
+		},
+		port: cfg.Port,
+	}
+}
+
+func (a *App) Run() error {
+	const op = "HTTP gateway is running"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("port", a.port),
+	)
+
+	l, err := net.Listen("tcp", a.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("HTTP gateway is running", slog.String("addr", l.Addr().String()))
+
+	if err = a.Serve(l); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Serve serves HTTP on given listener until Stop is called
+func (a *App) Serve(l net.Listener) error {
+	if err := a.httpServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+func (a *App) Stop() {
+	const op = "HTTP gateway is not running anymore"
+
+	a.log.With(
+		slog.String("op", op)).Info("HTTP gateway is stopped", slog.Int("port", a.port))
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := a.httpServer.Shutdown(ctx); err != nil {
+		a.log.Error("failed to stop HTTP gateway", sl.Err(err))
+	}
+}
+
+// cors answers preflight requests and sets CORS headers for allowed origins
+func cors(cfg config.CORSConfig, next http.Handler) http.Handler {
+	allowAll := slices.Contains(cfg.AllowedOrigins, "*")
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !(allowAll || slices.Contains(cfg.AllowedOrigins, origin)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		h.Set("Access-Control-Allow-Origin", origin)
+		if cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", headers)
+			h.Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Flush lets streaming handlers flush through the recorder
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func logging(log *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		log.Info("http request handled",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
+
+func recovery(log *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				log.Error("panic recovered",
+					slog.String("path", r.URL.Path),
+					slog.Any("panic", p),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				gateway.WriteError(w, status.Error(codes.Internal, "Internal error"))
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,377 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type appView struct {
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	Secret       string   `json:"secret,omitempty"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Confidential bool     `json:"confidential,omitempty"`
+	RedirectURIs []string `json:"redirect_uris,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	Audiences    []string `json:"audiences,omitempty"`
+	Passwordless bool     `json:"passwordless,omitempty"`
+}
+
+type exchangePolicyView struct {
+	ID       int64    `json:"id"`
+	AppID    int      `json:"app_id"`
+	Actor    string   `json:"actor"`
+	Audience string   `json:"audience"`
+	Scopes   []string `json:"scopes,omitempty"`
+}
+
+// listFlag collects values of flag given several times
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(v string) error {
+	*f = append(*f, v)
+
+	return nil
+}
+
+func (c *CLI) app(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "create":
+		return c.appCreate(ctx, args[1:])
+	case "list":
+		return c.appList(ctx, args[1:])
+	case "rotate-secret":
+		return c.appRotateSecret(ctx, args[1:])
+	case "rotate-client-secret":
+		return c.appRotateClientSecret(ctx, args[1:])
+	case "set-oauth":
+		return c.appSetOAuth(ctx, args[1:])
+	case "set-audiences":
+		return c.appSetAudiences(ctx, args[1:])
+	case "set-passwordless":
+		return c.appSetPasswordless(ctx, args[1:])
+	case "add-exchange-policy":
+		return c.appAddExchangePolicy(ctx, args[1:])
+	case "exchange-policies":
+		return c.appExchangePolicies(ctx, args[1:])
+	case "delete-exchange-policy":
+		return c.appDeleteExchangePolicy(ctx, args[1:])
+	default:
+		return ErrUsage
+	}
+}
+
+func (c *CLI) appCreate(ctx context.Context, args []string) error {
+	cmd := newCommand("app create")
+	if err := cmd.parse(args, 1); err != nil {
+		return err
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	app, err := adminService.CreateApp(ctx, cmd.arg(0))
+	if err != nil {
+		return err
+	}
+
+	view := appView{ID: app.ID, Name: app.Name, Secret: app.Secret}
+
+	return c.print(cmd, view, []string{"ID", "NAME", "SECRET"},
+		[][]string{{strconv.Itoa(view.ID), view.Name, view.Secret}})
+}
+
+// appList does not print secrets, they are shown only on create and rotation
+func (c *CLI) appList(ctx context.Context, args []string) error {
+	cmd := newCommand("app list")
+	if err := cmd.parse(args, 0); err != nil {
+		return err
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	apps, err := adminService.Apps(ctx)
+	if err != nil {
+		return err
+	}
+
+	views := make([]appView, 0, len(apps))
+	rows := make([][]string, 0, len(apps))
+	for _, app := range apps {
+		views = append(views, appView{ID: app.ID, Name: app.Name, RedirectURIs: app.RedirectURIs,
+			Scopes: app.Scopes, Audiences: app.Audiences, Passwordless: app.Passwordless, Confidential: app.Confidential()})
+		rows = append(rows, []string{strconv.Itoa(app.ID), app.Name,
+			strings.Join(app.RedirectURIs, " "), strings.Join(app.Scopes, " "), strings.Join(app.Audiences, " "),
+			strconv.FormatBool(app.Passwordless), strconv.FormatBool(app.Confidential())})
+	}
+
+	return c.print(cmd, views,
+		[]string{"ID", "NAME", "REDIRECT_URIS", "SCOPES", "AUDIENCES", "PASSWORDLESS", "CONFIDENTIAL"}, rows)
+}
+
+func (c *CLI) appRotateSecret(ctx context.Context, args []string) error {
+	cmd := newCommand("app rotate-secret")
+	if err := cmd.parse(args, 1); err != nil {
+		return err
+	}
+
+	appID, err := strconv.Atoi(cmd.arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid app id %q", cmd.arg(0))
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	secret, err := adminService.RotateAppSecret(ctx, appID)
+	if err != nil {
+		return err
+	}
+
+	view := appView{ID: appID, Secret: secret}
+
+	return c.print(cmd, view, []string{"ID", "SECRET"},
+		[][]string{{strconv.Itoa(appID), secret}})
+}
+
+// appRotateClientSecret issues new OAuth client secret of app, --public removes it instead
+func (c *CLI) appRotateClientSecret(ctx context.Context, args []string) error {
+	cmd := newCommand("app rotate-client-secret")
+	public := cmd.flags.Bool("public", false, "make app public client without secret")
+
+	if err := cmd.parse(args, 1); err != nil {
+		return err
+	}
+
+	appID, err := strconv.Atoi(cmd.arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid app id %q", cmd.arg(0))
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	var secret string
+	if *public {
+		err = adminService.MakeAppPublicClient(ctx, appID)
+	} else {
+		secret, err = adminService.RotateAppClientSecret(ctx, appID)
+	}
+	if err != nil {
+		return err
+	}
+
+	view := appView{ID: appID, ClientSecret: secret, Confidential: !*public}
+
+	return c.print(cmd, view, []string{"ID", "CLIENT_SECRET", "CONFIDENTIAL"},
+		[][]string{{strconv.Itoa(appID), secret, strconv.FormatBool(view.Confidential)}})
+}
+
+// appSetOAuth replaces OAuth client settings of app, omitted flags clear them
+func (c *CLI) appSetOAuth(ctx context.Context, args []string) error {
+	cmd := newCommand("app set-oauth")
+
+	var redirectURIs listFlag
+	cmd.flags.Var(&redirectURIs, "redirect-uri", "allowed redirect URI, may be repeated")
+	scope := cmd.flags.String("scope", "", "space delimited scopes app may request")
+
+	if err := cmd.parse(args, 1); err != nil {
+		return err
+	}
+
+	appID, err := strconv.Atoi(cmd.arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid app id %q", cmd.arg(0))
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	app, err := adminService.ConfigureAppOAuth(ctx, appID, redirectURIs, *scope)
+	if err != nil {
+		return err
+	}
+
+	view := appView{ID: app.ID, Name: app.Name, RedirectURIs: app.RedirectURIs, Scopes: app.Scopes}
+
+	return c.print(cmd, view, []string{"ID", "NAME", "REDIRECT_URIS", "SCOPES"},
+		[][]string{{strconv.Itoa(app.ID), app.Name, strings.Join(app.RedirectURIs, " "), strings.Join(app.Scopes, " ")}})
+}
+
+// appSetAudiences replaces resource servers tokens of app may be issued for, no flags clear them
+func (c *CLI) appSetAudiences(ctx context.Context, args []string) error {
+	cmd := newCommand("app set-audiences")
+
+	var audiences listFlag
+	cmd.flags.Var(&audiences, "audience", "allowed token audience, may be repeated")
+
+	if err := cmd.parse(args, 1); err != nil {
+		return err
+	}
+
+	appID, err := strconv.Atoi(cmd.arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid app id %q", cmd.arg(0))
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	app, err := adminService.ConfigureAppAudiences(ctx, appID, strings.Join(audiences, " "))
+	if err != nil {
+		return err
+	}
+
+	view := appView{ID: app.ID, Name: app.Name, Audiences: app.Audiences}
+
+	return c.print(cmd, view, []string{"ID", "NAME", "AUDIENCES"},
+		[][]string{{strconv.Itoa(app.ID), app.Name, strings.Join(app.Audiences, " ")}})
+}
+
+// appSetPasswordless enables login to app by email link or one-time code, --disable turns it off
+func (c *CLI) appSetPasswordless(ctx context.Context, args []string) error {
+	cmd := newCommand("app set-passwordless")
+	disable := cmd.flags.Bool("disable", false, "disable passwordless login")
+
+	if err := cmd.parse(args, 1); err != nil {
+		return err
+	}
+
+	appID, err := strconv.Atoi(cmd.arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid app id %q", cmd.arg(0))
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	app, err := adminService.ConfigureAppPasswordless(ctx, appID, !*disable)
+	if err != nil {
+		return err
+	}
+
+	view := appView{ID: app.ID, Name: app.Name, Passwordless: app.Passwordless}
+
+	return c.print(cmd, view, []string{"ID", "NAME", "PASSWORDLESS"},
+		[][]string{{strconv.Itoa(app.ID), app.Name, strconv.FormatBool(app.Passwordless)}})
+}
+
+// appAddExchangePolicy lets service account exchange tokens of app users for tokens of an audience
+func (c *CLI) appAddExchangePolicy(ctx context.Context, args []string) error {
+	cmd := newCommand("app add-exchange-policy")
+	actor := cmd.flags.String("actor", "", "client id of the service account acting for users")
+	audience := cmd.flags.String("audience", "", "audience of exchanged tokens")
+	scope := cmd.flags.String("scope", "", "space delimited scopes exchanged tokens may have")
+
+	if err := cmd.parse(args, 1); err != nil {
+		return err
+	}
+
+	if *actor == "" || *audience == "" {
+		return errors.New("--actor and --audience are required")
+	}
+
+	appID, err := strconv.Atoi(cmd.arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid app id %q", cmd.arg(0))
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	policy, err := adminService.CreateExchangePolicy(ctx, appID, *actor, *audience, *scope)
+	if err != nil {
+		return err
+	}
+
+	view := exchangePolicyView{ID: policy.ID, AppID: policy.AppID, Actor: policy.Actor,
+		Audience: policy.Audience, Scopes: policy.Scopes}
+
+	return c.print(cmd, view, []string{"ID", "ACTOR", "AUDIENCE", "SCOPES"},
+		[][]string{{strconv.FormatInt(view.ID, 10), view.Actor, view.Audience, strings.Join(view.Scopes, " ")}})
+}
+
+func (c *CLI) appExchangePolicies(ctx context.Context, args []string) error {
+	cmd := newCommand("app exchange-policies")
+	if err := cmd.parse(args, 1); err != nil {
+		return err
+	}
+
+	appID, err := strconv.Atoi(cmd.arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid app id %q", cmd.arg(0))
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	policies, err := adminService.ExchangePolicies(ctx, appID)
+	if err != nil {
+		return err
+	}
+
+	views := make([]exchangePolicyView, 0, len(policies))
+	rows := make([][]string, 0, len(policies))
+	for _, policy := range policies {
+		views = append(views, exchangePolicyView{ID: policy.ID, AppID: policy.AppID, Actor: policy.Actor,
+			Audience: policy.Audience, Scopes: policy.Scopes})
+		rows = append(rows, []string{strconv.FormatInt(policy.ID, 10), policy.Actor, policy.Audience,
+			strings.Join(policy.Scopes, " ")})
+	}
+
+	return c.print(cmd, views, []string{"ID", "ACTOR", "AUDIENCE", "SCOPES"}, rows)
+}
+
+func (c *CLI) appDeleteExchangePolicy(ctx context.Context, args []string) error {
+	cmd := newCommand("app delete-exchange-policy")
+	if err := cmd.parse(args, 1); err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseInt(cmd.arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid policy id %q", cmd.arg(0))
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	if err := adminService.DeleteExchangePolicy(ctx, id); err != nil {
+		return err
+	}
+
+	return c.print(cmd, map[string]any{"id": id, "deleted": true},
+		[]string{"ID", "DELETED"}, [][]string{{strconv.FormatInt(id, 10), "true"}})
+}
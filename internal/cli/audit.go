@@ -0,0 +1,253 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+type auditEventView struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	UserID    int64     `json:"user_id"`
+	Email     string    `json:"email"`
+	AppID     int       `json:"app_id"`
+	ActorID   int64     `json:"actor_id"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash"`
+}
+
+type auditVerificationView struct {
+	Valid       bool   `json:"valid"`
+	Events      int64  `json:"events"`
+	Checkpoints int64  `json:"checkpoints"`
+	BrokenAt    int64  `json:"broken_at,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// ErrBrokenChain makes audit verify exit with failure, so it can be used in scripts
+var ErrBrokenChain = errors.New("audit chain is broken")
+
+func (c *CLI) audit(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "list":
+		return c.auditList(ctx, args[1:])
+	case "export":
+		return c.auditExport(ctx, args[1:])
+	case "verify":
+		return c.auditVerify(ctx, args[1:])
+	case "checkpoint":
+		return c.auditCheckpoint(ctx, args[1:])
+	default:
+		return ErrUsage
+	}
+}
+
+// auditFilterFlags registers filter flags on cmd and returns function building the filter
+func auditFilterFlags(cmd *command) func() (models.AuditFilter, error) {
+	userID := cmd.flags.Int64("user", 0, "user id")
+	eventType := cmd.flags.String("type", "", "event type")
+	since := cmd.flags.String("since", "", "RFC 3339 time of the oldest event")
+	until := cmd.flags.String("until", "", "RFC 3339 time after the newest event")
+
+	return func() (models.AuditFilter, error) {
+		filter := models.AuditFilter{UserID: *userID, Type: *eventType}
+
+		for _, f := range []struct {
+			name  string
+			value string
+			dst   *time.Time
+		}{{"since", *since, &filter.Since}, {"until", *until, &filter.Until}} {
+			if f.value == "" {
+				continue
+			}
+
+			t, err := time.Parse(time.RFC3339, f.value)
+			if err != nil {
+				return models.AuditFilter{}, fmt.Errorf("invalid --%s: %w", f.name, err)
+			}
+
+			*f.dst = t
+		}
+
+		return filter, nil
+	}
+}
+
+func (c *CLI) auditList(ctx context.Context, args []string) error {
+	cmd := newCommand("audit list")
+	buildFilter := auditFilterFlags(cmd)
+	cursor := cmd.flags.Int64("cursor", 0, "id of the last event of the previous page")
+	limit := cmd.flags.Int("limit", 50, "max number of events")
+	if err := cmd.parse(args, 0); err != nil {
+		return err
+	}
+
+	filter, err := buildFilter()
+	if err != nil {
+		return err
+	}
+	filter.AfterID, filter.Limit = *cursor, *limit
+
+	storage, err := c.open()
+	if err != nil {
+		return err
+	}
+
+	events, next, err := c.auditService(storage).Events(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	views := make([]auditEventView, 0, len(events))
+	rows := make([][]string, 0, len(events))
+	for _, e := range events {
+		view := toAuditEventView(e)
+
+		views = append(views, view)
+		rows = append(rows, []string{
+			strconv.FormatInt(view.ID, 10),
+			view.CreatedAt.Format(time.DateTime),
+			view.Type,
+			strconv.FormatInt(view.UserID, 10),
+			view.Email,
+			strconv.FormatBool(view.Success),
+			view.Reason,
+			view.IP,
+		})
+	}
+
+	if next != 0 {
+		c.log.Warn("more events available", slog.Int64("cursor", next))
+	}
+
+	return c.print(cmd, views, []string{"ID", "TIME", "TYPE", "UID", "EMAIL", "SUCCESS", "REASON", "IP"}, rows)
+}
+
+// auditExport writes matching events as newline delimited JSON, the format SIEMs ingest
+func (c *CLI) auditExport(ctx context.Context, args []string) error {
+	cmd := newCommand("audit export")
+	buildFilter := auditFilterFlags(cmd)
+	if err := cmd.parse(args, 0); err != nil {
+		return err
+	}
+
+	filter, err := buildFilter()
+	if err != nil {
+		return err
+	}
+
+	storage, err := c.open()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(c.out)
+
+	return c.auditService(storage).Export(ctx, filter, func(e models.AuditEvent) error {
+		return enc.Encode(toAuditEventView(e))
+	})
+}
+
+func (c *CLI) auditVerify(ctx context.Context, args []string) error {
+	cmd := newCommand("audit verify")
+	if err := cmd.parse(args, 0); err != nil {
+		return err
+	}
+
+	storage, err := c.open()
+	if err != nil {
+		return err
+	}
+
+	res, err := c.auditService(storage).Verify(ctx)
+	if err != nil {
+		return err
+	}
+
+	view := auditVerificationView{
+		Valid:       res.Valid,
+		Events:      res.Events,
+		Checkpoints: res.Checkpoints,
+		BrokenAt:    res.BrokenAt,
+		Reason:      res.Reason,
+	}
+
+	brokenAt := ""
+	if res.BrokenAt != 0 {
+		brokenAt = strconv.FormatInt(res.BrokenAt, 10)
+	}
+
+	err = c.print(cmd, view, []string{"VALID", "EVENTS", "CHECKPOINTS", "BROKEN AT", "REASON"},
+		[][]string{{
+			strconv.FormatBool(view.Valid),
+			strconv.FormatInt(view.Events, 10),
+			strconv.FormatInt(view.Checkpoints, 10),
+			brokenAt,
+			view.Reason,
+		}})
+	if err != nil {
+		return err
+	}
+
+	if !res.Valid {
+		return ErrBrokenChain
+	}
+
+	return nil
+}
+
+// auditCheckpoint signs the end of audit chain now, without waiting for the server to do it
+func (c *CLI) auditCheckpoint(ctx context.Context, args []string) error {
+	cmd := newCommand("audit checkpoint")
+	if err := cmd.parse(args, 0); err != nil {
+		return err
+	}
+
+	storage, err := c.open()
+	if err != nil {
+		return err
+	}
+
+	checkpoint, err := c.auditService(storage).Checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+
+	view := map[string]any{"event_id": checkpoint.EventID, "hash": checkpoint.Hash, "kid": checkpoint.KeyID}
+
+	return c.print(cmd, view, []string{"EVENT ID", "HASH", "KID"},
+		[][]string{{strconv.FormatInt(checkpoint.EventID, 10), checkpoint.Hash, checkpoint.KeyID}})
+}
+
+func toAuditEventView(e models.AuditEvent) auditEventView {
+	return auditEventView{
+		ID:        e.ID,
+		Type:      e.Type,
+		UserID:    e.UserID,
+		Email:     e.Email,
+		AppID:     e.AppID,
+		ActorID:   e.ActorID,
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		Success:   e.Success,
+		Reason:    e.Reason,
+		CreatedAt: e.CreatedAt,
+		PrevHash:  e.PrevHash,
+		Hash:      e.Hash,
+	}
+}
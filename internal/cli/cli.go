@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"gRPC/internal/config"
+	"gRPC/internal/lib/clock"
+	"gRPC/internal/lib/password"
+	"gRPC/internal/services/admin"
+	"gRPC/internal/services/audit"
+	"gRPC/internal/services/auth"
+	"gRPC/internal/services/webhooks"
+	"gRPC/internal/storage"
+	"gRPC/internal/storage/driver"
+	"io"
+	"log/slog"
+	"strings"
+	"text/tabwriter"
+)
+
+const usage = `usage: sso [--config=path] <command> [flags] [args]
+
+commands:
+  migrate up|down|status|redo
+  user create --email=EMAIL --password=PASSWORD [--admin]
+  user list [--limit=N] [--offset=N]
+  user disable EMAIL
+  user promote EMAIL
+  app create NAME
+  app list
+  app rotate-secret APP_ID
+  app rotate-client-secret [--public] APP_ID
+  app set-oauth [--redirect-uri=URI]... [--scope=SCOPES] APP_ID
+  app set-audiences [--audience=AUDIENCE]... APP_ID
+  app set-passwordless [--disable] APP_ID
+  app add-exchange-policy --actor=CLIENT_ID --audience=AUDIENCE [--scope=SCOPES] APP_ID
+  app exchange-policies APP_ID
+  app delete-exchange-policy POLICY_ID
+  token issue --email=EMAIL --app=APP_ID
+  token inspect TOKEN
+  keys rotate
+  keys list
+  audit list [--user=ID] [--type=TYPE] [--since=TIME] [--until=TIME] [--cursor=ID] [--limit=N]
+  audit export [--user=ID] [--type=TYPE] [--since=TIME] [--until=TIME]
+  audit verify
+  audit checkpoint
+
+every command accepts --output=table|json`
+
+var ErrUsage = errors.New(usage)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// CLI runs administrative commands against the same storage and services as the server
+type CLI struct {
+	log     *slog.Logger
+	cfg     *config.Config
+	out     io.Writer
+	storage storage.Storage
+	// ownStorage is set when storage is opened by CLI, so it is stopped by CLI as well
+	ownStorage bool
+}
+
+// Option configures CLI
+type Option func(c *CLI)
+
+// WithStorage makes CLI work with s instead of storage of config, s is not stopped by CLI
+func WithStorage(s storage.Storage) Option {
+	return func(c *CLI) {
+		c.storage = s
+	}
+}
+
+// Run executes command given by args and writes its result to out
+func Run(ctx context.Context, log *slog.Logger, cfg *config.Config, out io.Writer, args []string, opts ...Option) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	c := &CLI{
+		log: log,
+		cfg: cfg,
+		out: out,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	defer c.close()
+
+	var err error
+	switch args[0] {
+	case "migrate":
+		err = c.migrate(ctx, args[1:])
+	case "user":
+		err = c.user(ctx, args[1:])
+	case "app":
+		err = c.app(ctx, args[1:])
+	case "token":
+		err = c.token(ctx, args[1:])
+	case "keys":
+		err = c.keys(ctx, args[1:])
+	case "audit":
+		err = c.audit(ctx, args[1:])
+	default:
+		err = ErrUsage
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return ErrUsage
+	}
+
+	return err
+}
+
+func (c *CLI) open() (storage.Storage, error) {
+	if c.storage != nil {
+		return c.storage, nil
+	}
+
+	s, err := driver.Open(c.cfg.StorageDriver, c.cfg.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+
+	c.storage, c.ownStorage = s, true
+
+	return s, nil
+}
+
+func (c *CLI) close() {
+	if c.ownStorage {
+		_ = c.storage.Stop()
+	}
+}
+
+func (c *CLI) authService() (*auth.Auth, error) {
+	storage, err := c.open()
+	if err != nil {
+		return nil, err
+	}
+
+	hasher, err := password.FromConfig(c.cfg.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := password.PolicyFromConfig(c.cfg.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	return auth.New(c.log, storage, storage, storage, storage, storage, c.auditService(storage), c.cfg.TokenTTL,
+		auth.WithPasswordHasher(hasher),
+		auth.WithPasswordPolicy(policy),
+		auth.WithPasswordHistory(c.cfg.Password.History),
+		auth.WithPasswordExpiry(c.cfg.Password.Expiry.MaxAge, c.cfg.Password.Expiry.Apps...),
+		auth.WithOpenID(c.cfg.OIDC.Issuer, storage),
+	), nil
+}
+
+func (c *CLI) adminService() (*admin.Admin, error) {
+	storage, err := c.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return admin.New(c.log, storage, storage, storage, storage, c.auditService(storage)), nil
+}
+
+// auditService records events the way the server does, webhook deliveries are queued
+// and sent by the server
+func (c *CLI) auditService(storage storage.Storage) *audit.Audit {
+	return audit.New(c.log, storage, clock.Real{},
+		audit.WithPublisher(webhooks.New(c.log, storage, clock.Real{})))
+}
+
+// command is a parsed subcommand invocation
+type command struct {
+	flags  *flag.FlagSet
+	output *string
+}
+
+func newCommand(name string) *command {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	return &command{
+		flags:  flags,
+		output: flags.String("output", outputTable, "output format: table or json"),
+	}
+}
+
+// parse parses flags and checks that exactly nArgs positional arguments are given
+func (cmd *command) parse(args []string, nArgs int) error {
+	if err := cmd.flags.Parse(args); err != nil {
+		return fmt.Errorf("%w\n\n%s", err, usage)
+	}
+
+	if cmd.flags.NArg() != nArgs {
+		return ErrUsage
+	}
+
+	if *cmd.output != outputTable && *cmd.output != outputJSON {
+		return fmt.Errorf("unknown output format %q", *cmd.output)
+	}
+
+	return nil
+}
+
+func (cmd *command) arg(i int) string {
+	return cmd.flags.Arg(i)
+}
+
+// print writes v as JSON or header and rows as a table depending on --output
+func (c *CLI) print(cmd *command, v any, header []string, rows [][]string) error {
+	if *cmd.output == outputJSON {
+		enc := json.NewEncoder(c.out)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	return w.Flush()
+}
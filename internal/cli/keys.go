@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+type keyView struct {
+	ID        string    `json:"kid"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (c *CLI) keys(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "rotate":
+		return c.keysRotate(ctx, args[1:])
+	case "list":
+		return c.keysList(ctx, args[1:])
+	default:
+		return ErrUsage
+	}
+}
+
+func (c *CLI) keysRotate(ctx context.Context, args []string) error {
+	cmd := newCommand("keys rotate")
+	if err := cmd.parse(args, 0); err != nil {
+		return err
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	key, err := adminService.RotateSigningKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	view := keyView{ID: key.ID, Active: true, CreatedAt: key.CreatedAt}
+
+	return c.print(cmd, view, []string{"KID", "ACTIVE", "CREATED AT"},
+		[][]string{{view.ID, "true", view.CreatedAt.Format(time.DateTime)}})
+}
+
+func (c *CLI) keysList(ctx context.Context, args []string) error {
+	cmd := newCommand("keys list")
+	if err := cmd.parse(args, 0); err != nil {
+		return err
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	signingKeys, err := adminService.SigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	views := make([]keyView, 0, len(signingKeys))
+	rows := make([][]string, 0, len(signingKeys))
+	for i, key := range signingKeys {
+		view := keyView{ID: key.ID, Active: i == 0, CreatedAt: key.CreatedAt}
+
+		views = append(views, view)
+		rows = append(rows, []string{view.ID, strconv.FormatBool(view.Active), view.CreatedAt.Format(time.DateTime)})
+	}
+
+	return c.print(cmd, views, []string{"KID", "ACTIVE", "CREATED AT"}, rows)
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"context"
+	"gRPC/internal/migrator"
+	"gRPC/internal/storage/driver"
+	"time"
+)
+
+type migrationView struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+func (c *CLI) migrate(ctx context.Context, args []string) error {
+	cmd := newCommand("migrate")
+	if err := cmd.parse(args, 1); err != nil {
+		return err
+	}
+
+	s, err := c.open()
+	if err != nil {
+		return err
+	}
+
+	m, err := driver.Migrator(s)
+	if err != nil {
+		return err
+	}
+
+	var migrations []migrator.Migration
+
+	action := cmd.arg(0)
+	switch action {
+	case "up":
+		migrations, err = m.Up(ctx)
+		if err != nil {
+			return err
+		}
+	case "down":
+		mg, err := m.Down(ctx)
+		if err != nil {
+			return err
+		}
+
+		migrations = append(migrations, mg)
+	case "redo":
+		mg, err := m.Redo(ctx)
+		if err != nil {
+			return err
+		}
+
+		migrations = append(migrations, mg)
+	case "status":
+		migrations, err = m.Status(ctx)
+		if err != nil {
+			return err
+		}
+	default:
+		return ErrUsage
+	}
+
+	header := []string{"ACTION", "MIGRATION"}
+	if action == "status" {
+		header = []string{"APPLIED AT", "MIGRATION"}
+	}
+
+	views := make([]migrationView, 0, len(migrations))
+	rows := make([][]string, 0, len(migrations))
+	for _, mg := range migrations {
+		view := migrationView{Version: mg.Version, Name: mg.Name, Applied: mg.Applied}
+		if mg.Applied {
+			view.AppliedAt = &mg.AppliedAt
+		}
+
+		first := action
+		if action == "status" {
+			first = "Pending"
+			if mg.Applied {
+				first = mg.AppliedAt.Format(time.DateTime)
+			}
+		}
+
+		views = append(views, view)
+		rows = append(rows, []string{first, mg.Name})
+	}
+
+	return c.print(cmd, views, header, rows)
+}
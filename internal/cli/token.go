@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+func (c *CLI) token(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "issue":
+		return c.tokenIssue(ctx, args[1:])
+	case "inspect":
+		return c.tokenInspect(ctx, args[1:])
+	default:
+		return ErrUsage
+	}
+}
+
+func (c *CLI) tokenIssue(ctx context.Context, args []string) error {
+	cmd := newCommand("token issue")
+	email := cmd.flags.String("email", "", "user email")
+	appID := cmd.flags.Int("app", 0, "app id")
+	if err := cmd.parse(args, 0); err != nil {
+		return err
+	}
+
+	if *email == "" || *appID == 0 {
+		return errors.New("--email and --app are required")
+	}
+
+	authService, err := c.authService()
+	if err != nil {
+		return err
+	}
+
+	token, err := authService.IssueToken(ctx, *email, *appID)
+	if err != nil {
+		return err
+	}
+
+	return c.print(cmd, map[string]string{"token": token}, []string{"TOKEN"}, [][]string{{token}})
+}
+
+func (c *CLI) tokenInspect(ctx context.Context, args []string) error {
+	cmd := newCommand("token inspect")
+	if err := cmd.parse(args, 1); err != nil {
+		return err
+	}
+
+	authService, err := c.authService()
+	if err != nil {
+		return err
+	}
+
+	claims, err := authService.InspectToken(ctx, cmd.arg(0))
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(claims))
+	for name := range claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := make([][]string, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, []string{name, fmt.Sprint(claims[name])})
+	}
+
+	return c.print(cmd, claims, []string{"CLAIM", "VALUE"}, rows)
+}
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/domain/models"
+	"strconv"
+	"time"
+)
+
+type userView struct {
+	ID        int64     `json:"id"`
+	Email     string    `json:"email"`
+	Verified  bool      `json:"verified"`
+	IsAdmin   bool      `json:"is_admin"`
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var userHeader = []string{"ID", "EMAIL", "VERIFIED", "ADMIN", "DISABLED", "CREATED AT"}
+
+func (c *CLI) user(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "create":
+		return c.userCreate(ctx, args[1:])
+	case "list":
+		return c.userList(ctx, args[1:])
+	case "disable":
+		return c.userDisable(ctx, args[1:])
+	case "promote":
+		return c.userPromote(ctx, args[1:])
+	default:
+		return ErrUsage
+	}
+}
+
+func (c *CLI) userCreate(ctx context.Context, args []string) error {
+	cmd := newCommand("user create")
+	email := cmd.flags.String("email", "", "user email")
+	password := cmd.flags.String("password", "", "user password")
+	isAdmin := cmd.flags.Bool("admin", false, "grant admin status")
+	if err := cmd.parse(args, 0); err != nil {
+		return err
+	}
+
+	if *email == "" || *password == "" {
+		return errors.New("--email and --password are required")
+	}
+
+	authService, err := c.authService()
+	if err != nil {
+		return err
+	}
+
+	id, err := authService.CreateUser(ctx, *email, *password)
+	if err != nil {
+		return err
+	}
+
+	if *isAdmin {
+		adminService, err := c.adminService()
+		if err != nil {
+			return err
+		}
+
+		if err := adminService.PromoteUser(ctx, *email); err != nil {
+			return err
+		}
+	}
+
+	view := userView{ID: id, Email: *email, IsAdmin: *isAdmin}
+
+	return c.print(cmd, view, userHeader, [][]string{userRow(view)})
+}
+
+func (c *CLI) userList(ctx context.Context, args []string) error {
+	cmd := newCommand("user list")
+	limit := cmd.flags.Int("limit", 50, "max number of users")
+	offset := cmd.flags.Int("offset", 0, "number of users to skip")
+	if err := cmd.parse(args, 0); err != nil {
+		return err
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	users, err := adminService.Users(ctx, *limit, *offset)
+	if err != nil {
+		return err
+	}
+
+	views := make([]userView, 0, len(users))
+	rows := make([][]string, 0, len(users))
+	for _, user := range users {
+		view := newUserView(user)
+
+		views = append(views, view)
+		rows = append(rows, userRow(view))
+	}
+
+	return c.print(cmd, views, userHeader, rows)
+}
+
+func (c *CLI) userDisable(ctx context.Context, args []string) error {
+	cmd := newCommand("user disable")
+	if err := cmd.parse(args, 1); err != nil {
+		return err
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	if err := adminService.DisableUser(ctx, cmd.arg(0)); err != nil {
+		return err
+	}
+
+	return c.print(cmd, map[string]any{"email": cmd.arg(0), "disabled": true},
+		[]string{"EMAIL", "DISABLED"}, [][]string{{cmd.arg(0), "true"}})
+}
+
+func (c *CLI) userPromote(ctx context.Context, args []string) error {
+	cmd := newCommand("user promote")
+	if err := cmd.parse(args, 1); err != nil {
+		return err
+	}
+
+	adminService, err := c.adminService()
+	if err != nil {
+		return err
+	}
+
+	if err := adminService.PromoteUser(ctx, cmd.arg(0)); err != nil {
+		return err
+	}
+
+	return c.print(cmd, map[string]any{"email": cmd.arg(0), "is_admin": true},
+		[]string{"EMAIL", "ADMIN"}, [][]string{{cmd.arg(0), "true"}})
+}
+
+func newUserView(user models.User) userView {
+	return userView{
+		ID:        user.ID,
+		Email:     user.Email,
+		Verified:  user.Verified,
+		IsAdmin:   user.IsAdmin,
+		Disabled:  user.Disabled,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+func userRow(view userView) []string {
+	createdAt := ""
+	if !view.CreatedAt.IsZero() {
+		createdAt = view.CreatedAt.Format(time.DateOnly)
+	}
+
+	return []string{
+		strconv.FormatInt(view.ID, 10),
+		view.Email,
+		strconv.FormatBool(view.Verified),
+		strconv.FormatBool(view.IsAdmin),
+		strconv.FormatBool(view.Disabled),
+		createdAt,
+	}
+}
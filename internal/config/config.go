@@ -3,15 +3,52 @@ package config
 import (
 	"flag"
 	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/joho/godotenv"
+	"log/slog"
 	"os"
 	"time"
 )
 
 type Config struct {
-	Env         string        `yaml:"env" env-default:"local"`
-	TokenTTL    time.Duration `yaml:"token_ttl" env-required:"true"`
-	GRPC        GRPCConfig    `yaml:"grpc"`
-	StoragePath string        `yaml:"storage_path" env-default:"local"`
+	Env           string             `yaml:"env" env-default:"local"`
+	TokenTTL      time.Duration      `yaml:"token_ttl" env-required:"true"`
+	GRPC          GRPCConfig         `yaml:"grpc"`
+	HTTP          HTTPConfig         `yaml:"http"`
+	Email         EmailConfig        `yaml:"email"`
+	KV            KVConfig           `yaml:"kv"`
+	Audit         AuditConfig        `yaml:"audit"`
+	Webhooks      WebhooksConfig     `yaml:"webhooks"`
+	Password      PasswordConfig     `yaml:"password"`
+	OIDC          OIDCConfig         `yaml:"oidc"`
+	Passwordless  PasswordlessConfig `yaml:"passwordless"`
+	WebAuthn      WebAuthnConfig     `yaml:"webauthn"`
+	SMS           SMSConfig          `yaml:"sms"`
+	StepUp        StepUpConfig       `yaml:"step_up"`
+	StoragePath   string             `yaml:"storage_path" env-default:"local"`
+	StorageDriver string             `yaml:"storage_driver" env-default:"postgres"`
+	AutoMigrate   bool               `yaml:"auto_migrate" env-default:"false"`
+}
+
+// LogValue implements slog.LogValuer, secrets are redacted so the config may be logged
+func (c Config) LogValue() slog.Value {
+	// redacted has no LogValue method, so logging it does not recurse
+	type redacted Config
+
+	cfg := redacted(c)
+	cfg.Email.Password = redact(c.Email.Password)
+	cfg.KV.Password = redact(c.KV.Password)
+	cfg.SMS.Token = redact(c.SMS.Token)
+
+	return slog.AnyValue(cfg)
+}
+
+// redact hides secret but keeps telling whether it is set
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	return "[REDACTED]"
 }
 
 type GRPCConfig struct {
@@ -19,6 +56,173 @@ type GRPCConfig struct {
 	Timeout time.Duration `yaml:"timeout"`
 }
 
+type HTTPConfig struct {
+	Port    int           `yaml:"port" env-default:"8080"`
+	Timeout time.Duration `yaml:"timeout" env-default:"10s"`
+	CORS    CORSConfig    `yaml:"cors"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins"`
+	AllowedMethods   []string      `yaml:"allowed_methods" env-default:"GET,POST,PUT,DELETE,OPTIONS"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" env-default:"Authorization,Content-Type"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age" env-default:"10m"`
+}
+
+const (
+	EmailDriverSMTP = "smtp"
+	EmailDriverLog  = "log"
+)
+
+// EmailConfig keeps env names of the former .env based setup
+type EmailConfig struct {
+	Driver   string `yaml:"driver" env-default:"smtp"`
+	Host     string `yaml:"host" env-default:"smtp.gmail.com"`
+	Port     int    `yaml:"port" env:"EmailPort" env-default:"587"`
+	Username string `yaml:"username" env:"SenderEmail"`
+	Password string `yaml:"password" env:"EmailSecret"`
+	From     string `yaml:"from" env:"SenderEmail"`
+}
+
+const (
+	KVDriverRedis  = "redis"
+	KVDriverMemory = "memory"
+)
+
+// KVConfig configures store of short-lived data like attempt counters
+type KVConfig struct {
+	Driver   string `yaml:"driver" env-default:"redis"`
+	Addr     string `yaml:"addr" env:"REDIS_ADDR" env-default:"localhost:6379"`
+	Password string `yaml:"password" env:"REDIS_PASSWORD"`
+	DB       int    `yaml:"db" env-default:"0"`
+	TLS      bool   `yaml:"tls"`
+	PoolSize int    `yaml:"pool_size" env-default:"10"`
+	// FailOpen stops rate limiting instead of failing logins when the store is down,
+	// one-time codes keep failing closed either way
+	FailOpen bool `yaml:"fail_open" env-default:"false"`
+}
+
+type AuditConfig struct {
+	// CheckpointInterval is how often the end of audit chain is signed, zero disables checkpoints
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval" env-default:"1h"`
+}
+
+type WebhooksConfig struct {
+	// PollInterval is how often due retries are looked for, new deliveries are sent right away
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"5s"`
+	Timeout      time.Duration `yaml:"timeout" env-default:"10s"`
+	MaxAttempts  int           `yaml:"max_attempts" env-default:"8"`
+}
+
+// OIDCConfig configures OpenID Connect provider served by HTTP gateway
+type OIDCConfig struct {
+	// Issuer is the public URL of HTTP gateway, it goes into iss claim and endpoint URLs
+	Issuer string `yaml:"issuer" env:"OIDC_ISSUER" env-default:"http://localhost:8080"`
+}
+
+// PasswordlessConfig configures login by emailed link or one-time code, apps enable it one by one
+type PasswordlessConfig struct {
+	// LinkURL is the page of app front end login links point to, they carry token query parameter
+	LinkURL string `yaml:"link_url" env:"PASSWORDLESS_LINK_URL"`
+	// TTL is how long links and codes stay valid
+	TTL time.Duration `yaml:"ttl" env-default:"15m"`
+}
+
+// WebAuthnConfig configures passkeys, they are bound to RPID and created on Origins only
+type WebAuthnConfig struct {
+	// RPID is the domain of the sites passkeys are used on, e.g. example.com
+	RPID   string `yaml:"rp_id" env:"WEBAUTHN_RP_ID" env-default:"localhost"`
+	RPName string `yaml:"rp_name" env-default:"SSO"`
+	// Origins are front ends running registration and login, e.g. https://login.example.com
+	Origins []string `yaml:"origins" env:"WEBAUTHN_ORIGINS"`
+	// Timeout is how long registration and login may take
+	Timeout time.Duration `yaml:"timeout" env-default:"5m"`
+}
+
+const (
+	SMSDriverLog  = "log"
+	SMSDriverFile = "file"
+	SMSDriverHTTP = "http"
+)
+
+// SMSConfig configures delivery of one-time codes to phones, the log driver only logs them
+type SMSConfig struct {
+	Driver string `yaml:"driver" env:"SMS_DRIVER" env-default:"log"`
+	// Path is the file the file driver appends messages to
+	Path string `yaml:"path" env-default:"sms.jsonl"`
+	// URL of provider endpoint the http driver posts JSON messages to with Token
+	URL     string        `yaml:"url" env:"SMS_URL"`
+	Token   string        `yaml:"token" env:"SMS_TOKEN"`
+	From    string        `yaml:"from" env:"SMS_FROM"`
+	Timeout time.Duration `yaml:"timeout" env-default:"10s"`
+	// CodeTTL is how long codes stay valid
+	CodeTTL time.Duration `yaml:"code_ttl" env-default:"5m"`
+}
+
+// StepUpConfig makes methods require recent or stronger authentication of the caller
+type StepUpConfig struct {
+	// Methods are keyed by full gRPC method name, e.g. /auth.AuthExtensions/DeletePhone
+	Methods map[string]StepUpRule `yaml:"methods"`
+}
+
+// StepUpRule is what the caller of method must have done, zero fields are not required
+type StepUpRule struct {
+	// MaxAge is how long ago the user may have authenticated
+	MaxAge time.Duration `yaml:"max_age"`
+	// MinACR is the lowest level of authentication, aal1 or aal2
+	MinACR string `yaml:"min_acr"`
+}
+
+const (
+	PasswordAlgorithmArgon2id = "argon2id"
+	PasswordAlgorithmBcrypt   = "bcrypt"
+)
+
+// PasswordConfig sets how new passwords are hashed, hashes made with
+// another algorithm or weaker parameters are upgraded on login
+type PasswordConfig struct {
+	Algorithm  string       `yaml:"algorithm" env-default:"argon2id"`
+	BcryptCost int          `yaml:"bcrypt_cost" env-default:"10"`
+	Argon2     Argon2Config `yaml:"argon2"`
+	Policy     PolicyConfig `yaml:"policy"`
+	// History is how many latest passwords of user may not be reused, zero disables the check
+	History int          `yaml:"history" env-default:"5"`
+	Expiry  ExpiryConfig `yaml:"expiry"`
+}
+
+type Argon2Config struct {
+	// Memory is in KiB
+	Memory      uint32 `yaml:"memory" env-default:"65536"`
+	Iterations  uint32 `yaml:"iterations" env-default:"3"`
+	Parallelism uint8  `yaml:"parallelism" env-default:"2"`
+	SaltLength  uint32 `yaml:"salt_length" env-default:"16"`
+	KeyLength   uint32 `yaml:"key_length" env-default:"32"`
+}
+
+// PolicyConfig sets rules new passwords have to follow
+type PolicyConfig struct {
+	MinLength int `yaml:"min_length" env-default:"8"`
+	// MaxLength is in bytes, bcrypt ignores everything past 72 bytes
+	MaxLength     int  `yaml:"max_length" env-default:"72"`
+	RequireLower  bool `yaml:"require_lower"`
+	RequireUpper  bool `yaml:"require_upper"`
+	RequireDigit  bool `yaml:"require_digit"`
+	RequireSymbol bool `yaml:"require_symbol"`
+	// MinStrength is the lowest accepted strength score, from 0 (guessable) to 4 (very strong)
+	MinStrength int `yaml:"min_strength" env-default:"2"`
+	// BreachedList is file of SHA-1 hashes of breached passwords, empty disables the check
+	BreachedList string `yaml:"breached_list"`
+}
+
+// ExpiryConfig makes passwords expire, users have to change expired password before logging in
+type ExpiryConfig struct {
+	// MaxAge is how long password stays valid, zero disables expiry
+	MaxAge time.Duration `yaml:"max_age"`
+	// Apps are ids of apps expiry applies to, empty means every app
+	Apps []int `yaml:"apps"`
+}
+
 func MustLoad() *Config {
 	path := fetchConfigPath()
 
@@ -30,6 +234,9 @@ func MustLoad() *Config {
 		panic("config file does not exist" + path)
 	}
 
+	// secrets may be kept in .env file next to the binary
+	_ = godotenv.Load()
+
 	var cfg Config
 	if err := cleanenv.ReadConfig(path, &cfg); err != nil {
 		panic("error while reading config" + err.Error())
@@ -1,7 +1,35 @@
 package models
 
+import "slices"
+
 type App struct {
 	ID     int
 	Name   string
 	Secret string
+	// RedirectURIs are the only URIs OAuth authorization responses are sent to
+	RedirectURIs []string
+	// Scopes are the scopes app may request as OAuth client
+	Scopes []string
+	// Audiences are resource servers tokens of app may be issued for
+	Audiences []string
+	// Passwordless lets users log in to app by email link or one-time code
+	Passwordless bool
+	// ClientSecretHash is digest of secret app authenticates with as confidential OAuth client,
+	// nil for public clients. Secret signs tokens and is never used as client secret
+	ClientSecretHash []byte
+}
+
+// Confidential reports whether app must authenticate with client secret as OAuth client
+func (a App) Confidential() bool {
+	return len(a.ClientSecretHash) > 0
+}
+
+// AllowsRedirectURI reports whether uri is registered for app, compared exactly
+func (a App) AllowsRedirectURI(uri string) bool {
+	return slices.Contains(a.RedirectURIs, uri)
+}
+
+// AllowsAudience reports whether tokens of app may be issued for audience
+func (a App) AllowsAudience(audience string) bool {
+	return slices.Contains(a.Audiences, audience)
 }
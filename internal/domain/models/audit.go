@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// Types of audit events
+const (
+	EventRegister         = "register"
+	EventLogin            = "login"
+	EventCodeValidation   = "code_validation"
+	EventTokenIssue       = "token_issue"
+	EventSessionRevoke    = "session_revoke"
+	EventImpersonate      = "impersonate"
+	EventUserCreate       = "user_create"
+	EventUserDisable      = "user_disable"
+	EventUserPromote      = "user_promote"
+	EventAppCreate        = "app_create"
+	EventAppSecretRotate  = "app_secret_rotate"
+	EventAppOAuthUpdate   = "app_oauth_update"
+	EventAppLoginUpdate   = "app_login_update"
+	EventSigningKeyRotate = "signing_key_rotate"
+
+	EventServiceAccountCreate  = "service_account_create"
+	EventServiceAccountRotate  = "service_account_rotate"
+	EventServiceAccountDisable = "service_account_disable"
+
+	EventExchangePolicyCreate = "exchange_policy_create"
+	EventExchangePolicyDelete = "exchange_policy_delete"
+
+	EventPasswordChange       = "password_change"
+	EventPasswordResetRequest = "password_reset_request"
+	EventPasswordReset        = "password_reset"
+
+	EventPasswordlessRequest = "passwordless_request"
+
+	EventPasskeyRegister = "passkey_register"
+	EventPasskeyDelete   = "passkey_delete"
+
+	EventPhoneVerify = "phone_verify"
+	EventPhoneDelete = "phone_delete"
+
+	EventReauthenticate = "reauthenticate"
+)
+
+// AuditEvent is a security relevant action recorded for later investigation
+type AuditEvent struct {
+	ID   int64
+	Type string
+	// UserID and Email identify the user the event is about, they are empty when unknown
+	UserID int64
+	Email  string
+	AppID  int
+	// ActorID is the admin who acted on the user, zero for actions of the user or operators
+	ActorID   int64
+	IP        string
+	UserAgent string
+	Success   bool
+	// Reason explains failure or carries details of the action
+	Reason    string
+	CreatedAt time.Time
+	// PrevHash is Hash of the previous event, Hash covers PrevHash and the event content,
+	// both are empty for events recorded before the chain was introduced
+	PrevHash string
+	Hash     string
+}
+
+// AuditCheckpoint is a signed statement that the chain ended with given event
+type AuditCheckpoint struct {
+	ID        int64
+	EventID   int64
+	Hash      string
+	KeyID     string
+	Signature []byte
+	CreatedAt time.Time
+}
+
+// AuditVerification is the result of walking audit chain
+type AuditVerification struct {
+	Valid       bool
+	Events      int64
+	Checkpoints int64
+	// BrokenAt is id of the first event which breaks the chain
+	BrokenAt int64
+	Reason   string
+}
+
+// AuditFilter selects audit events, zero fields do not restrict the selection
+type AuditFilter struct {
+	UserID int64
+	Type   string
+	Since  time.Time
+	Until  time.Time
+	// AfterID is the cursor, only events with greater id are selected
+	AfterID int64
+	Limit   int
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ExchangePolicy lets service account of app exchange tokens of app users (RFC 8693)
+// for tokens meant for Audience, limited to Scopes.
+type ExchangePolicy struct {
+	ID    int64
+	AppID int
+	// Actor is client id of the service account acting on behalf of users
+	Actor     string
+	Audience  string
+	Scopes    []string
+	CreatedAt time.Time
+}
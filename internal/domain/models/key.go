@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// SigningKey is a service wide asymmetric key, the newest one is active
+type SigningKey struct {
+	ID         string
+	PrivateKey []byte
+	CreatedAt  time.Time
+}
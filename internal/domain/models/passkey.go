@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Passkey is WebAuthn credential user logs in with
+type Passkey struct {
+	ID     int64
+	UserID int64
+	// CredentialID is assigned by the authenticator, it is unique across users
+	CredentialID []byte
+	// PublicKey is COSE encoded
+	PublicKey []byte
+	// SignCount is the latest signature counter reported by the authenticator,
+	// counters that do not grow point to cloned authenticators
+	SignCount uint32
+	Name      string
+	CreatedAt time.Time
+	// LastUsedAt is zero until the passkey is used to log in
+	LastUsedAt time.Time
+}
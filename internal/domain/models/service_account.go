@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ServiceAccount is identity of a backend service owned by an app.
+// It gets scoped tokens for itself with client credentials, no user is involved.
+type ServiceAccount struct {
+	ID       int64
+	AppID    int
+	Name     string
+	ClientID string
+	// SecretHash is SHA-256 of client secret, secrets are random so a fast hash is enough
+	SecretHash []byte
+	// PublicKey is PEM of the key client assertions are signed with, empty if not registered
+	PublicKey []byte
+	Scopes    []string
+	Disabled  bool
+	CreatedAt time.Time
+	// RotatedAt is when credentials were last replaced, tokens issued before are no longer valid.
+	// It is zero for accounts whose credentials were never rotated.
+	RotatedAt time.Time
+}
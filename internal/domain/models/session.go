@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Session is a login of user into app from some device
+type Session struct {
+	ID         string
+	UserID     int64
+	AppID      int
+	DeviceName string
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	// RevokedAt is zero while session is not revoked
+	RevokedAt time.Time
+	// ImpersonatorID is the admin who started the session on behalf of the user, zero for own sessions
+	ImpersonatorID int64
+	// AuthTime is when the user last proved identity in the session, AMR lists methods used
+	// (RFC 8176). AuthTime is zero for sessions started without authentication of the user.
+	AuthTime time.Time
+	AMR      []string
+}
+
+// Active reports whether session is neither revoked nor expired at now
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt.IsZero() && now.Before(s.ExpiresAt)
+}
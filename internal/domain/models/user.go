@@ -1,7 +1,24 @@
 package models
 
+import "time"
+
 type User struct {
-	ID       int64
-	Email    string
-	PassHash []byte
+	ID        int64
+	Email     string
+	PassHash  []byte
+	IsAdmin   bool
+	Verified  bool
+	Disabled  bool
+	CreatedAt time.Time
+	// Phone is in E.164 format, it can be used for second factor once PhoneVerified
+	Phone         string
+	PhoneVerified bool
+}
+
+// PasswordHistoryEntry is a password user has set, the latest one is the current password
+type PasswordHistoryEntry struct {
+	ID        int64
+	UserID    int64
+	PassHash  []byte
+	CreatedAt time.Time
 }
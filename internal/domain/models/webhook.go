@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Webhook event types apps may subscribe to
+const (
+	WebhookUserRegistered = "user.registered"
+	WebhookUserVerified   = "user.verified"
+	// WebhookUserDeleted is sent when user is disabled, accounts are never removed
+	WebhookUserDeleted     = "user.deleted"
+	WebhookPasswordChanged = "password.changed"
+
+	// WebhookPing is sent by webhook test only
+	WebhookPing = "webhook.ping"
+)
+
+// Webhook is URL of an app which is notified about events of given types
+type Webhook struct {
+	ID     int64
+	AppID  int
+	URL    string
+	Secret string
+	Events []string
+	// CreatedAt is zero for webhooks being saved
+	CreatedAt time.Time
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is a payload sent to webhook, possibly in several attempts
+type WebhookDelivery struct {
+	ID            int64
+	WebhookID     int64
+	EventType     string
+	Payload       []byte
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	// ResponseCode and LastError describe the last attempt
+	ResponseCode int
+	LastError    string
+	CreatedAt    time.Time
+	DeliveredAt  time.Time
+}
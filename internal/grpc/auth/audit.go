@@ -0,0 +1,210 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/services/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strconv"
+	"time"
+)
+
+type AuditEvent struct {
+	Id        int64     `json:"id"`
+	Type      string    `json:"type"`
+	UserId    int64     `json:"user_id"`
+	Email     string    `json:"email"`
+	AppId     int32     `json:"app_id"`
+	ActorId   int64     `json:"actor_id"`
+	Ip        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash"`
+}
+
+type AuditFilter struct {
+	UserId int64     `json:"user_id"`
+	Type   string    `json:"type"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+}
+
+type ListAuditEventsRequest struct {
+	AuditFilter
+	// Cursor is next_cursor of the previous page, empty for the first page
+	Cursor string `json:"cursor"`
+	Limit  int32  `json:"limit"`
+}
+
+type ListAuditEventsResponse struct {
+	Events     []*AuditEvent `json:"events"`
+	NextCursor string        `json:"next_cursor"`
+}
+
+type ExportAuditEventsRequest struct {
+	AuditFilter
+}
+
+type VerifyAuditChainRequest struct{}
+
+type VerifyAuditChainResponse struct {
+	Valid       bool  `json:"valid"`
+	Events      int64 `json:"events"`
+	Checkpoints int64 `json:"checkpoints"`
+	// BrokenAt is id of the first event breaking the chain, zero for valid chain
+	BrokenAt int64  `json:"broken_at"`
+	Reason   string `json:"reason"`
+}
+
+type AuditLog interface {
+	Events(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, int64, error)
+	Export(ctx context.Context, filter models.AuditFilter, fn func(models.AuditEvent) error) error
+	Verify(ctx context.Context) (models.AuditVerification, error)
+}
+
+type Admins interface {
+	Authenticate(ctx context.Context, token string) (auth.Principal, error)
+	RequireAdmin(ctx context.Context, caller auth.Principal) error
+}
+
+// AuditServer serves audit log to admins
+type AuditServer struct {
+	admins Admins
+	audit  AuditLog
+}
+
+func NewAuditServer(admins Admins, audit AuditLog) *AuditServer {
+	return &AuditServer{admins: admins, audit: audit}
+}
+
+func (s *AuditServer) ListAuditEvents(ctx context.Context, req *ListAuditEventsRequest) (*ListAuditEventsResponse, error) {
+	filter := req.toModel()
+
+	if req.Cursor != "" {
+		afterID, err := strconv.ParseInt(req.Cursor, 10, 64)
+		if err != nil || afterID < 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid cursor")
+		}
+
+		filter.AfterID = afterID
+	}
+
+	if req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+	filter.Limit = int(req.Limit)
+
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	events, next, err := s.audit.Events(ctx, filter)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Internal Error")
+	}
+
+	resp := &ListAuditEventsResponse{Events: make([]*AuditEvent, 0, len(events))}
+	for _, e := range events {
+		resp.Events = append(resp.Events, toAuditEvent(e))
+	}
+
+	if next != 0 {
+		resp.NextCursor = strconv.FormatInt(next, 10)
+	}
+
+	return resp, nil
+}
+
+// ExportAuditEvents streams every event matching filter, it is meant for SIEM ingestion
+func (s *AuditServer) ExportAuditEvents(ctx context.Context, req *ExportAuditEventsRequest, send func(*AuditEvent) error) error {
+	if err := s.requireAdmin(ctx); err != nil {
+		return err
+	}
+
+	err := s.audit.Export(ctx, req.toModel(), func(e models.AuditEvent) error {
+		return send(toAuditEvent(e))
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return status.FromContextError(err).Err()
+		}
+
+		return status.Error(codes.Internal, "Internal Error")
+	}
+
+	return nil
+}
+
+// VerifyAuditChain walks the whole audit chain, broken chain is reported in response, not as error
+func (s *AuditServer) VerifyAuditChain(ctx context.Context, _ *VerifyAuditChainRequest) (*VerifyAuditChainResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	res, err := s.audit.Verify(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Internal Error")
+	}
+
+	return &VerifyAuditChainResponse{
+		Valid:       res.Valid,
+		Events:      res.Events,
+		Checkpoints: res.Checkpoints,
+		BrokenAt:    res.BrokenAt,
+		Reason:      res.Reason,
+	}, nil
+}
+
+func (s *AuditServer) requireAdmin(ctx context.Context) error {
+	return requireAdmin(ctx, s.admins)
+}
+
+// requireAdmin authenticates caller and checks it is an admin
+func requireAdmin(ctx context.Context, admins Admins) error {
+	caller, err := authenticate(ctx, admins)
+	if err != nil {
+		return err
+	}
+
+	if err := admins.RequireAdmin(ctx, caller); err != nil {
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return status.Error(codes.PermissionDenied, "permission denied")
+		}
+
+		return status.Error(codes.Internal, "Internal Error")
+	}
+
+	return nil
+}
+
+func (f AuditFilter) toModel() models.AuditFilter {
+	return models.AuditFilter{
+		UserID: f.UserId,
+		Type:   f.Type,
+		Since:  f.Since,
+		Until:  f.Until,
+	}
+}
+
+func toAuditEvent(e models.AuditEvent) *AuditEvent {
+	return &AuditEvent{
+		Id:        e.ID,
+		Type:      e.Type,
+		UserId:    e.UserID,
+		Email:     e.Email,
+		AppId:     int32(e.AppID),
+		ActorId:   e.ActorID,
+		Ip:        e.IP,
+		UserAgent: e.UserAgent,
+		Success:   e.Success,
+		Reason:    e.Reason,
+		CreatedAt: e.CreatedAt,
+		PrevHash:  e.PrevHash,
+		Hash:      e.Hash,
+	}
+}
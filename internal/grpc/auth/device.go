@@ -0,0 +1,161 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/lib/oauth"
+	"gRPC/internal/services/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type StartDeviceAuthorizationRequest struct {
+	ClientId int32  `json:"client_id"`
+	Scope    string `json:"scope"`
+}
+
+type StartDeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationUri         string `json:"verification_uri"`
+	VerificationUriComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+type ApproveDeviceRequest struct {
+	UserCode string `json:"user_code"`
+	// Approve is false to deny the device
+	Approve bool `json:"approve"`
+}
+
+type ApproveDeviceResponse struct{}
+
+type PollDeviceTokenRequest struct {
+	ClientId     int32  `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	DeviceCode   string `json:"device_code"`
+	Audience     string `json:"audience"`
+}
+
+type PollDeviceTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+	IdToken     string `json:"id_token,omitempty"`
+}
+
+type Devices interface {
+	Authenticate(ctx context.Context, token string) (auth.Principal, error)
+	StartDeviceAuthorization(ctx context.Context, clientID int, scope string) (auth.DeviceAuthorization, error)
+	ApproveDevice(ctx context.Context, caller auth.Principal, userCode string, approve bool) error
+	PollDeviceToken(ctx context.Context, req auth.DeviceTokenRequest, client auth.ClientInfo) (auth.TokenGrant, error)
+}
+
+// DeviceServer serves device authorization grant (RFC 8628) to devices without browser
+// and lets logged-in users approve them
+type DeviceServer struct {
+	auth Devices
+}
+
+func NewDeviceServer(auth Devices) *DeviceServer {
+	return &DeviceServer{auth: auth}
+}
+
+func (s *DeviceServer) StartDeviceAuthorization(
+	ctx context.Context, req *StartDeviceAuthorizationRequest,
+) (*StartDeviceAuthorizationResponse, error) {
+	if req.ClientId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "client_id is required")
+	}
+
+	device, err := s.auth.StartDeviceAuthorization(ctx, int(req.ClientId), req.Scope)
+	if err != nil {
+		return nil, deviceError(err)
+	}
+
+	return &StartDeviceAuthorizationResponse{
+		DeviceCode:              device.DeviceCode,
+		UserCode:                device.UserCode,
+		VerificationUri:         device.VerificationURI,
+		VerificationUriComplete: device.VerificationURIComplete,
+		ExpiresIn:               int64(device.ExpiresIn.Seconds()),
+		Interval:                int64(device.Interval.Seconds()),
+	}, nil
+}
+
+// ApproveDevice approves or denies device of user code on behalf of the caller
+func (s *DeviceServer) ApproveDevice(ctx context.Context, req *ApproveDeviceRequest) (*ApproveDeviceResponse, error) {
+	if req.UserCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_code is required")
+	}
+
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.ApproveDevice(ctx, caller, req.UserCode, req.Approve); err != nil {
+		return nil, deviceError(err)
+	}
+
+	return &ApproveDeviceResponse{}, nil
+}
+
+// PollDeviceToken returns token once the user approved the device, codes tell the device
+// to keep polling: FailedPrecondition while pending and ResourceExhausted when too fast
+func (s *DeviceServer) PollDeviceToken(ctx context.Context, req *PollDeviceTokenRequest) (*PollDeviceTokenResponse, error) {
+	if req.ClientId == emptyValue || req.DeviceCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "client_id and device_code are required")
+	}
+
+	grant, err := s.auth.PollDeviceToken(ctx, auth.DeviceTokenRequest{
+		ClientID:     int(req.ClientId),
+		ClientSecret: req.ClientSecret,
+		DeviceCode:   req.DeviceCode,
+		Audience:     req.Audience,
+	}, clientInfo(ctx))
+	if err != nil {
+		return nil, deviceError(err)
+	}
+
+	return &PollDeviceTokenResponse{
+		AccessToken: grant.AccessToken,
+		ExpiresIn:   int64(grant.ExpiresIn.Seconds()),
+		Scope:       oauth.FormatScope(grant.Scopes),
+		IdToken:     grant.IDToken,
+	}, nil
+}
+
+func deviceError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrAuthorizationPending):
+		return status.Error(codes.FailedPrecondition, "authorization_pending")
+	case errors.Is(err, auth.ErrSlowDown):
+		return status.Error(codes.ResourceExhausted, "slow_down")
+	case errors.Is(err, auth.ErrAccessDenied):
+		return status.Error(codes.PermissionDenied, "access_denied")
+	case errors.Is(err, auth.ErrExpiredToken):
+		return status.Error(codes.NotFound, "expired_token")
+	case errors.Is(err, auth.ErrInvalidGrant):
+		return status.Error(codes.InvalidArgument, "invalid_grant")
+	case errors.Is(err, auth.ErrInvalidClient):
+		return status.Error(codes.Unauthenticated, "invalid client")
+	case errors.Is(err, auth.ErrInvalidScope):
+		return status.Error(codes.InvalidArgument, "invalid scope")
+	case errors.Is(err, auth.ErrInvalidAudience):
+		return status.Error(codes.InvalidArgument, "invalid audience")
+	case errors.Is(err, auth.ErrInvalidUserCode):
+		return status.Error(codes.NotFound, "invalid or expired user code")
+	case errors.Is(err, auth.ErrInsufficientScope):
+		return status.Error(codes.PermissionDenied, "first-party token is required")
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		return status.Error(codes.ResourceExhausted, "too many attempts")
+	case errors.Is(err, auth.ErrUnavailable):
+		return status.Error(codes.Unavailable, "service is temporarily unavailable")
+	default:
+		return status.Error(codes.Internal, "Internal Error")
+	}
+}
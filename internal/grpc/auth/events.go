@@ -0,0 +1,88 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/services/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strconv"
+	"time"
+)
+
+type WatchAuthEventsRequest struct {
+	// Types are event types to watch, all watchable types when empty
+	Types []string `json:"types"`
+	// Cursor is cursor of the last event seen, empty to replay from the beginning
+	Cursor string `json:"cursor"`
+}
+
+type AuthEvent struct {
+	Cursor    string    `json:"cursor"`
+	Type      string    `json:"type"`
+	UserId    int64     `json:"user_id"`
+	Email     string    `json:"email"`
+	AppId     int32     `json:"app_id"`
+	ActorId   int64     `json:"actor_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Watcher interface {
+	Authenticate(ctx context.Context, token string) (auth.Principal, error)
+	WatchEvents(ctx context.Context, caller auth.Principal, types []string, afterID int64, fn func(models.AuditEvent) error) error
+}
+
+// EventsServer streams security events to downstream services
+type EventsServer struct {
+	auth Watcher
+}
+
+func NewEventsServer(auth Watcher) *EventsServer {
+	return &EventsServer{auth: auth}
+}
+
+// WatchAuthEvents replays events after cursor and then follows new ones until the caller goes away
+func (s *EventsServer) WatchAuthEvents(ctx context.Context, req *WatchAuthEventsRequest, send func(*AuthEvent) error) error {
+	var afterID int64
+	if req.Cursor != "" {
+		id, err := strconv.ParseInt(req.Cursor, 10, 64)
+		if err != nil || id < 0 {
+			return status.Error(codes.InvalidArgument, "invalid cursor")
+		}
+
+		afterID = id
+	}
+
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return err
+	}
+
+	err = s.auth.WatchEvents(ctx, caller, req.Types, afterID, func(e models.AuditEvent) error {
+		return send(&AuthEvent{
+			Cursor:    strconv.FormatInt(e.ID, 10),
+			Type:      e.Type,
+			UserId:    e.UserID,
+			Email:     e.Email,
+			AppId:     int32(e.AppID),
+			ActorId:   e.ActorID,
+			CreatedAt: e.CreatedAt,
+		})
+	})
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, auth.ErrUnknownEventType):
+		return status.Error(codes.InvalidArgument, "unknown event type")
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, auth.ErrBusClosed) || errors.Is(err, auth.ErrUnavailable):
+		return status.Error(codes.Unavailable, "service is shutting down")
+	default:
+		return status.Error(codes.Internal, "Internal Error")
+	}
+}
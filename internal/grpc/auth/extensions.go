@@ -0,0 +1,232 @@
+package grpcapp
+
+import (
+	"context"
+	"encoding/json"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ExtensionsService is the gRPC service of RPCs which are not part of the published Auth proto yet.
+//
+// Their messages are plain Go structs declared in this package, so the service speaks JSON instead of protobuf:
+// clients call it with content-subtype JSONCodecName (application/grpc+json),
+// e.g. grpc.CallContentSubtype(JSONCodecName). The HTTP gateway serves the same RPCs
+// under the same full method names.
+//
+// Only the OAuth and OpenID Connect endpoints (/oauth/*, /.well-known/*) are HTTP-only,
+// their protocols are defined over HTTP.
+const ExtensionsService = "auth.AuthExtensions"
+
+// JSONCodecName is content-subtype of ExtensionsService calls
+const JSONCodecName = "json"
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec encodes protobuf messages with protojson and everything else with encoding/json,
+// the same way the HTTP gateway does
+type jsonCodec struct{}
+
+var (
+	jsonMarshaler   = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+	jsonUnmarshaler = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	if msg, ok := v.(proto.Message); ok {
+		return jsonMarshaler.Marshal(msg)
+	}
+
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	if msg, ok := v.(proto.Message); ok {
+		return jsonUnmarshaler.Unmarshal(data, msg)
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return JSONCodecName
+}
+
+// Service is everything Auth RPCs need from the auth service
+type Service interface {
+	Auth
+	Sessions
+	Admins
+	Watcher
+	Passwords
+	UserInfo
+	ServiceTokens
+	Devices
+	Tokens
+	Impersonation
+	Passwordless
+	Passkeys
+	Phones
+	StepUp
+}
+
+// Servers implement RPCs of ExtensionsService, the same instances serve gRPC and HTTP gateway
+type Servers struct {
+	Passwords       *PasswordServer
+	Passwordless    *PasswordlessServer
+	Passkeys        *PasskeysServer
+	Phones          *PhonesServer
+	StepUp          *StepUpServer
+	Sessions        *SessionsServer
+	Audit           *AuditServer
+	Events          *EventsServer
+	Webhooks        *WebhooksServer
+	UserInfo        *UserInfoServer
+	ServiceAccounts *ServiceAccountsServer
+	Device          *DeviceServer
+	Tokens          *TokensServer
+	Impersonation   *ImpersonationServer
+}
+
+func NewServers(auth Service, audit AuditLog, webhooks WebhookManager, accounts ServiceAccountManager) *Servers {
+	return &Servers{
+		Passwords:       NewPasswordServer(auth),
+		Passwordless:    NewPasswordlessServer(auth),
+		Passkeys:        NewPasskeysServer(auth),
+		Phones:          NewPhonesServer(auth),
+		StepUp:          NewStepUpServer(auth),
+		Sessions:        NewSessionsServer(auth),
+		Audit:           NewAuditServer(auth, audit),
+		Events:          NewEventsServer(auth),
+		Webhooks:        NewWebhooksServer(auth, webhooks),
+		UserInfo:        NewUserInfoServer(auth),
+		ServiceAccounts: NewServiceAccountsServer(auth, accounts, auth),
+		Device:          NewDeviceServer(auth),
+		Tokens:          NewTokensServer(auth),
+		Impersonation:   NewImpersonationServer(auth),
+	}
+}
+
+// RegisterExtensions serves RPCs of servers on gRPC server as ExtensionsService
+func RegisterExtensions(gRPC *grpc.Server, s *Servers) {
+	gRPC.RegisterService(&grpc.ServiceDesc{
+		ServiceName: ExtensionsService,
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{
+			unaryMethod("ChangePassword", s.Passwords.ChangePassword),
+			unaryMethod("RequestPasswordReset", s.Passwords.RequestPasswordReset),
+			unaryMethod("ResetPassword", s.Passwords.ResetPassword),
+
+			unaryMethod("StartPasswordlessLogin", s.Passwordless.StartPasswordlessLogin),
+			unaryMethod("CompletePasswordlessLogin", s.Passwordless.CompletePasswordlessLogin),
+
+			unaryMethod("BeginPasskeyRegistration", s.Passkeys.BeginPasskeyRegistration),
+			unaryMethod("FinishPasskeyRegistration", s.Passkeys.FinishPasskeyRegistration),
+			unaryMethod("ListPasskeys", s.Passkeys.ListPasskeys),
+			unaryMethod("DeletePasskey", s.Passkeys.DeletePasskey),
+			unaryMethod("BeginPasskeyLogin", s.Passkeys.BeginPasskeyLogin),
+			unaryMethod("FinishPasskeyLogin", s.Passkeys.FinishPasskeyLogin),
+
+			unaryMethod("GetPhone", s.Phones.GetPhone),
+			unaryMethod("StartPhoneVerification", s.Phones.StartPhoneVerification),
+			unaryMethod("VerifyPhone", s.Phones.VerifyPhone),
+			unaryMethod("DeletePhone", s.Phones.DeletePhone),
+			unaryMethod("BeginSMSLogin", s.Phones.BeginSMSLogin),
+			unaryMethod("FinishSMSLogin", s.Phones.FinishSMSLogin),
+
+			unaryMethod("Reauthenticate", s.StepUp.Reauthenticate),
+
+			unaryMethod("ListSessions", s.Sessions.ListSessions),
+			unaryMethod("RevokeSession", s.Sessions.RevokeSession),
+			unaryMethod("RevokeOtherSessions", s.Sessions.RevokeOtherSessions),
+			unaryMethod("ListUserSessions", s.Sessions.ListUserSessions),
+			unaryMethod("RevokeUserSession", s.Sessions.RevokeUserSession),
+			unaryMethod("RevokeUserSessions", s.Sessions.RevokeUserSessions),
+
+			unaryMethod("ListAuditEvents", s.Audit.ListAuditEvents),
+			unaryMethod("VerifyAuditChain", s.Audit.VerifyAuditChain),
+
+			unaryMethod("CreateWebhook", s.Webhooks.CreateWebhook),
+			unaryMethod("ListWebhooks", s.Webhooks.ListWebhooks),
+			unaryMethod("DeleteWebhook", s.Webhooks.DeleteWebhook),
+			unaryMethod("ListWebhookDeliveries", s.Webhooks.ListWebhookDeliveries),
+			unaryMethod("TestWebhook", s.Webhooks.TestWebhook),
+			unaryMethod("RedeliverWebhook", s.Webhooks.RedeliverWebhook),
+
+			unaryMethod("UserInfo", s.UserInfo.UserInfo),
+
+			unaryMethod("CreateServiceAccount", s.ServiceAccounts.CreateServiceAccount),
+			unaryMethod("ListServiceAccounts", s.ServiceAccounts.ListServiceAccounts),
+			unaryMethod("RotateServiceAccountSecret", s.ServiceAccounts.RotateServiceAccountSecret),
+			unaryMethod("DisableServiceAccount", s.ServiceAccounts.DisableServiceAccount),
+			unaryMethod("IssueServiceToken", s.ServiceAccounts.IssueServiceToken),
+
+			unaryMethod("StartDeviceAuthorization", s.Device.StartDeviceAuthorization),
+			unaryMethod("ApproveDevice", s.Device.ApproveDevice),
+			unaryMethod("PollDeviceToken", s.Device.PollDeviceToken),
+
+			unaryMethod("LoginWithScope", s.Tokens.LoginWithScope),
+			unaryMethod("ValidateToken", s.Tokens.ValidateToken),
+			unaryMethod("ExchangeToken", s.Tokens.ExchangeToken),
+
+			unaryMethod("Impersonate", s.Impersonation.Impersonate),
+		},
+		Streams: []grpc.StreamDesc{
+			serverStream("ExportAuditEvents", s.Audit.ExportAuditEvents),
+			serverStream("WatchAuthEvents", s.Events.WatchAuthEvents),
+		},
+		Metadata: "internal/grpc/auth/extensions.go",
+	}, s)
+}
+
+// unaryMethod describes unary RPC of ExtensionsService served by call
+func unaryMethod[Req any, Resp any](name string, call func(context.Context, *Req) (Resp, error)) grpc.MethodDesc {
+	info := &grpc.UnaryServerInfo{FullMethod: "/" + ExtensionsService + "/" + name}
+
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+
+			if interceptor == nil {
+				return call(ctx, req)
+			}
+
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: info.FullMethod},
+				func(ctx context.Context, req any) (any, error) {
+					return call(ctx, req.(*Req))
+				})
+		},
+	}
+}
+
+// serverStream describes server streaming RPC of ExtensionsService served by call
+func serverStream[Req any, Msg any](
+	name string, call func(ctx context.Context, req *Req, send func(Msg) error) error,
+) grpc.StreamDesc {
+	return grpc.StreamDesc{
+		StreamName:    name,
+		ServerStreams: true,
+		Handler: func(_ any, stream grpc.ServerStream) error {
+			req := new(Req)
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+
+			return call(stream.Context(), req, func(msg Msg) error {
+				return stream.SendMsg(msg)
+			})
+		},
+	}
+}
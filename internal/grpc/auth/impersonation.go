@@ -0,0 +1,80 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/services/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type ImpersonateRequest struct {
+	Email string `json:"email"`
+	AppId int32  `json:"app_id"`
+	// Reason is required, it is kept in audit log
+	Reason string `json:"reason"`
+}
+
+type ImpersonateResponse struct {
+	Token     string `json:"token"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+type Impersonation interface {
+	Authenticate(ctx context.Context, token string) (auth.Principal, error)
+	Impersonate(ctx context.Context, caller auth.Principal, req auth.ImpersonationRequest, client auth.ClientInfo) (auth.TokenGrant, error)
+}
+
+// ImpersonationServer lets admins act as users to see what they see
+type ImpersonationServer struct {
+	auth Impersonation
+}
+
+func NewImpersonationServer(auth Impersonation) *ImpersonationServer {
+	return &ImpersonationServer{auth: auth}
+}
+
+// Impersonate returns short-lived token of the user for the calling admin
+func (s *ImpersonationServer) Impersonate(ctx context.Context, req *ImpersonateRequest) (*ImpersonateResponse, error) {
+	if req.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+	if req.Reason == "" {
+		return nil, status.Error(codes.InvalidArgument, "reason is required")
+	}
+
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	grant, err := s.auth.Impersonate(ctx, caller, auth.ImpersonationRequest{
+		Email:  req.Email,
+		AppID:  int(req.AppId),
+		Reason: req.Reason,
+	}, clientInfo(ctx))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrPermissionDenied):
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		case errors.Is(err, auth.ErrImpersonationForbidden):
+			return nil, status.Error(codes.PermissionDenied, "admins may not be impersonated")
+		case errors.Is(err, auth.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		case errors.Is(err, auth.ErrInvalidAppID):
+			return nil, status.Error(codes.InvalidArgument, "invalid app_id")
+		case errors.Is(err, auth.ErrUserDisabled):
+			return nil, status.Error(codes.FailedPrecondition, "user is disabled")
+		default:
+			return nil, status.Error(codes.Internal, "Internal Error")
+		}
+	}
+
+	return &ImpersonateResponse{
+		Token:     grant.AccessToken,
+		ExpiresIn: int64(grant.ExpiresIn.Seconds()),
+	}, nil
+}
@@ -0,0 +1,290 @@
+package grpcapp
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/webauthn"
+	"gRPC/internal/services/auth"
+	ssov5 "github.com/LeeAntonV/Protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+// Binary values are base64url encoded, as in JSON of WebAuthn responses.
+
+type Passkey struct {
+	PasskeyId int64     `json:"passkey_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	// LastUsedAt is zero until the passkey is used to log in
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+type BeginPasskeyRegistrationRequest struct{}
+
+// BeginPasskeyRegistrationResponse carries options of navigator.credentials.create
+type BeginPasskeyRegistrationResponse struct {
+	PublicKey webauthn.CreationOptions `json:"public_key"`
+}
+
+type FinishPasskeyRegistrationRequest struct {
+	Name              string `json:"name"`
+	ClientDataJson    string `json:"client_data_json"`
+	AttestationObject string `json:"attestation_object"`
+}
+
+type ListPasskeysRequest struct{}
+
+type ListPasskeysResponse struct {
+	Passkeys []*Passkey `json:"passkeys"`
+}
+
+type DeletePasskeyRequest struct {
+	PasskeyId int64 `json:"passkey_id"`
+}
+
+type DeletePasskeyResponse struct{}
+
+// BeginPasskeyLoginRequest has email and password when passkey is the second factor
+type BeginPasskeyLoginRequest struct {
+	AppId    int32  `json:"app_id"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// BeginPasskeyLoginResponse carries options of navigator.credentials.get
+type BeginPasskeyLoginResponse struct {
+	PublicKey webauthn.RequestOptions `json:"public_key"`
+}
+
+type FinishPasskeyLoginRequest struct {
+	CredentialId      string `json:"credential_id"`
+	ClientDataJson    string `json:"client_data_json"`
+	AuthenticatorData string `json:"authenticator_data"`
+	Signature         string `json:"signature"`
+	UserHandle        string `json:"user_handle"`
+}
+
+type Passkeys interface {
+	Authenticate(ctx context.Context, token string) (auth.Principal, error)
+	BeginPasskeyRegistration(ctx context.Context, caller auth.Principal) (webauthn.CreationOptions, error)
+	FinishPasskeyRegistration(ctx context.Context, caller auth.Principal, req auth.PasskeyRegistration) (models.Passkey, error)
+	ListPasskeys(ctx context.Context, caller auth.Principal) ([]models.Passkey, error)
+	DeletePasskey(ctx context.Context, caller auth.Principal, id int64) error
+	BeginPasskeyLogin(ctx context.Context, req auth.PasskeyLogin, client auth.ClientInfo) (webauthn.RequestOptions, error)
+	FinishPasskeyLogin(ctx context.Context, req auth.PasskeyAssertion, client auth.ClientInfo) (string, error)
+}
+
+// PasskeysServer lets users register passkeys and log in with them
+type PasskeysServer struct {
+	auth Passkeys
+}
+
+func NewPasskeysServer(auth Passkeys) *PasskeysServer {
+	return &PasskeysServer{auth: auth}
+}
+
+func (s *PasskeysServer) BeginPasskeyRegistration(
+	ctx context.Context, _ *BeginPasskeyRegistrationRequest,
+) (*BeginPasskeyRegistrationResponse, error) {
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := s.auth.BeginPasskeyRegistration(ctx, caller)
+	if err != nil {
+		return nil, passkeysError(err)
+	}
+
+	return &BeginPasskeyRegistrationResponse{PublicKey: options}, nil
+}
+
+func (s *PasskeysServer) FinishPasskeyRegistration(
+	ctx context.Context, req *FinishPasskeyRegistrationRequest,
+) (*Passkey, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	clientData, err := decodeBinary("client_data_json", req.ClientDataJson)
+	if err != nil {
+		return nil, err
+	}
+
+	attestation, err := decodeBinary("attestation_object", req.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	passkey, err := s.auth.FinishPasskeyRegistration(ctx, caller, auth.PasskeyRegistration{
+		Name:              strings.TrimSpace(req.Name),
+		ClientDataJSON:    clientData,
+		AttestationObject: attestation,
+	})
+	if err != nil {
+		return nil, passkeysError(err)
+	}
+
+	return toPasskey(passkey), nil
+}
+
+func (s *PasskeysServer) ListPasskeys(ctx context.Context, _ *ListPasskeysRequest) (*ListPasskeysResponse, error) {
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	passkeys, err := s.auth.ListPasskeys(ctx, caller)
+	if err != nil {
+		return nil, passkeysError(err)
+	}
+
+	resp := &ListPasskeysResponse{Passkeys: make([]*Passkey, 0, len(passkeys))}
+	for _, passkey := range passkeys {
+		resp.Passkeys = append(resp.Passkeys, toPasskey(passkey))
+	}
+
+	return resp, nil
+}
+
+func (s *PasskeysServer) DeletePasskey(ctx context.Context, req *DeletePasskeyRequest) (*DeletePasskeyResponse, error) {
+	if req.PasskeyId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "passkey_id is required")
+	}
+
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.DeletePasskey(ctx, caller, req.PasskeyId); err != nil {
+		return nil, passkeysError(err)
+	}
+
+	return &DeletePasskeyResponse{}, nil
+}
+
+func (s *PasskeysServer) BeginPasskeyLogin(
+	ctx context.Context, req *BeginPasskeyLoginRequest,
+) (*BeginPasskeyLoginResponse, error) {
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+	if (strings.TrimSpace(req.Email) == "") != (req.Password == "") {
+		return nil, status.Error(codes.InvalidArgument, "email and password go together")
+	}
+
+	options, err := s.auth.BeginPasskeyLogin(ctx, auth.PasskeyLogin{
+		AppID:    int(req.AppId),
+		Email:    req.Email,
+		Password: req.Password,
+	}, clientInfo(ctx))
+	if err != nil {
+		var changeRequired *auth.PasswordChangeRequiredError
+		if errors.As(err, &changeRequired) {
+			return nil, passwordChangeRequired(changeRequired.Token)
+		}
+
+		return nil, passkeysError(err)
+	}
+
+	return &BeginPasskeyLoginResponse{PublicKey: options}, nil
+}
+
+// FinishPasskeyLogin returns access token like Login does
+func (s *PasskeysServer) FinishPasskeyLogin(
+	ctx context.Context, req *FinishPasskeyLoginRequest,
+) (*ssov5.LoginResponse, error) {
+	var (
+		assertion auth.PasskeyAssertion
+		err       error
+	)
+
+	fields := []struct {
+		name  string
+		value string
+		dst   *[]byte
+	}{
+		{"credential_id", req.CredentialId, &assertion.CredentialID},
+		{"client_data_json", req.ClientDataJson, &assertion.ClientDataJSON},
+		{"authenticator_data", req.AuthenticatorData, &assertion.AuthenticatorData},
+		{"signature", req.Signature, &assertion.Signature},
+	}
+
+	for _, field := range fields {
+		if *field.dst, err = decodeBinary(field.name, field.value); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.UserHandle != "" {
+		if assertion.UserHandle, err = decodeBinary("user_handle", req.UserHandle); err != nil {
+			return nil, err
+		}
+	}
+
+	token, err := s.auth.FinishPasskeyLogin(ctx, assertion, clientInfo(ctx))
+	if err != nil {
+		return nil, passkeysError(err)
+	}
+
+	return &ssov5.LoginResponse{Token: token}, nil
+}
+
+func toPasskey(passkey models.Passkey) *Passkey {
+	return &Passkey{
+		PasskeyId:  passkey.ID,
+		Name:       passkey.Name,
+		CreatedAt:  passkey.CreatedAt,
+		LastUsedAt: passkey.LastUsedAt,
+	}
+}
+
+// decodeBinary decodes required base64url field, padded or not
+func decodeBinary(name string, value string) ([]byte, error) {
+	if value == "" {
+		return nil, status.Error(codes.InvalidArgument, name+" is required")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, name+" must be base64url encoded")
+	}
+
+	return b, nil
+}
+
+func passkeysError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidPasskey):
+		return status.Error(codes.InvalidArgument, "invalid or expired passkey response")
+	case errors.Is(err, auth.ErrPasskeyExists):
+		return status.Error(codes.AlreadyExists, "passkey is already registered")
+	case errors.Is(err, auth.ErrPasskeyNotFound):
+		return status.Error(codes.NotFound, "passkey not found")
+	case errors.Is(err, auth.ErrPasskeysDisabled):
+		return status.Error(codes.FailedPrecondition, "passkeys are not enabled")
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, auth.InvalidCredentials):
+		return status.Error(codes.InvalidArgument, "invalid email or password")
+	case errors.Is(err, auth.ErrInvalidAppID):
+		return status.Error(codes.InvalidArgument, "invalid app_id")
+	case errors.Is(err, auth.ErrUserDisabled):
+		return status.Error(codes.PermissionDenied, "user is disabled")
+	case errors.Is(err, auth.ErrUnavailable):
+		return status.Error(codes.Unavailable, "service is temporarily unavailable")
+	default:
+		return status.Error(codes.Internal, "Internal Error")
+	}
+}
@@ -0,0 +1,177 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/lib/password"
+	"gRPC/internal/services/auth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ChangePasswordResponse struct{}
+
+type RequestPasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type RequestPasswordResetResponse struct{}
+
+type ResetPasswordRequest struct {
+	Email       string `json:"email"`
+	Code        string `json:"code"`
+	NewPassword string `json:"new_password"`
+}
+
+type ResetPasswordResponse struct{}
+
+type Passwords interface {
+	Authenticate(ctx context.Context, token string) (auth.Principal, error)
+	AuthenticatePasswordChange(ctx context.Context, token string) (auth.Principal, error)
+	ChangePassword(ctx context.Context, caller auth.Principal, current string, newPass string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, email string, code string, newPass string) error
+}
+
+// PasswordServer serves password change and reset
+type PasswordServer struct {
+	auth Passwords
+}
+
+func NewPasswordServer(auth Passwords) *PasswordServer {
+	return &PasswordServer{auth: auth}
+}
+
+func (s *PasswordServer) ChangePassword(ctx context.Context, req *ChangePasswordRequest) (*ChangePasswordResponse, error) {
+	if req.CurrentPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "current_password is required")
+	}
+	if strings.TrimSpace(req.NewPassword) == "" {
+		return nil, status.Error(codes.InvalidArgument, "new_password is required")
+	}
+
+	// tokens Login issues for expired passwords are accepted here only
+	caller, err := authenticate(ctx, changeAuthenticator{s.auth})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.ChangePassword(ctx, caller, req.CurrentPassword, req.NewPassword); err != nil {
+		if errors.Is(err, auth.InvalidCredentials) {
+			return nil, status.Error(codes.InvalidArgument, "invalid current password")
+		}
+
+		return nil, passwordError(err, "new_password")
+	}
+
+	return &ChangePasswordResponse{}, nil
+}
+
+func (s *PasswordServer) RequestPasswordReset(ctx context.Context, req *RequestPasswordResetRequest) (*RequestPasswordResetResponse, error) {
+	if strings.TrimSpace(req.Email) == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	if err := s.auth.RequestPasswordReset(ctx, req.Email); err != nil {
+		return nil, passwordError(err, "")
+	}
+
+	return &RequestPasswordResetResponse{}, nil
+}
+
+func (s *PasswordServer) ResetPassword(ctx context.Context, req *ResetPasswordRequest) (*ResetPasswordResponse, error) {
+	if strings.TrimSpace(req.Email) == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+	if strings.TrimSpace(req.Code) == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+	if strings.TrimSpace(req.NewPassword) == "" {
+		return nil, status.Error(codes.InvalidArgument, "new_password is required")
+	}
+
+	if err := s.auth.ResetPassword(ctx, req.Email, req.Code, req.NewPassword); err != nil {
+		if errors.Is(err, auth.ErrInvalidCode) {
+			return nil, status.Error(codes.InvalidArgument, "invalid or expired code")
+		}
+
+		return nil, passwordError(err, "new_password")
+	}
+
+	return &ResetPasswordResponse{}, nil
+}
+
+// changeAuthenticator authenticates password change, which tokens restricted to it may call
+type changeAuthenticator struct {
+	auth Passwords
+}
+
+func (c changeAuthenticator) Authenticate(ctx context.Context, token string) (auth.Principal, error) {
+	return c.auth.AuthenticatePasswordChange(ctx, token)
+}
+
+// passwordChangeRequired reports expired password, token restricted to ChangePassword is
+// passed as "change_token" in ErrorInfo metadata
+func passwordChangeRequired(token string) error {
+	const msg = "password has expired and has to be changed"
+
+	st, err := status.New(codes.FailedPrecondition, msg).WithDetails(&errdetails.ErrorInfo{
+		Reason:   "PASSWORD_CHANGE_REQUIRED",
+		Domain:   "sso",
+		Metadata: map[string]string{"change_token": token},
+	})
+	if err != nil {
+		return status.Error(codes.Internal, "Internal Error")
+	}
+
+	return st.Err()
+}
+
+// passwordError maps errors shared by password flows, policy violations are reported for field
+func passwordError(err error, field string) error {
+	var violation *password.ViolationError
+	if errors.As(err, &violation) {
+		return policyViolation(field, violation)
+	}
+	if errors.Is(err, auth.ErrInvalidToken) {
+		return status.Error(codes.Unauthenticated, "invalid access token")
+	}
+	if errors.Is(err, auth.ErrPermissionDenied) {
+		return status.Error(codes.PermissionDenied, "permission denied")
+	}
+	if errors.Is(err, auth.ErrTooManyAttempts) {
+		return status.Error(codes.ResourceExhausted, "too many attempts, try again later")
+	}
+	if errors.Is(err, auth.ErrUnavailable) {
+		return status.Error(codes.Unavailable, "service is temporarily unavailable")
+	}
+
+	return status.Error(codes.Internal, "Internal Error")
+}
+
+// policyViolation reports every broken rule as violation of field in BadRequest details
+func policyViolation(field string, violation *password.ViolationError) error {
+	details := &errdetails.BadRequest{}
+	for _, v := range violation.Violations {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: v,
+		})
+	}
+
+	msg := "password does not meet requirements: " + strings.Join(violation.Violations, "; ")
+
+	st, err := status.New(codes.InvalidArgument, msg).WithDetails(details)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, msg)
+	}
+
+	return st.Err()
+}
@@ -0,0 +1,110 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/services/auth"
+	ssov5 "github.com/LeeAntonV/Protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+type StartPasswordlessLoginRequest struct {
+	Email string `json:"email"`
+	AppId int32  `json:"app_id"`
+	// Method is "link" or "code", link is sent when it is empty
+	Method string `json:"method"`
+}
+
+// StartPasswordlessLoginResponse is the same whether the email has an account or not
+type StartPasswordlessLoginResponse struct{}
+
+// CompletePasswordlessLoginRequest carries token of login link or email and one-time code
+type CompletePasswordlessLoginRequest struct {
+	AppId int32  `json:"app_id"`
+	Token string `json:"token"`
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+type Passwordless interface {
+	StartPasswordlessLogin(ctx context.Context, email string, appID int, method string) error
+	CompletePasswordlessLogin(ctx context.Context, req auth.PasswordlessLogin, client auth.ClientInfo) (string, error)
+}
+
+// PasswordlessServer serves login by emailed link or one-time code to apps which enabled it
+type PasswordlessServer struct {
+	auth Passwordless
+}
+
+func NewPasswordlessServer(auth Passwordless) *PasswordlessServer {
+	return &PasswordlessServer{auth: auth}
+}
+
+func (s *PasswordlessServer) StartPasswordlessLogin(
+	ctx context.Context, req *StartPasswordlessLoginRequest,
+) (*StartPasswordlessLoginResponse, error) {
+	if strings.TrimSpace(req.Email) == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	method := req.Method
+	if method == "" {
+		method = auth.LoginMethodLink
+	}
+
+	if err := s.auth.StartPasswordlessLogin(ctx, req.Email, int(req.AppId), method); err != nil {
+		return nil, passwordlessError(err)
+	}
+
+	return &StartPasswordlessLoginResponse{}, nil
+}
+
+// CompletePasswordlessLogin returns access token like Login does
+func (s *PasswordlessServer) CompletePasswordlessLogin(
+	ctx context.Context, req *CompletePasswordlessLoginRequest,
+) (*ssov5.LoginResponse, error) {
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+	if req.Token == "" && (strings.TrimSpace(req.Email) == "" || req.Code == "") {
+		return nil, status.Error(codes.InvalidArgument, "token or email and code are required")
+	}
+
+	token, err := s.auth.CompletePasswordlessLogin(ctx, auth.PasswordlessLogin{
+		AppID: int(req.AppId),
+		Token: req.Token,
+		Email: req.Email,
+		Code:  req.Code,
+	}, clientInfo(ctx))
+	if err != nil {
+		return nil, passwordlessError(err)
+	}
+
+	return &ssov5.LoginResponse{Token: token}, nil
+}
+
+func passwordlessError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidCode):
+		return status.Error(codes.InvalidArgument, "invalid or expired code")
+	case errors.Is(err, auth.ErrInvalidLoginMethod):
+		return status.Error(codes.InvalidArgument, "method must be link or code")
+	case errors.Is(err, auth.ErrInvalidAppID):
+		return status.Error(codes.InvalidArgument, "invalid app_id")
+	case errors.Is(err, auth.ErrPasswordlessDisabled):
+		return status.Error(codes.FailedPrecondition, "passwordless login is disabled for app")
+	case errors.Is(err, auth.ErrUserDisabled):
+		return status.Error(codes.PermissionDenied, "user is disabled")
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		return status.Error(codes.ResourceExhausted, "too many attempts, try again later")
+	case errors.Is(err, auth.ErrUnavailable):
+		return status.Error(codes.Unavailable, "service is temporarily unavailable")
+	default:
+		return status.Error(codes.Internal, "Internal Error")
+	}
+}
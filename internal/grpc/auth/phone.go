@@ -0,0 +1,253 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/lib/sms"
+	"gRPC/internal/services/auth"
+	ssov5 "github.com/LeeAntonV/Protos/gen/go/sso"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+// Channel is "sms" or "voice", "sms" when omitted.
+
+type GetPhoneRequest struct{}
+
+type PhoneResponse struct {
+	Phone    string `json:"phone"`
+	Verified bool   `json:"verified"`
+}
+
+type StartPhoneVerificationRequest struct {
+	Phone   string `json:"phone"`
+	Channel string `json:"channel"`
+}
+
+type StartPhoneVerificationResponse struct{}
+
+type VerifyPhoneRequest struct {
+	Code string `json:"code"`
+}
+
+type DeletePhoneRequest struct{}
+
+type DeletePhoneResponse struct{}
+
+type BeginSMSLoginRequest struct {
+	AppId    int32  `json:"app_id"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Channel  string `json:"channel"`
+}
+
+// BeginSMSLoginResponse tells where the code went, Phone is masked
+type BeginSMSLoginResponse struct {
+	ChallengeId string    `json:"challenge_id"`
+	Phone       string    `json:"phone"`
+	Channel     string    `json:"channel"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type FinishSMSLoginRequest struct {
+	ChallengeId string `json:"challenge_id"`
+	Code        string `json:"code"`
+}
+
+type Phones interface {
+	Authenticate(ctx context.Context, token string) (auth.Principal, error)
+	Phone(ctx context.Context, caller auth.Principal) (string, bool, error)
+	StartPhoneVerification(ctx context.Context, caller auth.Principal, phone string, channel string) error
+	VerifyPhone(ctx context.Context, caller auth.Principal, code string) error
+	DeletePhone(ctx context.Context, caller auth.Principal) error
+	StartSMSLogin(ctx context.Context, req auth.SMSLogin, client auth.ClientInfo) (auth.SMSChallenge, error)
+	FinishSMSLogin(ctx context.Context, challengeID string, code string, client auth.ClientInfo) (string, error)
+}
+
+// PhonesServer lets users verify phone numbers and log in with codes sent to them
+type PhonesServer struct {
+	auth Phones
+}
+
+func NewPhonesServer(auth Phones) *PhonesServer {
+	return &PhonesServer{auth: auth}
+}
+
+func (s *PhonesServer) GetPhone(ctx context.Context, _ *GetPhoneRequest) (*PhoneResponse, error) {
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	phone, verified, err := s.auth.Phone(ctx, caller)
+	if err != nil {
+		return nil, phonesError(err)
+	}
+
+	return &PhoneResponse{Phone: phone, Verified: verified}, nil
+}
+
+func (s *PhonesServer) StartPhoneVerification(
+	ctx context.Context, req *StartPhoneVerificationRequest,
+) (*StartPhoneVerificationResponse, error) {
+	if strings.TrimSpace(req.Phone) == "" {
+		return nil, status.Error(codes.InvalidArgument, "phone is required")
+	}
+
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.StartPhoneVerification(ctx, caller, req.Phone, channelOrDefault(req.Channel)); err != nil {
+		return nil, phonesError(err)
+	}
+
+	return &StartPhoneVerificationResponse{}, nil
+}
+
+func (s *PhonesServer) VerifyPhone(ctx context.Context, req *VerifyPhoneRequest) (*PhoneResponse, error) {
+	if req.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.VerifyPhone(ctx, caller, req.Code); err != nil {
+		return nil, phonesError(err)
+	}
+
+	return s.GetPhone(ctx, &GetPhoneRequest{})
+}
+
+func (s *PhonesServer) DeletePhone(ctx context.Context, _ *DeletePhoneRequest) (*DeletePhoneResponse, error) {
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.DeletePhone(ctx, caller); err != nil {
+		return nil, phonesError(err)
+	}
+
+	return &DeletePhoneResponse{}, nil
+}
+
+func (s *PhonesServer) BeginSMSLogin(ctx context.Context, req *BeginSMSLoginRequest) (*BeginSMSLoginResponse, error) {
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+	if req.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+	if req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "password is required")
+	}
+
+	challenge, err := s.auth.StartSMSLogin(ctx, auth.SMSLogin{
+		AppID:    int(req.AppId),
+		Email:    req.Email,
+		Password: req.Password,
+		Channel:  channelOrDefault(req.Channel),
+	}, clientInfo(ctx))
+	if err != nil {
+		var changeRequired *auth.PasswordChangeRequiredError
+		if errors.As(err, &changeRequired) {
+			return nil, passwordChangeRequired(changeRequired.Token)
+		}
+
+		return nil, phonesError(err)
+	}
+
+	return &BeginSMSLoginResponse{
+		ChallengeId: challenge.ID,
+		Phone:       challenge.Phone,
+		Channel:     challenge.Channel,
+		ExpiresAt:   challenge.ExpiresAt,
+	}, nil
+}
+
+// FinishSMSLogin returns access token like Login does
+func (s *PhonesServer) FinishSMSLogin(ctx context.Context, req *FinishSMSLoginRequest) (*ssov5.LoginResponse, error) {
+	if req.ChallengeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "challenge_id is required")
+	}
+	if req.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	token, err := s.auth.FinishSMSLogin(ctx, req.ChallengeId, req.Code, clientInfo(ctx))
+	if err != nil {
+		return nil, phonesError(err)
+	}
+
+	return &ssov5.LoginResponse{Token: token}, nil
+}
+
+func channelOrDefault(channel string) string {
+	if channel == "" {
+		return sms.ChannelSMS
+	}
+
+	return channel
+}
+
+// secondFactorRequired tells client of Login to finish it by FinishSMSLogin with code sent for challenge
+func secondFactorRequired(challenge auth.SMSChallenge) error {
+	const msg = "code sent to the verified phone is required"
+
+	st, err := status.New(codes.FailedPrecondition, msg).WithDetails(&errdetails.ErrorInfo{
+		Reason: "SECOND_FACTOR_REQUIRED",
+		Domain: "sso",
+		Metadata: map[string]string{
+			"challenge_id": challenge.ID,
+			"phone":        challenge.Phone,
+			"channel":      challenge.Channel,
+			"expires_at":   challenge.ExpiresAt.UTC().Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return status.Error(codes.Internal, "Internal Error")
+	}
+
+	return st.Err()
+}
+
+func phonesError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidPhone):
+		return status.Error(codes.InvalidArgument, sms.ErrInvalidPhone.Error())
+	case errors.Is(err, auth.ErrInvalidChannel):
+		return status.Error(codes.InvalidArgument, "channel must be sms or voice")
+	case errors.Is(err, auth.ErrInvalidCode):
+		return status.Error(codes.InvalidArgument, "invalid code")
+	case errors.Is(err, auth.ErrPhoneCodeNotFound):
+		return status.Error(codes.InvalidArgument, "code is unknown or expired")
+	case errors.Is(err, auth.ErrNoPendingPhone):
+		return status.Error(codes.FailedPrecondition, "no phone verification in progress")
+	case errors.Is(err, auth.ErrPhoneNotVerified):
+		return status.Error(codes.FailedPrecondition, "user has no verified phone")
+	case errors.Is(err, auth.ErrSMSDisabled):
+		return status.Error(codes.FailedPrecondition, "sms codes are not enabled")
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		return status.Error(codes.ResourceExhausted, "too many attempts")
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, auth.InvalidCredentials):
+		return status.Error(codes.InvalidArgument, "invalid email or password")
+	case errors.Is(err, auth.ErrInvalidAppID):
+		return status.Error(codes.InvalidArgument, "invalid app_id")
+	case errors.Is(err, auth.ErrUserDisabled):
+		return status.Error(codes.PermissionDenied, "user is disabled")
+	case errors.Is(err, auth.ErrUnavailable):
+		return status.Error(codes.Unavailable, "service is temporarily unavailable")
+	default:
+		return status.Error(codes.Internal, "Internal Error")
+	}
+}
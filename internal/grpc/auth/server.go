@@ -2,19 +2,19 @@ package grpcapp
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"gRPC/internal/lib/password"
+	"gRPC/internal/services/auth"
 	ssov5 "github.com/LeeAntonV/Protos/gen/go/sso"
-	"github.com/redis/go-redis/v9"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"net/mail"
 	"strings"
-	"time"
 )
 
 type Auth interface {
-	Login(ctx context.Context, email string, password string, appID int) (token string, err error)
+	Login(ctx context.Context, email string, password string, appID int, client auth.ClientInfo) (token string, err error)
 	RegisterNewUser(ctx context.Context, email string, password string) (userID int64, err error)
 	IsAdmin(ctx context.Context, userID int) (bool, error)
 	ValidateCode(ctx context.Context, email string, code string) (bool, error)
@@ -28,13 +28,48 @@ type serverAPI struct {
 const emptyValue = 0
 
 func Register(gRPC *grpc.Server, auth Auth) {
-	ssov5.RegisterAuthServer(gRPC, &serverAPI{auth: auth})
+	ssov5.RegisterAuthServer(gRPC, NewServer(auth))
+}
+
+// NewServer returns Auth gRPC server implementation.
+//
+// It is also used by the HTTP gateway to serve the same RPCs in-process.
+func NewServer(auth Auth) ssov5.AuthServer {
+	return &serverAPI{auth: auth}
 }
 
 func (s *serverAPI) Login(ctx context.Context, req *ssov5.LoginRequest) (*ssov5.LoginResponse, error) {
-	token, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()))
+	if err := validateLogin(req); err != nil {
+		return nil, err
+	}
+
+	token, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()), clientInfo(ctx))
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		if errors.Is(err, auth.InvalidCredentials) {
+			return nil, status.Error(codes.InvalidArgument, "invalid email or password")
+		}
+		if errors.Is(err, auth.ErrInvalidAppID) {
+			return nil, status.Error(codes.InvalidArgument, "invalid app_id")
+		}
+		if errors.Is(err, auth.ErrUserDisabled) {
+			return nil, status.Error(codes.PermissionDenied, "user is disabled")
+		}
+
+		if errors.Is(err, auth.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, "too many attempts")
+		}
+
+		var changeRequired *auth.PasswordChangeRequiredError
+		if errors.As(err, &changeRequired) {
+			return nil, passwordChangeRequired(changeRequired.Token)
+		}
+
+		var secondFactor *auth.SecondFactorRequiredError
+		if errors.As(err, &secondFactor) {
+			return nil, secondFactorRequired(secondFactor.Challenge)
+		}
+
+		return nil, status.Error(codes.Internal, "failed to login")
 	}
 
 	return &ssov5.LoginResponse{
@@ -44,11 +79,20 @@ func (s *serverAPI) Login(ctx context.Context, req *ssov5.LoginRequest) (*ssov5.
 
 func (s *serverAPI) Register(ctx context.Context, req *ssov5.RegisterRequest) (*ssov5.RegisterResponse, error) {
 	if err := validateCredentials(req); err != nil {
-		return nil, status.Error(codes.Internal, "Internal Error")
+		return nil, err
 	}
 
 	userId, err := s.auth.RegisterNewUser(ctx, req.GetEmail(), req.GetPassword())
 	if err != nil {
+		if errors.Is(err, auth.ErrUserExists) {
+			return nil, status.Error(codes.AlreadyExists, "user already exists")
+		}
+
+		var violation *password.ViolationError
+		if errors.As(err, &violation) {
+			return nil, policyViolation("password", violation)
+		}
+
 		return nil, status.Error(codes.Internal, "Internal Error")
 	}
 
@@ -58,14 +102,17 @@ func (s *serverAPI) Register(ctx context.Context, req *ssov5.RegisterRequest) (*
 }
 
 func (s *serverAPI) IsAdmin(ctx context.Context, req *ssov5.IsAdminRequest) (*ssov5.IsAdminResponse, error) {
-	if req.UserId == emptyValue {
-		return nil, status.Error(codes.Internal, "You are not allowed to admin panel")
+	if req.GetUserId() == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
 	}
 
-	isAdmin, err := s.auth.IsAdmin(ctx, int(req.UserId))
-
+	isAdmin, err := s.auth.IsAdmin(ctx, int(req.GetUserId()))
 	if err != nil {
-		return nil, status.Error(codes.Internal, "Invalid user id")
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+
+		return nil, status.Error(codes.Internal, "Internal Error")
 	}
 
 	return &ssov5.IsAdminResponse{
@@ -74,27 +121,23 @@ func (s *serverAPI) IsAdmin(ctx context.Context, req *ssov5.IsAdminRequest) (*ss
 }
 
 func (s *serverAPI) ValidCode(ctx context.Context, req *ssov5.CodeRequest) (*ssov5.CodeResponse, error) {
-	if len(strings.TrimSpace(req.Code)) == emptyValue {
-		return nil, status.Error(codes.Internal, "Code field must not be empty")
-	}
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
-	})
-
-	val, err := rdb.Get(ctx, req.Email).Result()
-	if val == req.Code {
-		return &ssov5.CodeResponse{ValidCode: false}, status.Error(codes.Internal, "Wrong code")
+	if len(strings.TrimSpace(req.GetCode())) == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "Code field must not be empty")
 	}
 
-	validCode, err := s.auth.ValidateCode(ctx, req.Email, req.Code)
+	validCode, err := s.auth.ValidateCode(ctx, req.GetEmail(), req.GetCode())
 	if err != nil {
-		if isUnique := UniqueValue(ctx, rdb, req.Email, req.Code, 1*time.Minute); !isUnique {
-			rdb.Set(ctx, req.Email, req.Code, 1*time.Minute)
+		if errors.Is(err, auth.ErrInvalidCode) {
+			return nil, status.Error(codes.InvalidArgument, "Wrong code")
+		}
+		if errors.Is(err, auth.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, "too many attempts, try again later")
+		}
+		if errors.Is(err, auth.ErrUnavailable) {
+			return nil, status.Error(codes.Unavailable, "service is temporarily unavailable")
 		}
 
-		return nil, status.Error(codes.Internal, "Wrong code")
+		return nil, status.Error(codes.Internal, "Internal Error")
 	}
 
 	return &ssov5.CodeResponse{
@@ -102,21 +145,34 @@ func (s *serverAPI) ValidCode(ctx context.Context, req *ssov5.CodeRequest) (*sso
 	}, nil
 }
 
-func validateCredentials(req *ssov5.RegisterRequest) error {
-	_, err := mail.ParseAddress(req.GetEmail())
-	if err != nil || len(strings.TrimSpace(req.GetPassword())) == emptyValue {
-		return status.Error(codes.InvalidArgument, "invalid credentials")
+func validateLogin(req *ssov5.LoginRequest) error {
+	if strings.TrimSpace(req.GetEmail()) == "" {
+		return status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	if req.GetPassword() == "" {
+		return status.Error(codes.InvalidArgument, "password is required")
+	}
+
+	if req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, "app_id is required")
 	}
 
 	return nil
 }
 
-func UniqueValue(ctx context.Context, client *redis.Client, key string, value string, exp time.Duration) bool {
-	isNew, err := client.SetNX(ctx, key, value, exp).Result()
+func validateCredentials(req *ssov5.RegisterRequest) error {
+	if strings.TrimSpace(req.GetEmail()) == "" {
+		return status.Error(codes.InvalidArgument, "email is required")
+	}
 
-	if err != nil {
-		return false
+	if len(strings.TrimSpace(req.GetPassword())) == emptyValue {
+		return status.Error(codes.InvalidArgument, "password is required")
+	}
+
+	if _, err := mail.ParseAddress(req.GetEmail()); err != nil {
+		return status.Error(codes.InvalidArgument, "invalid email")
 	}
 
-	return isNew
+	return nil
 }
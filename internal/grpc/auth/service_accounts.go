@@ -0,0 +1,258 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/oauth"
+	"gRPC/internal/services/admin"
+	"gRPC/internal/services/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+type ServiceAccount struct {
+	Id           int64     `json:"id"`
+	AppId        int32     `json:"app_id"`
+	Name         string    `json:"name"`
+	ClientId     string    `json:"client_id"`
+	Scopes       []string  `json:"scopes"`
+	HasPublicKey bool      `json:"has_public_key"`
+	Disabled     bool      `json:"disabled"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type CreateServiceAccountRequest struct {
+	AppId  int32    `json:"app_id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// PublicKey is optional PEM of RSA key client assertions are signed with
+	PublicKey string `json:"public_key"`
+}
+
+type CreateServiceAccountResponse struct {
+	ServiceAccount *ServiceAccount `json:"service_account"`
+	// ClientSecret is returned only once
+	ClientSecret string `json:"client_secret"`
+}
+
+type ListServiceAccountsRequest struct {
+	AppId int32 `json:"app_id"`
+}
+
+type ListServiceAccountsResponse struct {
+	ServiceAccounts []*ServiceAccount `json:"service_accounts"`
+}
+
+type RotateServiceAccountSecretRequest struct {
+	ServiceAccountId int64 `json:"service_account_id"`
+	// PublicKey replaces the registered key when set
+	PublicKey string `json:"public_key"`
+}
+
+type RotateServiceAccountSecretResponse struct {
+	ClientSecret string `json:"client_secret"`
+}
+
+type DisableServiceAccountRequest struct {
+	ServiceAccountId int64 `json:"service_account_id"`
+}
+
+type DisableServiceAccountResponse struct{}
+
+// IssueServiceTokenRequest is client_credentials grant, the account authenticates
+// with client_secret or with client_assertion signed by its key
+type IssueServiceTokenRequest struct {
+	ClientId        string `json:"client_id"`
+	ClientSecret    string `json:"client_secret"`
+	ClientAssertion string `json:"client_assertion"`
+	Scope           string `json:"scope"`
+	Audience        string `json:"audience"`
+}
+
+type IssueServiceTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+type ServiceAccountManager interface {
+	CreateServiceAccount(
+		ctx context.Context, appID int, name string, scope string, publicKey []byte,
+	) (models.ServiceAccount, string, error)
+	ServiceAccounts(ctx context.Context, appID int) ([]models.ServiceAccount, error)
+	RotateServiceAccountSecret(ctx context.Context, id int64, publicKey []byte) (string, error)
+	DisableServiceAccount(ctx context.Context, id int64) error
+}
+
+type ServiceTokens interface {
+	IssueServiceToken(ctx context.Context, req auth.ClientCredentials) (auth.TokenGrant, error)
+}
+
+// ServiceAccountsServer lets admins manage service accounts of apps
+// and the accounts get their tokens
+type ServiceAccountsServer struct {
+	admins   Admins
+	accounts ServiceAccountManager
+	tokens   ServiceTokens
+}
+
+func NewServiceAccountsServer(admins Admins, accounts ServiceAccountManager, tokens ServiceTokens) *ServiceAccountsServer {
+	return &ServiceAccountsServer{admins: admins, accounts: accounts, tokens: tokens}
+}
+
+func (s *ServiceAccountsServer) CreateServiceAccount(
+	ctx context.Context, req *CreateServiceAccountRequest,
+) (*CreateServiceAccountResponse, error) {
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	if err := requireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	account, secret, err := s.accounts.CreateServiceAccount(ctx,
+		int(req.AppId), req.Name, strings.Join(req.Scopes, " "), []byte(req.PublicKey))
+	if err != nil {
+		return nil, serviceAccountsError(err)
+	}
+
+	return &CreateServiceAccountResponse{ServiceAccount: toServiceAccount(account), ClientSecret: secret}, nil
+}
+
+func (s *ServiceAccountsServer) ListServiceAccounts(
+	ctx context.Context, req *ListServiceAccountsRequest,
+) (*ListServiceAccountsResponse, error) {
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if err := requireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	accounts, err := s.accounts.ServiceAccounts(ctx, int(req.AppId))
+	if err != nil {
+		return nil, serviceAccountsError(err)
+	}
+
+	resp := &ListServiceAccountsResponse{ServiceAccounts: make([]*ServiceAccount, 0, len(accounts))}
+	for _, account := range accounts {
+		resp.ServiceAccounts = append(resp.ServiceAccounts, toServiceAccount(account))
+	}
+
+	return resp, nil
+}
+
+// RotateServiceAccountSecret replaces client secret, the old one stops working at once
+func (s *ServiceAccountsServer) RotateServiceAccountSecret(
+	ctx context.Context, req *RotateServiceAccountSecretRequest,
+) (*RotateServiceAccountSecretResponse, error) {
+	if req.ServiceAccountId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "service_account_id is required")
+	}
+
+	if err := requireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	secret, err := s.accounts.RotateServiceAccountSecret(ctx, req.ServiceAccountId, []byte(req.PublicKey))
+	if err != nil {
+		return nil, serviceAccountsError(err)
+	}
+
+	return &RotateServiceAccountSecretResponse{ClientSecret: secret}, nil
+}
+
+func (s *ServiceAccountsServer) DisableServiceAccount(
+	ctx context.Context, req *DisableServiceAccountRequest,
+) (*DisableServiceAccountResponse, error) {
+	if req.ServiceAccountId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "service_account_id is required")
+	}
+
+	if err := requireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	if err := s.accounts.DisableServiceAccount(ctx, req.ServiceAccountId); err != nil {
+		return nil, serviceAccountsError(err)
+	}
+
+	return &DisableServiceAccountResponse{}, nil
+}
+
+// IssueServiceToken exchanges client credentials of service account for its access token
+func (s *ServiceAccountsServer) IssueServiceToken(
+	ctx context.Context, req *IssueServiceTokenRequest,
+) (*IssueServiceTokenResponse, error) {
+	if req.ClientId == "" && req.ClientAssertion == "" {
+		return nil, status.Error(codes.InvalidArgument, "client_id is required")
+	}
+
+	grant, err := s.tokens.IssueServiceToken(ctx, auth.ClientCredentials{
+		ClientID:     req.ClientId,
+		ClientSecret: req.ClientSecret,
+		Assertion:    req.ClientAssertion,
+		Scope:        req.Scope,
+		Audience:     req.Audience,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidClient):
+			return nil, status.Error(codes.Unauthenticated, "invalid client credentials")
+		case errors.Is(err, auth.ErrInvalidScope):
+			return nil, status.Error(codes.InvalidArgument, "invalid scope")
+		case errors.Is(err, auth.ErrInvalidAudience):
+			return nil, status.Error(codes.InvalidArgument, "invalid audience")
+		case errors.Is(err, auth.ErrUnavailable):
+			return nil, status.Error(codes.Unavailable, "service is temporarily unavailable")
+		default:
+			return nil, status.Error(codes.Internal, "Internal Error")
+		}
+	}
+
+	return &IssueServiceTokenResponse{
+		AccessToken: grant.AccessToken,
+		ExpiresIn:   int64(grant.ExpiresIn.Seconds()),
+		Scope:       oauth.FormatScope(grant.Scopes),
+	}, nil
+}
+
+func serviceAccountsError(err error) error {
+	switch {
+	case errors.Is(err, admin.ErrAppNotFound):
+		return status.Error(codes.NotFound, "app not found")
+	case errors.Is(err, admin.ErrServiceAccountNotFound):
+		return status.Error(codes.NotFound, "service account not found")
+	case errors.Is(err, admin.ErrServiceAccountExists):
+		return status.Error(codes.AlreadyExists, "service account already exists")
+	case errors.Is(err, admin.ErrInvalidScope):
+		return status.Error(codes.InvalidArgument, "invalid scope")
+	case errors.Is(err, admin.ErrInvalidPublicKey):
+		return status.Error(codes.InvalidArgument, "invalid public key")
+	case errors.Is(err, admin.ErrInvalidName):
+		return status.Error(codes.InvalidArgument, "invalid name")
+	default:
+		return status.Error(codes.Internal, "Internal Error")
+	}
+}
+
+func toServiceAccount(a models.ServiceAccount) *ServiceAccount {
+	return &ServiceAccount{
+		Id:           a.ID,
+		AppId:        int32(a.AppID),
+		Name:         a.Name,
+		ClientId:     a.ClientID,
+		Scopes:       a.Scopes,
+		HasPublicKey: len(a.PublicKey) > 0,
+		Disabled:     a.Disabled,
+		CreatedAt:    a.CreatedAt,
+	}
+}
@@ -0,0 +1,261 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/services/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"strings"
+	"time"
+)
+
+type Session struct {
+	SessionId  string    `json:"session_id"`
+	AppId      int32     `json:"app_id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	Ip         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current is set for the session of the caller
+	Current bool `json:"current"`
+	// ImpersonatorId is the admin who started the session on behalf of the user
+	ImpersonatorId int64 `json:"impersonator_id,omitempty"`
+}
+
+type ListSessionsRequest struct{}
+
+type ListSessionsResponse struct {
+	Sessions []*Session `json:"sessions"`
+}
+
+type RevokeSessionRequest struct {
+	SessionId string `json:"session_id"`
+}
+
+type RevokeSessionResponse struct{}
+
+type RevokeOtherSessionsRequest struct{}
+
+type RevokeSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+type ListUserSessionsRequest struct {
+	UserId int64 `json:"user_id"`
+}
+
+type RevokeUserSessionRequest struct {
+	UserId    int64  `json:"user_id"`
+	SessionId string `json:"session_id"`
+}
+
+type RevokeUserSessionsRequest struct {
+	UserId int64 `json:"user_id"`
+}
+
+type Sessions interface {
+	Authenticate(ctx context.Context, token string) (auth.Principal, error)
+	ListSessions(ctx context.Context, caller auth.Principal) ([]models.Session, error)
+	RevokeSession(ctx context.Context, caller auth.Principal, sessionID string) error
+	RevokeOtherSessions(ctx context.Context, caller auth.Principal) (int64, error)
+	UserSessions(ctx context.Context, caller auth.Principal, userID int64) ([]models.Session, error)
+	RevokeUserSession(ctx context.Context, caller auth.Principal, userID int64, sessionID string) error
+	RevokeUserSessions(ctx context.Context, caller auth.Principal, userID int64) (int64, error)
+}
+
+// SessionsServer serves session management RPCs of the authenticated user
+type SessionsServer struct {
+	auth Sessions
+}
+
+func NewSessionsServer(auth Sessions) *SessionsServer {
+	return &SessionsServer{auth: auth}
+}
+
+func (s *SessionsServer) ListSessions(ctx context.Context, _ *ListSessionsRequest) (*ListSessionsResponse, error) {
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.auth.ListSessions(ctx, caller)
+	if err != nil {
+		return nil, sessionsError(err)
+	}
+
+	return &ListSessionsResponse{Sessions: toSessions(sessions, caller.SessionID)}, nil
+}
+
+func (s *SessionsServer) RevokeSession(ctx context.Context, req *RevokeSessionRequest) (*RevokeSessionResponse, error) {
+	if req.SessionId == "" {
+		return nil, status.Error(codes.InvalidArgument, "session_id is required")
+	}
+
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.RevokeSession(ctx, caller, req.SessionId); err != nil {
+		return nil, sessionsError(err)
+	}
+
+	return &RevokeSessionResponse{}, nil
+}
+
+func (s *SessionsServer) RevokeOtherSessions(ctx context.Context, _ *RevokeOtherSessionsRequest) (*RevokeSessionsResponse, error) {
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := s.auth.RevokeOtherSessions(ctx, caller)
+	if err != nil {
+		return nil, sessionsError(err)
+	}
+
+	return &RevokeSessionsResponse{Revoked: n}, nil
+}
+
+func (s *SessionsServer) ListUserSessions(ctx context.Context, req *ListUserSessionsRequest) (*ListSessionsResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.auth.UserSessions(ctx, caller, req.UserId)
+	if err != nil {
+		return nil, sessionsError(err)
+	}
+
+	return &ListSessionsResponse{Sessions: toSessions(sessions, caller.SessionID)}, nil
+}
+
+func (s *SessionsServer) RevokeUserSession(ctx context.Context, req *RevokeUserSessionRequest) (*RevokeSessionResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if req.SessionId == "" {
+		return nil, status.Error(codes.InvalidArgument, "session_id is required")
+	}
+
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.RevokeUserSession(ctx, caller, req.UserId, req.SessionId); err != nil {
+		return nil, sessionsError(err)
+	}
+
+	return &RevokeSessionResponse{}, nil
+}
+
+func (s *SessionsServer) RevokeUserSessions(ctx context.Context, req *RevokeUserSessionsRequest) (*RevokeSessionsResponse, error) {
+	if req.UserId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := s.auth.RevokeUserSessions(ctx, caller, req.UserId)
+	if err != nil {
+		return nil, sessionsError(err)
+	}
+
+	return &RevokeSessionsResponse{Revoked: n}, nil
+}
+
+func sessionsError(err error) error {
+	if errors.Is(err, auth.ErrSessionNotFound) {
+		return status.Error(codes.NotFound, "session not found")
+	}
+	if errors.Is(err, auth.ErrPermissionDenied) {
+		return status.Error(codes.PermissionDenied, "permission denied")
+	}
+
+	return status.Error(codes.Internal, "Internal Error")
+}
+
+func toSessions(sessions []models.Session, currentID string) []*Session {
+	res := make([]*Session, 0, len(sessions))
+	for _, s := range sessions {
+		res = append(res, &Session{
+			SessionId:      s.ID,
+			AppId:          int32(s.AppID),
+			DeviceName:     s.DeviceName,
+			UserAgent:      s.UserAgent,
+			Ip:             s.IP,
+			CreatedAt:      s.CreatedAt,
+			LastSeenAt:     s.LastSeenAt,
+			ExpiresAt:      s.ExpiresAt,
+			Current:        s.ID == currentID,
+			ImpersonatorId: s.ImpersonatorID,
+		})
+	}
+
+	return res
+}
+
+type authenticator interface {
+	Authenticate(ctx context.Context, token string) (auth.Principal, error)
+}
+
+// authenticate resolves caller from "authorization: Bearer <token>" metadata
+func authenticate(ctx context.Context, authn authenticator) (auth.Principal, error) {
+	token, ok := strings.CutPrefix(firstMetadata(ctx, "authorization"), "Bearer ")
+	if !ok || token == "" {
+		return auth.Principal{}, status.Error(codes.Unauthenticated, "access token is required")
+	}
+
+	caller, err := authn.Authenticate(ctx, token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return auth.Principal{}, status.Error(codes.Unauthenticated, "invalid access token")
+		}
+
+		return auth.Principal{}, status.Error(codes.Internal, "Internal Error")
+	}
+
+	return caller, nil
+}
+
+// clientInfo describes the calling device from request metadata and peer address
+func clientInfo(ctx context.Context) auth.ClientInfo {
+	info := auth.ClientInfo{
+		DeviceName: firstMetadata(ctx, "x-device-name"),
+		UserAgent:  firstMetadata(ctx, "user-agent"),
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			info.IP = host
+		} else {
+			info.IP = p.Addr.String()
+		}
+	}
+
+	return info
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	if v := metadata.ValueFromIncomingContext(ctx, key); len(v) > 0 {
+		return v[0]
+	}
+
+	return ""
+}
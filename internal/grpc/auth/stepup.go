@@ -0,0 +1,134 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/grpc/interceptors"
+	"gRPC/internal/services/auth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strconv"
+	"time"
+)
+
+// ReauthenticateRequest carries password, with Channel also set a code is sent
+// to the verified phone and the call is repeated with ChallengeId and Code
+type ReauthenticateRequest struct {
+	Password    string `json:"password"`
+	Channel     string `json:"channel"`
+	ChallengeId string `json:"challenge_id"`
+	Code        string `json:"code"`
+}
+
+// ReauthenticateResponse carries either new Token of the same session
+// or challenge waiting for the code, Phone is masked
+type ReauthenticateResponse struct {
+	Token       string     `json:"token,omitempty"`
+	ChallengeId string     `json:"challenge_id,omitempty"`
+	Phone       string     `json:"phone,omitempty"`
+	Channel     string     `json:"channel,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+type StepUp interface {
+	Authenticate(ctx context.Context, token string) (auth.Principal, error)
+	CheckAuthLevel(caller auth.Principal, maxAge time.Duration, minACR string) error
+	Reauthenticate(ctx context.Context, caller auth.Principal, req auth.Reauthentication, client auth.ClientInfo) (auth.Reauthenticated, error)
+}
+
+// StepUpServer lets users raise authentication level of their tokens
+// and checks it for interceptors.RequireAuth
+type StepUpServer struct {
+	auth StepUp
+}
+
+func NewStepUpServer(auth StepUp) *StepUpServer {
+	return &StepUpServer{auth: auth}
+}
+
+func (s *StepUpServer) Reauthenticate(ctx context.Context, req *ReauthenticateRequest) (*ReauthenticateResponse, error) {
+	if req.ChallengeId == "" && req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "password or challenge_id is required")
+	}
+	if req.ChallengeId != "" && req.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.auth.Reauthenticate(ctx, caller, auth.Reauthentication{
+		Password:    req.Password,
+		Channel:     req.Channel,
+		ChallengeID: req.ChallengeId,
+		Code:        req.Code,
+	}, clientInfo(ctx))
+	if err != nil {
+		var changeRequired *auth.PasswordChangeRequiredError
+		if errors.As(err, &changeRequired) {
+			return nil, passwordChangeRequired(changeRequired.Token)
+		}
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid access token")
+		}
+
+		return nil, phonesError(err)
+	}
+
+	if result.Token != "" {
+		return &ReauthenticateResponse{Token: result.Token}, nil
+	}
+
+	return &ReauthenticateResponse{
+		ChallengeId: result.Challenge.ID,
+		Phone:       result.Challenge.Phone,
+		Channel:     result.Challenge.Channel,
+		ExpiresAt:   &result.Challenge.ExpiresAt,
+	}, nil
+}
+
+// CheckAuth implements interceptors.AuthChecker for the bearer token of the call
+func (s *StepUpServer) CheckAuth(ctx context.Context, requirement interceptors.AuthRequirement) error {
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return err
+	}
+
+	if err := s.auth.CheckAuthLevel(caller, requirement.MaxAge, requirement.MinACR); err != nil {
+		if errors.Is(err, auth.ErrStepUpRequired) {
+			return stepUpRequired(requirement)
+		}
+
+		return status.Error(codes.Internal, "Internal Error")
+	}
+
+	return nil
+}
+
+// stepUpRequired tells the client to reauthenticate, metadata repeats what is required
+// the way OAuth step-up challenge (RFC 9470) does
+func stepUpRequired(requirement interceptors.AuthRequirement) error {
+	const msg = "recent or stronger authentication is required, reauthenticate and retry"
+
+	metadata := map[string]string{}
+	if requirement.MaxAge > 0 {
+		metadata["max_age"] = strconv.Itoa(int(requirement.MaxAge.Seconds()))
+	}
+	if requirement.MinACR != "" {
+		metadata["acr_values"] = requirement.MinACR
+	}
+
+	st, err := status.New(codes.Unauthenticated, msg).WithDetails(&errdetails.ErrorInfo{
+		Reason:   "INSUFFICIENT_USER_AUTHENTICATION",
+		Domain:   "sso",
+		Metadata: metadata,
+	})
+	if err != nil {
+		return status.Error(codes.Internal, "Internal Error")
+	}
+
+	return st.Err()
+}
@@ -0,0 +1,221 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/lib/oauth"
+	"gRPC/internal/services/auth"
+	ssov5 "github.com/LeeAntonV/Protos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type LoginWithScopeRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	AppId    int32  `json:"app_id"`
+	// Scope and Audience are space delimited, empty ones request everything allowed for the app
+	Scope    string `json:"scope"`
+	Audience string `json:"audience"`
+}
+
+type LoginWithScopeResponse struct {
+	Token     string `json:"token"`
+	ExpiresIn int64  `json:"expires_in"`
+	Scope     string `json:"scope"`
+}
+
+type ValidateTokenRequest struct {
+	Token string `json:"token"`
+	// Audience is the resource server the token must be meant for, empty one is not checked
+	Audience string   `json:"audience"`
+	Scopes   []string `json:"scopes"`
+}
+
+type ValidateTokenResponse struct {
+	TokenId  string   `json:"jti"`
+	UserId   int64    `json:"user_id,omitempty"`
+	Email    string   `json:"email,omitempty"`
+	ClientId string   `json:"client_id,omitempty"`
+	AppId    int32    `json:"app_id"`
+	Scope    *string  `json:"scope,omitempty"`
+	Audience []string `json:"audience,omitempty"`
+	Actors   []string `json:"actors,omitempty"`
+	// ImpersonatorId is set for tokens of sessions admin started on behalf of the user
+	ImpersonatorId int64 `json:"impersonator_id,omitempty"`
+	IssuedAt       int64 `json:"iat"`
+	ExpiresAt      int64 `json:"exp"`
+}
+
+// ExchangeTokenRequest is token exchange of service account acting on behalf of user
+// of subject_token, it authenticates with client credentials or with its actor_token
+type ExchangeTokenRequest struct {
+	SubjectToken    string `json:"subject_token"`
+	ActorToken      string `json:"actor_token"`
+	ClientId        string `json:"client_id"`
+	ClientSecret    string `json:"client_secret"`
+	ClientAssertion string `json:"client_assertion"`
+	Scope           string `json:"scope"`
+	Audience        string `json:"audience"`
+}
+
+type ExchangeTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+type Tokens interface {
+	LoginWithScope(ctx context.Context, req auth.LoginRequest, client auth.ClientInfo) (auth.TokenGrant, error)
+	ValidateToken(ctx context.Context, token string, audience string, scopes []string) (auth.TokenInfo, error)
+	ExchangeToken(ctx context.Context, req auth.TokenExchange) (auth.TokenGrant, error)
+}
+
+// TokensServer issues tokens limited to scopes and audiences and lets resource servers
+// check tokens presented to them
+type TokensServer struct {
+	auth Tokens
+}
+
+func NewTokensServer(auth Tokens) *TokensServer {
+	return &TokensServer{auth: auth}
+}
+
+func (s *TokensServer) LoginWithScope(ctx context.Context, req *LoginWithScopeRequest) (*LoginWithScopeResponse, error) {
+	err := validateLogin(&ssov5.LoginRequest{Email: req.Email, Password: req.Password, AppId: req.AppId})
+	if err != nil {
+		return nil, err
+	}
+
+	grant, err := s.auth.LoginWithScope(ctx, auth.LoginRequest{
+		Email:    req.Email,
+		Password: req.Password,
+		AppID:    int(req.AppId),
+		Scope:    req.Scope,
+		Audience: req.Audience,
+	}, clientInfo(ctx))
+	if err != nil {
+		var (
+			changeRequired *auth.PasswordChangeRequiredError
+			secondFactor   *auth.SecondFactorRequiredError
+		)
+
+		switch {
+		case errors.Is(err, auth.InvalidCredentials):
+			return nil, status.Error(codes.InvalidArgument, "invalid email or password")
+		case errors.Is(err, auth.ErrInvalidAppID):
+			return nil, status.Error(codes.InvalidArgument, "invalid app_id")
+		case errors.Is(err, auth.ErrInvalidScope):
+			return nil, status.Error(codes.InvalidArgument, "invalid scope")
+		case errors.Is(err, auth.ErrInvalidAudience):
+			return nil, status.Error(codes.InvalidArgument, "invalid audience")
+		case errors.Is(err, auth.ErrUserDisabled):
+			return nil, status.Error(codes.PermissionDenied, "user is disabled")
+		case errors.Is(err, auth.ErrTooManyAttempts):
+			return nil, status.Error(codes.ResourceExhausted, "too many attempts")
+		case errors.As(err, &changeRequired):
+			return nil, passwordChangeRequired(changeRequired.Token)
+		case errors.As(err, &secondFactor):
+			return nil, secondFactorRequired(secondFactor.Challenge)
+		default:
+			return nil, status.Error(codes.Internal, "failed to login")
+		}
+	}
+
+	return &LoginWithScopeResponse{
+		Token:     grant.AccessToken,
+		ExpiresIn: int64(grant.ExpiresIn.Seconds()),
+		Scope:     oauth.FormatScope(grant.Scopes),
+	}, nil
+}
+
+// ValidateToken checks token for resource server: Unauthenticated means the token is not valid
+// at all, PermissionDenied that it is not meant for the audience or lacks required scopes
+func (s *TokensServer) ValidateToken(ctx context.Context, req *ValidateTokenRequest) (*ValidateTokenResponse, error) {
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	info, err := s.auth.ValidateToken(ctx, req.Token, req.Audience, req.Scopes)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, "invalid access token")
+		case errors.Is(err, auth.ErrInvalidAudience):
+			return nil, status.Error(codes.PermissionDenied, "token is not meant for the audience")
+		case errors.Is(err, auth.ErrInsufficientScope):
+			return nil, status.Error(codes.PermissionDenied, "token lacks required scope")
+		default:
+			return nil, status.Error(codes.Internal, "Internal Error")
+		}
+	}
+
+	resp := &ValidateTokenResponse{
+		TokenId:        info.ID,
+		UserId:         info.UserID,
+		Email:          info.Email,
+		ClientId:       info.ClientID,
+		AppId:          int32(info.AppID),
+		Audience:       info.Audience,
+		Actors:         info.Actors,
+		IssuedAt:       info.IssuedAt.Unix(),
+		ExpiresAt:      info.ExpiresAt.Unix(),
+		ImpersonatorId: info.ImpersonatorID,
+	}
+
+	// first-party tokens have no scope at all, which differs from empty one
+	if info.Scopes != nil {
+		scope := oauth.FormatScope(info.Scopes)
+		resp.Scope = &scope
+	}
+
+	return resp, nil
+}
+
+// ExchangeToken issues token of subject_token user for audience to service account
+// allowed to act on the user behalf by exchange policy of its app
+func (s *TokensServer) ExchangeToken(ctx context.Context, req *ExchangeTokenRequest) (*ExchangeTokenResponse, error) {
+	if req.SubjectToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "subject_token is required")
+	}
+	if req.Audience == "" {
+		return nil, status.Error(codes.InvalidArgument, "audience is required")
+	}
+	if req.ClientId == "" && req.ClientAssertion == "" && req.ActorToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "client_id or actor_token is required")
+	}
+
+	grant, err := s.auth.ExchangeToken(ctx, auth.TokenExchange{
+		ClientID:     req.ClientId,
+		ClientSecret: req.ClientSecret,
+		Assertion:    req.ClientAssertion,
+		SubjectToken: req.SubjectToken,
+		ActorToken:   req.ActorToken,
+		Scope:        req.Scope,
+		Audience:     req.Audience,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidClient):
+			return nil, status.Error(codes.Unauthenticated, "invalid client credentials")
+		case errors.Is(err, auth.ErrInvalidRequest):
+			return nil, status.Error(codes.InvalidArgument, "invalid subject or actor token")
+		case errors.Is(err, auth.ErrInvalidScope):
+			return nil, status.Error(codes.InvalidArgument, "invalid scope")
+		case errors.Is(err, auth.ErrInvalidAudience):
+			return nil, status.Error(codes.PermissionDenied, "exchange for the audience is not allowed")
+		case errors.Is(err, auth.ErrUserDisabled):
+			return nil, status.Error(codes.PermissionDenied, "user is disabled")
+		case errors.Is(err, auth.ErrUnavailable):
+			return nil, status.Error(codes.Unavailable, "service is temporarily unavailable")
+		default:
+			return nil, status.Error(codes.Internal, "Internal Error")
+		}
+	}
+
+	return &ExchangeTokenResponse{
+		AccessToken: grant.AccessToken,
+		ExpiresIn:   int64(grant.ExpiresIn.Seconds()),
+		Scope:       oauth.FormatScope(grant.Scopes),
+	}, nil
+}
@@ -0,0 +1,60 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/services/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type UserInfoRequest struct{}
+
+// UserInfoResponse carries OpenID Connect claims, email ones only when email scope is granted
+type UserInfoResponse struct {
+	Sub           string `json:"sub"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+}
+
+type UserInfo interface {
+	Authenticate(ctx context.Context, token string) (auth.Principal, error)
+	UserInfo(ctx context.Context, caller auth.Principal) (map[string]any, error)
+}
+
+// UserInfoServer serves claims about the caller
+type UserInfoServer struct {
+	auth UserInfo
+}
+
+func NewUserInfoServer(auth UserInfo) *UserInfoServer {
+	return &UserInfoServer{auth: auth}
+}
+
+func (s *UserInfoServer) UserInfo(ctx context.Context, _ *UserInfoRequest) (*UserInfoResponse, error) {
+	caller, err := authenticate(ctx, s.auth)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.auth.UserInfo(ctx, caller)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInsufficientScope):
+			return nil, status.Error(codes.PermissionDenied, "openid scope is required")
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, "invalid access token")
+		default:
+			return nil, status.Error(codes.Internal, "Internal Error")
+		}
+	}
+
+	resp := &UserInfoResponse{}
+	resp.Sub, _ = claims["sub"].(string)
+	resp.Email, _ = claims["email"].(string)
+	if verified, ok := claims["email_verified"].(bool); ok {
+		resp.EmailVerified = &verified
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,272 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/services/webhooks"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+type Webhook struct {
+	Id        int64     `json:"id"`
+	AppId     int32     `json:"app_id"`
+	Url       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	Id            int64     `json:"id"`
+	WebhookId     int64     `json:"webhook_id"`
+	Event         string    `json:"event"`
+	Status        string    `json:"status"`
+	Attempts      int32     `json:"attempts"`
+	ResponseCode  int32     `json:"response_code"`
+	LastError     string    `json:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	DeliveredAt   time.Time `json:"delivered_at"`
+}
+
+type CreateWebhookRequest struct {
+	AppId  int32    `json:"app_id"`
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type CreateWebhookResponse struct {
+	Webhook *Webhook `json:"webhook"`
+	// Secret signs deliveries, it is returned only once
+	Secret string `json:"secret"`
+}
+
+type ListWebhooksRequest struct {
+	AppId int32 `json:"app_id"`
+}
+
+type ListWebhooksResponse struct {
+	Webhooks []*Webhook `json:"webhooks"`
+}
+
+type DeleteWebhookRequest struct {
+	AppId     int32 `json:"app_id"`
+	WebhookId int64 `json:"webhook_id"`
+}
+
+type DeleteWebhookResponse struct{}
+
+type ListWebhookDeliveriesRequest struct {
+	WebhookId int64 `json:"webhook_id"`
+	Limit     int32 `json:"limit"`
+}
+
+type ListWebhookDeliveriesResponse struct {
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+}
+
+type RedeliverWebhookRequest struct {
+	DeliveryId int64 `json:"delivery_id"`
+}
+
+type TestWebhookRequest struct {
+	WebhookId int64 `json:"webhook_id"`
+}
+
+type WebhookDeliveryResponse struct {
+	Delivery *WebhookDelivery `json:"delivery"`
+}
+
+type WebhookManager interface {
+	Create(ctx context.Context, appID int, url string, events []string) (models.Webhook, error)
+	List(ctx context.Context, appID int) ([]models.Webhook, error)
+	Delete(ctx context.Context, appID int, webhookID int64) error
+	Deliveries(ctx context.Context, webhookID int64, limit int) ([]models.WebhookDelivery, error)
+	Redeliver(ctx context.Context, deliveryID int64) (models.WebhookDelivery, error)
+	Test(ctx context.Context, webhookID int64) (models.WebhookDelivery, error)
+}
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+// WebhooksServer lets admins manage webhooks of apps
+type WebhooksServer struct {
+	admins   Admins
+	webhooks WebhookManager
+}
+
+func NewWebhooksServer(admins Admins, webhooks WebhookManager) *WebhooksServer {
+	return &WebhooksServer{admins: admins, webhooks: webhooks}
+}
+
+func (s *WebhooksServer) CreateWebhook(ctx context.Context, req *CreateWebhookRequest) (*CreateWebhookResponse, error) {
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+	if req.Url == "" {
+		return nil, status.Error(codes.InvalidArgument, "url is required")
+	}
+
+	if err := requireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.webhooks.Create(ctx, int(req.AppId), req.Url, req.Events)
+	if err != nil {
+		return nil, webhooksError(err)
+	}
+
+	return &CreateWebhookResponse{Webhook: toWebhook(webhook), Secret: webhook.Secret}, nil
+}
+
+func (s *WebhooksServer) ListWebhooks(ctx context.Context, req *ListWebhooksRequest) (*ListWebhooksResponse, error) {
+	if req.AppId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if err := requireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	list, err := s.webhooks.List(ctx, int(req.AppId))
+	if err != nil {
+		return nil, webhooksError(err)
+	}
+
+	resp := &ListWebhooksResponse{Webhooks: make([]*Webhook, 0, len(list))}
+	for _, webhook := range list {
+		resp.Webhooks = append(resp.Webhooks, toWebhook(webhook))
+	}
+
+	return resp, nil
+}
+
+func (s *WebhooksServer) DeleteWebhook(ctx context.Context, req *DeleteWebhookRequest) (*DeleteWebhookResponse, error) {
+	if req.AppId == emptyValue || req.WebhookId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "app_id and webhook_id are required")
+	}
+
+	if err := requireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	if err := s.webhooks.Delete(ctx, int(req.AppId), req.WebhookId); err != nil {
+		return nil, webhooksError(err)
+	}
+
+	return &DeleteWebhookResponse{}, nil
+}
+
+// ListWebhookDeliveries returns delivery log of webhook, the newest first
+func (s *WebhooksServer) ListWebhookDeliveries(ctx context.Context, req *ListWebhookDeliveriesRequest) (*ListWebhookDeliveriesResponse, error) {
+	if req.WebhookId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "webhook_id is required")
+	}
+	if req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = defaultDeliveriesLimit
+	}
+	limit = min(limit, maxDeliveriesLimit)
+
+	if err := requireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.webhooks.Deliveries(ctx, req.WebhookId, limit)
+	if err != nil {
+		return nil, webhooksError(err)
+	}
+
+	resp := &ListWebhookDeliveriesResponse{Deliveries: make([]*WebhookDelivery, 0, len(deliveries))}
+	for _, d := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, toWebhookDelivery(d))
+	}
+
+	return resp, nil
+}
+
+// RedeliverWebhook queues payload of earlier delivery again
+func (s *WebhooksServer) RedeliverWebhook(ctx context.Context, req *RedeliverWebhookRequest) (*WebhookDeliveryResponse, error) {
+	if req.DeliveryId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "delivery_id is required")
+	}
+
+	if err := requireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.webhooks.Redeliver(ctx, req.DeliveryId)
+	if err != nil {
+		return nil, webhooksError(err)
+	}
+
+	return &WebhookDeliveryResponse{Delivery: toWebhookDelivery(delivery)}, nil
+}
+
+// TestWebhook sends ping to webhook and returns the outcome
+func (s *WebhooksServer) TestWebhook(ctx context.Context, req *TestWebhookRequest) (*WebhookDeliveryResponse, error) {
+	if req.WebhookId == emptyValue {
+		return nil, status.Error(codes.InvalidArgument, "webhook_id is required")
+	}
+
+	if err := requireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.webhooks.Test(ctx, req.WebhookId)
+	if err != nil {
+		return nil, webhooksError(err)
+	}
+
+	return &WebhookDeliveryResponse{Delivery: toWebhookDelivery(delivery)}, nil
+}
+
+func webhooksError(err error) error {
+	switch {
+	case errors.Is(err, webhooks.ErrAppNotFound):
+		return status.Error(codes.NotFound, "app not found")
+	case errors.Is(err, webhooks.ErrWebhookNotFound):
+		return status.Error(codes.NotFound, "webhook not found")
+	case errors.Is(err, webhooks.ErrDeliveryNotFound):
+		return status.Error(codes.NotFound, "webhook delivery not found")
+	case errors.Is(err, webhooks.ErrInvalidURL):
+		return status.Error(codes.InvalidArgument, webhooks.ErrInvalidURL.Error())
+	case errors.Is(err, webhooks.ErrUnknownEvent):
+		return status.Error(codes.InvalidArgument, "unknown webhook event")
+	default:
+		return status.Error(codes.Internal, "Internal Error")
+	}
+}
+
+func toWebhook(w models.Webhook) *Webhook {
+	return &Webhook{
+		Id:        w.ID,
+		AppId:     int32(w.AppID),
+		Url:       w.URL,
+		Events:    w.Events,
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+func toWebhookDelivery(d models.WebhookDelivery) *WebhookDelivery {
+	return &WebhookDelivery{
+		Id:            d.ID,
+		WebhookId:     d.WebhookID,
+		Event:         d.EventType,
+		Status:        d.Status,
+		Attempts:      int32(d.Attempts),
+		ResponseCode:  int32(d.ResponseCode),
+		LastError:     d.LastError,
+		NextAttemptAt: d.NextAttemptAt,
+		CreatedAt:     d.CreatedAt,
+		DeliveredAt:   d.DeliveredAt,
+	}
+}
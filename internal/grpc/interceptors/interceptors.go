@@ -0,0 +1,146 @@
+package interceptors
+
+import (
+	"context"
+	"gRPC/internal/lib/sl"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// Chain combines interceptors into one, the first one being the outermost.
+//
+// It is used by transports which are not a grpc.Server themselves (HTTP gateway)
+// so they run exactly the same interceptors as gRPC calls do.
+func Chain(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, current := interceptors[i], next
+			next = func(ctx context.Context, req any) (any, error) {
+				return interceptor(ctx, req, info, current)
+			}
+		}
+
+		return next(ctx, req)
+	}
+}
+
+// ChainStream is Chain of stream interceptors
+func ChainStream(interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, current := interceptors[i], next
+			next = func(srv any, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, current)
+			}
+		}
+
+		return next(srv, ss)
+	}
+}
+
+// Logging logs every call with its status code and duration
+func Logging(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		log := log.With(
+			slog.String("method", info.FullMethod),
+			slog.String("code", status.Code(err).String()),
+			slog.Duration("duration", time.Since(start)),
+		)
+
+		if err != nil {
+			log.Warn("request failed", sl.Err(err))
+		} else {
+			log.Info("request handled")
+		}
+
+		return resp, err
+	}
+}
+
+// Recovery turns a panic inside a handler into codes.Internal error
+func Recovery(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				log.Error("panic recovered",
+					slog.String("method", info.FullMethod),
+					slog.Any("panic", p),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				err = status.Error(codes.Internal, "Internal error")
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// Timeout limits how long a single call may run
+func Timeout(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if timeout <= 0 {
+			return handler(ctx, req)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return handler(ctx, req)
+	}
+}
+
+// AuthRequirement is how recently and how strongly the caller must have authenticated,
+// zero fields are not required
+type AuthRequirement struct {
+	MaxAge time.Duration
+	MinACR string
+}
+
+// AuthChecker returns status error unless the caller of ctx meets requirement
+type AuthChecker interface {
+	CheckAuth(ctx context.Context, requirement AuthRequirement) error
+}
+
+// RequireAuth rejects calls of methods listed in policy, by their full names,
+// when the caller does not meet the requirement of the method
+func RequireAuth(policy map[string]AuthRequirement, checker AuthChecker) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		requirement, ok := policy[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		if err := checker.CheckAuth(ctx, requirement); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// RequireAuthStream is RequireAuth for streaming methods
+func RequireAuthStream(policy map[string]AuthRequirement, checker AuthChecker) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		requirement, ok := policy[info.FullMethod]
+		if !ok {
+			return handler(srv, ss)
+		}
+
+		if err := checker.CheckAuth(ss.Context(), requirement); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,344 @@
+package authhttp
+
+import (
+	authgrpc "gRPC/internal/grpc/auth"
+	"gRPC/internal/http/gateway"
+	ssov5 "github.com/LeeAntonV/Protos/gen/go/sso"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// service is the gRPC service name the endpoints are mapped to
+const service = "/auth.Auth/"
+
+// extensions is the prefix of full method names of ExtensionsService
+const extensions = "/" + authgrpc.ExtensionsService + "/"
+
+// Register exposes Auth RPCs as JSON endpoints on mux.
+//
+// Every call goes through interceptor under the same full method name
+// as the corresponding gRPC call.
+func Register(mux *http.ServeMux, auth ssov5.AuthServer, interceptor grpc.UnaryServerInterceptor) {
+	mux.Handle("POST /v1/auth/register",
+		gateway.Unary(interceptor, service+"Register", auth.Register, nil))
+	mux.Handle("POST /v1/auth/login",
+		gateway.Unary(interceptor, service+"Login", auth.Login, nil))
+	mux.Handle("POST /v1/auth/code",
+		gateway.Unary(interceptor, service+"ValidCode", auth.ValidCode, nil))
+	mux.Handle("GET /v1/users/{user_id}/admin",
+		gateway.Unary(interceptor, service+"IsAdmin", auth.IsAdmin, bindUserID))
+}
+
+// RegisterPasswords exposes password change and reset RPCs as JSON endpoints on mux
+func RegisterPasswords(mux *http.ServeMux, passwords *authgrpc.PasswordServer, interceptor grpc.UnaryServerInterceptor) {
+	mux.Handle("POST /v1/auth/password/change",
+		gateway.Unary(interceptor, extensions+"ChangePassword", passwords.ChangePassword, nil))
+	mux.Handle("POST /v1/auth/password/forgot",
+		gateway.Unary(interceptor, extensions+"RequestPasswordReset", passwords.RequestPasswordReset, nil))
+	mux.Handle("POST /v1/auth/password/reset",
+		gateway.Unary(interceptor, extensions+"ResetPassword", passwords.ResetPassword, nil))
+}
+
+// RegisterPasswordless exposes passwordless login RPCs as JSON endpoints on mux
+func RegisterPasswordless(
+	mux *http.ServeMux, passwordless *authgrpc.PasswordlessServer, interceptor grpc.UnaryServerInterceptor,
+) {
+	mux.Handle("POST /v1/auth/passwordless/start",
+		gateway.Unary(interceptor, extensions+"StartPasswordlessLogin", passwordless.StartPasswordlessLogin, nil))
+	mux.Handle("POST /v1/auth/passwordless/complete",
+		gateway.Unary(interceptor, extensions+"CompletePasswordlessLogin", passwordless.CompletePasswordlessLogin, nil))
+}
+
+// RegisterPasskeys exposes passkey management and login RPCs as JSON endpoints on mux
+func RegisterPasskeys(mux *http.ServeMux, passkeys *authgrpc.PasskeysServer, interceptor grpc.UnaryServerInterceptor) {
+	mux.Handle("POST /v1/passkeys/registration/begin",
+		gateway.Unary(interceptor, extensions+"BeginPasskeyRegistration", passkeys.BeginPasskeyRegistration, nil))
+	mux.Handle("POST /v1/passkeys/registration/finish",
+		gateway.Unary(interceptor, extensions+"FinishPasskeyRegistration", passkeys.FinishPasskeyRegistration, nil))
+	mux.Handle("GET /v1/passkeys",
+		gateway.Unary(interceptor, extensions+"ListPasskeys", passkeys.ListPasskeys, nil))
+	mux.Handle("DELETE /v1/passkeys/{passkey_id}",
+		gateway.Unary(interceptor, extensions+"DeletePasskey", passkeys.DeletePasskey,
+			func(r *http.Request, req *authgrpc.DeletePasskeyRequest) error {
+				return pathInt64(r, "passkey_id", &req.PasskeyId)
+			}))
+	mux.Handle("POST /v1/auth/passkey/begin",
+		gateway.Unary(interceptor, extensions+"BeginPasskeyLogin", passkeys.BeginPasskeyLogin, nil))
+	mux.Handle("POST /v1/auth/passkey/finish",
+		gateway.Unary(interceptor, extensions+"FinishPasskeyLogin", passkeys.FinishPasskeyLogin, nil))
+}
+
+// RegisterPhones exposes phone verification and login with codes sent to phones as JSON endpoints on mux
+func RegisterPhones(mux *http.ServeMux, phones *authgrpc.PhonesServer, interceptor grpc.UnaryServerInterceptor) {
+	mux.Handle("GET /v1/phone",
+		gateway.Unary(interceptor, extensions+"GetPhone", phones.GetPhone, nil))
+	mux.Handle("PUT /v1/phone",
+		gateway.Unary(interceptor, extensions+"StartPhoneVerification", phones.StartPhoneVerification, nil))
+	mux.Handle("POST /v1/phone/verify",
+		gateway.Unary(interceptor, extensions+"VerifyPhone", phones.VerifyPhone, nil))
+	mux.Handle("DELETE /v1/phone",
+		gateway.Unary(interceptor, extensions+"DeletePhone", phones.DeletePhone, nil))
+	mux.Handle("POST /v1/auth/sms/begin",
+		gateway.Unary(interceptor, extensions+"BeginSMSLogin", phones.BeginSMSLogin, nil))
+	mux.Handle("POST /v1/auth/sms/finish",
+		gateway.Unary(interceptor, extensions+"FinishSMSLogin", phones.FinishSMSLogin, nil))
+}
+
+// RegisterStepUp exposes Reauthenticate RPC as JSON endpoint on mux
+func RegisterStepUp(mux *http.ServeMux, stepUp *authgrpc.StepUpServer, interceptor grpc.UnaryServerInterceptor) {
+	mux.Handle("POST /v1/auth/reauthenticate",
+		gateway.Unary(interceptor, extensions+"Reauthenticate", stepUp.Reauthenticate, nil))
+}
+
+// RegisterUserInfo exposes UserInfo RPC as JSON endpoint on mux
+func RegisterUserInfo(mux *http.ServeMux, userInfo *authgrpc.UserInfoServer, interceptor grpc.UnaryServerInterceptor) {
+	mux.Handle("GET /v1/userinfo",
+		gateway.Unary(interceptor, extensions+"UserInfo", userInfo.UserInfo, nil))
+}
+
+// RegisterSessions exposes session management RPCs as JSON endpoints on mux
+func RegisterSessions(mux *http.ServeMux, sessions *authgrpc.SessionsServer, interceptor grpc.UnaryServerInterceptor) {
+	mux.Handle("GET /v1/sessions",
+		gateway.Unary(interceptor, extensions+"ListSessions", sessions.ListSessions, nil))
+	mux.Handle("DELETE /v1/sessions/{session_id}",
+		gateway.Unary(interceptor, extensions+"RevokeSession", sessions.RevokeSession, bindSessionID))
+	mux.Handle("POST /v1/sessions/revoke-others",
+		gateway.Unary(interceptor, extensions+"RevokeOtherSessions", sessions.RevokeOtherSessions, nil))
+
+	mux.Handle("GET /v1/users/{user_id}/sessions",
+		gateway.Unary(interceptor, extensions+"ListUserSessions", sessions.ListUserSessions,
+			func(r *http.Request, req *authgrpc.ListUserSessionsRequest) error {
+				return pathUserID(r, &req.UserId)
+			}))
+	mux.Handle("DELETE /v1/users/{user_id}/sessions/{session_id}",
+		gateway.Unary(interceptor, extensions+"RevokeUserSession", sessions.RevokeUserSession,
+			func(r *http.Request, req *authgrpc.RevokeUserSessionRequest) error {
+				req.SessionId = r.PathValue("session_id")
+				return pathUserID(r, &req.UserId)
+			}))
+	mux.Handle("DELETE /v1/users/{user_id}/sessions",
+		gateway.Unary(interceptor, extensions+"RevokeUserSessions", sessions.RevokeUserSessions,
+			func(r *http.Request, req *authgrpc.RevokeUserSessionsRequest) error {
+				return pathUserID(r, &req.UserId)
+			}))
+}
+
+// RegisterAudit exposes audit log RPCs as JSON endpoints on mux
+func RegisterAudit(
+	mux *http.ServeMux, audit *authgrpc.AuditServer,
+	interceptor grpc.UnaryServerInterceptor, streamInterceptor grpc.StreamServerInterceptor,
+) {
+	mux.Handle("GET /v1/audit/events",
+		gateway.Unary(interceptor, extensions+"ListAuditEvents", audit.ListAuditEvents,
+			func(r *http.Request, req *authgrpc.ListAuditEventsRequest) error {
+				q := r.URL.Query()
+				req.Cursor = q.Get("cursor")
+
+				if v := q.Get("limit"); v != "" {
+					limit, err := strconv.ParseInt(v, 10, 32)
+					if err != nil {
+						return status.Error(codes.InvalidArgument, "invalid limit")
+					}
+
+					req.Limit = int32(limit)
+				}
+
+				return bindAuditFilter(r, &req.AuditFilter)
+			}))
+	mux.Handle("GET /v1/audit/events:export",
+		gateway.ServerStream(streamInterceptor, extensions+"ExportAuditEvents", audit.ExportAuditEvents,
+			func(r *http.Request, req *authgrpc.ExportAuditEventsRequest) error {
+				return bindAuditFilter(r, &req.AuditFilter)
+			}))
+	mux.Handle("POST /v1/audit/verify",
+		gateway.Unary(interceptor, extensions+"VerifyAuditChain", audit.VerifyAuditChain, nil))
+}
+
+// RegisterWebhooks exposes webhook management RPCs as JSON endpoints on mux
+func RegisterWebhooks(mux *http.ServeMux, webhooks *authgrpc.WebhooksServer, interceptor grpc.UnaryServerInterceptor) {
+	mux.Handle("POST /v1/apps/{app_id}/webhooks",
+		gateway.Unary(interceptor, extensions+"CreateWebhook", webhooks.CreateWebhook,
+			func(r *http.Request, req *authgrpc.CreateWebhookRequest) error {
+				return pathAppID(r, &req.AppId)
+			}))
+	mux.Handle("GET /v1/apps/{app_id}/webhooks",
+		gateway.Unary(interceptor, extensions+"ListWebhooks", webhooks.ListWebhooks,
+			func(r *http.Request, req *authgrpc.ListWebhooksRequest) error {
+				return pathAppID(r, &req.AppId)
+			}))
+	mux.Handle("DELETE /v1/apps/{app_id}/webhooks/{webhook_id}",
+		gateway.Unary(interceptor, extensions+"DeleteWebhook", webhooks.DeleteWebhook,
+			func(r *http.Request, req *authgrpc.DeleteWebhookRequest) error {
+				if err := pathInt64(r, "webhook_id", &req.WebhookId); err != nil {
+					return err
+				}
+
+				return pathAppID(r, &req.AppId)
+			}))
+	mux.Handle("GET /v1/webhooks/{webhook_id}/deliveries",
+		gateway.Unary(interceptor, extensions+"ListWebhookDeliveries", webhooks.ListWebhookDeliveries,
+			func(r *http.Request, req *authgrpc.ListWebhookDeliveriesRequest) error {
+				if v := r.URL.Query().Get("limit"); v != "" {
+					limit, err := strconv.ParseInt(v, 10, 32)
+					if err != nil {
+						return status.Error(codes.InvalidArgument, "invalid limit")
+					}
+
+					req.Limit = int32(limit)
+				}
+
+				return pathInt64(r, "webhook_id", &req.WebhookId)
+			}))
+	mux.Handle("POST /v1/webhooks/{webhook_id}/test",
+		gateway.Unary(interceptor, extensions+"TestWebhook", webhooks.TestWebhook,
+			func(r *http.Request, req *authgrpc.TestWebhookRequest) error {
+				return pathInt64(r, "webhook_id", &req.WebhookId)
+			}))
+	mux.Handle("POST /v1/webhook-deliveries/{delivery_id}/redeliver",
+		gateway.Unary(interceptor, extensions+"RedeliverWebhook", webhooks.RedeliverWebhook,
+			func(r *http.Request, req *authgrpc.RedeliverWebhookRequest) error {
+				return pathInt64(r, "delivery_id", &req.DeliveryId)
+			}))
+}
+
+// RegisterServiceAccounts exposes service account RPCs as JSON endpoints on mux
+func RegisterServiceAccounts(
+	mux *http.ServeMux, accounts *authgrpc.ServiceAccountsServer, interceptor grpc.UnaryServerInterceptor,
+) {
+	mux.Handle("POST /v1/apps/{app_id}/service-accounts",
+		gateway.Unary(interceptor, extensions+"CreateServiceAccount", accounts.CreateServiceAccount,
+			func(r *http.Request, req *authgrpc.CreateServiceAccountRequest) error {
+				return pathAppID(r, &req.AppId)
+			}))
+	mux.Handle("GET /v1/apps/{app_id}/service-accounts",
+		gateway.Unary(interceptor, extensions+"ListServiceAccounts", accounts.ListServiceAccounts,
+			func(r *http.Request, req *authgrpc.ListServiceAccountsRequest) error {
+				return pathAppID(r, &req.AppId)
+			}))
+	mux.Handle("POST /v1/service-accounts/{service_account_id}/rotate",
+		gateway.Unary(interceptor, extensions+"RotateServiceAccountSecret", accounts.RotateServiceAccountSecret,
+			func(r *http.Request, req *authgrpc.RotateServiceAccountSecretRequest) error {
+				return pathInt64(r, "service_account_id", &req.ServiceAccountId)
+			}))
+	mux.Handle("POST /v1/service-accounts/{service_account_id}/disable",
+		gateway.Unary(interceptor, extensions+"DisableServiceAccount", accounts.DisableServiceAccount,
+			func(r *http.Request, req *authgrpc.DisableServiceAccountRequest) error {
+				return pathInt64(r, "service_account_id", &req.ServiceAccountId)
+			}))
+	mux.Handle("POST /v1/service-accounts/token",
+		gateway.Unary(interceptor, extensions+"IssueServiceToken", accounts.IssueServiceToken, nil))
+}
+
+// RegisterDevice exposes device authorization RPCs as JSON endpoints on mux
+func RegisterDevice(mux *http.ServeMux, device *authgrpc.DeviceServer, interceptor grpc.UnaryServerInterceptor) {
+	mux.Handle("POST /v1/device/authorize",
+		gateway.Unary(interceptor, extensions+"StartDeviceAuthorization", device.StartDeviceAuthorization, nil))
+	mux.Handle("POST /v1/device/approve",
+		gateway.Unary(interceptor, extensions+"ApproveDevice", device.ApproveDevice, nil))
+	mux.Handle("POST /v1/device/token",
+		gateway.Unary(interceptor, extensions+"PollDeviceToken", device.PollDeviceToken, nil))
+}
+
+// RegisterTokens exposes scoped login, token validation and token exchange RPCs as JSON endpoints on mux
+func RegisterTokens(mux *http.ServeMux, tokens *authgrpc.TokensServer, interceptor grpc.UnaryServerInterceptor) {
+	mux.Handle("POST /v1/auth/login:scoped",
+		gateway.Unary(interceptor, extensions+"LoginWithScope", tokens.LoginWithScope, nil))
+	mux.Handle("POST /v1/tokens:validate",
+		gateway.Unary(interceptor, extensions+"ValidateToken", tokens.ValidateToken, nil))
+	mux.Handle("POST /v1/tokens:exchange",
+		gateway.Unary(interceptor, extensions+"ExchangeToken", tokens.ExchangeToken, nil))
+}
+
+// RegisterImpersonation exposes admin impersonation RPC as JSON endpoint on mux
+func RegisterImpersonation(
+	mux *http.ServeMux, impersonation *authgrpc.ImpersonationServer, interceptor grpc.UnaryServerInterceptor,
+) {
+	mux.Handle("POST /v1/users:impersonate",
+		gateway.Unary(interceptor, extensions+"Impersonate", impersonation.Impersonate, nil))
+}
+
+// RegisterEvents exposes event watching as newline delimited JSON stream on mux
+func RegisterEvents(mux *http.ServeMux, events *authgrpc.EventsServer, interceptor grpc.StreamServerInterceptor) {
+	mux.Handle("GET /v1/events:watch",
+		gateway.ServerStream(interceptor, extensions+"WatchAuthEvents", events.WatchAuthEvents,
+			func(r *http.Request, req *authgrpc.WatchAuthEventsRequest) error {
+				q := r.URL.Query()
+				req.Cursor = q.Get("cursor")
+
+				if v := q.Get("types"); v != "" {
+					req.Types = strings.Split(v, ",")
+				}
+
+				return nil
+			}))
+}
+
+// bindAuditFilter reads filter from query: user_id, type and RFC 3339 since and until
+func bindAuditFilter(r *http.Request, filter *authgrpc.AuditFilter) error {
+	q := r.URL.Query()
+
+	filter.Type = q.Get("type")
+
+	if v := q.Get("user_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, "invalid user_id")
+		}
+
+		filter.UserId = id
+	}
+
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := q.Get(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return status.Errorf(codes.InvalidArgument, "invalid %s", name)
+			}
+
+			*t = parsed
+		}
+	}
+
+	return nil
+}
+
+func bindSessionID(r *http.Request, req *authgrpc.RevokeSessionRequest) error {
+	req.SessionId = r.PathValue("session_id")
+
+	return nil
+}
+
+func bindUserID(r *http.Request, req *ssov5.IsAdminRequest) error {
+	return pathUserID(r, &req.UserId)
+}
+
+func pathUserID(r *http.Request, userID *int64) error {
+	return pathInt64(r, "user_id", userID)
+}
+
+func pathAppID(r *http.Request, appID *int32) error {
+	id, err := strconv.ParseInt(r.PathValue("app_id"), 10, 32)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid app_id")
+	}
+
+	*appID = int32(id)
+
+	return nil
+}
+
+func pathInt64(r *http.Request, name string, v *int64) error {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid %s", name)
+	}
+
+	*v = id
+
+	return nil
+}
@@ -0,0 +1,325 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// maxBodySize limits the size of JSON request body
+const maxBodySize = 1 << 20
+
+// forwardedHeaders are copied from HTTP request into incoming gRPC metadata,
+// so interceptors and handlers see the same values on both transports.
+var forwardedHeaders = []string{
+	"authorization",
+	"user-agent",
+	"x-forwarded-for",
+	"x-request-id",
+	"x-device-name",
+}
+
+var (
+	marshaler   = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+	unmarshaler = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// Binder fills request fields which are not part of JSON body, e.g. path values
+type Binder[Req any] func(r *http.Request, req *Req) error
+
+// Unary adapts gRPC style unary handler to http.Handler.
+//
+// Request body is decoded into Req, the call goes through interceptor
+// as if it was received by gRPC server under fullMethod, and the result
+// (or error, mapped from its gRPC status) is written as JSON.
+func Unary[Req any, Resp any](
+	interceptor grpc.UnaryServerInterceptor,
+	fullMethod string,
+	call func(context.Context, *Req) (Resp, error),
+	bind Binder[Req],
+) http.Handler {
+	info := &grpc.UnaryServerInfo{FullMethod: fullMethod}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := new(Req)
+
+		if err := decode(r, req); err != nil {
+			WriteError(w, status.Error(codes.InvalidArgument, "invalid request body"))
+			return
+		}
+
+		if bind != nil {
+			if err := bind(r, req); err != nil {
+				WriteError(w, err)
+				return
+			}
+		}
+
+		handler := func(ctx context.Context, req any) (any, error) {
+			return call(ctx, req.(*Req))
+		}
+
+		ctx := IncomingContext(r)
+
+		var (
+			resp any
+			err  error
+		)
+		if interceptor != nil {
+			resp, err = interceptor(ctx, req, info, handler)
+		} else {
+			resp, err = handler(ctx, req)
+		}
+
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		WriteJSON(w, http.StatusOK, resp)
+	})
+}
+
+// ServerStream adapts gRPC style server streaming handler to http.Handler.
+//
+// The call goes through interceptor as if it was received by gRPC server under fullMethod.
+// Messages passed to send are written as newline delimited JSON and flushed
+// one by one. Error returned before the first message is written as usual,
+// later errors are written as the last line {"error": {"code": ..., "message": ...}}.
+// Streams may outlive the server write timeout, it is lifted for them.
+func ServerStream[Req any, Msg any](
+	interceptor grpc.StreamServerInterceptor,
+	fullMethod string,
+	call func(ctx context.Context, req *Req, send func(Msg) error) error,
+	bind Binder[Req],
+) http.Handler {
+	info := &grpc.StreamServerInfo{FullMethod: fullMethod, IsServerStream: true}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := new(Req)
+
+		if err := decode(r, req); err != nil {
+			WriteError(w, status.Error(codes.InvalidArgument, "invalid request body"))
+			return
+		}
+
+		if bind != nil {
+			if err := bind(r, req); err != nil {
+				WriteError(w, err)
+				return
+			}
+		}
+
+		ctx := IncomingContext(r)
+
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		flusher, _ := w.(http.Flusher)
+		enc := json.NewEncoder(w)
+		started := false
+
+		send := func(msg Msg) error {
+			if !started {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				w.WriteHeader(http.StatusOK)
+				started = true
+			}
+
+			if err := enc.Encode(msg); err != nil {
+				return err
+			}
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+
+			return nil
+		}
+
+		handler := func(_ any, stream grpc.ServerStream) error {
+			return call(stream.Context(), req, func(msg Msg) error {
+				return stream.SendMsg(msg)
+			})
+		}
+
+		stream := &serverStream{ctx: ctx, send: func(msg any) error {
+			return send(msg.(Msg))
+		}}
+
+		var err error
+		if interceptor != nil {
+			err = interceptor(nil, stream, info, handler)
+		} else {
+			err = handler(nil, stream)
+		}
+
+		switch {
+		case err != nil && !started:
+			WriteError(w, err)
+		case err != nil:
+			st := status.Convert(err)
+			_ = enc.Encode(map[string]any{"error": map[string]any{"code": st.Code(), "message": st.Message()}})
+		case !started:
+			// empty stream
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// serverStream is grpc.ServerStream of HTTP request, its request is already decoded
+// and headers are written with the first message
+type serverStream struct {
+	ctx  context.Context
+	send func(msg any) error
+}
+
+func (s *serverStream) SetHeader(metadata.MD) error  { return nil }
+func (s *serverStream) SendHeader(metadata.MD) error { return nil }
+func (s *serverStream) SetTrailer(metadata.MD)       {}
+func (s *serverStream) Context() context.Context     { return s.ctx }
+func (s *serverStream) SendMsg(m any) error          { return s.send(m) }
+func (s *serverStream) RecvMsg(any) error            { return io.EOF }
+
+// IncomingContext returns request context carrying gRPC metadata and peer
+// built from HTTP headers and remote address.
+func IncomingContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	for _, key := range forwardedHeaders {
+		if v := r.Header.Values(key); len(v) > 0 {
+			md.Set(key, v...)
+		}
+	}
+
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: addr})
+	}
+
+	return ctx
+}
+
+// WriteJSON writes v with given status code.
+// Protobuf messages are encoded with protojson, everything else with encoding/json.
+func WriteJSON(w http.ResponseWriter, code int, v any) {
+	var (
+		body []byte
+		err  error
+	)
+
+	if msg, ok := v.(proto.Message); ok {
+		body, err = marshaler.Marshal(msg)
+	} else {
+		body, err = json.Marshal(v)
+	}
+
+	if err != nil {
+		code = http.StatusInternalServerError
+		body = []byte(`{"code":13,"message":"failed to encode response"}`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
+}
+
+type fieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// WriteError writes err as {"code": ..., "message": ...} with HTTP status
+// derived from its gRPC status code. BadRequest details are added as
+// "field_violations", ErrorInfo ones as "reason" and "metadata".
+func WriteError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+
+	body := struct {
+		Code            codes.Code        `json:"code"`
+		Message         string            `json:"message"`
+		FieldViolations []fieldViolation  `json:"field_violations,omitempty"`
+		Reason          string            `json:"reason,omitempty"`
+		Metadata        map[string]string `json:"metadata,omitempty"`
+	}{
+		Code:    st.Code(),
+		Message: st.Message(),
+	}
+
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				body.FieldViolations = append(body.FieldViolations, fieldViolation{Field: v.GetField(), Description: v.GetDescription()})
+			}
+		case *errdetails.ErrorInfo:
+			body.Reason, body.Metadata = d.GetReason(), d.GetMetadata()
+		}
+	}
+
+	WriteJSON(w, HTTPStatusFromCode(st.Code()), body)
+}
+
+// HTTPStatusFromCode maps gRPC status code to HTTP status code
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		// as grpc-gateway does, 412 is meant for conditional request headers
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func decode(r *http.Request, v any) error {
+	if r.Body == nil || r.Method == http.MethodGet || r.Method == http.MethodDelete {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		return err
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+
+	if msg, ok := v.(proto.Message); ok {
+		return unmarshaler.Unmarshal(body, msg)
+	}
+
+	return json.Unmarshal(body, v)
+}
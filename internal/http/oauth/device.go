@@ -0,0 +1,230 @@
+package oauthhttp
+
+import (
+	"errors"
+	"gRPC/internal/http/gateway"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/services/auth"
+	"net/http"
+	"strconv"
+)
+
+const (
+	deviceAuthorizationPath = "/oauth/device_authorization"
+	devicePath              = "/oauth/device"
+
+	grantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// deviceAuthorizationResponse is RFC 8628 section 3.2 response
+type deviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+type devicePage struct {
+	Action   string
+	UserCode string
+	Error    string
+	// Message replaces the form once the user decided
+	Message string
+}
+
+// deviceAuthorization starts device flow, the device shows returned user code to the user
+func (s *server) deviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", "invalid form")
+		return
+	}
+
+	clientIDParam, _, basic := clientCredentials(r)
+
+	clientID, err := strconv.Atoi(clientIDParam)
+	if err != nil {
+		writeClientError(w, basic)
+		return
+	}
+
+	device, err := s.authorizer.StartDeviceAuthorization(r.Context(), clientID, r.PostForm.Get("scope"))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidClient):
+			writeClientError(w, basic)
+		case errors.Is(err, auth.ErrInvalidScope):
+			writeTokenError(w, http.StatusBadRequest, "invalid_scope", "")
+		case errors.Is(err, auth.ErrUnavailable):
+			s.log.Error("failed to start device authorization", sl.Err(err))
+			writeTokenError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "")
+		default:
+			s.log.Error("failed to start device authorization", sl.Err(err))
+			writeTokenError(w, http.StatusInternalServerError, "server_error", "")
+		}
+
+		return
+	}
+
+	writeNoStore(w)
+	gateway.WriteJSON(w, http.StatusOK, deviceAuthorizationResponse{
+		DeviceCode:              device.DeviceCode,
+		UserCode:                device.UserCode,
+		VerificationURI:         device.VerificationURI,
+		VerificationURIComplete: device.VerificationURIComplete,
+		ExpiresIn:               int64(device.ExpiresIn.Seconds()),
+		Interval:                int64(device.Interval.Seconds()),
+	})
+}
+
+// devicePage asks the user for the code shown on device, then for consent to connect it
+func (s *server) devicePage(w http.ResponseWriter, r *http.Request) {
+	userCode := r.URL.Query().Get("user_code")
+	if userCode == "" {
+		render(w, http.StatusOK, "device.html", devicePage{Action: devicePath})
+		return
+	}
+
+	req, ok := s.deviceRequest(w, r, userCode)
+	if !ok {
+		return
+	}
+
+	s.renderDeviceConsent(w, req)
+}
+
+// device handles device consent form, the device gets its token when the user
+// allows access with valid credentials. The code is not looked up before, so unknown
+// codes and wrong credentials get the same answer.
+func (s *server) device(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+	if err := r.ParseForm(); err != nil {
+		renderError(w, http.StatusBadRequest, "Invalid form.")
+		return
+	}
+
+	form := r.PostForm
+	userCode := form.Get("user_code")
+
+	// denying needs credentials as well, or anyone who sees the code could deny it
+	approve := form.Get("decision") == "allow"
+	decide := s.authorizer.AuthorizeDevice
+	if !approve {
+		decide = s.authorizer.DenyDevice
+	}
+
+	err := decide(r.Context(), userCode, form.Get("email"), form.Get("password"), clientInfo(r))
+	if err != nil {
+		var changeRequired *auth.PasswordChangeRequiredError
+
+		switch {
+		case errors.Is(err, auth.InvalidCredentials), errors.Is(err, auth.ErrInvalidUserCode):
+			renderDeviceCode(w, http.StatusUnauthorized, userCode,
+				"The code is invalid or expired, or the email or password is wrong.")
+		case errors.Is(err, auth.ErrUserDisabled):
+			renderDeviceCode(w, http.StatusForbidden, userCode, "This account is disabled.")
+		case errors.As(err, &changeRequired):
+			renderDeviceCode(w, http.StatusForbidden, userCode, "Your password has expired. Change it and try again.")
+		case errors.Is(err, auth.ErrTooManyAttempts):
+			renderError(w, http.StatusTooManyRequests, "Too many attempts, try again later.")
+		default:
+			s.log.Error("failed to decide on device", sl.Err(err))
+			renderError(w, http.StatusInternalServerError, "Internal error.")
+		}
+
+		return
+	}
+
+	if !approve {
+		render(w, http.StatusOK, "device.html", devicePage{Message: "Access denied. You may close this page."})
+		return
+	}
+
+	render(w, http.StatusOK, "device.html", devicePage{Message: "Your device is connected. You may close this page."})
+}
+
+// deviceRequest returns pending device authorization of user code or shows the code form again
+func (s *server) deviceRequest(w http.ResponseWriter, r *http.Request, userCode string) (auth.DeviceRequest, bool) {
+	req, err := s.authorizer.DeviceRequest(r.Context(), userCode, clientInfo(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidUserCode):
+			renderDeviceCode(w, http.StatusBadRequest, userCode, "The code is invalid or expired.")
+			return auth.DeviceRequest{}, false
+		case errors.Is(err, auth.ErrTooManyAttempts):
+			renderError(w, http.StatusTooManyRequests, "Too many attempts, try again later.")
+			return auth.DeviceRequest{}, false
+		}
+
+		s.log.Error("failed to get device request", sl.Err(err))
+		renderError(w, http.StatusInternalServerError, "Internal error.")
+
+		return auth.DeviceRequest{}, false
+	}
+
+	return req, true
+}
+
+// renderDeviceCode asks for the code shown on device again
+func renderDeviceCode(w http.ResponseWriter, code int, userCode string, message string) {
+	render(w, code, "device.html", devicePage{Action: devicePath, UserCode: userCode, Error: message})
+}
+
+func (s *server) renderDeviceConsent(w http.ResponseWriter, req auth.DeviceRequest) {
+	render(w, http.StatusOK, "authorize.html", consentPage{
+		AppName: req.App.Name,
+		Scopes:  req.Scopes,
+		Action:  devicePath,
+		Params:  map[string]string{"user_code": req.UserCode},
+		// the device is denied for the signed in user only
+		DenyNeedsCredentials: true,
+	})
+}
+
+// pollDeviceToken serves device_code grant, RFC 8628 section 3.4
+func (s *server) pollDeviceToken(w http.ResponseWriter, r *http.Request) {
+	clientIDParam, secret, basic := clientCredentials(r)
+
+	clientID, err := strconv.Atoi(clientIDParam)
+	if err != nil {
+		writeClientError(w, basic)
+		return
+	}
+
+	grant, err := s.authorizer.PollDeviceToken(r.Context(), auth.DeviceTokenRequest{
+		ClientID:     clientID,
+		ClientSecret: secret,
+		DeviceCode:   r.PostForm.Get("device_code"),
+		Audience:     r.PostForm.Get("audience"),
+	}, clientInfo(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrAuthorizationPending):
+			writeTokenError(w, http.StatusBadRequest, "authorization_pending", "")
+		case errors.Is(err, auth.ErrSlowDown):
+			writeTokenError(w, http.StatusBadRequest, "slow_down", "")
+		case errors.Is(err, auth.ErrAccessDenied):
+			writeTokenError(w, http.StatusBadRequest, "access_denied", "")
+		case errors.Is(err, auth.ErrExpiredToken):
+			writeTokenError(w, http.StatusBadRequest, "expired_token", "")
+		case errors.Is(err, auth.ErrInvalidClient):
+			writeClientError(w, basic)
+		case errors.Is(err, auth.ErrInvalidGrant):
+			writeTokenError(w, http.StatusBadRequest, "invalid_grant", "")
+		case errors.Is(err, auth.ErrInvalidAudience):
+			writeTokenError(w, http.StatusBadRequest, "invalid_target", "")
+		case errors.Is(err, auth.ErrUnavailable):
+			s.log.Error("failed to poll device token", sl.Err(err))
+			writeTokenError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "")
+		default:
+			s.log.Error("failed to poll device token", sl.Err(err))
+			writeTokenError(w, http.StatusInternalServerError, "server_error", "")
+		}
+
+		return
+	}
+
+	writeTokenGrant(w, grant)
+}
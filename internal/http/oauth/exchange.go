@@ -0,0 +1,93 @@
+package oauthhttp
+
+import (
+	"errors"
+	"gRPC/internal/http/gateway"
+	"gRPC/internal/lib/oauth"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/services/auth"
+	"net/http"
+)
+
+const (
+	grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	// tokenTypeAccessToken is the only token type exchanged and issued, RFC 8693 section 3
+	tokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// exchangeToken serves token exchange grant of service accounts acting on behalf of users,
+// the account authenticates like with client_credentials grant or with its actor_token
+func (s *server) exchangeToken(w http.ResponseWriter, r *http.Request) {
+	form := r.PostForm
+
+	clientID, secret, basic := clientCredentials(r)
+
+	assertion := form.Get("client_assertion")
+	if assertion != "" && form.Get("client_assertion_type") != assertionTypeJWTBearer {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", "unsupported client_assertion_type")
+		return
+	}
+
+	if form.Get("subject_token") == "" || form.Get("subject_token_type") != tokenTypeAccessToken {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", "subject_token of access_token type is required")
+		return
+	}
+
+	actorToken := form.Get("actor_token")
+	if actorToken != "" && form.Get("actor_token_type") != tokenTypeAccessToken {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", "actor_token must be of access_token type")
+		return
+	}
+
+	if clientID == "" && assertion == "" && actorToken == "" {
+		writeClientError(w, basic)
+		return
+	}
+
+	if t := form.Get("requested_token_type"); t != "" && t != tokenTypeAccessToken {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", "only access_token may be requested")
+		return
+	}
+
+	grant, err := s.authorizer.ExchangeToken(r.Context(), auth.TokenExchange{
+		ClientID:     clientID,
+		ClientSecret: secret,
+		Assertion:    assertion,
+		SubjectToken: form.Get("subject_token"),
+		ActorToken:   actorToken,
+		Scope:        form.Get("scope"),
+		Audience:     form.Get("audience"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidClient):
+			writeClientError(w, basic)
+		case errors.Is(err, auth.ErrInvalidRequest):
+			writeTokenError(w, http.StatusBadRequest, "invalid_request", "invalid subject_token or actor_token")
+		case errors.Is(err, auth.ErrInvalidScope):
+			writeTokenError(w, http.StatusBadRequest, "invalid_scope", "")
+		case errors.Is(err, auth.ErrInvalidAudience):
+			writeTokenError(w, http.StatusBadRequest, "invalid_target", "")
+		case errors.Is(err, auth.ErrUserDisabled):
+			writeTokenError(w, http.StatusBadRequest, "invalid_grant", "user is disabled")
+		case errors.Is(err, auth.ErrUnavailable):
+			s.log.Error("failed to exchange token", sl.Err(err))
+			writeTokenError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "")
+		default:
+			s.log.Error("failed to exchange token", sl.Err(err))
+			writeTokenError(w, http.StatusInternalServerError, "server_error", "")
+		}
+
+		return
+	}
+
+	writeNoStore(w)
+	gateway.WriteJSON(w, http.StatusOK, tokenResponse{
+		AccessToken:     grant.AccessToken,
+		IssuedTokenType: tokenTypeAccessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(grant.ExpiresIn.Seconds()),
+		Scope:           oauth.FormatScope(grant.Scopes),
+	})
+}
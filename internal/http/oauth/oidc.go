@@ -0,0 +1,124 @@
+package oauthhttp
+
+import (
+	"errors"
+	"gRPC/internal/http/gateway"
+	"gRPC/internal/lib/keys"
+	"gRPC/internal/lib/oauth"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/services/auth"
+	"net/http"
+	"strings"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	jwksPath      = "/.well-known/jwks.json"
+	userInfoPath  = "/oauth/userinfo"
+)
+
+// discoveryDocument is OpenID Provider Metadata (OpenID Connect Discovery 1.0)
+type discoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	ResponseModesSupported            []string `json:"response_modes_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	// TokenEndpointAuthSigningAlgValuesSupported are algorithms of private_key_jwt assertions
+	TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	ClaimsSupported                            []string `json:"claims_supported"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported"`
+}
+
+func (s *server) discovery(w http.ResponseWriter, _ *http.Request) {
+	gateway.WriteJSON(w, http.StatusOK, discoveryDocument{
+		Issuer:                 s.issuer,
+		AuthorizationEndpoint:  s.issuer + authorizePath,
+		TokenEndpoint:          s.issuer + tokenPath,
+		UserInfoEndpoint:       s.issuer + userInfoPath,
+		JWKSURI:                s.issuer + jwksPath,
+		ScopesSupported:        []string{auth.ScopeOpenID, auth.ScopeProfile, auth.ScopeEmail},
+		ResponseTypesSupported: []string{"code"},
+		ResponseModesSupported: []string{"query"},
+		GrantTypesSupported: []string{
+			grantTypeAuthorizationCode, grantTypeClientCredentials, grantTypeDeviceCode, grantTypeTokenExchange,
+		},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{"RS256"},
+		TokenEndpointAuthMethodsSupported: []string{
+			"client_secret_basic", "client_secret_post", "private_key_jwt", "none",
+		},
+		TokenEndpointAuthSigningAlgValuesSupported: []string{"RS256"},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "at_hash", "email", "email_verified",
+		},
+		CodeChallengeMethodsSupported: []string{oauth.MethodS256},
+	})
+}
+
+func (s *server) jwks(w http.ResponseWriter, r *http.Request) {
+	jwks, err := s.authorizer.JSONWebKeys(r.Context())
+	if err != nil {
+		s.log.Error("failed to get signing keys", sl.Err(err))
+		gateway.WriteJSON(w, http.StatusInternalServerError, tokenError{Error: "server_error"})
+		return
+	}
+
+	gateway.WriteJSON(w, http.StatusOK, struct {
+		Keys []keys.JWK `json:"keys"`
+	}{Keys: jwks})
+}
+
+// userInfo returns claims about the owner of bearer access token (OpenID Connect Core 5.3)
+func (s *server) userInfo(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	caller, err := s.authorizer.Authenticate(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			writeBearerError(w, http.StatusUnauthorized, "invalid_token")
+			return
+		}
+
+		s.log.Error("failed to authenticate", sl.Err(err))
+		gateway.WriteJSON(w, http.StatusInternalServerError, tokenError{Error: "server_error"})
+		return
+	}
+
+	claims, err := s.authorizer.UserInfo(r.Context(), caller)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInsufficientScope):
+			writeBearerError(w, http.StatusForbidden, "insufficient_scope")
+		case errors.Is(err, auth.ErrInvalidToken):
+			writeBearerError(w, http.StatusUnauthorized, "invalid_token")
+		default:
+			s.log.Error("failed to get user info", sl.Err(err))
+			gateway.WriteJSON(w, http.StatusInternalServerError, tokenError{Error: "server_error"})
+		}
+
+		return
+	}
+
+	writeNoStore(w)
+	gateway.WriteJSON(w, http.StatusOK, claims)
+}
+
+// writeBearerError reports error of bearer token as RFC 6750 describes
+func writeBearerError(w http.ResponseWriter, code int, errCode string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="`+errCode+`"`)
+	gateway.WriteJSON(w, code, tokenError{Error: errCode})
+}
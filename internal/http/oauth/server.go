@@ -0,0 +1,533 @@
+// Package oauthhttp serves OAuth 2.0 authorization server endpoints:
+// authorization with consent page, device authorization with its verification page
+// and token endpoint for authorization code with PKCE, client credentials, device code
+// and token exchange grants, and OpenID Connect provider ones on top.
+package oauthhttp
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"gRPC/internal/http/gateway"
+	"gRPC/internal/lib/keys"
+	"gRPC/internal/lib/oauth"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/services/auth"
+	"html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	authorizePath = "/oauth/authorize"
+	tokenPath     = "/oauth/token"
+
+	grantTypeAuthorizationCode = "authorization_code"
+	grantTypeClientCredentials = "client_credentials"
+
+	// assertionTypeJWTBearer is the only client_assertion_type, RFC 7523 section 2.2
+	assertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	// maxFormSize limits the size of form posted to the endpoints
+	maxFormSize = 64 << 10
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+var templates = template.Must(template.ParseFS(templatesFS, "templates/*.html"))
+
+// authorizeParams are authorization request parameters carried through consent form
+var authorizeParams = []string{
+	"response_type", "client_id", "redirect_uri", "scope", "state", "code_challenge", "code_challenge_method", "nonce",
+}
+
+// Authorizer is the part of Auth service the endpoints are built on
+type Authorizer interface {
+	CheckAuthorization(ctx context.Context, req auth.AuthorizationRequest) (auth.Authorization, error)
+	Authorize(ctx context.Context, authz auth.Authorization, email string, pass string, client auth.ClientInfo) (string, error)
+	FinishSMSAuthorization(
+		ctx context.Context, authz auth.Authorization, challengeID string, code string, client auth.ClientInfo,
+	) (string, error)
+	ExchangeCode(ctx context.Context, req auth.CodeExchange) (auth.TokenGrant, error)
+	IssueServiceToken(ctx context.Context, req auth.ClientCredentials) (auth.TokenGrant, error)
+	ExchangeToken(ctx context.Context, req auth.TokenExchange) (auth.TokenGrant, error)
+
+	StartDeviceAuthorization(ctx context.Context, clientID int, scope string) (auth.DeviceAuthorization, error)
+	DeviceRequest(ctx context.Context, userCode string, client auth.ClientInfo) (auth.DeviceRequest, error)
+	AuthorizeDevice(ctx context.Context, userCode string, email string, pass string, client auth.ClientInfo) error
+	DenyDevice(ctx context.Context, userCode string, email string, pass string, client auth.ClientInfo) error
+	PollDeviceToken(ctx context.Context, req auth.DeviceTokenRequest, client auth.ClientInfo) (auth.TokenGrant, error)
+
+	Authenticate(ctx context.Context, token string) (auth.Principal, error)
+	UserInfo(ctx context.Context, caller auth.Principal) (map[string]any, error)
+	JSONWebKeys(ctx context.Context) ([]keys.JWK, error)
+}
+
+type server struct {
+	log        *slog.Logger
+	authorizer Authorizer
+	issuer     string
+}
+
+// Register exposes OAuth and OpenID Connect endpoints on mux,
+// issuer is the URL the endpoints are published under
+func Register(mux *http.ServeMux, log *slog.Logger, authorizer Authorizer, issuer string) {
+	s := &server{log: log, authorizer: authorizer, issuer: strings.TrimSuffix(issuer, "/")}
+
+	mux.HandleFunc("GET "+authorizePath, s.authorizePage)
+	mux.HandleFunc("POST "+authorizePath, s.authorize)
+	mux.HandleFunc("POST "+tokenPath, s.token)
+	mux.HandleFunc("POST "+deviceAuthorizationPath, s.deviceAuthorization)
+	mux.HandleFunc("GET "+devicePath, s.devicePage)
+	mux.HandleFunc("POST "+devicePath, s.device)
+
+	mux.HandleFunc("GET "+discoveryPath, s.discovery)
+	mux.HandleFunc("GET "+jwksPath, s.jwks)
+	mux.HandleFunc("GET "+userInfoPath, s.userInfo)
+	mux.HandleFunc("POST "+userInfoPath, s.userInfo)
+}
+
+// authorizePage validates authorization request and shows consent page
+func (s *server) authorizePage(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	authz, ok := s.checkAuthorization(w, r, params)
+	if !ok {
+		return
+	}
+
+	s.renderConsent(w, http.StatusOK, authz, params, "", "")
+}
+
+// authorize handles consent form, code is issued when user allows access with valid credentials.
+// Users with verified phone are asked for the code sent to it as well.
+func (s *server) authorize(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+	if err := r.ParseForm(); err != nil {
+		renderError(w, http.StatusBadRequest, "Invalid form.")
+		return
+	}
+
+	params := r.PostForm
+
+	authz, ok := s.checkAuthorization(w, r, params)
+	if !ok {
+		return
+	}
+
+	if params.Get("decision") != "allow" {
+		redirectError(w, r, authz.RedirectURI, params.Get("state"), "access_denied", "user denied access")
+		return
+	}
+
+	if challengeID := params.Get("challenge_id"); challengeID != "" {
+		s.finishSMSAuthorization(w, r, authz, params, challengeID)
+		return
+	}
+
+	email := params.Get("email")
+
+	code, err := s.authorizer.Authorize(r.Context(), authz, email, params.Get("password"), clientInfo(r))
+	if err != nil {
+		var (
+			changeRequired *auth.PasswordChangeRequiredError
+			secondFactor   *auth.SecondFactorRequiredError
+		)
+
+		switch {
+		case errors.As(err, &secondFactor):
+			renderSMSCode(w, http.StatusOK, authz, params, secondFactor.Challenge.ID, secondFactor.Challenge.Phone, "")
+		case errors.Is(err, auth.InvalidCredentials):
+			s.renderConsent(w, http.StatusUnauthorized, authz, params, email, "Invalid email or password.")
+		case errors.Is(err, auth.ErrUserDisabled):
+			s.renderConsent(w, http.StatusForbidden, authz, params, email, "This account is disabled.")
+		case errors.As(err, &changeRequired):
+			s.renderConsent(w, http.StatusForbidden, authz, params, email,
+				"Your password has expired. Change it and try again.")
+		case errors.Is(err, auth.ErrUnavailable):
+			s.log.Error("failed to authorize", sl.Err(err))
+			redirectError(w, r, authz.RedirectURI, params.Get("state"), "temporarily_unavailable", "")
+		default:
+			s.log.Error("failed to authorize", sl.Err(err))
+			redirectError(w, r, authz.RedirectURI, params.Get("state"), "server_error", "")
+		}
+
+		return
+	}
+
+	redirect(w, r, authz.RedirectURI, url.Values{"code": {code}}, params.Get("state"))
+}
+
+// finishSMSAuthorization issues code once the user enters the one sent to the phone,
+// expired challenges start over from the consent page
+func (s *server) finishSMSAuthorization(
+	w http.ResponseWriter, r *http.Request, authz auth.Authorization, params url.Values, challengeID string,
+) {
+	code, err := s.authorizer.FinishSMSAuthorization(r.Context(), authz, challengeID, params.Get("code"), clientInfo(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidCode):
+			renderSMSCode(w, http.StatusUnauthorized, authz, params, challengeID, params.Get("phone"), "Invalid code.")
+		case errors.Is(err, auth.ErrPhoneCodeNotFound), errors.Is(err, auth.ErrTooManyAttempts):
+			s.renderConsent(w, http.StatusUnauthorized, authz, params, "", "The code has expired, sign in again.")
+		case errors.Is(err, auth.ErrUserDisabled):
+			s.renderConsent(w, http.StatusForbidden, authz, params, "", "This account is disabled.")
+		case errors.Is(err, auth.ErrUnavailable):
+			s.log.Error("failed to authorize", sl.Err(err))
+			redirectError(w, r, authz.RedirectURI, params.Get("state"), "temporarily_unavailable", "")
+		default:
+			s.log.Error("failed to authorize", sl.Err(err))
+			redirectError(w, r, authz.RedirectURI, params.Get("state"), "server_error", "")
+		}
+
+		return
+	}
+
+	redirect(w, r, authz.RedirectURI, url.Values{"code": {code}}, params.Get("state"))
+}
+
+// checkAuthorization validates authorization request in params and reports
+// the error to the user or the client app when it is invalid
+func (s *server) checkAuthorization(w http.ResponseWriter, r *http.Request, params url.Values) (auth.Authorization, bool) {
+	clientID, err := strconv.Atoi(params.Get("client_id"))
+	if err != nil {
+		renderError(w, http.StatusBadRequest, "Unknown client.")
+		return auth.Authorization{}, false
+	}
+
+	authz, err := s.authorizer.CheckAuthorization(r.Context(), auth.AuthorizationRequest{
+		ResponseType:        params.Get("response_type"),
+		ClientID:            clientID,
+		RedirectURI:         params.Get("redirect_uri"),
+		Scope:               params.Get("scope"),
+		CodeChallenge:       params.Get("code_challenge"),
+		CodeChallengeMethod: params.Get("code_challenge_method"),
+		Nonce:               params.Get("nonce"),
+	})
+	if err == nil {
+		return authz, true
+	}
+
+	state := params.Get("state")
+
+	switch {
+	case errors.Is(err, auth.ErrInvalidClient):
+		renderError(w, http.StatusBadRequest, "Unknown client.")
+	case errors.Is(err, auth.ErrInvalidRedirectURI):
+		renderError(w, http.StatusBadRequest, "Redirect URI is not registered for the client.")
+	case errors.Is(err, auth.ErrUnsupportedResponseType):
+		redirectError(w, r, authz.RedirectURI, state, "unsupported_response_type", "only code response type is supported")
+	case errors.Is(err, auth.ErrInvalidScope):
+		redirectError(w, r, authz.RedirectURI, state, "invalid_scope", "")
+	case errors.Is(err, auth.ErrInvalidRequest):
+		redirectError(w, r, authz.RedirectURI, state, "invalid_request", "code_challenge with "+oauth.MethodS256+" method is required")
+	default:
+		s.log.Error("failed to check authorization request", sl.Err(err))
+		renderError(w, http.StatusInternalServerError, "Internal error.")
+	}
+
+	return auth.Authorization{}, false
+}
+
+type consentPage struct {
+	AppName string
+	Scopes  []string
+	Action  string
+	Params  map[string]string
+	Email   string
+	Error   string
+	// DenyNeedsCredentials makes the form validated on deny as well
+	DenyNeedsCredentials bool
+}
+
+func (s *server) renderConsent(
+	w http.ResponseWriter, code int, authz auth.Authorization, params url.Values, email string, message string,
+) {
+	page := consentPage{
+		AppName: authz.App.Name,
+		Scopes:  authz.Scopes,
+		Action:  authorizePath,
+		Params:  make(map[string]string, len(authorizeParams)),
+		Email:   email,
+		Error:   message,
+	}
+
+	for _, name := range authorizeParams {
+		if v := params.Get(name); v != "" {
+			page.Params[name] = v
+		}
+	}
+
+	render(w, code, "authorize.html", page)
+}
+
+type smsPage struct {
+	AppName string
+	Phone   string
+	Action  string
+	Params  map[string]string
+	Error   string
+}
+
+// renderSMSCode asks for the code sent to masked phone for challenge of authorization
+func renderSMSCode(
+	w http.ResponseWriter, code int, authz auth.Authorization, params url.Values, challengeID string, phone string, message string,
+) {
+	page := smsPage{
+		AppName: authz.App.Name,
+		Phone:   phone,
+		Action:  authorizePath,
+		Params:  map[string]string{"challenge_id": challengeID, "phone": phone},
+		Error:   message,
+	}
+
+	for _, name := range authorizeParams {
+		if v := params.Get(name); v != "" {
+			page.Params[name] = v
+		}
+	}
+
+	render(w, code, "sms.html", page)
+}
+
+func renderError(w http.ResponseWriter, code int, message string) {
+	render(w, code, "error.html", message)
+}
+
+func render(w http.ResponseWriter, code int, name string, data any) {
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Cache-Control", "no-store")
+	h.Set("X-Frame-Options", "DENY")
+	h.Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	h.Set("Referrer-Policy", "no-referrer")
+
+	w.WriteHeader(code)
+	_ = templates.ExecuteTemplate(w, name, data)
+}
+
+// redirect sends the user back to the client app with params and state added to redirectURI
+func redirect(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values, state string) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		renderError(w, http.StatusBadRequest, "Redirect URI is not registered for the client.")
+		return
+	}
+
+	q := u.Query()
+	for name, values := range params {
+		q[name] = values
+	}
+
+	if state != "" {
+		q.Set("state", state)
+	}
+
+	u.RawQuery = q.Encode()
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func redirectError(w http.ResponseWriter, r *http.Request, redirectURI string, state string, code string, description string) {
+	params := url.Values{"error": {code}}
+	if description != "" {
+		params.Set("error_description", description)
+	}
+
+	redirect(w, r, redirectURI, params, state)
+}
+
+// clientInfo describes the user agent the authorization came from
+func clientInfo(r *http.Request) auth.ClientInfo {
+	info := auth.ClientInfo{UserAgent: r.UserAgent()}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		info.IP = host
+	} else {
+		info.IP = r.RemoteAddr
+	}
+
+	return info
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	// IssuedTokenType is set by token exchange only
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	Scope           string `json:"scope,omitempty"`
+	IDToken         string `json:"id_token,omitempty"`
+}
+
+type tokenError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// token issues access token for the grant of grant_type.
+//
+// Client may authenticate with HTTP Basic or client_id and client_secret in the form,
+// public clients send client_id only.
+func (s *server) token(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", "invalid form")
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case grantTypeAuthorizationCode:
+		s.exchangeCode(w, r)
+	case grantTypeClientCredentials:
+		s.issueServiceToken(w, r)
+	case grantTypeDeviceCode:
+		s.pollDeviceToken(w, r)
+	case grantTypeTokenExchange:
+		s.exchangeToken(w, r)
+	default:
+		writeTokenError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+}
+
+// exchangeCode exchanges authorization code for access token
+func (s *server) exchangeCode(w http.ResponseWriter, r *http.Request) {
+	form := r.PostForm
+
+	clientIDParam, secret, basic := clientCredentials(r)
+
+	clientID, err := strconv.Atoi(clientIDParam)
+	if err != nil {
+		writeClientError(w, basic)
+		return
+	}
+
+	if form.Get("code") == "" || form.Get("code_verifier") == "" {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", "code and code_verifier are required")
+		return
+	}
+
+	grant, err := s.authorizer.ExchangeCode(r.Context(), auth.CodeExchange{
+		ClientID:     clientID,
+		ClientSecret: secret,
+		Code:         form.Get("code"),
+		RedirectURI:  form.Get("redirect_uri"),
+		CodeVerifier: form.Get("code_verifier"),
+		Audience:     form.Get("audience"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidClient):
+			writeClientError(w, basic)
+		case errors.Is(err, auth.ErrInvalidGrant):
+			writeTokenError(w, http.StatusBadRequest, "invalid_grant", "")
+		case errors.Is(err, auth.ErrInvalidAudience):
+			writeTokenError(w, http.StatusBadRequest, "invalid_target", "")
+		case errors.Is(err, auth.ErrUnavailable):
+			s.log.Error("failed to exchange code", sl.Err(err))
+			writeTokenError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "")
+		default:
+			s.log.Error("failed to exchange code", sl.Err(err))
+			writeTokenError(w, http.StatusInternalServerError, "server_error", "")
+		}
+
+		return
+	}
+
+	writeTokenGrant(w, grant)
+}
+
+// issueServiceToken serves client_credentials grant of service accounts, they authenticate
+// with client secret or with private_key_jwt assertion
+func (s *server) issueServiceToken(w http.ResponseWriter, r *http.Request) {
+	form := r.PostForm
+
+	clientID, secret, basic := clientCredentials(r)
+
+	assertion := form.Get("client_assertion")
+	if assertion != "" && form.Get("client_assertion_type") != assertionTypeJWTBearer {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", "unsupported client_assertion_type")
+		return
+	}
+
+	if clientID == "" && assertion == "" {
+		writeClientError(w, basic)
+		return
+	}
+
+	grant, err := s.authorizer.IssueServiceToken(r.Context(), auth.ClientCredentials{
+		ClientID:     clientID,
+		ClientSecret: secret,
+		Assertion:    assertion,
+		Scope:        form.Get("scope"),
+		Audience:     form.Get("audience"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidClient):
+			writeClientError(w, basic)
+		case errors.Is(err, auth.ErrInvalidScope):
+			writeTokenError(w, http.StatusBadRequest, "invalid_scope", "")
+		case errors.Is(err, auth.ErrInvalidAudience):
+			writeTokenError(w, http.StatusBadRequest, "invalid_target", "")
+		case errors.Is(err, auth.ErrUnavailable):
+			s.log.Error("failed to issue service token", sl.Err(err))
+			writeTokenError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "")
+		default:
+			s.log.Error("failed to issue service token", sl.Err(err))
+			writeTokenError(w, http.StatusInternalServerError, "server_error", "")
+		}
+
+		return
+	}
+
+	writeTokenGrant(w, grant)
+}
+
+// clientCredentials returns client id and secret from HTTP Basic authentication or the form
+func clientCredentials(r *http.Request) (id string, secret string, basic bool) {
+	if user, pass, ok := r.BasicAuth(); ok {
+		// RFC 6749 section 2.3.1 requires form encoding of both parts
+		id, _ = url.QueryUnescape(user)
+		secret, _ = url.QueryUnescape(pass)
+
+		return id, secret, true
+	}
+
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret"), false
+}
+
+func writeClientError(w http.ResponseWriter, basic bool) {
+	if basic {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+
+	writeTokenError(w, http.StatusUnauthorized, "invalid_client", "")
+}
+
+func writeTokenGrant(w http.ResponseWriter, grant auth.TokenGrant) {
+	writeNoStore(w)
+	gateway.WriteJSON(w, http.StatusOK, tokenResponse{
+		AccessToken: grant.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(grant.ExpiresIn.Seconds()),
+		Scope:       oauth.FormatScope(grant.Scopes),
+		IDToken:     grant.IDToken,
+	})
+}
+
+func writeTokenError(w http.ResponseWriter, code int, errCode string, description string) {
+	writeNoStore(w)
+	gateway.WriteJSON(w, code, tokenError{Error: errCode, Description: description})
+}
+
+func writeNoStore(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+}
@@ -0,0 +1,79 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/lib/sl"
+	"log/slog"
+	"time"
+)
+
+var ErrNotFound = errors.New("key not found")
+
+// Store is a key-value store with expiring keys, such as Redis.
+//
+// It keeps short-lived data only: one-time codes, attempt counters and so on.
+type Store interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value string, ttl time.Duration) error
+	// Incr increments counter and returns its new value,
+	// ttl is set when the counter is created
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	Delete(ctx context.Context, key string) error
+	Close() error
+}
+
+// FailOpen wraps store so that its failures are logged and ignored:
+// missing values are reported as not found and counters as zero.
+//
+// It keeps authentication working while the store is down at the cost of
+// the protection the store provides, so it is meant for rate-limit counters only.
+// One-time codes kept in it would stop being single-use.
+func FailOpen(log *slog.Logger, store Store) Store {
+	return &failOpen{log: log, store: store}
+}
+
+type failOpen struct {
+	log   *slog.Logger
+	store Store
+}
+
+func (f *failOpen) Get(ctx context.Context, key string) (string, error) {
+	v, err := f.store.Get(ctx, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		f.log.Warn("kv store is unavailable, failing open", sl.Err(err))
+		return "", ErrNotFound
+	}
+
+	return v, err
+}
+
+func (f *failOpen) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	if err := f.store.Set(ctx, key, value, ttl); err != nil {
+		f.log.Warn("kv store is unavailable, failing open", sl.Err(err))
+	}
+
+	return nil
+}
+
+func (f *failOpen) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	n, err := f.store.Incr(ctx, key, ttl)
+	if err != nil {
+		f.log.Warn("kv store is unavailable, failing open", sl.Err(err))
+		return 0, nil
+	}
+
+	return n, nil
+}
+
+func (f *failOpen) Delete(ctx context.Context, key string) error {
+	if err := f.store.Delete(ctx, key); err != nil {
+		f.log.Warn("kv store is unavailable, failing open", sl.Err(err))
+	}
+
+	return nil
+}
+
+func (f *failOpen) Close() error {
+	return f.store.Close()
+}
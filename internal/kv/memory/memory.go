@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gRPC/internal/kv"
+	"gRPC/internal/lib/clock"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// Store keeps keys in process memory, it is meant for tests and local runs
+type Store struct {
+	mu      sync.Mutex
+	clock   clock.Clock
+	entries map[string]entry
+}
+
+var _ kv.Store = (*Store)(nil)
+
+// New returns empty store, keys expire according to clk
+func New(clk clock.Clock) *Store {
+	return &Store{
+		clock:   clk,
+		entries: make(map[string]entry),
+	}
+}
+
+func (s *Store) Get(_ context.Context, key string) (string, error) {
+	const op = "kv.memory.Get"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.get(key)
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, kv.ErrNotFound)
+	}
+
+	return e.value, nil
+}
+
+func (s *Store) Set(_ context.Context, key string, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = entry{value: value, expiresAt: s.expiresAt(ttl)}
+
+	return nil
+}
+
+func (s *Store) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	const op = "kv.memory.Incr"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.get(key)
+	if !ok {
+		e = entry{value: "0", expiresAt: s.expiresAt(ttl)}
+	}
+
+	n, err := strconv.ParseInt(e.value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n++
+	e.value = strconv.FormatInt(n, 10)
+	s.entries[key] = e
+
+	return n, nil
+}
+
+func (s *Store) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+
+	return nil
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+// get returns live entry, expired ones are removed
+func (s *Store) get(key string) (entry, bool) {
+	e, ok := s.entries[key]
+	if !ok {
+		return entry{}, false
+	}
+
+	if !e.expiresAt.IsZero() && !s.clock.Now().Before(e.expiresAt) {
+		delete(s.entries, key)
+		return entry{}, false
+	}
+
+	return e, true
+}
+
+// expiresAt returns expiration time for ttl, zero ttl means no expiration
+func (s *Store) expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+
+	return s.clock.Now().Add(ttl)
+}
@@ -0,0 +1,52 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gRPC/internal/kv"
+	"gRPC/internal/kv/memory"
+	"gRPC/internal/lib/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Now())
+	s := memory.New(clk)
+
+	require.NoError(t, s.Set(ctx, "key", "value", time.Minute))
+
+	v, err := s.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", v)
+
+	clk.Advance(time.Minute)
+
+	_, err = s.Get(ctx, "key")
+	assert.ErrorIs(t, err, kv.ErrNotFound)
+}
+
+func TestStore_Incr(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Now())
+	s := memory.New(clk)
+
+	for want := int64(1); want <= 3; want++ {
+		n, err := s.Incr(ctx, "counter", time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, want, n)
+
+		// later increments must not extend the window
+		clk.Advance(10 * time.Second)
+	}
+
+	clk.Advance(30 * time.Second)
+
+	n, err := s.Incr(ctx, "counter", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
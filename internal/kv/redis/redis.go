@@ -0,0 +1,112 @@
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"gRPC/internal/kv"
+	"net"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Store struct {
+	client *redis.Client
+}
+
+var _ kv.Store = (*Store)(nil)
+
+type Options struct {
+	Addr     string
+	Password string
+	DB       int
+	TLS      bool
+	PoolSize int
+}
+
+// New creates Redis client, connection is established lazily on first command
+func New(opts Options) *Store {
+	redisOpts := &redis.Options{
+		Addr:     opts.Addr,
+		Password: opts.Password,
+		DB:       opts.DB,
+		PoolSize: opts.PoolSize,
+	}
+
+	if opts.TLS {
+		host, _, err := net.SplitHostPort(opts.Addr)
+		if err != nil {
+			host = opts.Addr
+		}
+
+		redisOpts.TLSConfig = &tls.Config{
+			ServerName: host,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	return &Store{client: redis.NewClient(redisOpts)}
+}
+
+func (s *Store) Get(ctx context.Context, key string) (string, error) {
+	const op = "kv.redis.Get"
+
+	v, err := s.client.Get(ctx, key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", fmt.Errorf("%s: %w", op, kv.ErrNotFound)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return v, nil
+}
+
+func (s *Store) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	const op = "kv.redis.Set"
+
+	if err := s.client.Set(ctx, key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// incrScript increments counter and sets its expiration in one step, so a counter never
+// outlives its window. Expiration is set only when the key has none, so the window
+// is not extended by later increments.
+var incrScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+func (s *Store) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	const op = "kv.redis.Incr"
+
+	n, err := incrScript.Run(ctx, s.client, []string{key}, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	const op = "kv.redis.Delete"
+
+	if err := s.client.Del(ctx, key).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Store) Close() error {
+	return s.client.Close()
+}
@@ -1,53 +1,113 @@
 package email
 
 import (
+	"context"
 	"fmt"
-	"github.com/joho/godotenv"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
-	"log"
-	"math/rand"
+	"log/slog"
 	"net/mail"
 	"net/smtp"
-	"os"
+	"strconv"
+	"sync"
 )
 
-func SendEmail(email string) (int, error) {
-	randomInt := rand.Intn(900000) + 100000
-	err := godotenv.Load()
-	if err != nil {
-		//return 0, err
-		log.Fatal(err)
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// SMTPSender sends emails through SMTP server with plain auth
+type SMTPSender struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPSender(host string, port int, username string, password string, from string) *SMTPSender {
+	return &SMTPSender{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
 	}
+}
+
+// Send sends msg, the context is not used as net/smtp does not support it
+func (s *SMTPSender) Send(_ context.Context, msg Message) error {
+	const op = "email.SMTPSender.Send"
 
-	////var logger *slog.Logger
-	////log := logger.With(
-	////	slog.String("email", email),
-	////)
-	//
-	//log.Info("Attempting to send email")
+	from := mail.Address{Address: s.from}
+	to := mail.Address{Address: msg.To}
 
-	senderEmail := os.Getenv("SenderEmail")
-	password := os.Getenv("EmailSecret")
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s",
+		from.String(), to.String(), msg.Subject, msg.Body)
 
-	smtpPort := os.Getenv("EmailPort")
+	auth := smtp.PlainAuth("", s.username, s.password, s.host)
+	addr := s.host + ":" + strconv.Itoa(s.port)
 
-	smtpServer := "smtp.gmail.com"
+	if err := smtp.SendMail(addr, auth, from.Address, []string{to.Address}, []byte(message)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
 
-	from := mail.Address{Address: senderEmail}
-	to := mail.Address{Address: email}
-	subject := "GRPC server register message"
+// LogSender writes emails to log instead of sending them, for local runs
+type LogSender struct {
+	log *slog.Logger
+}
+
+func NewLogSender(log *slog.Logger) *LogSender {
+	return &LogSender{log: log}
+}
 
-	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%d",
-		from.String(), to.String(), subject, randomInt)
+func (s *LogSender) Send(_ context.Context, msg Message) error {
+	s.log.Info("email is not sent, log driver is used",
+		slog.String("to", msg.To),
+		slog.String("subject", msg.Subject),
+		slog.String("body", msg.Body),
+	)
+
+	return nil
+}
+
+// Outbox keeps sent emails in memory, for tests
+type Outbox struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (o *Outbox) Send(_ context.Context, msg Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.messages = append(o.messages, msg)
+
+	return nil
+}
+
+// Messages returns all emails sent so far
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]Message(nil), o.messages...)
+}
 
-	auth := smtp.PlainAuth("", senderEmail, password, smtpServer)
-	err = smtp.SendMail(fmt.Sprintf("%s:%s", smtpServer, smtpPort), auth, from.Address, []string{to.Address}, []byte(message))
+// Last returns the latest email sent to given address
+func (o *Outbox) Last(to string) (Message, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 
-	if err != nil {
-		//log.Error("Failed to send email", sl.Err(err))
-		return 0, status.Error(codes.Internal, "Failed to send code")
+	for i := len(o.messages) - 1; i >= 0; i-- {
+		if o.messages[i].To == to {
+			return o.messages[i], true
+		}
 	}
 
-	return randomInt, nil
+	return Message{}, false
 }
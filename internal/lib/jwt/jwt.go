@@ -1,41 +1,279 @@
 package jwt
 
 import (
+	"crypto/rsa"
+	"errors"
 	"fmt"
 	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/keys"
 	"github.com/dgrijalva/jwt-go"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"strings"
 	"time"
 )
 
-// CreateNewToken generates new token by HS256 signing algorithm
-func CreateNewToken(user models.User, app models.App, tokenTTL time.Duration) (string, error) {
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are registered claims of access token besides its subject and lifetime
+type Claims struct {
+	// Issuer is left out when empty
+	Issuer   string
+	Audience []string
+	// Scopes limit the token, nil stands for first-party token without scope claim
+	Scopes []string
+	// ID is unique id of the token, its jti claim
+	ID string
+	// Actor is set on tokens issued by token exchange and impersonation
+	Actor *Actor
+	// Impersonator is id of the admin impersonating the user, zero when nobody does
+	Impersonator int64
+	// AuthTime is when the user authenticated, AMR lists methods used (RFC 8176)
+	// and ACR is the level they reach. They are left out when the user did not authenticate.
+	AuthTime time.Time
+	AMR      []string
+	ACR      string
+}
+
+// Actor is the party acting on behalf of token subject, RFC 8693 act claim.
+// Its own Actor is the party which acted before it.
+type Actor struct {
+	Subject string
+	Actor   *Actor
+}
+
+// ParseActor returns act claim chain of claims, nil when there is none
+func ParseActor(claims map[string]any) *Actor {
+	act, ok := claims["act"].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	sub, _ := act["sub"].(string)
+
+	return &Actor{Subject: sub, Actor: ParseActor(act)}
+}
+
+// Chain returns subjects of actor and the actors before it, the current one first
+func (a *Actor) Chain() []string {
+	var chain []string
+	for ; a != nil; a = a.Actor {
+		chain = append(chain, a.Subject)
+	}
+
+	return chain
+}
+
+func (a *Actor) claim() map[string]any {
+	act := map[string]any{"sub": a.Subject}
+	if a.Actor != nil {
+		act["act"] = a.Actor.claim()
+	}
+
+	return act
+}
+
+// CreateNewToken generates new token of session by HS256 signing algorithm
+func CreateNewToken(user models.User, app models.App, session models.Session) (string, error) {
+	return CreateScopedToken(user, app, session, Claims{})
+}
+
+// CreateScopedToken is CreateNewToken with registered claims, token is limited to OAuth
+// scopes and audiences granted to app
+func CreateScopedToken(user models.User, app models.App, session models.Session, registered Claims) (string, error) {
+	tokenString, err := CreateSessionToken(user, app, session.ID, registered, session.CreatedAt, session.ExpiresAt)
+	if err != nil {
+		return "", status.Error(codes.Internal, "Internal error")
+	}
+	return tokenString, nil
+}
+
+// CreateSessionToken generates HS256 token of existing user session living from issuedAt
+// until expiresAt, e.g. when the user authenticates again or someone acts on the user
+// behalf, then registered claims name the actor.
+func CreateSessionToken(
+	user models.User, app models.App, sessionID string, registered Claims, issuedAt time.Time, expiresAt time.Time,
+) (string, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 	claims := token.Claims.(jwt.MapClaims)
 	claims["uid"] = user.ID
 	claims["user"] = user.Email
-	claims["exp"] = time.Now().Add(tokenTTL).Unix()
+	claims["email"] = user.Email
+	claims["iat"] = issuedAt.Unix()
+	claims["nbf"] = issuedAt.Unix()
+	claims["exp"] = expiresAt.Unix()
 	claims["app_id"] = app.ID
+	claims["sid"] = sessionID
+	setRegistered(claims, registered)
+
+	return token.SignedString([]byte(app.Secret))
+}
+
+// CreateServiceToken generates HS256 token of service account with scopes granted to it.
+// It has no uid and sid claims, so it is never mistaken for a token of a user session.
+func CreateServiceToken(
+	account models.ServiceAccount, app models.App, registered Claims, issuedAt time.Time, expiresAt time.Time,
+) (string, error) {
+	token := jwt.New(jwt.SigningMethodHS256)
+	claims := token.Claims.(jwt.MapClaims)
+	claims["sub"] = account.ClientID
+	claims["client_id"] = account.ClientID
+	claims["app_id"] = app.ID
+	claims["iat"] = issuedAt.Unix()
+	claims["nbf"] = issuedAt.Unix()
+	claims["exp"] = expiresAt.Unix()
+
+	if registered.Scopes == nil {
+		registered.Scopes = []string{}
+	}
+	setRegistered(claims, registered)
 
-	tokenString, err := token.SignedString([]byte(app.Secret))
+	return token.SignedString([]byte(app.Secret))
+}
+
+// setRegistered sets aud as a string when there is a single audience, as RFC 7519 allows
+func setRegistered(claims jwt.MapClaims, registered Claims) {
+	if registered.Issuer != "" {
+		claims["iss"] = registered.Issuer
+	}
+
+	switch len(registered.Audience) {
+	case 0:
+	case 1:
+		claims["aud"] = registered.Audience[0]
+	default:
+		claims["aud"] = registered.Audience
+	}
+
+	if registered.Scopes != nil {
+		claims["scope"] = strings.Join(registered.Scopes, " ")
+	}
+
+	if registered.ID != "" {
+		claims["jti"] = registered.ID
+	}
+
+	if registered.Actor != nil {
+		claims["act"] = registered.Actor.claim()
+	}
+
+	if registered.Impersonator != 0 {
+		claims["impersonator"] = registered.Impersonator
+	}
+
+	if !registered.AuthTime.IsZero() {
+		claims["auth_time"] = registered.AuthTime.Unix()
+	}
+
+	if len(registered.AMR) != 0 {
+		claims["amr"] = registered.AMR
+	}
+
+	if registered.ACR != "" {
+		claims["acr"] = registered.ACR
+	}
+}
+
+// CreateIDToken signs OpenID Connect ID token with claims by RS256 and service signing key,
+// its kid header lets clients pick the key from JWKS
+func CreateIDToken(claims map[string]any, key models.SigningKey) (string, error) {
+	privateKey, err := keys.PrivateKey(key)
 	if err != nil {
-		return "", status.Error(codes.Internal, "Internal error")
+		return "", err
 	}
-	return tokenString, nil
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims(claims))
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(privateKey)
 }
 
 // CheckTokenValidity checks if jwt token is valid for system
 //
-// If token is valid returns true, if not, false
+// If token is valid returns nil, if not, error
 func CheckTokenValidity(tokenString string, app models.App) error {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return app.Secret, nil
+	_, err := ParseToken(tokenString, app, time.Now())
+
+	return err
+}
+
+// ParseToken verifies token signed by app secret and its exp, nbf and iat claims at now,
+// returns its claims. Time comes from the caller, so tokens follow the clock of the service.
+func ParseToken(tokenString string, app models.App, now time.Time) (map[string]any, error) {
+	parser := jwt.Parser{SkipClaimsValidation: true}
+
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+
+		return []byte(app.Secret), nil
 	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+
+	switch {
+	case !claims.VerifyExpiresAt(now.Unix(), true):
+		return nil, fmt.Errorf("%w: token is expired", ErrInvalidToken)
+	case !claims.VerifyNotBefore(now.Unix(), false):
+		return nil, fmt.Errorf("%w: token is not valid yet", ErrInvalidToken)
+	case !claims.VerifyIssuedAt(now.Unix(), false):
+		return nil, fmt.Errorf("%w: token is used before issued", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// ParseClientAssertion verifies RS256 signature of client assertion (RFC 7523) and returns its claims.
+// Time claims are not checked, the caller does it with its own clock.
+func ParseClientAssertion(tokenString string, publicKey *rsa.PublicKey) (map[string]any, error) {
+	parser := jwt.Parser{SkipClaimsValidation: true}
 
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+
+		return publicKey, nil
+	})
 	if err != nil || !token.Valid {
-		return fmt.Errorf("%s: %w", "Invalid token", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	return token.Claims.(jwt.MapClaims), nil
+}
+
+// UnverifiedIssuer returns iss claim without checking signature,
+// client assertion names its client there
+func UnverifiedIssuer(tokenString string) (string, error) {
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	issuer, _ := token.Claims.(jwt.MapClaims)["iss"].(string)
+	if issuer == "" {
+		return "", fmt.Errorf("%w: iss claim is missing", ErrInvalidToken)
+	}
+
+	return issuer, nil
+}
+
+// UnverifiedAppID returns app_id claim without checking signature,
+// so the app whose secret signed the token can be looked up first
+func UnverifiedAppID(tokenString string) (int, error) {
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	appID, ok := token.Claims.(jwt.MapClaims)["app_id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("%w: app_id claim is missing", ErrInvalidToken)
 	}
 
-	return nil
+	return int(appID), nil
 }
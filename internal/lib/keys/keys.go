@@ -0,0 +1,140 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"math/big"
+	"time"
+)
+
+const rsaBits = 2048
+
+var ErrInvalidKey = errors.New("invalid signing key")
+
+// Generate returns new RSA signing key, its id is derived from the public key
+func Generate(now time.Time) (models.SigningKey, error) {
+	const op = "keys.Generate"
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, rsaBits)
+	if err != nil {
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sum := sha256.Sum256(publicDER)
+
+	return models.SigningKey{
+		ID: hex.EncodeToString(sum[:8]),
+		PrivateKey: pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		}),
+		CreatedAt: now,
+	}, nil
+}
+
+// PrivateKey decodes PEM encoded private key of key
+func PrivateKey(key models.SigningKey) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(key.PrivateKey)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+
+	return privateKey, nil
+}
+
+// Sign signs SHA-256 digest of data with key using RSASSA-PKCS1-v1_5
+func Sign(key models.SigningKey, data []byte) ([]byte, error) {
+	const op = "keys.Sign"
+
+	privateKey, err := PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	digest := sha256.Sum256(data)
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return signature, nil
+}
+
+// Verify checks signature made by Sign
+func Verify(key models.SigningKey, data []byte, signature []byte) error {
+	privateKey, err := PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(data)
+
+	return rsa.VerifyPKCS1v15(&privateKey.PublicKey, crypto.SHA256, digest[:], signature)
+}
+
+// JWK is public part of signing key as RSA JSON Web Key (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// PublicJWK returns public part of key, so signatures made by it can be verified by others
+func PublicJWK(key models.SigningKey) (JWK, error) {
+	privateKey, err := PrivateKey(key)
+	if err != nil {
+		return JWK{}, err
+	}
+
+	return JWK{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: "RS256",
+		KeyID:     key.ID,
+		Modulus:   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+	}, nil
+}
+
+// ParsePublicKey decodes PEM encoded PKIX RSA public key
+func ParsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, ErrInvalidKey
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+
+	publicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: not an RSA key", ErrInvalidKey)
+	}
+
+	return publicKey, nil
+}
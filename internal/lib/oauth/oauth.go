@@ -0,0 +1,168 @@
+// Package oauth implements the parts of OAuth 2.0 (RFC 6749) and PKCE (RFC 7636)
+// which do not depend on storage: scope, redirect URI and code verifier checks.
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// MethodS256 is the only supported code challenge method, plain is not accepted
+const MethodS256 = "S256"
+
+var (
+	ErrInvalidScope       = errors.New("invalid scope")
+	ErrInvalidAudience    = errors.New("invalid audience")
+	ErrInvalidRedirectURI = errors.New("invalid redirect uri")
+	ErrInvalidVerifier    = errors.New("invalid code verifier")
+	ErrInvalidChallenge   = errors.New("invalid code challenge")
+)
+
+// ParseScope splits space delimited scope and checks its tokens, duplicates are dropped
+func ParseScope(scope string) ([]string, error) {
+	return parseList(scope, ErrInvalidScope)
+}
+
+// ParseAudience splits space delimited audience parameter naming resource servers,
+// its values follow the rules of scope tokens
+func ParseAudience(audience string) ([]string, error) {
+	return parseList(audience, ErrInvalidAudience)
+}
+
+func parseList(list string, errInvalid error) ([]string, error) {
+	var tokens []string
+	for _, token := range strings.Split(list, " ") {
+		if token == "" {
+			continue
+		}
+
+		if !validScopeToken(token) {
+			return nil, fmt.Errorf("%w: %q", errInvalid, token)
+		}
+
+		if !slices.Contains(tokens, token) {
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens, nil
+}
+
+// FormatScope joins scopes into space delimited scope parameter
+func FormatScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// validScopeToken reports whether token consists of characters allowed by RFC 6749 section 3.3
+func validScopeToken(token string) bool {
+	for i := 0; i < len(token); i++ {
+		c := token[i]
+		if c < 0x21 || c == '"' || c == '\\' || c > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ValidateRedirectURI checks that uri may be registered as redirect URI:
+// it must be absolute, without fragment, and use https unless it points to loopback
+func ValidateRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRedirectURI, err)
+	}
+
+	switch {
+	case !u.IsAbs() || u.Host == "":
+		return fmt.Errorf("%w: %q is not absolute", ErrInvalidRedirectURI, uri)
+	case u.Fragment != "" || strings.Contains(uri, "#"):
+		return fmt.Errorf("%w: %q has fragment", ErrInvalidRedirectURI, uri)
+	case strings.ContainsAny(uri, " \t\n"):
+		return fmt.Errorf("%w: %q has whitespace", ErrInvalidRedirectURI, uri)
+	case u.Scheme == "https":
+		return nil
+	case u.Scheme == "http" && isLoopback(u.Hostname()):
+		return nil
+	default:
+		return fmt.Errorf("%w: %q must use https", ErrInvalidRedirectURI, uri)
+	}
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// ValidateChallenge checks S256 code challenge sent with authorization request
+func ValidateChallenge(challenge string, method string) error {
+	if method != MethodS256 {
+		return fmt.Errorf("%w: method must be %s", ErrInvalidChallenge, MethodS256)
+	}
+
+	// base64url of SHA-256 digest without padding
+	if b, err := base64.RawURLEncoding.DecodeString(challenge); err != nil || len(b) != sha256.Size {
+		return fmt.Errorf("%w: not a base64url encoded SHA-256 digest", ErrInvalidChallenge)
+	}
+
+	return nil
+}
+
+// Challenge returns S256 code challenge of verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// VerifyChallenge checks that verifier matches S256 challenge
+func VerifyChallenge(challenge string, verifier string) error {
+	if len(verifier) < 43 || len(verifier) > 128 || !unreserved(verifier) {
+		return ErrInvalidVerifier
+	}
+
+	if subtle.ConstantTimeCompare([]byte(Challenge(verifier)), []byte(challenge)) != 1 {
+		return ErrInvalidVerifier
+	}
+
+	return nil
+}
+
+// unreserved reports whether s consists of characters allowed in code verifier
+func unreserved(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		ok := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~'
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HashClientSecret returns digest generated client secret is stored as.
+// Such secrets are long and random, so a fast hash is enough for them.
+func HashClientSecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+
+	return sum[:]
+}
+
+// VerifyClientSecret compares secret with its digest in constant time
+func VerifyClientSecret(hash []byte, secret string) bool {
+	return subtle.ConstantTimeCompare(HashClientSecret(secret), hash) == 1
+}
@@ -0,0 +1,138 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+// Argon2id hashes passwords with argon2id, hashes are encoded as
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2id struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+const argon2Prefix = "$argon2id$"
+
+// argon2Hash is decoded argon2id hash
+type argon2Hash struct {
+	version int
+	params  Argon2id
+	salt    []byte
+	key     []byte
+}
+
+func (a Argon2id) Hash(password string) ([]byte, error) {
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+
+	return []byte(fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2Prefix, argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)), nil
+}
+
+func (a Argon2id) Verify(hash []byte, password string) error {
+	h, err := decodeArgon2(hash)
+	if err != nil {
+		return err
+	}
+
+	p := h.params
+	key := argon2.IDKey([]byte(password), h.salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(h.key)))
+
+	if subtle.ConstantTimeCompare(key, h.key) != 1 {
+		return ErrMismatch
+	}
+
+	return nil
+}
+
+func (a Argon2id) NeedsRehash(hash []byte) bool {
+	h, err := decodeArgon2(hash)
+	if err != nil {
+		return true
+	}
+
+	p := h.params
+
+	return p.Memory < a.Memory ||
+		p.Iterations < a.Iterations ||
+		p.Parallelism < a.Parallelism ||
+		uint32(len(h.salt)) < a.SaltLength ||
+		uint32(len(h.key)) < a.KeyLength
+}
+
+func (a Argon2id) validate() error {
+	switch {
+	case a.Iterations < 1:
+		return errors.New("argon2 iterations must be positive")
+	case a.Parallelism < 1:
+		return errors.New("argon2 parallelism must be positive")
+	case a.Memory < 8*uint32(a.Parallelism):
+		return errors.New("argon2 memory must be at least 8 KiB per thread")
+	case a.SaltLength < 8:
+		return errors.New("argon2 salt must be at least 8 bytes")
+	case a.KeyLength < 16:
+		return errors.New("argon2 key must be at least 16 bytes")
+	}
+
+	return nil
+}
+
+func decodeArgon2(hash []byte) (argon2Hash, error) {
+	s, ok := strings.CutPrefix(string(hash), argon2Prefix)
+	if !ok {
+		return argon2Hash{}, ErrUnknownAlgorithm
+	}
+
+	parts := strings.Split(s, "$")
+	if len(parts) != 4 {
+		return argon2Hash{}, ErrMalformedHash
+	}
+
+	var h argon2Hash
+
+	if _, err := fmt.Sscanf(parts[0], "v=%d", &h.version); err != nil {
+		return argon2Hash{}, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	// the library implements the current version only
+	if h.version != argon2.Version {
+		return argon2Hash{}, fmt.Errorf("%w: unsupported version %d", ErrMalformedHash, h.version)
+	}
+
+	p := &h.params
+	if _, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return argon2Hash{}, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	if p.Iterations < 1 || p.Parallelism < 1 {
+		return argon2Hash{}, ErrMalformedHash
+	}
+
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return argon2Hash{}, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil {
+		return argon2Hash{}, fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+	if len(h.key) == 0 {
+		return argon2Hash{}, ErrMalformedHash
+	}
+
+	return h, nil
+}
@@ -0,0 +1,61 @@
+package password
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Bcrypt hashes passwords with bcrypt, only the first 72 bytes of password count
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+}
+
+func (b Bcrypt) Verify(hash []byte, password string) error {
+	if !isBcrypt(hash) {
+		return ErrUnknownAlgorithm
+	}
+
+	err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformedHash, err)
+	}
+
+	return nil
+}
+
+func (b Bcrypt) NeedsRehash(hash []byte) bool {
+	if !isBcrypt(hash) {
+		return true
+	}
+
+	cost, err := bcrypt.Cost(hash)
+
+	return err != nil || cost < b.Cost
+}
+
+func (b Bcrypt) validate() error {
+	if b.Cost < bcrypt.MinCost || b.Cost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be within %d..%d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	return nil
+}
+
+func isBcrypt(hash []byte) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if bytes.HasPrefix(hash, []byte(prefix)) {
+			return true
+		}
+	}
+
+	return false
+}
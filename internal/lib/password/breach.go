@@ -0,0 +1,77 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// prefixLength is the length of hash prefix used for lookup, as in the Pwned Passwords range API
+const prefixLength = 5
+
+// BreachList is set of SHA-1 hashes of breached passwords.
+//
+// Hashes are grouped by prefix and looked up the k-anonymity way, so the
+// list can be replaced by a range service without changing callers.
+type BreachList struct {
+	ranges map[string][]string
+}
+
+// LoadBreachList reads file with one hex SHA-1 hash per line, optionally
+// followed by ":count" as in Pwned Passwords downloads. Empty lines and
+// lines starting with # are skipped.
+func LoadBreachList(path string) (*BreachList, error) {
+	const op = "password.LoadBreachList"
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	l := &BreachList{ranges: make(map[string][]string)}
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(text, ":")
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("%s: line %d: invalid SHA-1 hash", op, line)
+		}
+
+		hash = strings.ToUpper(hash)
+		l.ranges[hash[:prefixLength]] = append(l.ranges[hash[:prefixLength]], hash[prefixLength:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, suffixes := range l.ranges {
+		slices.Sort(suffixes)
+	}
+
+	return l, nil
+}
+
+// Range returns sorted suffixes of hashes starting with prefix
+func (l *BreachList) Range(prefix string) []string {
+	return l.ranges[strings.ToUpper(prefix)]
+}
+
+// Contains reports if password is in the list
+func (l *BreachList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	_, found := slices.BinarySearch(l.Range(hash[:prefixLength]), hash[prefixLength:])
+
+	return found
+}
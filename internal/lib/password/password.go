@@ -0,0 +1,123 @@
+// Package password hashes user passwords.
+//
+// Hashes describe themselves: bcrypt ones keep the usual $2a$ form and
+// argon2id ones the PHC string format, so a hash made with old settings
+// still verifies and can be told apart from one made with current settings.
+package password
+
+import (
+	"errors"
+	"fmt"
+	"gRPC/internal/config"
+)
+
+var (
+	ErrMismatch         = errors.New("password does not match")
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+	ErrMalformedHash    = errors.New("malformed password hash")
+)
+
+type Hasher interface {
+	Hash(password string) ([]byte, error)
+	// Verify returns ErrMismatch when password does not match hash
+	Verify(hash []byte, password string) error
+	// NeedsRehash reports if hash was made by another algorithm or with weaker parameters
+	NeedsRehash(hash []byte) bool
+}
+
+// New returns hasher which hashes with preferred and verifies hashes of preferred and legacy ones
+func New(preferred Hasher, legacy ...Hasher) Hasher {
+	return &chain{preferred: preferred, all: append([]Hasher{preferred}, legacy...)}
+}
+
+// FromConfig builds hasher of cfg.Algorithm, hashes of the other algorithm are still verified
+func FromConfig(cfg config.PasswordConfig) (Hasher, error) {
+	const op = "password.FromConfig"
+
+	bcryptHasher := Bcrypt{Cost: cfg.BcryptCost}
+	argon2Hasher := Argon2id{
+		Memory:      cfg.Argon2.Memory,
+		Iterations:  cfg.Argon2.Iterations,
+		Parallelism: cfg.Argon2.Parallelism,
+		SaltLength:  cfg.Argon2.SaltLength,
+		KeyLength:   cfg.Argon2.KeyLength,
+	}
+
+	switch cfg.Algorithm {
+	case config.PasswordAlgorithmArgon2id:
+		if err := argon2Hasher.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return New(argon2Hasher, bcryptHasher), nil
+	case config.PasswordAlgorithmBcrypt:
+		if err := bcryptHasher.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return New(bcryptHasher, argon2Hasher), nil
+	default:
+		return nil, fmt.Errorf("%s: %w: %q", op, ErrUnknownAlgorithm, cfg.Algorithm)
+	}
+}
+
+// PolicyFromConfig builds policy of cfg.Policy and loads its breached password list.
+//
+// Passwords longer than bcrypt takes into account are refused when bcrypt is the algorithm.
+func PolicyFromConfig(cfg config.PasswordConfig) (Policy, error) {
+	const op = "password.PolicyFromConfig"
+
+	c := cfg.Policy
+
+	policy := Policy{
+		MinLength:     c.MinLength,
+		MaxLength:     c.MaxLength,
+		RequireLower:  c.RequireLower,
+		RequireUpper:  c.RequireUpper,
+		RequireDigit:  c.RequireDigit,
+		RequireSymbol: c.RequireSymbol,
+		MinStrength:   c.MinStrength,
+	}
+
+	if cfg.Algorithm == config.PasswordAlgorithmBcrypt && (policy.MaxLength == 0 || policy.MaxLength > bcryptMaxLength) {
+		policy.MaxLength = bcryptMaxLength
+	}
+
+	if policy.MaxLength > 0 && policy.MinLength > policy.MaxLength {
+		return Policy{}, fmt.Errorf("%s: min length %d exceeds max length %d", op, policy.MinLength, policy.MaxLength)
+	}
+
+	if c.BreachedList != "" {
+		list, err := LoadBreachList(c.BreachedList)
+		if err != nil {
+			return Policy{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		policy.Breached = list
+	}
+
+	return policy, nil
+}
+
+type chain struct {
+	preferred Hasher
+	all       []Hasher
+}
+
+func (c *chain) Hash(password string) ([]byte, error) {
+	return c.preferred.Hash(password)
+}
+
+func (c *chain) Verify(hash []byte, password string) error {
+	for _, h := range c.all {
+		if err := h.Verify(hash, password); !errors.Is(err, ErrUnknownAlgorithm) {
+			return err
+		}
+	}
+
+	return ErrUnknownAlgorithm
+}
+
+func (c *chain) NeedsRehash(hash []byte) bool {
+	return c.preferred.NeedsRehash(hash)
+}
@@ -0,0 +1,92 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bcryptMaxLength is how many bytes of password bcrypt takes into account
+const bcryptMaxLength = 72
+
+// Policy is the set of rules new passwords have to follow
+type Policy struct {
+	// MinLength is in characters
+	MinLength int
+	// MaxLength is in bytes, zero means no limit
+	MaxLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// MinStrength is the lowest accepted score of Strength
+	MinStrength int
+	// Breached rejects known breached passwords, nil disables the check
+	Breached *BreachList
+}
+
+// ViolationError lists every rule the password breaks
+type ViolationError struct {
+	Violations []string
+}
+
+func (e *ViolationError) Error() string {
+	return "password violates policy: " + strings.Join(e.Violations, "; ")
+}
+
+// Check returns *ViolationError if password of user with given email breaks the policy
+func (p Policy) Check(password string, email string) error {
+	var violations []string
+
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes long", p.MaxLength))
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	if p.RequireLower && !lower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	local, _, _ := strings.Cut(email, "@")
+	if email != "" && (strings.EqualFold(password, email) || strings.EqualFold(password, local)) {
+		violations = append(violations, "must not be the email address")
+	} else if Strength(password, email, local) < p.MinStrength {
+		violations = append(violations, "is too easy to guess")
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, "has appeared in a data breach")
+	}
+
+	if len(violations) > 0 {
+		return &ViolationError{Violations: violations}
+	}
+
+	return nil
+}
@@ -0,0 +1,290 @@
+package password
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxAnalyzed limits the work spent on very long passwords, the rest of them is ignored
+	maxAnalyzed = 100
+	// bruteforceCardinality is the guesses per character not covered by any pattern
+	bruteforceCardinality = 10
+	// minPatternGuesses keeps any pattern from being cheaper than a few characters of brute force
+	minPatternGuesses = 50
+	minPatternLength  = 3
+)
+
+// commonPasswords are most used passwords and words, ordered by popularity
+var commonPasswords = []string{
+	"password", "123456", "qwerty", "123456789", "12345678", "111111", "1234567",
+	"iloveyou", "admin", "welcome", "monkey", "login", "abc123", "starwars",
+	"dragon", "passw0rd", "master", "hello", "freedom", "whatever", "qazwsx",
+	"trustno1", "letmein", "football", "baseball", "sunshine", "princess",
+	"shadow", "superman", "michael", "secret", "charlie", "jennifer", "jordan",
+	"hunter", "ashley", "bailey", "access", "flower", "mustang",
+	"batman", "soccer", "hockey", "killer", "george", "andrew", "summer",
+	"winter", "spring", "autumn", "love", "lovely", "ninja", "pepper", "cookie",
+	"cheese", "orange", "banana", "computer", "internet", "security", "changeme",
+	"default", "guest", "root", "user", "test", "pass", "qwertyuiop", "asdfgh",
+	"zxcvbn", "google", "apple", "samsung", "london", "berlin", "moscow",
+	"company", "office", "money", "thomas", "daniel", "matrix", "family",
+}
+
+// keyboardRows are adjacent keys of US layout, runs along them are easy to type and to guess
+var keyboardRows = []string{
+	"`1234567890-=", "~!@#$%^&*()_+", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./",
+}
+
+// leet maps characters commonly substituted for letters back to the letters
+var leet = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i', '!': 'i',
+	'|': 'l', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+var commonRanks = func() map[string]int {
+	ranks := make(map[string]int, len(commonPasswords))
+	for i, word := range commonPasswords {
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+
+	return ranks
+}()
+
+// match is a guessable pattern spanning runes [start, end) of password
+type match struct {
+	start, end int
+	guesses    float64
+}
+
+// Strength estimates how hard password is to guess, from 0 (too guessable)
+// to 4 (very unguessable), in the manner of zxcvbn.
+//
+// Password is split into the sequence of patterns that is cheapest to guess:
+// common passwords and userInputs, possibly capitalized or in leetspeak,
+// repeats, alphabetic and numeric sequences, keyboard runs and years.
+// Characters not covered by any pattern are guessed by brute force.
+func Strength(password string, userInputs ...string) int {
+	runes := []rune(password)
+	if len(runes) > maxAnalyzed {
+		runes = runes[:maxAnalyzed]
+	}
+
+	matches := dictionaryMatches(runes, userInputs)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, keyboardMatches(runes)...)
+	matches = append(matches, yearMatches(runes)...)
+
+	// best[i] is the fewest guesses needed for the first i runes
+	best := make([]float64, len(runes)+1)
+	best[0] = 1
+
+	for end := 1; end <= len(runes); end++ {
+		best[end] = best[end-1] * bruteforceCardinality
+
+		for _, m := range matches {
+			if m.end == end {
+				best[end] = min(best[end], best[m.start]*max(m.guesses, minPatternGuesses))
+			}
+		}
+	}
+
+	return score(best[len(runes)])
+}
+
+func score(guesses float64) int {
+	switch {
+	case guesses < 1e3:
+		return 0
+	case guesses < 1e6:
+		return 1
+	case guesses < 1e8:
+		return 2
+	case guesses < 1e10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func dictionaryMatches(runes []rune, userInputs []string) []match {
+	ranks := commonRanks
+
+	if len(userInputs) > 0 {
+		ranks = make(map[string]int, len(commonRanks)+len(userInputs))
+		for word, rank := range commonRanks {
+			ranks[word] = rank
+		}
+		// user inputs are the first thing an attacker tries
+		for _, input := range userInputs {
+			if input = strings.ToLower(input); len([]rune(input)) >= minPatternLength {
+				ranks[input] = 1
+			}
+		}
+	}
+
+	maxWord := 0
+	for word := range ranks {
+		maxWord = max(maxWord, len([]rune(word)))
+	}
+
+	lower := make([]rune, len(runes))
+	unleet := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+		unleet[i] = lower[i]
+		if l, ok := leet[lower[i]]; ok {
+			unleet[i] = l
+		}
+	}
+
+	var matches []match
+
+	for start := range runes {
+		for end := start + minPatternLength; end <= len(runes) && end-start <= maxWord; end++ {
+			variations := capitalizations(runes[start:end])
+
+			if rank, ok := ranks[string(lower[start:end])]; ok {
+				matches = append(matches, match{start, end, float64(rank) * variations})
+			} else if rank, ok := ranks[string(unleet[start:end])]; ok {
+				matches = append(matches, match{start, end, float64(rank) * variations * 2})
+			}
+		}
+	}
+
+	return matches
+}
+
+// capitalizations is how many ways of capitalizing a word have to be tried to get word
+func capitalizations(word []rune) float64 {
+	upper := 0
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+
+	switch {
+	case upper == 0:
+		return 1
+	case upper == len(word), upper == 1 && unicode.IsUpper(word[0]):
+		return 2
+	default:
+		return float64(uint64(1) << min(upper, len(word)-upper, 32))
+	}
+}
+
+func repeatMatches(runes []rune) []match {
+	var matches []match
+
+	for start := 0; start < len(runes); {
+		end := start + 1
+		for end < len(runes) && runes[end] == runes[start] {
+			end++
+		}
+
+		if end-start >= minPatternLength {
+			matches = append(matches, match{start, end, cardinality(runes[start]) * float64(end-start)})
+		}
+
+		start = end
+	}
+
+	return matches
+}
+
+func sequenceMatches(runes []rune) []match {
+	var matches []match
+
+	for start := 0; start+1 < len(runes); {
+		delta := runes[start+1] - runes[start]
+		if delta != 1 && delta != -1 {
+			start++
+			continue
+		}
+
+		end := start + 2
+		for end < len(runes) && runes[end]-runes[end-1] == delta {
+			end++
+		}
+
+		if end-start >= minPatternLength {
+			base := cardinality(runes[start])
+			if strings.ContainsRune("aAzZ019", runes[start]) {
+				base = 4
+			}
+			if delta < 0 {
+				base *= 2
+			}
+
+			matches = append(matches, match{start, end, base * float64(end-start)})
+		}
+
+		start = end - 1
+	}
+
+	return matches
+}
+
+func keyboardMatches(runes []rune) []match {
+	lower := strings.ToLower(string(runes))
+	lowerRunes := []rune(lower)
+
+	var matches []match
+
+	for start := range lowerRunes {
+		for end := start + minPatternLength + 1; end <= len(lowerRunes); end++ {
+			run := string(lowerRunes[start:end])
+			if !onKeyboardRow(run) {
+				break
+			}
+
+			matches = append(matches, match{start, end, float64(bruteforceCardinality * (end - start))})
+		}
+	}
+
+	return matches
+}
+
+func onKeyboardRow(run string) bool {
+	reversed := []rune(run)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+
+	for _, row := range keyboardRows {
+		if strings.Contains(row, run) || strings.Contains(row, string(reversed)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func yearMatches(runes []rune) []match {
+	var matches []match
+
+	for start := 0; start+4 <= len(runes); start++ {
+		year := string(runes[start : start+4])
+		if (strings.HasPrefix(year, "19") || strings.HasPrefix(year, "20")) &&
+			unicode.IsDigit(runes[start+2]) && unicode.IsDigit(runes[start+3]) {
+			matches = append(matches, match{start, start + 4, 200})
+		}
+	}
+
+	return matches
+}
+
+func cardinality(r rune) float64 {
+	switch {
+	case unicode.IsDigit(r):
+		return 10
+	case unicode.IsLower(r), unicode.IsUpper(r):
+		return 26
+	default:
+		return 33
+	}
+}
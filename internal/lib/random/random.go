@@ -0,0 +1,38 @@
+package random
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"math/big"
+	"strings"
+)
+
+// String returns url safe string built from n cryptographically random bytes
+func String(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Digits returns cryptographically random numeric code of given length
+func Digits(length int) (string, error) {
+	return FromAlphabet("0123456789", length)
+}
+
+// FromAlphabet returns cryptographically random string of given length built from alphabet characters
+func FromAlphabet(alphabet string, length int) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < length; i++ {
+		d, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteByte(alphabet[d.Int64()])
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,178 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidPhone = errors.New("phone number must be in international format, like +15551234567")
+
+const (
+	// ChannelSMS sends text message, ChannelVoice reads the message out in a call
+	ChannelSMS   = "sms"
+	ChannelVoice = "voice"
+)
+
+// e164 is the international phone number format providers accept
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// Message is a short text delivered to phone over Channel
+type Message struct {
+	To      string `json:"to"`
+	Body    string `json:"body"`
+	Channel string `json:"channel"`
+}
+
+// NormalizePhone returns phone in E.164 format, spaces, dashes, dots
+// and parentheses people write numbers with are dropped
+func NormalizePhone(phone string) (string, error) {
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		default:
+			return r
+		}
+	}, phone)
+
+	if !e164.MatchString(normalized) {
+		return "", ErrInvalidPhone
+	}
+
+	return normalized, nil
+}
+
+// MaskPhone hides all but the last digits of phone, so it can be shown before login completes
+func MaskPhone(phone string) string {
+	const visible = 2
+
+	if len(phone) <= visible+1 {
+		return phone
+	}
+
+	return phone[:1] + strings.Repeat("*", len(phone)-visible-1) + phone[len(phone)-visible:]
+}
+
+// HTTPSender sends messages through provider accepting JSON messages with bearer token,
+// from is the sender number or name the provider has registered
+type HTTPSender struct {
+	url    string
+	token  string
+	from   string
+	client *http.Client
+}
+
+func NewHTTPSender(url string, token string, from string, timeout time.Duration) *HTTPSender {
+	return &HTTPSender{
+		url:    url,
+		token:  token,
+		from:   from,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *HTTPSender) Send(ctx context.Context, msg Message) error {
+	const op = "sms.HTTPSender.Send"
+
+	body, err := json.Marshal(struct {
+		Message
+		From string `json:"from,omitempty"`
+	}{msg, s.from})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	// the body is drained so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: provider responded with %s", op, resp.Status)
+	}
+
+	return nil
+}
+
+// LogSender writes messages to log instead of sending them, for local runs
+type LogSender struct {
+	log *slog.Logger
+}
+
+func NewLogSender(log *slog.Logger) *LogSender {
+	return &LogSender{log: log}
+}
+
+func (s *LogSender) Send(_ context.Context, msg Message) error {
+	s.log.Info("sms is not sent, log driver is used",
+		slog.String("to", msg.To),
+		slog.String("channel", msg.Channel),
+		slog.String("body", msg.Body),
+	)
+
+	return nil
+}
+
+// FileSender appends messages to file as JSON lines instead of sending them,
+// so local tools and scripts can pick codes up
+type FileSender struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileSender(path string) *FileSender {
+	return &FileSender{path: path}
+}
+
+func (s *FileSender) Send(_ context.Context, msg Message) error {
+	const op = "sms.FileSender.Send"
+
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package webauthn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// CBOR is decoded only as far as WebAuthn needs it: integers, byte and text strings,
+// arrays, maps and simple values of definite length. Floats and tags are rejected.
+
+var errCBOR = errors.New("malformed CBOR")
+
+// maxCBORDepth bounds nesting of arrays and maps
+const maxCBORDepth = 8
+
+const (
+	cborUint   = 0
+	cborNegint = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborSimple = 7
+)
+
+// decodeCBOR decodes the first CBOR item of data and returns it with the rest of data.
+//
+// Integers are returned as int64, byte strings as []byte, text as string,
+// arrays as []any and maps as map[any]any with int64 or string keys.
+func decodeCBOR(data []byte) (any, []byte, error) {
+	d := cborDecoder{data: data}
+
+	v, err := d.value(0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return v, d.data, nil
+}
+
+type cborDecoder struct {
+	data []byte
+}
+
+func (d *cborDecoder) value(depth int) (any, error) {
+	if depth > maxCBORDepth {
+		return nil, fmt.Errorf("%w: nested too deep", errCBOR)
+	}
+
+	if len(d.data) == 0 {
+		return nil, fmt.Errorf("%w: unexpected end", errCBOR)
+	}
+
+	major, info := d.data[0]>>5, d.data[0]&0x1f
+	d.data = d.data[1:]
+
+	if major == cborSimple {
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("%w: unsupported simple value %d", errCBOR, info)
+		}
+	}
+
+	n, err := d.argument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUint:
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: integer overflow", errCBOR)
+		}
+
+		return int64(n), nil
+	case cborNegint:
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: integer overflow", errCBOR)
+		}
+
+		return -1 - int64(n), nil
+	case cborBytes:
+		b, err := d.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+
+		return append([]byte(nil), b...), nil
+	case cborText:
+		b, err := d.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+
+		return string(b), nil
+	case cborArray:
+		// every item takes at least one byte
+		if n > uint64(len(d.data)) {
+			return nil, fmt.Errorf("%w: unexpected end", errCBOR)
+		}
+
+		items := make([]any, 0, n)
+		for i := uint64(0); i < n; i++ {
+			item, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			items = append(items, item)
+		}
+
+		return items, nil
+	case cborMap:
+		if n > uint64(len(d.data)) {
+			return nil, fmt.Errorf("%w: unexpected end", errCBOR)
+		}
+
+		m := make(map[any]any, n)
+		for i := uint64(0); i < n; i++ {
+			key, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, fmt.Errorf("%w: unsupported map key %T", errCBOR, key)
+			}
+
+			if _, ok := m[key]; ok {
+				return nil, fmt.Errorf("%w: duplicate map key %v", errCBOR, key)
+			}
+
+			m[key], err = d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return m, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported major type %d", errCBOR, major)
+	}
+}
+
+// argument reads the argument of item header with additional info
+func (d *cborDecoder) argument(info byte) (uint64, error) {
+	if info < 24 {
+		return uint64(info), nil
+	}
+
+	var size int
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		return 0, fmt.Errorf("%w: indefinite length is not supported", errCBOR)
+	}
+
+	b, err := d.bytes(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+
+	return n, nil
+}
+
+func (d *cborDecoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)) {
+		return nil, fmt.Errorf("%w: unexpected end", errCBOR)
+	}
+
+	b := d.data[:n]
+	d.data = d.data[n:]
+
+	return b, nil
+}
@@ -0,0 +1,402 @@
+// Package webauthn implements the relying party side of WebAuthn (passkeys):
+// options for navigator.credentials calls and checks of their responses.
+//
+// Only ES256 credentials and "none" attestation are supported, which is what
+// passkey providers return when relying party does not ask for attestation.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+)
+
+var (
+	ErrInvalidClientData      = errors.New("invalid client data")
+	ErrInvalidAuthData        = errors.New("invalid authenticator data")
+	ErrUnsupportedAttestation = errors.New("unsupported attestation")
+	ErrUnsupportedKey         = errors.New("unsupported credential key")
+	ErrInvalidSignature       = errors.New("invalid assertion signature")
+)
+
+const (
+	// AlgES256 is COSE identifier of ECDSA with P-256 and SHA-256
+	AlgES256 = -7
+
+	// ChallengeLength is the number of random bytes in challenges
+	ChallengeLength = 32
+
+	typePublicKey = "public-key"
+
+	// user verification requirements
+	UserVerificationRequired  = "required"
+	UserVerificationPreferred = "preferred"
+
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+
+	// authDataLength is the length of authenticator data without attested credential and extensions
+	authDataLength = 37
+	aaguidLength   = 16
+
+	// COSE key parameters and values
+	coseKty      = 1
+	coseAlg      = 3
+	coseCrv      = -1
+	coseX        = -2
+	coseY        = -3
+	coseKtyEC2   = 2
+	coseCrvP256  = 1
+	p256CoordLen = 32
+)
+
+// RelyingParty is the site passkeys are created for
+type RelyingParty struct {
+	// ID is the domain passkeys are scoped to
+	ID   string
+	Name string
+	// Origins are web origins ceremonies may run on, e.g. https://login.example.com
+	Origins []string
+}
+
+// Credential is public key credential created by authenticator
+type Credential struct {
+	ID []byte
+	// PublicKey is COSE encoded
+	PublicKey    []byte
+	SignCount    uint32
+	UserVerified bool
+}
+
+// Assertion is what verified assertion tells about authenticator
+type Assertion struct {
+	SignCount    uint32
+	UserVerified bool
+}
+
+// ClientData is the part of collected client data relying party checks
+type ClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// CreationOptions are publicKey options of navigator.credentials.create,
+// in the JSON form of WebAuthn Level 3, binary values are base64url encoded
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RPEntity               `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are publicKey options of navigator.credentials.get
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+type RPEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// NewChallenge returns random challenge of a ceremony
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, ChallengeLength)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// CreationOptions returns options registering passkey of user with userHandle and name,
+// authenticators already holding one of exclude credentials are not used again
+func (rp RelyingParty) CreationOptions(
+	challenge []byte, userHandle []byte, name string, exclude [][]byte, timeout time.Duration,
+) CreationOptions {
+	return CreationOptions{
+		Challenge:          encode(challenge),
+		RP:                 RPEntity{ID: rp.ID, Name: rp.Name},
+		User:               UserEntity{ID: encode(userHandle), Name: name, DisplayName: name},
+		PubKeyCredParams:   []CredentialParameter{{Type: typePublicKey, Alg: AlgES256}},
+		Timeout:            timeout.Milliseconds(),
+		ExcludeCredentials: descriptors(exclude),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: UserVerificationPreferred,
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions returns options asking for assertion by one of allow credentials,
+// empty allow lets user pick any discoverable credential of relying party
+func (rp RelyingParty) RequestOptions(
+	challenge []byte, allow [][]byte, userVerification string, timeout time.Duration,
+) RequestOptions {
+	return RequestOptions{
+		Challenge:        encode(challenge),
+		Timeout:          timeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: descriptors(allow),
+		UserVerification: userVerification,
+	}
+}
+
+// ParseClientData decodes client data JSON, so its challenge may be looked up
+// before the response is verified
+func ParseClientData(clientDataJSON []byte) (ClientData, error) {
+	var clientData ClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return ClientData{}, fmt.Errorf("%w: %w", ErrInvalidClientData, err)
+	}
+
+	return clientData, nil
+}
+
+// VerifyRegistration checks response of navigator.credentials.create called with
+// challenge and returns the created credential
+func (rp RelyingParty) VerifyRegistration(
+	challenge []byte, clientDataJSON []byte, attestationObject []byte,
+) (Credential, error) {
+	if err := rp.verifyClientData(clientDataJSON, typeCreate, challenge); err != nil {
+		return Credential{}, err
+	}
+
+	attestation, rest, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return Credential{}, fmt.Errorf("%w: %w", ErrUnsupportedAttestation, err)
+	}
+
+	object, ok := attestation.(map[any]any)
+	if !ok || len(rest) != 0 {
+		return Credential{}, fmt.Errorf("%w: not an attestation object", ErrUnsupportedAttestation)
+	}
+
+	// without attestation statement nothing vouches for the authenticator, it is not needed for passkeys
+	if format, _ := object["fmt"].(string); format != "none" {
+		return Credential{}, fmt.Errorf("%w: format %q", ErrUnsupportedAttestation, format)
+	}
+
+	if statement, ok := object["attStmt"].(map[any]any); !ok || len(statement) != 0 {
+		return Credential{}, fmt.Errorf("%w: none attestation has statement", ErrUnsupportedAttestation)
+	}
+
+	authData, ok := object["authData"].([]byte)
+	if !ok {
+		return Credential{}, fmt.Errorf("%w: missing", ErrInvalidAuthData)
+	}
+
+	flags, signCount, err := rp.parseAuthData(authData)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	if flags&flagAttested == 0 {
+		return Credential{}, fmt.Errorf("%w: no attested credential", ErrInvalidAuthData)
+	}
+
+	attested := authData[authDataLength:]
+	if len(attested) < aaguidLength+2 {
+		return Credential{}, fmt.Errorf("%w: attested credential is truncated", ErrInvalidAuthData)
+	}
+
+	idLength := int(binary.BigEndian.Uint16(attested[aaguidLength:]))
+	attested = attested[aaguidLength+2:]
+
+	if idLength == 0 || len(attested) < idLength {
+		return Credential{}, fmt.Errorf("%w: invalid credential id", ErrInvalidAuthData)
+	}
+
+	id, publicKey := attested[:idLength], attested[idLength:]
+
+	coseKey, extensions, err := decodeCBOR(publicKey)
+	if err != nil {
+		return Credential{}, fmt.Errorf("%w: %w", ErrUnsupportedKey, err)
+	}
+
+	if _, err := ecdsaKey(coseKey); err != nil {
+		return Credential{}, err
+	}
+
+	return Credential{
+		ID:           bytes.Clone(id),
+		PublicKey:    bytes.Clone(publicKey[:len(publicKey)-len(extensions)]),
+		SignCount:    signCount,
+		UserVerified: flags&flagUserVerified != 0,
+	}, nil
+}
+
+// VerifyAssertion checks response of navigator.credentials.get called with challenge,
+// signed by credential with COSE encoded publicKey
+func (rp RelyingParty) VerifyAssertion(
+	challenge []byte, publicKey []byte, clientDataJSON []byte, authData []byte, signature []byte,
+) (Assertion, error) {
+	if err := rp.verifyClientData(clientDataJSON, typeGet, challenge); err != nil {
+		return Assertion{}, err
+	}
+
+	flags, signCount, err := rp.parseAuthData(authData)
+	if err != nil {
+		return Assertion{}, err
+	}
+
+	coseKey, _, err := decodeCBOR(publicKey)
+	if err != nil {
+		return Assertion{}, fmt.Errorf("%w: %w", ErrUnsupportedKey, err)
+	}
+
+	key, err := ecdsaKey(coseKey)
+	if err != nil {
+		return Assertion{}, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(bytes.Clone(authData), clientDataHash[:]...))
+
+	if !ecdsa.VerifyASN1(key, digest[:], signature) {
+		return Assertion{}, ErrInvalidSignature
+	}
+
+	return Assertion{SignCount: signCount, UserVerified: flags&flagUserVerified != 0}, nil
+}
+
+func (rp RelyingParty) verifyClientData(clientDataJSON []byte, ceremony string, challenge []byte) error {
+	clientData, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return err
+	}
+
+	if clientData.Type != ceremony {
+		return fmt.Errorf("%w: type %q", ErrInvalidClientData, clientData.Type)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(clientData.Challenge), []byte(encode(challenge))) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrInvalidClientData)
+	}
+
+	if !slices.Contains(rp.Origins, clientData.Origin) {
+		return fmt.Errorf("%w: origin %q", ErrInvalidClientData, clientData.Origin)
+	}
+
+	if clientData.CrossOrigin {
+		return fmt.Errorf("%w: cross-origin ceremony", ErrInvalidClientData)
+	}
+
+	return nil
+}
+
+// parseAuthData checks relying party and user presence of authenticator data
+// and returns its flags and signature counter
+func (rp RelyingParty) parseAuthData(authData []byte) (byte, uint32, error) {
+	if len(authData) < authDataLength {
+		return 0, 0, fmt.Errorf("%w: truncated", ErrInvalidAuthData)
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(authData[:sha256.Size], rpIDHash[:]) != 1 {
+		return 0, 0, fmt.Errorf("%w: created for another relying party", ErrInvalidAuthData)
+	}
+
+	flags := authData[sha256.Size]
+	if flags&flagUserPresent == 0 {
+		return 0, 0, fmt.Errorf("%w: user is not present", ErrInvalidAuthData)
+	}
+
+	return flags, binary.BigEndian.Uint32(authData[sha256.Size+1:]), nil
+}
+
+// ecdsaKey returns P-256 public key of decoded COSE key
+func ecdsaKey(coseKey any) (*ecdsa.PublicKey, error) {
+	params, ok := coseKey.(map[any]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: not a COSE key", ErrUnsupportedKey)
+	}
+
+	kty, _ := params[int64(coseKty)].(int64)
+	alg, _ := params[int64(coseAlg)].(int64)
+	crv, _ := params[int64(coseCrv)].(int64)
+
+	if kty != coseKtyEC2 || alg != AlgES256 || crv != coseCrvP256 {
+		return nil, fmt.Errorf("%w: only ES256 is supported", ErrUnsupportedKey)
+	}
+
+	x, _ := params[int64(coseX)].([]byte)
+	y, _ := params[int64(coseY)].([]byte)
+
+	if len(x) != p256CoordLen || len(y) != p256CoordLen {
+		return nil, fmt.Errorf("%w: invalid coordinates", ErrUnsupportedKey)
+	}
+
+	// ecdh rejects points which are not on the curve
+	point := append(append([]byte{4}, x...), y...)
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupportedKey, err)
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
+
+func descriptors(ids [][]byte) []CredentialDescriptor {
+	descriptors := make([]CredentialDescriptor, 0, len(ids))
+	for _, id := range ids {
+		descriptors = append(descriptors, CredentialDescriptor{Type: typePublicKey, ID: encode(id)})
+	}
+
+	return descriptors
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,358 @@
+package migrator
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// versionTable is the goose bookkeeping table, so databases migrated
+// by goose CLI before are picked up as is.
+const versionTable = "goose_db_version"
+
+var (
+	ErrNoMigrations = errors.New("no migrations applied")
+	ErrBadFileName  = errors.New("migration file name must start with version number")
+)
+
+// Locker guards migrations from running concurrently on several replicas
+type Locker interface {
+	Lock(ctx context.Context, conn *sql.Conn) error
+	Unlock(ctx context.Context, conn *sql.Conn) error
+}
+
+// Migration is a single goose formatted SQL file
+type Migration struct {
+	Version   int64
+	Name      string
+	Up        string
+	Down      string
+	NoTx      bool
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db     *sql.DB
+	fsys   fs.FS
+	locker Locker
+	schema string
+}
+
+// New returns migrator applying migrations from fsys to db.
+//
+// schema is a dialect specific DDL creating the version table.
+func New(db *sql.DB, fsys fs.FS, locker Locker, schema string) *Migrator {
+	return &Migrator{
+		db:     db,
+		fsys:   fsys,
+		locker: locker,
+		schema: schema,
+	}
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up(ctx context.Context) (applied []Migration, err error) {
+	const op = "migrator.Up"
+
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		migrations, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mg := range migrations {
+			if mg.Applied {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, mg, true); err != nil {
+				return err
+			}
+
+			mg.Applied, mg.AppliedAt = true, time.Now()
+			applied = append(applied, mg)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return applied, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the latest applied migration
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	const op = "migrator.Down"
+
+	var rolledBack Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		last, err := m.last(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if err := m.apply(ctx, conn, last, false); err != nil {
+			return err
+		}
+
+		rolledBack = last
+		rolledBack.Applied, rolledBack.AppliedAt = false, time.Time{}
+
+		return nil
+	})
+	if err != nil {
+		return Migration{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rolledBack, nil
+}
+
+// Redo rolls back the latest applied migration and applies it again
+func (m *Migrator) Redo(ctx context.Context) (Migration, error) {
+	const op = "migrator.Redo"
+
+	var redone Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		last, err := m.last(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		redone = last
+
+		if err := m.apply(ctx, conn, last, false); err != nil {
+			return err
+		}
+
+		return m.apply(ctx, conn, last, true)
+	})
+	if err != nil {
+		return Migration{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return redone, nil
+}
+
+// Status returns all known migrations with their applied state
+func (m *Migrator) Status(ctx context.Context) ([]Migration, error) {
+	const op = "migrator.Status"
+
+	var migrations []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) (err error) {
+		migrations, err = m.status(ctx, conn)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return migrations, nil
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := m.locker.Lock(ctx, conn); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if unlockErr := m.locker.Unlock(context.WithoutCancel(ctx), conn); unlockErr != nil && err == nil {
+			err = fmt.Errorf("failed to release migration lock: %w", unlockErr)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, m.schema); err != nil {
+		return fmt.Errorf("failed to create version table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) last(ctx context.Context, conn *sql.Conn) (Migration, error) {
+	migrations, err := m.status(ctx, conn)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if migrations[i].Applied {
+			return migrations[i], nil
+		}
+	}
+
+	return Migration{}, ErrNoMigrations
+}
+
+func (m *Migrator) status(ctx context.Context, conn *sql.Conn) ([]Migration, error) {
+	migrations, err := Load(m.fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx,
+		"SELECT version_id, tstamp FROM "+versionTable+" WHERE is_applied ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version int64
+			tstamp  sql.NullTime
+		)
+		if err := rows.Scan(&version, &tstamp); err != nil {
+			return nil, err
+		}
+
+		applied[version] = tstamp.Time
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range migrations {
+		if at, ok := applied[migrations[i].Version]; ok {
+			migrations[i].Applied = true
+			migrations[i].AppliedAt = at
+		}
+	}
+
+	return migrations, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mg Migration, up bool) error {
+	query, record := mg.Down, "DELETE FROM "+versionTable+" WHERE version_id = $1"
+	args := []any{mg.Version}
+	if up {
+		query, record = mg.Up, "INSERT INTO "+versionTable+" (version_id, is_applied) VALUES ($1, $2)"
+		args = append(args, true)
+	}
+
+	if mg.NoTx {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("migration %s: %w", mg.Name, err)
+		}
+		if _, err := conn.ExecContext(ctx, record, args...); err != nil {
+			return fmt.Errorf("migration %s: %w", mg.Name, err)
+		}
+
+		return nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("migration %s: %w", mg.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("migration %s: %w", mg.Name, err)
+	}
+
+	return tx.Commit()
+}
+
+// Load reads and parses all *.sql files of fsys sorted by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		version, err := parseVersion(name)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		mg := parse(string(data))
+		mg.Version = version
+		mg.Name = name
+
+		migrations = append(migrations, mg)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func parseVersion(name string) (int64, error) {
+	prefix, _, ok := strings.Cut(path.Base(name), "_")
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrBadFileName, name)
+	}
+
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("%w: %s", ErrBadFileName, name)
+	}
+
+	return version, nil
+}
+
+// parse splits goose annotated file into up and down parts
+func parse(data string) Migration {
+	var (
+		mg      Migration
+		up, dn  strings.Builder
+		current *strings.Builder
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose ")
+		if !ok {
+			if current != nil {
+				current.WriteString(line)
+				current.WriteByte('\n')
+			}
+
+			continue
+		}
+
+		switch strings.TrimSpace(annotation) {
+		case "Up":
+			current = &up
+		case "Down":
+			current = &dn
+		case "NO TRANSACTION":
+			mg.NoTx = true
+		}
+	}
+
+	mg.Up = up.String()
+	mg.Down = dn.String()
+
+	return mg
+}
@@ -0,0 +1,486 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/clock"
+	"gRPC/internal/lib/keys"
+	"gRPC/internal/lib/oauth"
+	"gRPC/internal/lib/random"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/storage"
+	"log/slog"
+	"time"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrAppNotFound  = errors.New("app not found")
+	ErrAppExists    = errors.New("app already exists")
+
+	ErrServiceAccountNotFound = errors.New("service account not found")
+	ErrServiceAccountExists   = errors.New("service account already exists")
+	ErrInvalidName            = errors.New("invalid name")
+	ErrInvalidPublicKey       = errors.New("invalid public key")
+
+	ErrExchangePolicyNotFound = errors.New("exchange policy not found")
+	ErrExchangePolicyExists   = errors.New("exchange policy already exists")
+
+	ErrInvalidRedirectURI = oauth.ErrInvalidRedirectURI
+	ErrInvalidScope       = oauth.ErrInvalidScope
+	ErrInvalidAudience    = oauth.ErrInvalidAudience
+)
+
+// secretSize is the number of random bytes in generated app secret
+const secretSize = 32
+
+// Admin contains operations available to operators only
+type Admin struct {
+	log        *slog.Logger
+	usrManager UserManager
+	appManager AppManager
+	keyManager KeyManager
+	saManager  ServiceAccountManager
+	auditor    Auditor
+	clock      clock.Clock
+}
+
+type Option func(a *Admin)
+
+// WithClock replaces the real clock, it must be the clock of auth service
+// for tokens issued before rotation of service account to be told apart
+func WithClock(clk clock.Clock) Option {
+	return func(a *Admin) {
+		a.clock = clk
+	}
+}
+
+// Auditor records security events
+type Auditor interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+type UserManager interface {
+	User(ctx context.Context, email string) (models.User, error)
+	Users(ctx context.Context, limit int, offset int) ([]models.User, error)
+	DisableUser(ctx context.Context, userID int64) error
+	SetAdmin(ctx context.Context, userID int64, isAdmin bool) error
+}
+
+type AppManager interface {
+	App(ctx context.Context, appID int) (models.App, error)
+	SaveApp(ctx context.Context, name string, secret string) (int, error)
+	Apps(ctx context.Context) ([]models.App, error)
+	UpdateAppSecret(ctx context.Context, appID int, secret string) error
+	UpdateAppOAuth(ctx context.Context, appID int, redirectURIs []string, scopes []string) error
+	UpdateAppAudiences(ctx context.Context, appID int, audiences []string) error
+	UpdateAppPasswordless(ctx context.Context, appID int, enabled bool) error
+	UpdateAppClientSecret(ctx context.Context, appID int, secretHash []byte) error
+}
+
+type KeyManager interface {
+	SaveSigningKey(ctx context.Context, key models.SigningKey) error
+	SigningKeys(ctx context.Context) ([]models.SigningKey, error)
+}
+
+type ServiceAccountManager interface {
+	SaveServiceAccount(ctx context.Context, account models.ServiceAccount) (int64, error)
+	ServiceAccount(ctx context.Context, id int64) (models.ServiceAccount, error)
+	ServiceAccounts(ctx context.Context, appID int) ([]models.ServiceAccount, error)
+	UpdateServiceAccountCredentials(
+		ctx context.Context, id int64, secretHash []byte, publicKey []byte, rotatedAt time.Time,
+	) error
+	DisableServiceAccount(ctx context.Context, id int64) error
+
+	ServiceAccountByClientID(ctx context.Context, clientID string) (models.ServiceAccount, error)
+	SaveExchangePolicy(ctx context.Context, policy models.ExchangePolicy) (int64, error)
+	ExchangePolicies(ctx context.Context, appID int) ([]models.ExchangePolicy, error)
+	DeleteExchangePolicy(ctx context.Context, id int64) error
+}
+
+// New returns new instance of Admin service.
+func New(
+	log *slog.Logger,
+	usrManager UserManager,
+	appManager AppManager,
+	keyManager KeyManager,
+	saManager ServiceAccountManager,
+	auditor Auditor,
+	opts ...Option,
+) *Admin {
+	a := &Admin{
+		log:        log,
+		usrManager: usrManager,
+		appManager: appManager,
+		keyManager: keyManager,
+		saManager:  saManager,
+		auditor:    auditor,
+		clock:      clock.Real{},
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// Users returns page of registered users
+func (a *Admin) Users(ctx context.Context, limit int, offset int) ([]models.User, error) {
+	const op = "Admin.Users"
+
+	users, err := a.usrManager.Users(ctx, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// DisableUser forbids user with given email to log in
+func (a *Admin) DisableUser(ctx context.Context, email string) error {
+	const op = "Admin.DisableUser"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	user, err := a.user(ctx, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.usrManager.DisableUser(ctx, user.ID); err != nil {
+		log.Error("failed to disable user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.record(ctx, models.AuditEvent{Type: models.EventUserDisable, UserID: user.ID, Email: user.Email})
+
+	log.Info("user disabled")
+
+	return nil
+}
+
+// PromoteUser grants admin status to user with given email
+func (a *Admin) PromoteUser(ctx context.Context, email string) error {
+	const op = "Admin.PromoteUser"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	user, err := a.user(ctx, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.usrManager.SetAdmin(ctx, user.ID, true); err != nil {
+		log.Error("failed to promote user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.record(ctx, models.AuditEvent{Type: models.EventUserPromote, UserID: user.ID, Email: user.Email})
+
+	log.Info("user promoted to admin")
+
+	return nil
+}
+
+// CreateApp registers new app with generated secret
+func (a *Admin) CreateApp(ctx context.Context, name string) (models.App, error) {
+	const op = "Admin.CreateApp"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("name", name),
+	)
+
+	secret, err := random.String(secretSize)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := a.appManager.SaveApp(ctx, name, secret)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppExists) {
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrAppExists)
+		}
+
+		log.Error("failed to save app", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.record(ctx, models.AuditEvent{Type: models.EventAppCreate, AppID: id})
+
+	log.Info("app created", slog.Int("app_id", id))
+
+	return models.App{ID: id, Name: name, Secret: secret}, nil
+}
+
+// Apps returns all registered apps
+func (a *Admin) Apps(ctx context.Context) ([]models.App, error) {
+	const op = "Admin.Apps"
+
+	apps, err := a.appManager.Apps(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return apps, nil
+}
+
+// RotateAppSecret replaces app secret, tokens signed by the old one become invalid
+func (a *Admin) RotateAppSecret(ctx context.Context, appID int) (string, error) {
+	const op = "Admin.RotateAppSecret"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	secret, err := random.String(secretSize)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.appManager.UpdateAppSecret(ctx, appID, secret); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		log.Error("failed to rotate app secret", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.record(ctx, models.AuditEvent{Type: models.EventAppSecretRotate, AppID: appID})
+
+	log.Info("app secret rotated")
+
+	return secret, nil
+}
+
+// RotateAppClientSecret makes app confidential OAuth client with new client secret,
+// only its digest is stored, so the secret is returned once
+func (a *Admin) RotateAppClientSecret(ctx context.Context, appID int) (string, error) {
+	const op = "Admin.RotateAppClientSecret"
+
+	secret, err := random.String(secretSize)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.updateAppClientSecret(ctx, appID, oauth.HashClientSecret(secret), "client secret rotated"); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return secret, nil
+}
+
+// MakeAppPublicClient removes client secret of app, it then uses OAuth flows as public client with PKCE
+func (a *Admin) MakeAppPublicClient(ctx context.Context, appID int) error {
+	const op = "Admin.MakeAppPublicClient"
+
+	if err := a.updateAppClientSecret(ctx, appID, nil, "public client"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (a *Admin) updateAppClientSecret(ctx context.Context, appID int, secretHash []byte, reason string) error {
+	log := a.log.With(
+		slog.String("op", "Admin.updateAppClientSecret"),
+		slog.Int("app_id", appID),
+	)
+
+	if err := a.appManager.UpdateAppClientSecret(ctx, appID, secretHash); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return ErrAppNotFound
+		}
+
+		log.Error("failed to update app client secret", sl.Err(err))
+		return err
+	}
+
+	a.record(ctx, models.AuditEvent{Type: models.EventAppOAuthUpdate, AppID: appID, Reason: reason})
+
+	log.Info("app client secret updated", slog.Bool("confidential", secretHash != nil))
+
+	return nil
+}
+
+// ConfigureAppOAuth sets redirect URIs and scopes app may use as OAuth client,
+// app without redirect URIs cannot use authorization code flow
+func (a *Admin) ConfigureAppOAuth(ctx context.Context, appID int, redirectURIs []string, scope string) (models.App, error) {
+	const op = "Admin.ConfigureAppOAuth"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	for _, uri := range redirectURIs {
+		if err := oauth.ValidateRedirectURI(uri); err != nil {
+			return models.App{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	scopes, err := oauth.ParseScope(scope)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.appManager.UpdateAppOAuth(ctx, appID, redirectURIs, scopes); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		log.Error("failed to update app", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.record(ctx, models.AuditEvent{Type: models.EventAppOAuthUpdate, AppID: appID})
+
+	log.Info("app OAuth settings updated")
+
+	app, err := a.appManager.App(ctx, appID)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app, nil
+}
+
+// ConfigureAppAudiences sets resource servers tokens of app may be issued for,
+// audience is space delimited like scope
+func (a *Admin) ConfigureAppAudiences(ctx context.Context, appID int, audience string) (models.App, error) {
+	const op = "Admin.ConfigureAppAudiences"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	audiences, err := oauth.ParseAudience(audience)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.appManager.UpdateAppAudiences(ctx, appID, audiences); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		log.Error("failed to update app", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.record(ctx, models.AuditEvent{Type: models.EventAppOAuthUpdate, AppID: appID})
+
+	log.Info("app audiences updated")
+
+	app, err := a.appManager.App(ctx, appID)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app, nil
+}
+
+// ConfigureAppPasswordless enables or disables login to app by email link or one-time code
+func (a *Admin) ConfigureAppPasswordless(ctx context.Context, appID int, enabled bool) (models.App, error) {
+	const op = "Admin.ConfigureAppPasswordless"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	if err := a.appManager.UpdateAppPasswordless(ctx, appID, enabled); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		log.Error("failed to update app", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	reason := "passwordless disabled"
+	if enabled {
+		reason = "passwordless enabled"
+	}
+
+	a.record(ctx, models.AuditEvent{Type: models.EventAppLoginUpdate, AppID: appID, Reason: reason})
+
+	log.Info("app passwordless login updated", slog.Bool("enabled", enabled))
+
+	app, err := a.appManager.App(ctx, appID)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app, nil
+}
+
+// RotateSigningKey generates new service signing key and makes it active.
+//
+// Previous keys are kept, so signatures made by them can still be verified.
+func (a *Admin) RotateSigningKey(ctx context.Context) (models.SigningKey, error) {
+	const op = "Admin.RotateSigningKey"
+
+	key, err := keys.Generate(time.Now().UTC())
+	if err != nil {
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.keyManager.SaveSigningKey(ctx, key); err != nil {
+		a.log.Error("failed to save signing key", slog.String("op", op), sl.Err(err))
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.record(ctx, models.AuditEvent{Type: models.EventSigningKeyRotate, Reason: "kid " + key.ID})
+
+	a.log.Info("signing key rotated", slog.String("op", op), slog.String("kid", key.ID))
+
+	return key, nil
+}
+
+// SigningKeys returns all service signing keys, the active one first
+func (a *Admin) SigningKeys(ctx context.Context) ([]models.SigningKey, error) {
+	const op = "Admin.SigningKeys"
+
+	signingKeys, err := a.keyManager.SigningKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return signingKeys, nil
+}
+
+// record records successful operator action
+func (a *Admin) record(ctx context.Context, event models.AuditEvent) {
+	event.Success = true
+	if event.Reason == "" {
+		event.Reason = "by_operator"
+	}
+
+	a.auditor.Record(ctx, event)
+}
+
+func (a *Admin) user(ctx context.Context, email string) (models.User, error) {
+	user, err := a.usrManager.User(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.User{}, ErrUserNotFound
+		}
+
+		return models.User{}, err
+	}
+
+	return user, nil
+}
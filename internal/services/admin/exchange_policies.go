@@ -0,0 +1,121 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/oauth"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/storage"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// CreateExchangePolicy lets service account of app with client id actor exchange tokens
+// of app users for tokens meant for audience, limited to scope. Audience must be one of the app.
+func (a *Admin) CreateExchangePolicy(
+	ctx context.Context, appID int, actor string, audience string, scope string,
+) (models.ExchangePolicy, error) {
+	const op = "Admin.CreateExchangePolicy"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+		slog.String("actor", actor),
+	)
+
+	scopes, err := oauth.ParseScope(scope)
+	if err != nil {
+		return models.ExchangePolicy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appManager.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.ExchangePolicy{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		return models.ExchangePolicy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !app.AllowsAudience(audience) {
+		return models.ExchangePolicy{}, fmt.Errorf("%s: %w: %q is not an audience of app", op, ErrInvalidAudience, audience)
+	}
+
+	account, err := a.saManager.ServiceAccountByClientID(ctx, actor)
+	if err != nil {
+		if errors.Is(err, storage.ErrServiceAccountNotFound) {
+			return models.ExchangePolicy{}, fmt.Errorf("%s: %w", op, ErrServiceAccountNotFound)
+		}
+
+		return models.ExchangePolicy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if account.AppID != appID {
+		return models.ExchangePolicy{}, fmt.Errorf("%s: %w: actor belongs to another app", op, ErrServiceAccountNotFound)
+	}
+
+	policy := models.ExchangePolicy{
+		AppID:     appID,
+		Actor:     actor,
+		Audience:  audience,
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	policy.ID, err = a.saManager.SaveExchangePolicy(ctx, policy)
+	if err != nil {
+		if errors.Is(err, storage.ErrExchangePolicyExists) {
+			return models.ExchangePolicy{}, fmt.Errorf("%s: %w", op, ErrExchangePolicyExists)
+		}
+
+		log.Error("failed to save exchange policy", sl.Err(err))
+		return models.ExchangePolicy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.record(ctx, models.AuditEvent{
+		Type:   models.EventExchangePolicyCreate,
+		AppID:  appID,
+		Reason: "actor " + actor + " audience " + audience,
+	})
+
+	log.Info("exchange policy created", slog.Int64("id", policy.ID))
+
+	return policy, nil
+}
+
+// ExchangePolicies returns token exchange policies of app
+func (a *Admin) ExchangePolicies(ctx context.Context, appID int) ([]models.ExchangePolicy, error) {
+	const op = "Admin.ExchangePolicies"
+
+	policies, err := a.saManager.ExchangePolicies(ctx, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return policies, nil
+}
+
+// DeleteExchangePolicy stops token exchange the policy allowed,
+// tokens exchanged before stay valid until they expire
+func (a *Admin) DeleteExchangePolicy(ctx context.Context, id int64) error {
+	const op = "Admin.DeleteExchangePolicy"
+
+	if err := a.saManager.DeleteExchangePolicy(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrExchangePolicyNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrExchangePolicyNotFound)
+		}
+
+		a.log.Error("failed to delete exchange policy", slog.String("op", op), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.record(ctx, models.AuditEvent{
+		Type:   models.EventExchangePolicyDelete,
+		Reason: "policy " + strconv.FormatInt(id, 10),
+	})
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/keys"
+	"gRPC/internal/lib/oauth"
+	"gRPC/internal/lib/random"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/storage"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	// clientIDPrefix tells service account client ids from app ids
+	clientIDPrefix = "sa_"
+	clientIDSize   = 12
+)
+
+// CreateServiceAccount registers service account of app with generated client id and secret.
+// The secret is returned only here, it is stored hashed.
+//
+// publicKey is optional PEM of RSA key the account may sign client assertions with.
+func (a *Admin) CreateServiceAccount(
+	ctx context.Context, appID int, name string, scope string, publicKey []byte,
+) (models.ServiceAccount, string, error) {
+	const op = "Admin.CreateServiceAccount"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+		slog.String("name", name),
+	)
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.ServiceAccount{}, "", fmt.Errorf("%s: %w: name is required", op, ErrInvalidName)
+	}
+
+	scopes, err := oauth.ParseScope(scope)
+	if err != nil {
+		return models.ServiceAccount{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := validatePublicKey(publicKey); err != nil {
+		return models.ServiceAccount{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := a.appManager.App(ctx, appID); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.ServiceAccount{}, "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		return models.ServiceAccount{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	clientID, err := random.String(clientIDSize)
+	if err != nil {
+		return models.ServiceAccount{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := random.String(secretSize)
+	if err != nil {
+		return models.ServiceAccount{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	account := models.ServiceAccount{
+		AppID:      appID,
+		Name:       name,
+		ClientID:   clientIDPrefix + clientID,
+		SecretHash: oauth.HashClientSecret(secret),
+		PublicKey:  publicKey,
+		Scopes:     scopes,
+		CreatedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+
+	account.ID, err = a.saManager.SaveServiceAccount(ctx, account)
+	if err != nil {
+		if errors.Is(err, storage.ErrServiceAccountExists) {
+			return models.ServiceAccount{}, "", fmt.Errorf("%s: %w", op, ErrServiceAccountExists)
+		}
+
+		log.Error("failed to save service account", sl.Err(err))
+		return models.ServiceAccount{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.record(ctx, models.AuditEvent{
+		Type:   models.EventServiceAccountCreate,
+		AppID:  appID,
+		Reason: "client_id " + account.ClientID,
+	})
+
+	log.Info("service account created", slog.String("client_id", account.ClientID))
+
+	return account, secret, nil
+}
+
+// ServiceAccounts returns service accounts of app
+func (a *Admin) ServiceAccounts(ctx context.Context, appID int) ([]models.ServiceAccount, error) {
+	const op = "Admin.ServiceAccounts"
+
+	accounts, err := a.saManager.ServiceAccounts(ctx, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return accounts, nil
+}
+
+// RotateServiceAccountSecret replaces client secret of service account and returns the new one.
+// Non-empty publicKey replaces the registered key, otherwise the key is kept.
+//
+// Tokens issued before are no longer valid.
+func (a *Admin) RotateServiceAccountSecret(ctx context.Context, id int64, publicKey []byte) (string, error) {
+	const op = "Admin.RotateServiceAccountSecret"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("service_account_id", id),
+	)
+
+	if err := validatePublicKey(publicKey); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	account, err := a.serviceAccount(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(publicKey) == 0 {
+		publicKey = account.PublicKey
+	}
+
+	secret, err := random.String(secretSize)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.saManager.UpdateServiceAccountCredentials(ctx, id, oauth.HashClientSecret(secret), publicKey, a.clock.Now())
+	if err != nil {
+		if errors.Is(err, storage.ErrServiceAccountNotFound) {
+			return "", fmt.Errorf("%s: %w", op, ErrServiceAccountNotFound)
+		}
+
+		log.Error("failed to rotate service account secret", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.record(ctx, models.AuditEvent{
+		Type:   models.EventServiceAccountRotate,
+		AppID:  account.AppID,
+		Reason: "client_id " + account.ClientID,
+	})
+
+	log.Info("service account secret rotated")
+
+	return secret, nil
+}
+
+// DisableServiceAccount forbids service account to get new tokens,
+// tokens issued before stay valid until they expire
+func (a *Admin) DisableServiceAccount(ctx context.Context, id int64) error {
+	const op = "Admin.DisableServiceAccount"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("service_account_id", id),
+	)
+
+	account, err := a.serviceAccount(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.saManager.DisableServiceAccount(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrServiceAccountNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrServiceAccountNotFound)
+		}
+
+		log.Error("failed to disable service account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.record(ctx, models.AuditEvent{
+		Type:   models.EventServiceAccountDisable,
+		AppID:  account.AppID,
+		Reason: "client_id " + account.ClientID,
+	})
+
+	log.Info("service account disabled")
+
+	return nil
+}
+
+func (a *Admin) serviceAccount(ctx context.Context, id int64) (models.ServiceAccount, error) {
+	account, err := a.saManager.ServiceAccount(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrServiceAccountNotFound) {
+			return models.ServiceAccount{}, ErrServiceAccountNotFound
+		}
+
+		return models.ServiceAccount{}, err
+	}
+
+	return account, nil
+}
+
+// validatePublicKey accepts empty key, the account then authenticates with its secret only
+func validatePublicKey(publicKey []byte) error {
+	if len(publicKey) == 0 {
+		return nil
+	}
+
+	if _, err := keys.ParsePublicKey(publicKey); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPublicKey, err)
+	}
+
+	return nil
+}
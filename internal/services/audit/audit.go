@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/clock"
+	"gRPC/internal/lib/sl"
+	"log/slog"
+	"time"
+)
+
+const (
+	// DefaultLimit is page size used when filter does not set one
+	DefaultLimit = 100
+	MaxLimit     = 1000
+
+	// exportBatch is how many events are read at once during export
+	exportBatch = 500
+)
+
+// Audit keeps the log of security events
+type Audit struct {
+	log        *slog.Logger
+	storage    Storage
+	clock      clock.Clock
+	publishers []Publisher
+}
+
+type Storage interface {
+	SaveAuditEvent(ctx context.Context, seal func(prevHash string) models.AuditEvent) (int64, error)
+	AuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+	LastAuditEvent(ctx context.Context) (models.AuditEvent, error)
+	SaveAuditCheckpoint(ctx context.Context, checkpoint models.AuditCheckpoint) error
+	AuditCheckpoints(ctx context.Context) ([]models.AuditCheckpoint, error)
+	SigningKeys(ctx context.Context) ([]models.SigningKey, error)
+}
+
+// Publisher passes saved events to whoever follows them live
+type Publisher interface {
+	Publish(event models.AuditEvent)
+}
+
+// Option configures optional dependencies of Audit
+type Option func(a *Audit)
+
+// WithPublisher makes Audit publish every saved event to p, it may be given several times
+func WithPublisher(p Publisher) Option {
+	return func(a *Audit) {
+		a.publishers = append(a.publishers, p)
+	}
+}
+
+// New returns new instance of Audit service.
+func New(log *slog.Logger, storage Storage, clk clock.Clock, opts ...Option) *Audit {
+	a := &Audit{
+		log:     log,
+		storage: storage,
+		clock:   clk,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// Record appends event to the chain, the time is set to now if missing.
+//
+// Failure to record is logged and does not fail the audited action.
+func (a *Audit) Record(ctx context.Context, event models.AuditEvent) {
+	const op = "Audit.Record"
+
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = a.clock.Now()
+	}
+	// storages keep microseconds, the hash has to survive the round trip
+	event.CreatedAt = event.CreatedAt.UTC().Truncate(time.Microsecond)
+
+	// the action is already done, so the event is saved even if the caller gave up
+	ctx = context.WithoutCancel(ctx)
+
+	id, err := a.storage.SaveAuditEvent(ctx, func(prevHash string) models.AuditEvent {
+		event.PrevHash = prevHash
+		event.Hash = hashEvent(event)
+
+		return event
+	})
+	if err != nil {
+		a.log.Error("failed to record audit event",
+			slog.String("op", op),
+			slog.String("type", event.Type),
+			slog.Int64("uid", event.UserID),
+			sl.Err(err),
+		)
+
+		return
+	}
+
+	event.ID = id
+	for _, p := range a.publishers {
+		p.Publish(event)
+	}
+}
+
+// Events returns page of events matching filter and cursor of the next page,
+// which is zero when there are no more events
+func (a *Audit) Events(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, int64, error) {
+	const op = "Audit.Events"
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultLimit
+	}
+	filter.Limit = min(filter.Limit, MaxLimit)
+
+	events, err := a.storage.AuditEvents(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var next int64
+	if len(events) == filter.Limit {
+		next = events[len(events)-1].ID
+	}
+
+	return events, next, nil
+}
+
+// Export calls fn for every event matching filter, filter.Limit is ignored
+func (a *Audit) Export(ctx context.Context, filter models.AuditFilter, fn func(models.AuditEvent) error) error {
+	const op = "Audit.Export"
+
+	filter.Limit = exportBatch
+
+	for {
+		events, err := a.storage.AuditEvents(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		if len(events) < exportBatch {
+			return nil
+		}
+
+		filter.AfterID = events[len(events)-1].ID
+	}
+}
@@ -0,0 +1,225 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/keys"
+	"gRPC/internal/storage"
+	"time"
+)
+
+var (
+	ErrEmptyLog     = errors.New("audit log is empty")
+	ErrNoSigningKey = errors.New("no signing key")
+)
+
+// sealedEvent is what Hash of an event covers, everything but the id assigned by storage
+type sealedEvent struct {
+	PrevHash  string `json:"prev_hash"`
+	Type      string `json:"type"`
+	UserID    int64  `json:"user_id"`
+	Email     string `json:"email"`
+	AppID     int    `json:"app_id"`
+	ActorID   int64  `json:"actor_id"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	Success   bool   `json:"success"`
+	Reason    string `json:"reason"`
+	CreatedAt string `json:"created_at"`
+}
+
+func hashEvent(e models.AuditEvent) string {
+	// marshaling a struct of plain fields does not fail
+	data, _ := json.Marshal(sealedEvent{
+		PrevHash:  e.PrevHash,
+		Type:      e.Type,
+		UserID:    e.UserID,
+		Email:     e.Email,
+		AppID:     e.AppID,
+		ActorID:   e.ActorID,
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		Success:   e.Success,
+		Reason:    e.Reason,
+		CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// checkpointPayload is what checkpoint signature covers
+func checkpointPayload(eventID int64, hash string) []byte {
+	return []byte(fmt.Sprintf("audit-checkpoint:%d:%s", eventID, hash))
+}
+
+// Checkpoint signs the end of the chain with the newest signing key.
+//
+// When nothing was recorded since the latest checkpoint, that checkpoint is returned.
+func (a *Audit) Checkpoint(ctx context.Context) (models.AuditCheckpoint, error) {
+	const op = "Audit.Checkpoint"
+
+	last, err := a.storage.LastAuditEvent(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrAuditEventNotFound) {
+			return models.AuditCheckpoint{}, fmt.Errorf("%s: %w", op, ErrEmptyLog)
+		}
+
+		return models.AuditCheckpoint{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// events recorded before the chain was introduced have nothing to sign
+	if last.Hash == "" {
+		return models.AuditCheckpoint{}, fmt.Errorf("%s: %w", op, ErrEmptyLog)
+	}
+
+	checkpoints, err := a.storage.AuditCheckpoints(ctx)
+	if err != nil {
+		return models.AuditCheckpoint{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n := len(checkpoints); n > 0 && checkpoints[n-1].EventID == last.ID {
+		return checkpoints[n-1], nil
+	}
+
+	signingKeys, err := a.storage.SigningKeys(ctx)
+	if err != nil {
+		return models.AuditCheckpoint{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(signingKeys) == 0 {
+		return models.AuditCheckpoint{}, fmt.Errorf("%s: %w", op, ErrNoSigningKey)
+	}
+
+	signature, err := keys.Sign(signingKeys[0], checkpointPayload(last.ID, last.Hash))
+	if err != nil {
+		return models.AuditCheckpoint{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	checkpoint := models.AuditCheckpoint{
+		EventID:   last.ID,
+		Hash:      last.Hash,
+		KeyID:     signingKeys[0].ID,
+		Signature: signature,
+		CreatedAt: a.clock.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	if err := a.storage.SaveAuditCheckpoint(ctx, checkpoint); err != nil {
+		return models.AuditCheckpoint{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return checkpoint, nil
+}
+
+// Verify walks the whole chain and reports the first broken link.
+//
+// Every event has to match its hash and link to the previous one,
+// every checkpoint has to be validly signed and match the event it covers.
+// Events recorded before the chain was introduced are skipped.
+func (a *Audit) Verify(ctx context.Context) (models.AuditVerification, error) {
+	const op = "Audit.Verify"
+
+	checkpoints, err := a.storage.AuditCheckpoints(ctx)
+	if err != nil {
+		return models.AuditVerification{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	signingKeys, err := a.storage.SigningKeys(ctx)
+	if err != nil {
+		return models.AuditVerification{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	keyByID := make(map[string]models.SigningKey, len(signingKeys))
+	for _, key := range signingKeys {
+		keyByID[key.ID] = key
+	}
+
+	pending := make(map[int64][]models.AuditCheckpoint, len(checkpoints))
+	for _, c := range checkpoints {
+		pending[c.EventID] = append(pending[c.EventID], c)
+	}
+
+	var (
+		res      models.AuditVerification
+		prevHash string
+		sealed   bool
+	)
+	broken := func(eventID int64, reason string) (models.AuditVerification, error) {
+		res.BrokenAt, res.Reason = eventID, reason
+		return res, nil
+	}
+
+	filter := models.AuditFilter{Limit: exportBatch}
+	for {
+		events, err := a.storage.AuditEvents(ctx, filter)
+		if err != nil {
+			return models.AuditVerification{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, e := range events {
+			if e.Hash == "" {
+				if sealed {
+					return broken(e.ID, "event is not sealed")
+				}
+
+				continue
+			}
+			sealed = true
+
+			if e.PrevHash != prevHash {
+				return broken(e.ID, "event does not link to the previous one")
+			}
+			if hashEvent(e) != e.Hash {
+				return broken(e.ID, "event does not match its hash")
+			}
+
+			for _, c := range pending[e.ID] {
+				if c.Hash != e.Hash {
+					return broken(e.ID, fmt.Sprintf("checkpoint %d does not match the event", c.ID))
+				}
+
+				key, ok := keyByID[c.KeyID]
+				if !ok {
+					return broken(e.ID, fmt.Sprintf("checkpoint %d is signed by unknown key %s", c.ID, c.KeyID))
+				}
+
+				if err := keys.Verify(key, checkpointPayload(c.EventID, c.Hash), c.Signature); err != nil {
+					return broken(e.ID, fmt.Sprintf("checkpoint %d has invalid signature", c.ID))
+				}
+
+				res.Checkpoints++
+			}
+			delete(pending, e.ID)
+
+			prevHash = e.Hash
+			res.Events++
+		}
+
+		if len(events) < exportBatch {
+			break
+		}
+
+		filter.AfterID = events[len(events)-1].ID
+	}
+
+	// checkpointed events which were not met are deleted, the earliest is reported
+	var missing int64
+	for eventID := range pending {
+		if missing == 0 || eventID < missing {
+			missing = eventID
+		}
+	}
+	if missing != 0 {
+		return broken(missing, "checkpointed event is missing")
+	}
+
+	res.Valid = true
+
+	return res, nil
+}
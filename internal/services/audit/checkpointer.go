@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"gRPC/internal/lib/sl"
+	"log/slog"
+	"time"
+)
+
+// Checkpointer signs the end of audit chain periodically
+type Checkpointer struct {
+	log      *slog.Logger
+	audit    *Audit
+	interval time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewCheckpointer returns Checkpointer, zero interval disables checkpoints
+func NewCheckpointer(log *slog.Logger, audit *Audit, interval time.Duration) *Checkpointer {
+	return &Checkpointer{
+		log:      log,
+		audit:    audit,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Run makes checkpoints until Stop is called
+func (c *Checkpointer) Run() {
+	const op = "audit.Checkpointer.Run"
+
+	defer close(c.done)
+
+	if c.interval <= 0 {
+		return
+	}
+
+	log := c.log.With(slog.String("op", op))
+	log.Info("audit checkpoints are running", slog.Duration("interval", c.interval))
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+
+		checkpoint, err := c.audit.Checkpoint(context.Background())
+		switch {
+		case errors.Is(err, ErrEmptyLog):
+		case errors.Is(err, ErrNoSigningKey):
+			log.Warn("audit checkpoint skipped, signing key is not generated yet")
+		case err != nil:
+			log.Error("failed to make audit checkpoint", sl.Err(err))
+		default:
+			log.Debug("audit checkpoint made", slog.Int64("event_id", checkpoint.EventID))
+		}
+	}
+}
+
+// Stop stops Run and waits for it to return
+func (c *Checkpointer) Stop() {
+	close(c.stop)
+	<-c.done
+}
@@ -5,19 +5,30 @@ import (
 	"errors"
 	"fmt"
 	"gRPC/internal/domain/models"
-	codesender "gRPC/internal/lib/email"
+	"gRPC/internal/kv"
+	"gRPC/internal/lib/clock"
+	"gRPC/internal/lib/email"
 	"gRPC/internal/lib/jwt"
+	"gRPC/internal/lib/password"
+	"gRPC/internal/lib/random"
 	"gRPC/internal/lib/sl"
+	"gRPC/internal/lib/webauthn"
 	"gRPC/internal/storage"
-	"gRPC/internal/storage/postgres"
 	"golang.org/x/crypto/bcrypt"
 	"log/slog"
-	"strconv"
 	"time"
 )
 
 var (
-	ErrUserExists = errors.New("user already exists")
+	ErrUserExists   = errors.New("user already exists")
+	ErrUserNotFound = errors.New("user not found")
+	ErrInvalidAppID = errors.New("invalid app id")
+	ErrInvalidCode  = errors.New("invalid confirmation code")
+	ErrUserDisabled = errors.New("user is disabled")
+	ErrInvalidToken = errors.New("invalid token")
+
+	ErrTooManyAttempts = errors.New("too many attempts")
+	ErrUnavailable     = errors.New("service is temporarily unavailable")
 )
 
 type Auth struct {
@@ -26,16 +37,42 @@ type Auth struct {
 	usrProvider  UserProvider
 	appProvider  AppProvider
 	codeProvider CodeProvider
+	sessions     SessionStorage
+	auditor      Auditor
+	emailSender  EmailSender
+	kv           kv.Store
+	limits       kv.Store
+	hasher       password.Hasher
+	policy       *password.Policy
+	history      int
+	maxAge       time.Duration
+	expiringApps []int
+	events       *EventBus
+	issuer       string
+	signingKeys  KeyProvider
+	accounts     ServiceAccountProvider
+	linkURL      string
+	loginCodeTTL time.Duration
+	rp           webauthn.RelyingParty
+	passkeys     PasskeyStorage
+	passkeyTTL   time.Duration
+	smsSender    SMSSender
+	phones       PhoneStorage
+	smsCodeTTL   time.Duration
+	clock        clock.Clock
 	tokenTTL     time.Duration
 }
 
 type UserSaver interface {
 	SaveUser(ctx context.Context, email string, passHash []byte, verCode []byte) (uid int64, err error)
+	UpdatePassword(ctx context.Context, userID int64, passHash []byte) error
+	SavePasswordHistory(ctx context.Context, entry models.PasswordHistoryEntry) error
 }
 
 type UserProvider interface {
 	User(ctx context.Context, email string) (user models.User, err error)
 	IsAdmin(ctx context.Context, userID int64) (status bool, err error)
+	PasswordHistory(ctx context.Context, userID int64, limit int) ([]models.PasswordHistoryEntry, error)
 }
 
 type AppProvider interface {
@@ -44,30 +81,163 @@ type AppProvider interface {
 
 type CodeProvider interface {
 	ValidateCode(ctx context.Context, email string) (code string, err error)
+	AcceptCode(ctx context.Context, email string) error
+}
+
+// Auditor records security events
+type Auditor interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+type EmailSender interface {
+	Send(ctx context.Context, msg email.Message) error
+}
+
+// Option configures optional dependencies of Auth
+type Option func(a *Auth)
+
+// WithEmailSender sets sender of confirmation emails, they are logged by default
+func WithEmailSender(sender EmailSender) Option {
+	return func(a *Auth) {
+		a.emailSender = sender
+	}
+}
+
+// WithKV sets store of one-time codes and attempt counters, attempts are not limited
+// and flows relying on one-time codes are unavailable without it
+func WithKV(store kv.Store) Option {
+	return func(a *Auth) {
+		a.kv = store
+	}
+}
+
+// WithRateLimits sets store of attempt counters alone, e.g. kv.FailOpen one,
+// the store of WithKV keeps them by default. One-time codes never go there.
+func WithRateLimits(store kv.Store) Option {
+	return func(a *Auth) {
+		a.limits = store
+	}
+}
+
+// WithPasswordHasher sets hasher of passwords, bcrypt with default cost by default
+func WithPasswordHasher(hasher password.Hasher) Option {
+	return func(a *Auth) {
+		a.hasher = hasher
+	}
+}
+
+// WithPasswordPolicy sets rules new passwords are checked against, any non-empty password is accepted by default
+func WithPasswordPolicy(policy password.Policy) Option {
+	return func(a *Auth) {
+		a.policy = &policy
+	}
+}
+
+// WithPasswordHistory forbids reusing depth latest passwords, they may be reused by default
+func WithPasswordHistory(depth int) Option {
+	return func(a *Auth) {
+		a.history = depth
+	}
+}
+
+// WithPasswordExpiry makes passwords older than maxAge expire on login to given apps,
+// or to every app when none are given. Passwords do not expire by default.
+func WithPasswordExpiry(maxAge time.Duration, appIDs ...int) Option {
+	return func(a *Auth) {
+		a.maxAge = maxAge
+		a.expiringApps = appIDs
+	}
+}
+
+// WithEventBus sets bus admins watch events on, watching is unavailable without it
+func WithEventBus(bus *EventBus) Option {
+	return func(a *Auth) {
+		a.events = bus
+	}
+}
+
+// WithClock sets clock used for token timestamps, system clock by default
+func WithClock(clk clock.Clock) Option {
+	return func(a *Auth) {
+		a.clock = clk
+	}
 }
 
 var (
 	InvalidCredentials = errors.New("invalid credentials")
 )
 
+const (
+	// codeLength is the number of digits in confirmation code
+	codeLength  = 6
+	codeSubject = "GRPC server register message"
+
+	// reasons recorded in audit events
+	reasonUserNotFound    = "user_not_found"
+	reasonInvalidPassword = "invalid_password"
+	reasonUserDisabled    = "user_disabled"
+	reasonInvalidApp      = "invalid_app"
+	reasonUserExists      = "user_exists"
+	reasonInvalidCode     = "invalid_code"
+	reasonTooManyAttempts = "too_many_attempts"
+	reasonByOperator      = "by_operator"
+	reasonWeakPassword    = "weak_password"
+	reasonPasswordExpired = "password_expired"
+
+	// maxCodeAttempts is how many codes may be checked or requested per email within codeAttemptsWindow
+	maxCodeAttempts    = 5
+	codeAttemptsWindow = 15 * time.Minute
+)
+
 // New returns new instance of Auth service.
-func New(log *slog.Logger, usrSaver UserSaver, usrProvider UserProvider, appProvider AppProvider, codeProvider CodeProvider, tokenTTL time.Duration) *Auth {
-	return &Auth{
+func New(
+	log *slog.Logger,
+	usrSaver UserSaver,
+	usrProvider UserProvider,
+	appProvider AppProvider,
+	codeProvider CodeProvider,
+	sessions SessionStorage,
+	auditor Auditor,
+	tokenTTL time.Duration,
+	opts ...Option,
+) *Auth {
+	a := &Auth{
 		usrSaver:     usrSaver,
 		usrProvider:  usrProvider,
 		log:          log,
 		appProvider:  appProvider,
 		codeProvider: codeProvider,
+		sessions:     sessions,
+		auditor:      auditor,
+		emailSender:  email.NewLogSender(log),
+		hasher:       password.Bcrypt{Cost: bcrypt.DefaultCost},
+		loginCodeTTL: defaultLoginCodeTTL,
+		passkeyTTL:   defaultPasskeyTTL,
+		smsCodeTTL:   defaultSMSCodeTTL,
+		clock:        clock.Real{},
 		tokenTTL:     tokenTTL,
 	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if a.limits == nil {
+		a.limits = a.kv
+	}
+
+	return a
 }
 
 // Login verifies if given credentials exist in the system
+// and starts new session on the client
 //
 // If user exists, but password is incorrect returns error
 // If user do not exist, returns error
+// If password has expired, returns *PasswordChangeRequiredError
+// If user has verified phone, returns *SecondFactorRequiredError
 func (a *Auth) Login(
-	ctx context.Context, email string, password string, appID int,
+	ctx context.Context, email string, pass string, appID int, client ClientInfo,
 ) (string, error) {
 	const op = "Auth.Login"
 
@@ -78,69 +248,287 @@ func (a *Auth) Login(
 
 	log.Info("logging into user account")
 
-	user, err := a.usrProvider.User(ctx, email)
+	event := models.AuditEvent{
+		Type:      models.EventLogin,
+		Email:     email,
+		AppID:     appID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	user, err := a.verifyCredentials(ctx, log, event, pass)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.UserID = user.ID
+
+	if err := a.requireSecondFactor(ctx, log, user, smsLoginState{AppID: appID}, event); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := a.issueToken(ctx, log, user, appID, client, nil, nil, passwordAuthentication)
+	if err != nil {
+		if errors.Is(err, ErrInvalidAppID) {
+			a.auditFailure(ctx, event, reasonInvalidApp)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.Success = true
+	a.auditor.Record(ctx, event)
+
+	log.Info("Successful logging")
+	return token, nil
+}
+
+// verifyCredentials checks password of event.Email logging into event.AppID and audits
+// failures as event. Expired password is reported as *PasswordChangeRequiredError.
+func (a *Auth) verifyCredentials(
+	ctx context.Context, log *slog.Logger, event models.AuditEvent, pass string,
+) (models.User, error) {
+	user, err := a.usrProvider.User(ctx, event.Email)
 	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			a.auditFailure(ctx, event, reasonUserNotFound)
+
+			return models.User{}, InvalidCredentials
+		}
+
 		log.Error("failed to login into user account", sl.Err(err))
+		return models.User{}, err
+	}
+
+	event.UserID = user.ID
+
+	if err := a.hasher.Verify(user.PassHash, pass); err != nil {
+		log.Info("invalid credentials", sl.Err(err))
+		a.auditFailure(ctx, event, reasonInvalidPassword)
+
+		return models.User{}, InvalidCredentials
+	}
+
+	if user.Disabled {
+		log.Warn("user is disabled")
+		a.auditFailure(ctx, event, reasonUserDisabled)
+
+		return models.User{}, ErrUserDisabled
+	}
+
+	a.rehashPassword(ctx, log, user, pass)
+
+	expired, err := a.passwordExpired(ctx, user, event.AppID)
+	if err != nil {
+		log.Error("failed to check password age", sl.Err(err))
+		return models.User{}, err
+	}
+
+	if expired {
+		changeToken, err := a.issuePasswordChangeToken(ctx, user)
+		if err != nil {
+			log.Error("failed to issue password change token", sl.Err(err))
+			return models.User{}, err
+		}
+
+		log.Info("password expired")
+		a.auditFailure(ctx, event, reasonPasswordExpired)
+
+		return models.User{}, &PasswordChangeRequiredError{Token: changeToken}
+	}
+
+	return user, nil
+}
+
+// rehashPassword replaces hash of just verified password when it was made
+// with outdated algorithm or parameters, failure does not fail the login
+func (a *Auth) rehashPassword(ctx context.Context, log *slog.Logger, user models.User, pass string) {
+	if !a.hasher.NeedsRehash(user.PassHash) {
+		return
+	}
+
+	passHash, err := a.hasher.Hash(pass)
+	if err != nil {
+		log.Error("failed to rehash password", sl.Err(err))
+		return
+	}
+
+	if err := a.usrSaver.UpdatePassword(ctx, user.ID, passHash); err != nil {
+		log.Error("failed to save rehashed password", sl.Err(err))
+		return
+	}
+
+	log.Info("password hash upgraded")
+}
+
+// IssueToken issues token for existing user without checking the password.
+//
+// It is meant for operators only and must not be exposed to end users.
+func (a *Auth) IssueToken(ctx context.Context, email string, appID int) (string, error) {
+	const op = "Auth.IssueToken"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("username", email),
+	)
+
+	user, err := a.usrProvider.User(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return "", fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
-		a.log.Info("invalid credentials", sl.Err(err))
+	token, err := a.issueToken(ctx, log, user, appID, ClientInfo{DeviceName: operatorDevice}, nil, nil, authentication{})
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.auditor.Record(ctx, models.AuditEvent{
+		Type:    models.EventTokenIssue,
+		UserID:  user.ID,
+		Email:   user.Email,
+		AppID:   appID,
+		Success: true,
+		Reason:  reasonByOperator,
+	})
+
+	log.Info("token issued")
 
-		return "", fmt.Errorf("%s: %w", op, InvalidCredentials)
+	return token, nil
+}
+
+// InspectToken verifies token signature, time claims and issuer and returns its claims
+func (a *Auth) InspectToken(ctx context.Context, token string) (map[string]any, error) {
+	const op = "Auth.InspectToken"
+
+	appID, err := jwt.UnverifiedAppID(token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
 	}
 
 	app, err := a.appProvider.App(ctx, appID)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims, err := jwt.ParseToken(token, app, a.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrInvalidToken, err)
 	}
 
-	log.Info("user logged successfully")
+	// tokens issued before iss claim was added have none
+	if iss, ok := claims["iss"]; ok && iss != a.issuer {
+		return nil, fmt.Errorf("%s: %w: issued by %v", op, ErrInvalidToken, iss)
+	}
+
+	return claims, nil
+}
 
-	token, err := jwt.CreateNewToken(user, app, a.tokenTTL)
+// issueToken starts session of user and returns its access token,
+// scopes limit what the token grants, nil stands for first-party token.
+// Audience nil stands for all audiences of app, authn is how the user proved identity.
+func (a *Auth) issueToken(
+	ctx context.Context, log *slog.Logger, user models.User, appID int, client ClientInfo,
+	scopes []string, audience []string, authn authentication,
+) (string, error) {
+	app, err := a.appProvider.App(ctx, appID)
 	if err != nil {
-		a.log.Error("Failed to create token", sl.Err(err))
-		return "", fmt.Errorf("%s :%w", op, err)
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+
+			return "", ErrInvalidAppID
+		}
+
+		return "", err
+	}
+
+	if audience == nil {
+		audience = app.Audiences
+	}
+
+	claims, err := a.registeredClaims(scopes, audience)
+	if err != nil {
+		return "", err
+	}
+
+	session, err := a.startSession(ctx, user, app, client, authn)
+	if err != nil {
+		log.Error("Failed to start session", sl.Err(err))
+		return "", err
+	}
+
+	authn.claims(&claims, session.CreatedAt)
+
+	token, err := jwt.CreateScopedToken(user, app, session, claims)
+	if err != nil {
+		log.Error("Failed to create token", sl.Err(err))
+		return "", err
 	}
 
-	log.Info("Successful logging")
 	return token, nil
 }
 
 // RegisterNewUser verifies if user with this email do not exist
 //
 // If user exists, returns error
-func (a *Auth) RegisterNewUser(ctx context.Context, email string, password string) (userID int64, err error) {
+func (a *Auth) RegisterNewUser(ctx context.Context, userEmail string, password string) (userID int64, err error) {
 	const op = "Auth.RegisterNewUser"
 
 	log := a.log.With(
 		slog.String("op", op),
-		slog.String("email", email),
+		slog.String("email", userEmail),
 	)
 
 	log.Info("registering new user")
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	event := models.AuditEvent{Type: models.EventRegister, Email: userEmail}
+
+	if err := a.checkPassword(password, userEmail); err != nil {
+		log.Info("password rejected by policy", sl.Err(err))
+		a.auditFailure(ctx, event, reasonWeakPassword)
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := a.hasher.Hash(password)
 	if err != nil {
 		log.Error("failed to generate password hash", sl.Err(err))
 		return 2, fmt.Errorf("%s: %w", op, err)
 	}
 
-	verificationCode, err := codesender.SendEmail(email)
-	fmt.Println(err)
+	code, err := random.Digits(codeLength)
 	if err != nil {
-		fmt.Println(err)
-		log.Error("Failed to send code")
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	stringCode := strconv.Itoa(verificationCode)
-	hashedCode, err := bcrypt.GenerateFromPassword([]byte(stringCode), bcrypt.DefaultCost)
+	err = a.emailSender.Send(ctx, email.Message{
+		To:      userEmail,
+		Subject: codeSubject,
+		Body:    code,
+	})
+	if err != nil {
+		log.Error("Failed to send code", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
 
-	id, err := a.usrSaver.SaveUser(ctx, email, passHash, hashedCode)
+	hashedCode, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := a.usrSaver.SaveUser(ctx, userEmail, passHash, hashedCode)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
 			log.Warn("user already exists", sl.Err(err))
+			a.auditFailure(ctx, event, reasonUserExists)
 
 			return 0, fmt.Errorf("%s: %w", op, ErrUserExists)
 		}
@@ -148,11 +536,71 @@ func (a *Auth) RegisterNewUser(ctx context.Context, email string, password strin
 		return 1, fmt.Errorf("%s: %w", op, err)
 	}
 
+	a.recordPassword(ctx, log, id, passHash)
+
+	event.UserID, event.Success = id, true
+	a.auditor.Record(ctx, event)
+
 	log.Info("user registered")
 
 	return id, nil
 }
 
+// CreateUser creates user without sending confirmation email.
+//
+// It is meant for operators, who create accounts on behalf of users.
+func (a *Auth) CreateUser(ctx context.Context, email string, password string) (int64, error) {
+	const op = "Auth.CreateUser"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	if err := a.checkPassword(password, email); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := a.hasher.Hash(password)
+	if err != nil {
+		log.Error("failed to generate password hash", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, err := random.Digits(codeLength)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	hashedCode, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := a.usrSaver.SaveUser(ctx, email, passHash, hashedCode)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			return 0, fmt.Errorf("%s: %w", op, ErrUserExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.recordPassword(ctx, log, id, passHash)
+
+	a.auditor.Record(ctx, models.AuditEvent{
+		Type:    models.EventUserCreate,
+		UserID:  id,
+		Email:   email,
+		Success: true,
+		Reason:  reasonByOperator,
+	})
+
+	log.Info("user created")
+
+	return id, nil
+}
+
 // ValidateCode verifies if confirmation code provided by user is valid
 //
 // If so return true, else false
@@ -168,26 +616,92 @@ func (a *Auth) ValidateCode(
 
 	log.Info("Trying to validate confirmation code")
 
+	event := models.AuditEvent{Type: models.EventCodeValidation, Email: email}
+
+	if err := a.countAttempt(ctx, codeAttemptsKey(email)); err != nil {
+		log.Warn("code attempt rejected", sl.Err(err))
+		if errors.Is(err, ErrTooManyAttempts) {
+			a.auditFailure(ctx, event, reasonTooManyAttempts)
+		}
+
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
 	dbCode, err := a.codeProvider.ValidateCode(ctx, email)
 	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			a.auditFailure(ctx, event, reasonUserNotFound)
+
+			return false, fmt.Errorf("%s: %w", op, ErrInvalidCode)
+		}
+
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(dbCode), []byte(code)); err != nil {
-		return false, fmt.Errorf("%s: %w", op, err)
+		log.Info("invalid confirmation code", sl.Err(err))
+		a.auditFailure(ctx, event, reasonInvalidCode)
+
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidCode)
 	}
 
-	err = postgres.AcceptCode(email)
-	if err != nil {
-		fmt.Println(err)
+	if err := a.codeProvider.AcceptCode(ctx, email); err != nil {
+		log.Error("failed to accept code", sl.Err(err))
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := a.resetAttempts(ctx, codeAttemptsKey(email)); err != nil {
+		log.Warn("failed to reset code attempts", sl.Err(err))
+	}
+
+	event.Success = true
+	a.auditor.Record(ctx, event)
+
 	log.Info("Code is valid")
 
 	return true, nil
 }
 
+// auditFailure records event of failed action with given reason
+func (a *Auth) auditFailure(ctx context.Context, event models.AuditEvent, reason string) {
+	event.Success = false
+	event.Reason = reason
+
+	a.auditor.Record(ctx, event)
+}
+
+// countAttempt registers attempt counted under key, such as check of confirmation
+// code, and returns ErrTooManyAttempts when the limit is exceeded
+func (a *Auth) countAttempt(ctx context.Context, key string) error {
+	if a.limits == nil {
+		return nil
+	}
+
+	attempts, err := a.limits.Incr(ctx, key, codeAttemptsWindow)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	if attempts > maxCodeAttempts {
+		return ErrTooManyAttempts
+	}
+
+	return nil
+}
+
+// resetAttempts forgets attempts counted under key
+func (a *Auth) resetAttempts(ctx context.Context, key string) error {
+	if a.limits == nil {
+		return nil
+	}
+
+	return a.limits.Delete(ctx, key)
+}
+
+func codeAttemptsKey(email string) string {
+	return "code_attempts:" + email
+}
+
 // IsAdmin verifies if user is admin
 //
 // If user is not admin, returns false, else true
@@ -205,6 +719,12 @@ func (a *Auth) IsAdmin(
 
 	isAdmin, err := a.usrProvider.IsAdmin(ctx, int64(userID))
 	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+
+			return false, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
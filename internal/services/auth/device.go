@@ -0,0 +1,608 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/kv"
+	"gRPC/internal/lib/random"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/storage"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Device authorization errors, RFC 8628 section 3.5
+var (
+	ErrAuthorizationPending = errors.New("authorization pending")
+	ErrSlowDown             = errors.New("polling too fast")
+	ErrAccessDenied         = errors.New("access denied")
+	ErrExpiredToken         = errors.New("device code expired")
+
+	ErrInvalidUserCode = errors.New("invalid user code")
+)
+
+const (
+	// deviceVerificationPath is the page of issuer the user approves device on
+	deviceVerificationPath = "/oauth/device"
+
+	// deviceCodeTTL is how long the user has to approve device
+	deviceCodeTTL = 10 * time.Minute
+	// devicePollInterval is the minimum time between token requests of device
+	devicePollInterval = 5 * time.Second
+	// slowDownStep is added to the interval every time the device polls too often, RFC 8628 section 3.5
+	slowDownStep = 5 * time.Second
+
+	// userCodeAlphabet has no vowels, so codes do not spell words, RFC 8628 section 6.1
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+
+	deviceStatusPending  = "pending"
+	deviceStatusApproved = "approved"
+	deviceStatusDenied   = "denied"
+
+	reasonDeviceCode = "device_code"
+)
+
+// DeviceAuthorization is returned to device, which shows the user code with
+// verification URI to the user and polls for token with the device code
+type DeviceAuthorization struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresIn               time.Duration
+	Interval                time.Duration
+}
+
+// DeviceRequest is device authorization the user is asked to approve
+type DeviceRequest struct {
+	App      models.App
+	Scopes   []string
+	UserCode string
+}
+
+// DeviceTokenRequest is token request with device_code grant
+type DeviceTokenRequest struct {
+	ClientID     int
+	ClientSecret string
+	DeviceCode   string
+	// Audience is a subset of the app audiences, empty audience requests all of them
+	Audience string
+}
+
+// deviceAuthorization is what device code stands for, it is kept in kv store
+type deviceAuthorization struct {
+	AppID    int      `json:"app_id"`
+	Scopes   []string `json:"scopes"`
+	UserCode string   `json:"user_code"`
+	// ExpiresAt is unix seconds, the entry is rewritten with the time left when the user decides
+	ExpiresAt int64  `json:"expires_at"`
+	Status    string `json:"status"`
+	UserID    int64  `json:"uid,omitempty"`
+	Email     string `json:"email,omitempty"`
+	AuthTime  int64  `json:"auth_time,omitempty"`
+	// AMR is how the user authenticated approving the device
+	AMR []string `json:"amr,omitempty"`
+}
+
+// StartDeviceAuthorization starts device flow of client app asking for scope.
+// Fails with ErrInvalidClient and ErrInvalidScope.
+func (a *Auth) StartDeviceAuthorization(ctx context.Context, clientID int, scope string) (DeviceAuthorization, error) {
+	const op = "Auth.StartDeviceAuthorization"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", clientID),
+	)
+
+	if a.kv == nil {
+		return DeviceAuthorization{}, fmt.Errorf("%s: %w: no store for device codes", op, ErrUnavailable)
+	}
+
+	// confidential clients authenticate when polling, device code is useless without their secret
+	app, err := a.oauthApp(ctx, clientID)
+	if err != nil {
+		return DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	scopes, err := a.grantScopes(scope, app)
+	if err != nil {
+		return DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deviceCode, err := random.String(sessionIDLength)
+	if err != nil {
+		return DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userCode, err := random.FromAlphabet(userCodeAlphabet, userCodeLength)
+	if err != nil {
+		return DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	device := deviceAuthorization{
+		AppID:     app.ID,
+		Scopes:    scopes,
+		UserCode:  userCode,
+		ExpiresAt: a.clock.Now().Add(deviceCodeTTL).Unix(),
+		Status:    deviceStatusPending,
+	}
+
+	if err := a.saveDevice(ctx, deviceCode, device); err != nil {
+		log.Error("failed to save device code", sl.Err(err))
+		return DeviceAuthorization{}, fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+	}
+
+	if err := a.kv.Set(ctx, userCodeKey(userCode), deviceCode, deviceCodeTTL); err != nil {
+		log.Error("failed to save user code", sl.Err(err))
+		return DeviceAuthorization{}, fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+	}
+
+	log.Info("device authorization started")
+
+	verificationURI := a.issuer + deviceVerificationPath
+
+	return DeviceAuthorization{
+		DeviceCode:              deviceCode,
+		UserCode:                formatUserCode(userCode),
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(formatUserCode(userCode)),
+		ExpiresIn:               deviceCodeTTL,
+		Interval:                devicePollInterval,
+	}, nil
+}
+
+// DeviceRequest returns pending device authorization of user code, so the user
+// sees which app asks for access before approving it. User codes are short, so lookups
+// of client count against its limit of guessing them.
+func (a *Auth) DeviceRequest(ctx context.Context, userCode string, client ClientInfo) (DeviceRequest, error) {
+	const op = "Auth.DeviceRequest"
+
+	if err := a.countAttempt(ctx, userCodeAttemptsKey(client.IP)); err != nil {
+		return DeviceRequest{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, device, err := a.pendingDevice(ctx, userCode)
+	if err != nil {
+		return DeviceRequest{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, device.AppID)
+	if err != nil {
+		return DeviceRequest{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return DeviceRequest{App: app, Scopes: device.Scopes, UserCode: formatUserCode(device.UserCode)}, nil
+}
+
+// AuthorizeDevice approves device of user code for the user with given credentials.
+//
+// Credential errors are the same as Login ones, unknown user code is InvalidCredentials too.
+func (a *Auth) AuthorizeDevice(ctx context.Context, userCode string, email string, pass string, client ClientInfo) error {
+	const op = "Auth.AuthorizeDevice"
+
+	if err := a.decideDeviceWithPassword(ctx, op, userCode, email, pass, true, client); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DenyDevice denies device of user code for the user with given credentials,
+// the device gets ErrAccessDenied on its next poll. Credentials are required, so that
+// whoever sees or guesses the code cannot deny it.
+//
+// Credential errors are the same as Login ones, unknown user code is InvalidCredentials too.
+func (a *Auth) DenyDevice(ctx context.Context, userCode string, email string, pass string, client ClientInfo) error {
+	const op = "Auth.DenyDevice"
+
+	if err := a.decideDeviceWithPassword(ctx, op, userCode, email, pass, false, client); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// decideDeviceWithPassword approves or denies device of user code for the user with given credentials,
+// attempts of client count against the same limit as looking user codes up. Unknown code fails
+// like wrong credentials, so the form does not tell which codes are pending.
+func (a *Auth) decideDeviceWithPassword(
+	ctx context.Context, op string, userCode string, email string, pass string, approve bool, client ClientInfo,
+) error {
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("username", email),
+	)
+
+	if err := a.countAttempt(ctx, userCodeAttemptsKey(client.IP)); err != nil {
+		return err
+	}
+
+	deviceCode, device, err := a.pendingDevice(ctx, userCode)
+	if err != nil {
+		if errors.Is(err, ErrInvalidUserCode) {
+			log.Info("unknown user code", sl.Err(err))
+			return InvalidCredentials
+		}
+
+		return err
+	}
+
+	event := models.AuditEvent{
+		Type:      models.EventLogin,
+		Email:     email,
+		AppID:     device.AppID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	user, err := a.verifyCredentials(ctx, log, event, pass)
+	if err != nil {
+		return err
+	}
+
+	if err := a.decideDevice(ctx, deviceCode, device, user.ID, user.Email, approve, passwordAuthentication); err != nil {
+		return err
+	}
+
+	if approve {
+		event.UserID, event.Success, event.Reason = user.ID, true, reasonDeviceCode
+		a.auditor.Record(ctx, event)
+	}
+
+	log.Info("device decided", slog.Bool("approved", approve))
+
+	return nil
+}
+
+// ApproveDevice lets logged-in caller approve or deny device of user code.
+// Only first-party tokens may do it, scoped ones get ErrInsufficientScope
+// and impersonation sessions get ErrPermissionDenied.
+func (a *Auth) ApproveDevice(ctx context.Context, caller Principal, userCode string, approve bool) error {
+	const op = "Auth.ApproveDevice"
+
+	if caller.Scopes != nil {
+		return fmt.Errorf("%s: %w", op, ErrInsufficientScope)
+	}
+	if caller.ImpersonatorID != 0 {
+		return fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	// user codes are short, guessing them is limited like guessing confirmation codes
+	if err := a.countAttempt(ctx, userCodeAttemptsKey(caller.Email)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	deviceCode, device, err := a.pendingDevice(ctx, userCode)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.decideDevice(ctx, deviceCode, device, caller.UserID, caller.Email, approve, caller.authentication()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.log.Info("device decided", slog.String("op", op), slog.Int64("uid", caller.UserID), slog.Bool("approved", approve))
+
+	return nil
+}
+
+// PollDeviceToken returns token once the user approved the device.
+//
+// Before that it fails with ErrAuthorizationPending, or ErrSlowDown when the device
+// polls more often than the interval, every slow_down adds 5 seconds to it. Denied and expired devices get ErrAccessDenied
+// and ErrExpiredToken, device code of another client gets ErrInvalidGrant.
+func (a *Auth) PollDeviceToken(ctx context.Context, req DeviceTokenRequest, client ClientInfo) (TokenGrant, error) {
+	const op = "Auth.PollDeviceToken"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", req.ClientID),
+	)
+
+	if a.kv == nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w: no store for device codes", op, ErrUnavailable)
+	}
+
+	app, err := a.clientApp(ctx, log, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	device, err := a.device(ctx, req.DeviceCode)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if device.AppID != app.ID {
+		return TokenGrant{}, fmt.Errorf("%s: %w: device code was issued to another client", op, ErrInvalidGrant)
+	}
+
+	audience, err := grantAudience(req.Audience, app)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	interval, err := a.devicePollInterval(ctx, req.DeviceCode)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+	}
+
+	polls, err := a.limits.Incr(ctx, devicePollKey(req.DeviceCode), interval)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+	}
+
+	if polls > 1 {
+		if _, err := a.limits.Incr(ctx, deviceSlowDownKey(req.DeviceCode), deviceCodeTTL); err != nil {
+			return TokenGrant{}, fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+		}
+
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, ErrSlowDown)
+	}
+
+	switch device.Status {
+	case deviceStatusPending:
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, ErrAuthorizationPending)
+	case deviceStatusDenied:
+		a.forgetDevice(ctx, req.DeviceCode, device)
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, ErrAccessDenied)
+	}
+
+	redeemed, err := a.kv.Incr(ctx, deviceUsedKey(req.DeviceCode), deviceCodeTTL)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+	}
+
+	if redeemed > 1 {
+		return TokenGrant{}, fmt.Errorf("%s: %w: device code was already used", op, ErrInvalidGrant)
+	}
+
+	a.forgetDevice(ctx, req.DeviceCode, device)
+
+	event := models.AuditEvent{
+		Type:      models.EventTokenIssue,
+		UserID:    device.UserID,
+		Email:     device.Email,
+		AppID:     app.ID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	user, err := a.usrProvider.User(ctx, device.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			a.auditFailure(ctx, event, reasonUserNotFound)
+			return TokenGrant{}, fmt.Errorf("%s: %w", op, ErrInvalidGrant)
+		}
+
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.ID != device.UserID {
+		a.auditFailure(ctx, event, reasonInvalidGrant)
+		return TokenGrant{}, fmt.Errorf("%s: %w: user was replaced", op, ErrInvalidGrant)
+	}
+
+	if user.Disabled {
+		a.auditFailure(ctx, event, reasonUserDisabled)
+		return TokenGrant{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidGrant, ErrUserDisabled)
+	}
+
+	if client.DeviceName == "" {
+		client.DeviceName = app.Name
+	}
+
+	// empty scope still limits the token, unlike nil
+	scopes := device.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	authn := authentication{Time: time.Unix(device.AuthTime, 0), Methods: device.AMR}
+
+	token, err := a.issueToken(ctx, log, user, app.ID, client, scopes, audience, authn)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	grant := TokenGrant{AccessToken: token, ExpiresIn: a.tokenTTL, Scopes: scopes}
+
+	if slices.Contains(scopes, ScopeOpenID) {
+		grant.IDToken, err = a.idToken(ctx, user, app, authorizationCode{Scopes: scopes, AuthTime: device.AuthTime, AMR: device.AMR}, token)
+		if err != nil {
+			log.Error("failed to issue ID token", sl.Err(err))
+			return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	event.Success, event.Reason = true, reasonDeviceCode
+	a.auditor.Record(ctx, event)
+
+	log.Info("device token issued", slog.Int64("uid", user.ID))
+
+	return grant, nil
+}
+
+// pendingDevice returns device code and authorization of user code the user has not decided on yet
+func (a *Auth) pendingDevice(ctx context.Context, userCode string) (string, deviceAuthorization, error) {
+	if a.kv == nil {
+		return "", deviceAuthorization{}, fmt.Errorf("%w: no store for device codes", ErrUnavailable)
+	}
+
+	deviceCode, err := a.kv.Get(ctx, userCodeKey(normalizeUserCode(userCode)))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return "", deviceAuthorization{}, ErrInvalidUserCode
+		}
+
+		return "", deviceAuthorization{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	device, err := a.device(ctx, deviceCode)
+	if err != nil {
+		if errors.Is(err, ErrExpiredToken) {
+			return "", deviceAuthorization{}, ErrInvalidUserCode
+		}
+
+		return "", deviceAuthorization{}, err
+	}
+
+	if device.Status != deviceStatusPending {
+		return "", deviceAuthorization{}, fmt.Errorf("%w: device was already decided on", ErrInvalidUserCode)
+	}
+
+	return deviceCode, device, nil
+}
+
+// decideDevice records decision of the user authenticated by authn, only the first one
+// counts even when decisions are made concurrently
+func (a *Auth) decideDevice(
+	ctx context.Context, deviceCode string, device deviceAuthorization,
+	userID int64, email string, approve bool, authn authentication,
+) error {
+	decided, err := a.kv.Incr(ctx, deviceDecidedKey(deviceCode), deviceCodeTTL)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	if decided > 1 {
+		return fmt.Errorf("%w: device was already decided on", ErrInvalidUserCode)
+	}
+
+	if err := a.kv.Delete(ctx, userCodeKey(device.UserCode)); err != nil {
+		a.log.Warn("failed to delete user code", sl.Err(err))
+	}
+
+	device.Status = deviceStatusDenied
+	if approve {
+		device.Status = deviceStatusApproved
+		device.UserID, device.Email, device.AuthTime = userID, email, a.clock.Now().Unix()
+		if !authn.Time.IsZero() {
+			device.AuthTime = authn.Time.Unix()
+		}
+		device.AMR = authn.Methods
+	}
+
+	if err := a.saveDevice(ctx, deviceCode, device); err != nil {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	return nil
+}
+
+func (a *Auth) device(ctx context.Context, deviceCode string) (deviceAuthorization, error) {
+	if deviceCode == "" {
+		return deviceAuthorization{}, fmt.Errorf("%w: device code is missing", ErrInvalidGrant)
+	}
+
+	value, err := a.kv.Get(ctx, deviceCodeKey(deviceCode))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return deviceAuthorization{}, ErrExpiredToken
+		}
+
+		return deviceAuthorization{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	var device deviceAuthorization
+	if err := json.Unmarshal([]byte(value), &device); err != nil {
+		return deviceAuthorization{}, err
+	}
+
+	return device, nil
+}
+
+// devicePollInterval returns how often device may poll, the interval grows with every slow_down it got
+func (a *Auth) devicePollInterval(ctx context.Context, deviceCode string) (time.Duration, error) {
+	value, err := a.limits.Get(ctx, deviceSlowDownKey(deviceCode))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return devicePollInterval, nil
+		}
+
+		return 0, err
+	}
+
+	slowDowns, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return devicePollInterval + time.Duration(slowDowns)*slowDownStep, nil
+}
+
+// saveDevice keeps device until it expires
+func (a *Auth) saveDevice(ctx context.Context, deviceCode string, device deviceAuthorization) error {
+	ttl := time.Unix(device.ExpiresAt, 0).Sub(a.clock.Now())
+	if ttl <= 0 {
+		return ErrExpiredToken
+	}
+
+	value, err := json.Marshal(device)
+	if err != nil {
+		return err
+	}
+
+	return a.kv.Set(ctx, deviceCodeKey(deviceCode), string(value), ttl)
+}
+
+func (a *Auth) forgetDevice(ctx context.Context, deviceCode string, device deviceAuthorization) {
+	for _, key := range []string{deviceCodeKey(deviceCode), userCodeKey(device.UserCode)} {
+		if err := a.kv.Delete(ctx, key); err != nil {
+			a.log.Warn("failed to delete device code", sl.Err(err))
+		}
+	}
+}
+
+// formatUserCode splits user code in halves, so it is easier to read and type
+func formatUserCode(code string) string {
+	return code[:len(code)/2] + "-" + code[len(code)/2:]
+}
+
+// normalizeUserCode undoes formatting and case changes the user may have made
+func normalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+
+		return r
+	}, strings.ToUpper(code))
+}
+
+func deviceCodeKey(code string) string {
+	return "device_code:" + code
+}
+
+func deviceUsedKey(code string) string {
+	return "device_code_used:" + code
+}
+
+func deviceDecidedKey(code string) string {
+	return "device_code_decided:" + code
+}
+
+func devicePollKey(code string) string {
+	return "device_code_poll:" + code
+}
+
+func deviceSlowDownKey(code string) string {
+	return "device_code_slow_down:" + code
+}
+
+func userCodeKey(code string) string {
+	return "device_user_code:" + code
+}
+
+// userCodeAttemptsKey counts user code guesses of client address or signed in user
+func userCodeAttemptsKey(guesser string) string {
+	return "device_user_code_attempts:" + guesser
+}
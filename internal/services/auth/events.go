@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"slices"
+	"sync"
+)
+
+var (
+	ErrUnknownEventType = errors.New("unknown event type")
+	ErrBusClosed        = errors.New("event bus is closed")
+)
+
+// WatchableEvents are audit event types downstream services may watch,
+// only successful events are delivered
+var WatchableEvents = []string{
+	models.EventRegister,
+	models.EventCodeValidation,
+	models.EventUserCreate,
+	models.EventUserPromote,
+	models.EventUserDisable,
+	models.EventPasswordChange,
+	models.EventPasswordReset,
+}
+
+const (
+	// replayBatch is how many stored events are read at once during replay
+	replayBatch = 500
+	// subscriptionBuffer is how many live events may wait for a slow watcher
+	// before it falls back to replay from storage
+	subscriptionBuffer = 64
+)
+
+// EventLog is where recorded events are replayed from
+type EventLog interface {
+	AuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+}
+
+// EventBus delivers recorded audit events to watchers in-process.
+//
+// Watchers first replay stored events after their cursor and then follow live ones,
+// so a reconnecting watcher misses nothing.
+type EventBus struct {
+	log EventLog
+
+	mu     sync.Mutex
+	subs   map[*subscription]struct{}
+	closed bool
+}
+
+type subscription struct {
+	events chan models.AuditEvent
+	// lagged is signaled when live event was dropped because events was full
+	lagged chan struct{}
+	// done is closed when the bus is closed
+	done chan struct{}
+}
+
+func NewEventBus(log EventLog) *EventBus {
+	return &EventBus{
+		log:  log,
+		subs: make(map[*subscription]struct{}),
+	}
+}
+
+// Publish passes saved event to watchers, it never blocks
+func (b *EventBus) Publish(event models.AuditEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		select {
+		case sub.events <- event:
+		default:
+			select {
+			case sub.lagged <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Close ends every watch with ErrBusClosed
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+
+	for sub := range b.subs {
+		close(sub.done)
+	}
+	b.subs = nil
+}
+
+// Watch calls fn for every successful event of given types saved after afterID,
+// all watchable types are delivered when types is empty.
+//
+// It returns when ctx is done, fn fails or the bus is closed.
+func (b *EventBus) Watch(ctx context.Context, types []string, afterID int64, fn func(models.AuditEvent) error) error {
+	const op = "EventBus.Watch"
+
+	if len(types) == 0 {
+		types = WatchableEvents
+	}
+	for _, t := range types {
+		if !slices.Contains(WatchableEvents, t) {
+			return fmt.Errorf("%s: %w: %s", op, ErrUnknownEventType, t)
+		}
+	}
+
+	// subscription goes first, so events saved during replay are not missed
+	sub, unsubscribe := b.subscribe()
+	if sub == nil {
+		return fmt.Errorf("%s: %w", op, ErrBusClosed)
+	}
+	defer unsubscribe()
+
+	deliver := func(event models.AuditEvent) error {
+		afterID = event.ID
+
+		if !event.Success || !slices.Contains(types, event.Type) {
+			return nil
+		}
+
+		return fn(event)
+	}
+
+	replay := func() error {
+		filter := models.AuditFilter{AfterID: afterID, Limit: replayBatch}
+
+		for {
+			events, err := b.log.AuditEvents(ctx, filter)
+			if err != nil {
+				return err
+			}
+
+			for _, event := range events {
+				if err := deliver(event); err != nil {
+					return err
+				}
+			}
+
+			if len(events) < replayBatch {
+				return nil
+			}
+
+			filter.AfterID = afterID
+		}
+	}
+
+	if err := replay(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for {
+		var err error
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", op, ctx.Err())
+		case <-sub.done:
+			return fmt.Errorf("%s: %w", op, ErrBusClosed)
+		case <-sub.lagged:
+			err = replay()
+		case event := <-sub.events:
+			switch {
+			case event.ID <= afterID:
+				// already replayed
+			case event.ID == afterID+1:
+				err = deliver(event)
+			default:
+				// events are published after commit and may come out of order,
+				// storage has every event before this one
+				err = replay()
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+}
+
+func (b *EventBus) subscribe() (*subscription, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, nil
+	}
+
+	sub := &subscription{
+		events: make(chan models.AuditEvent, subscriptionBuffer),
+		lagged: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	b.subs[sub] = struct{}{}
+
+	return sub, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subs, sub)
+	}
+}
+
+// WatchEvents lets admin follow security events, see EventBus.Watch
+func (a *Auth) WatchEvents(
+	ctx context.Context,
+	caller Principal,
+	types []string,
+	afterID int64,
+	fn func(models.AuditEvent) error,
+) error {
+	const op = "Auth.WatchEvents"
+
+	if a.events == nil {
+		return fmt.Errorf("%s: %w", op, ErrUnavailable)
+	}
+
+	if err := a.RequireAdmin(ctx, caller); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.events.Watch(ctx, types, afterID, fn); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/jwt"
+	"gRPC/internal/lib/oauth"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/storage"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+const reasonTokenExchange = "token_exchange"
+
+// TokenExchange is token exchange request of service account, RFC 8693.
+//
+// The account acts on behalf of the user of SubjectToken. It authenticates like with
+// ClientCredentials or presents its own token as ActorToken, both must name the same account.
+type TokenExchange struct {
+	ClientID     string
+	ClientSecret string
+	Assertion    string
+	SubjectToken string
+	ActorToken   string
+	// Scope narrows scopes the exchange policy allows, empty scope requests all of them
+	Scope string
+	// Audience is the single resource server the new token is meant for
+	Audience string
+}
+
+// ExchangeToken issues token of SubjectToken user for another audience to service account
+// acting on its behalf. The token carries act claim naming the account, preceded by actors
+// of the subject token, and its session and expiration are those of the subject token at most.
+//
+// Account must have exchange policy of its app for the audience, otherwise ErrInvalidAudience
+// is returned. Invalid subject and actor tokens get ErrInvalidRequest, scopes beyond
+// the policy and the subject token ErrInvalidScope.
+func (a *Auth) ExchangeToken(ctx context.Context, req TokenExchange) (TokenGrant, error) {
+	const op = "Auth.ExchangeToken"
+
+	if a.accounts == nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w: service accounts are not enabled", op, ErrInvalidClient)
+	}
+
+	event := models.AuditEvent{Type: models.EventTokenIssue}
+
+	account, err := a.exchangeActor(ctx, op, req, event)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("client_id", account.ClientID),
+	)
+
+	event.AppID = account.AppID
+
+	subject, err := a.InspectToken(ctx, req.SubjectToken)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return TokenGrant{}, fmt.Errorf("%s: %w: subject token: %w", op, ErrInvalidRequest, err)
+		}
+
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, err := a.tokenSession(ctx, subject)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return TokenGrant{}, fmt.Errorf("%s: %w: subject token: %w", op, ErrInvalidRequest, err)
+		}
+
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if session.AppID != account.AppID {
+		return TokenGrant{}, fmt.Errorf("%s: %w: subject token is of another app", op, ErrInvalidRequest)
+	}
+
+	event.UserID = session.UserID
+
+	audiences, err := oauth.ParseAudience(req.Audience)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidAudience, err)
+	}
+
+	if len(audiences) != 1 {
+		return TokenGrant{}, fmt.Errorf("%s: %w: exactly one audience is required", op, ErrInvalidAudience)
+	}
+
+	policy, err := a.accounts.ExchangePolicy(ctx, account.AppID, account.ClientID, audiences[0])
+	if err != nil {
+		if errors.Is(err, storage.ErrExchangePolicyNotFound) {
+			log.Warn("no exchange policy", slog.String("audience", audiences[0]))
+			a.auditFailure(ctx, event, reasonTokenExchange)
+
+			return TokenGrant{}, fmt.Errorf("%s: %w: exchange for %q is not allowed", op, ErrInvalidAudience, audiences[0])
+		}
+
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	scopes, err := exchangeScopes(req.Scope, policy, subject)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	email, _ := subject["email"].(string)
+
+	user, err := a.usrProvider.User(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return TokenGrant{}, fmt.Errorf("%s: %w: user of subject token not found", op, ErrInvalidRequest)
+		}
+
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.ID != session.UserID {
+		return TokenGrant{}, fmt.Errorf("%s: %w: user of subject token not found", op, ErrInvalidRequest)
+	}
+
+	if user.Disabled {
+		a.auditFailure(ctx, event, reasonUserDisabled)
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, ErrUserDisabled)
+	}
+
+	app, err := a.appProvider.App(ctx, account.AppID)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims, err := a.registeredClaims(scopes, audiences)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims.Actor = &jwt.Actor{Subject: account.ClientID, Actor: jwt.ParseActor(subject)}
+
+	now := a.clock.Now()
+
+	// exchanged token must not outlive the subject one
+	expiresAt := now.Add(a.tokenTTL)
+	if exp, _ := subject["exp"].(float64); time.Unix(int64(exp), 0).Before(expiresAt) {
+		expiresAt = time.Unix(int64(exp), 0)
+	}
+
+	token, err := jwt.CreateSessionToken(user, app, session.ID, claims, now, expiresAt)
+	if err != nil {
+		log.Error("failed to generate token", sl.Err(err))
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.Email = user.Email
+	event.Success, event.Reason = true, reasonTokenExchange
+	a.auditor.Record(ctx, event)
+
+	log.Info("token exchanged", slog.Int64("uid", user.ID), slog.String("audience", audiences[0]))
+
+	return TokenGrant{AccessToken: token, ExpiresIn: expiresAt.Sub(now), Scopes: scopes}, nil
+}
+
+// exchangeActor returns service account making token exchange request, authenticated
+// by its client credentials or by its actor token
+func (a *Auth) exchangeActor(ctx context.Context, op string, req TokenExchange, event models.AuditEvent) (models.ServiceAccount, error) {
+	credentials := ClientCredentials{ClientID: req.ClientID, ClientSecret: req.ClientSecret, Assertion: req.Assertion}
+
+	var actorID string
+
+	if req.ActorToken != "" {
+		actor, err := a.InspectToken(ctx, req.ActorToken)
+		if err != nil {
+			if errors.Is(err, ErrInvalidToken) {
+				return models.ServiceAccount{}, fmt.Errorf("%w: actor token: %w", ErrInvalidRequest, err)
+			}
+
+			return models.ServiceAccount{}, err
+		}
+
+		// only service accounts act on behalf of users
+		actorID, _ = actor["client_id"].(string)
+		if _, ok := actor["uid"]; ok || actorID == "" {
+			return models.ServiceAccount{}, fmt.Errorf("%w: actor token is not a service token", ErrInvalidRequest)
+		}
+
+		if _, err := a.tokenServiceAccount(ctx, actor); err != nil {
+			if errors.Is(err, ErrInvalidToken) {
+				return models.ServiceAccount{}, fmt.Errorf("%w: actor token: %w", ErrInvalidRequest, err)
+			}
+
+			return models.ServiceAccount{}, err
+		}
+	}
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("client_id", credentials.clientID()),
+	)
+
+	if credentials.ClientSecret == "" && credentials.Assertion == "" {
+		if actorID == "" {
+			return models.ServiceAccount{}, fmt.Errorf("%w: client credentials or actor token are required", ErrInvalidClient)
+		}
+
+		if credentials.ClientID != "" && credentials.ClientID != actorID {
+			return models.ServiceAccount{}, fmt.Errorf("%w: actor token is of another client", ErrInvalidClient)
+		}
+
+		account, err := a.accounts.ServiceAccountByClientID(ctx, actorID)
+		if err != nil {
+			if errors.Is(err, storage.ErrServiceAccountNotFound) {
+				return models.ServiceAccount{}, fmt.Errorf("%w: service account not found", ErrInvalidClient)
+			}
+
+			return models.ServiceAccount{}, err
+		}
+
+		if account.Disabled {
+			event.AppID = account.AppID
+			a.auditFailure(ctx, event, reasonServiceAccountDisabled)
+
+			return models.ServiceAccount{}, fmt.Errorf("%w: service account is disabled", ErrInvalidClient)
+		}
+
+		return account, nil
+	}
+
+	account, err := a.authenticateServiceAccount(ctx, log, credentials, event)
+	if err != nil {
+		return models.ServiceAccount{}, err
+	}
+
+	if actorID != "" && actorID != account.ClientID {
+		return models.ServiceAccount{}, fmt.Errorf("%w: actor token is of another client", ErrInvalidRequest)
+	}
+
+	return account, nil
+}
+
+// exchangeScopes returns scopes of scope parameter allowed by exchange policy and held
+// by subject token, all of such scopes when the parameter is empty. First-party subject
+// tokens hold every scope. It fails with ErrInvalidScope.
+func exchangeScopes(scope string, policy models.ExchangePolicy, subject map[string]any) ([]string, error) {
+	requested, err := oauth.ParseScope(scope)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidScope, err)
+	}
+
+	available := []string{}
+	subjectScope, scoped := subject["scope"].(string)
+
+	for _, scope := range policy.Scopes {
+		if !scoped || slices.Contains(strings.Fields(subjectScope), scope) {
+			available = append(available, scope)
+		}
+	}
+
+	if len(requested) == 0 {
+		return available, nil
+	}
+
+	for _, scope := range requested {
+		if !slices.Contains(available, scope) {
+			return nil, fmt.Errorf("%w: %q may not be exchanged", ErrInvalidScope, scope)
+		}
+	}
+
+	return requested, nil
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/jwt"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/storage"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// ErrImpersonationForbidden is returned for attempts to impersonate admins
+var ErrImpersonationForbidden = errors.New("impersonation is forbidden")
+
+const (
+	// impersonationTTL bounds lifetime of impersonated sessions, they end sooner when tokens do
+	impersonationTTL = 15 * time.Minute
+
+	reasonImpersonateAdmin = "impersonate_admin"
+)
+
+// ImpersonationRequest asks for token of user with Email in app, Reason is kept in audit log
+type ImpersonationRequest struct {
+	Email  string
+	AppID  int
+	Reason string
+}
+
+// Impersonate starts short-lived session of user on behalf of caller, who must be admin,
+// and returns its token. The session remembers the admin and the token names them in
+// act and impersonator claims, so resource servers can tell it from sessions of the user.
+//
+// Admins may not be impersonated, attempts get ErrImpersonationForbidden.
+// Both granted and denied attempts are recorded in audit log.
+func (a *Auth) Impersonate(
+	ctx context.Context, caller Principal, req ImpersonationRequest, client ClientInfo,
+) (TokenGrant, error) {
+	const op = "Auth.Impersonate"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", caller.UserID),
+		slog.String("username", req.Email),
+	)
+
+	if err := a.RequireAdmin(ctx, caller); err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{
+		Type:      models.EventImpersonate,
+		Email:     req.Email,
+		AppID:     req.AppID,
+		ActorID:   caller.UserID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	app, err := a.appProvider.App(ctx, req.AppID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return TokenGrant{}, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.usrProvider.User(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return TokenGrant{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.UserID = user.ID
+
+	isAdmin, err := a.usrProvider.IsAdmin(ctx, user.ID)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if isAdmin {
+		log.Warn("attempt to impersonate admin")
+		a.auditFailure(ctx, event, reasonImpersonateAdmin)
+
+		return TokenGrant{}, fmt.Errorf("%s: %w: user is admin", op, ErrImpersonationForbidden)
+	}
+
+	if user.Disabled {
+		a.auditFailure(ctx, event, reasonUserDisabled)
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, ErrUserDisabled)
+	}
+
+	claims, err := a.registeredClaims(nil, app.Audiences)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims.Actor = &jwt.Actor{Subject: strconv.FormatInt(caller.UserID, 10)}
+	claims.Impersonator = caller.UserID
+
+	session, err := a.newSession(user, app, client)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	session.ImpersonatorID = caller.UserID
+	if expiresAt := session.CreatedAt.Add(impersonationTTL); expiresAt.Before(session.ExpiresAt) {
+		session.ExpiresAt = expiresAt
+	}
+
+	if err := a.sessions.SaveSession(ctx, session); err != nil {
+		log.Error("Failed to start session", sl.Err(err))
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := jwt.CreateSessionToken(user, app, session.ID, claims, session.CreatedAt, session.ExpiresAt)
+	if err != nil {
+		log.Error("failed to generate token", sl.Err(err))
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.Success, event.Reason = true, req.Reason
+	a.auditor.Record(ctx, event)
+
+	log.Info("user impersonated", slog.String("session_id", session.ID))
+
+	return TokenGrant{AccessToken: token, ExpiresIn: session.ExpiresAt.Sub(session.CreatedAt)}, nil
+}
@@ -0,0 +1,510 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/kv"
+	"gRPC/internal/lib/oauth"
+	"gRPC/internal/lib/random"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/storage"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrInvalidClient and ErrInvalidRedirectURI must not be sent to the redirect URI,
+	// the other authorization errors are reported to the client app there
+	ErrInvalidClient      = errors.New("invalid client")
+	ErrInvalidRedirectURI = errors.New("invalid redirect uri")
+
+	ErrInvalidRequest          = errors.New("invalid authorization request")
+	ErrUnsupportedResponseType = errors.New("unsupported response type")
+	ErrInvalidScope            = errors.New("invalid scope")
+	ErrInvalidGrant            = errors.New("invalid grant")
+)
+
+const (
+	// responseTypeCode is the only supported response type, implicit flow is not
+	responseTypeCode = "code"
+
+	// authorizationCodeTTL is how long authorization code may be exchanged for token
+	authorizationCodeTTL = time.Minute
+
+	reasonAuthorizationCode = "authorization_code"
+	reasonInvalidGrant      = "invalid_grant"
+)
+
+// AuthorizationRequest is OAuth authorization request made by client app on behalf of user
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            int
+	RedirectURI         string
+	Scope               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	// Nonce is copied into ID token, so client can tie it to its session
+	Nonce string
+}
+
+// Authorization is validated AuthorizationRequest the user is asked to consent to
+type Authorization struct {
+	App models.App
+	// RedirectURI is where the response goes, the registered one when request omits it
+	RedirectURI string
+	Scopes      []string
+
+	request AuthorizationRequest
+}
+
+// CodeExchange is token request with authorization_code grant.
+//
+// ClientSecret is optional, public clients are authenticated by PKCE only.
+type CodeExchange struct {
+	ClientID     int
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	// Audience is a subset of the app audiences, empty audience requests all of them
+	Audience string
+}
+
+// TokenGrant is access token issued to client app
+type TokenGrant struct {
+	AccessToken string
+	ExpiresIn   time.Duration
+	Scopes      []string
+	// IDToken is issued when openid scope is granted
+	IDToken string
+}
+
+// authorizationCode is what authorization code stands for, it is kept in kv store
+type authorizationCode struct {
+	UserID      int64      `json:"uid"`
+	Email       string     `json:"email"`
+	AppID       int        `json:"app_id"`
+	RedirectURI string     `json:"redirect_uri"`
+	Scopes      []string   `json:"scopes"`
+	Challenge   string     `json:"challenge"`
+	Client      ClientInfo `json:"client"`
+	Nonce       string     `json:"nonce,omitempty"`
+	// AuthTime is when the user entered credentials, unix seconds
+	AuthTime int64 `json:"auth_time"`
+	// AMR is how the user authenticated, entering credentials unless the code stands for device
+	AMR []string `json:"amr,omitempty"`
+}
+
+// CheckAuthorization validates authorization request of client app.
+//
+// ErrInvalidClient and ErrInvalidRedirectURI mean the request must not be redirected back.
+// With other errors the returned Authorization still has App and RedirectURI set,
+// so the error can be reported to the client app.
+func (a *Auth) CheckAuthorization(ctx context.Context, req AuthorizationRequest) (Authorization, error) {
+	const op = "Auth.CheckAuthorization"
+
+	app, err := a.appProvider.App(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return Authorization{}, fmt.Errorf("%s: %w", op, ErrInvalidClient)
+		}
+
+		return Authorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(app.RedirectURIs) == 0 {
+		return Authorization{}, fmt.Errorf("%s: %w: app is not registered as OAuth client", op, ErrInvalidClient)
+	}
+
+	authz := Authorization{App: app, RedirectURI: req.RedirectURI, request: req}
+
+	switch {
+	case req.RedirectURI == "" && len(app.RedirectURIs) == 1:
+		authz.RedirectURI = app.RedirectURIs[0]
+	case req.RedirectURI == "":
+		return Authorization{}, fmt.Errorf("%s: %w: redirect_uri is required", op, ErrInvalidRedirectURI)
+	case !app.AllowsRedirectURI(req.RedirectURI):
+		return Authorization{}, fmt.Errorf("%s: %w: %q is not registered", op, ErrInvalidRedirectURI, req.RedirectURI)
+	}
+
+	if req.ResponseType != responseTypeCode {
+		return authz, fmt.Errorf("%s: %w: %q", op, ErrUnsupportedResponseType, req.ResponseType)
+	}
+
+	scopes, err := a.grantScopes(req.Scope, app)
+	if err != nil {
+		return authz, fmt.Errorf("%s: %w", op, err)
+	}
+
+	authz.Scopes = scopes
+
+	if err := oauth.ValidateChallenge(req.CodeChallenge, req.CodeChallengeMethod); err != nil {
+		return authz, fmt.Errorf("%s: %w: %w", op, ErrInvalidRequest, err)
+	}
+
+	return authz, nil
+}
+
+// Authorize checks credentials of the user who consented to authz and returns
+// authorization code the client app exchanges for access token.
+//
+// Credential errors are the same as Login ones. Users with verified phone get
+// *SecondFactorRequiredError, FinishSMSAuthorization returns the code then.
+func (a *Auth) Authorize(
+	ctx context.Context, authz Authorization, email string, pass string, client ClientInfo,
+) (string, error) {
+	const op = "Auth.Authorize"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("username", email),
+		slog.Int("app_id", authz.App.ID),
+	)
+
+	if a.kv == nil {
+		return "", fmt.Errorf("%s: %w: no store for authorization codes", op, ErrUnavailable)
+	}
+
+	event := models.AuditEvent{
+		Type:      models.EventLogin,
+		Email:     email,
+		AppID:     authz.App.ID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	user, err := a.verifyCredentials(ctx, log, event, pass)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if client.DeviceName == "" {
+		client.DeviceName = authz.App.Name
+	}
+
+	data := authorizationCode{
+		UserID:      user.ID,
+		Email:       user.Email,
+		AppID:       authz.App.ID,
+		RedirectURI: authz.request.RedirectURI,
+		Scopes:      authz.Scopes,
+		Challenge:   authz.request.CodeChallenge,
+		Client:      client,
+		Nonce:       authz.request.Nonce,
+	}
+
+	state := smsLoginState{AppID: authz.App.ID, Authorization: &data}
+	if err := a.requireSecondFactor(ctx, log, user, state, event); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	data.AuthTime, data.AMR = a.clock.Now().Unix(), passwordAuthentication.Methods
+
+	code, err := a.saveAuthorizationCode(ctx, log, data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.UserID, event.Success, event.Reason = user.ID, true, reasonAuthorizationCode
+	a.auditor.Record(ctx, event)
+
+	log.Info("authorization code issued")
+
+	return code, nil
+}
+
+// FinishSMSAuthorization checks code sent for challenge Authorize started for authz
+// and returns authorization code like Authorize does. Every code is accepted once.
+//
+// Unknown, expired and other challenges get ErrPhoneCodeNotFound, wrong codes get ErrInvalidCode.
+func (a *Auth) FinishSMSAuthorization(
+	ctx context.Context, authz Authorization, challengeID string, code string, client ClientInfo,
+) (string, error) {
+	const op = "Auth.FinishSMSAuthorization"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", authz.App.ID),
+	)
+
+	if err := a.smsEnabled(); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{
+		Type:      models.EventLogin,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	state, user, err := a.finishSMSChallenge(ctx, log, challengeID, code, event)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the code is redirected to where the authorization asked for, nowhere else
+	data := state.Authorization
+	if data == nil || data.AppID != authz.App.ID || data.RedirectURI != authz.request.RedirectURI {
+		return "", fmt.Errorf("%s: %w: challenge is of another authorization", op, ErrPhoneCodeNotFound)
+	}
+
+	data.AuthTime, data.AMR = a.clock.Now().Unix(), smsMethods(state.Channel)
+
+	authzCode, err := a.saveAuthorizationCode(ctx, log, *data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.UserID, event.AppID, event.Email = user.ID, data.AppID, user.Email
+	event.Success, event.Reason = true, reasonAuthorizationCode
+	a.auditor.Record(ctx, event)
+
+	log.Info("authorization code issued", slog.Int64("uid", user.ID))
+
+	return authzCode, nil
+}
+
+// saveAuthorizationCode stores data under new authorization code and returns the code
+func (a *Auth) saveAuthorizationCode(ctx context.Context, log *slog.Logger, data authorizationCode) (string, error) {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := random.String(sessionIDLength)
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.kv.Set(ctx, authorizationCodeKey(code), string(value), authorizationCodeTTL); err != nil {
+		log.Error("failed to save authorization code", sl.Err(err))
+		return "", fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	return code, nil
+}
+
+// ExchangeCode redeems authorization code for access token.
+//
+// Code is accepted once, only from the app it was issued to, with the same
+// redirect URI and the verifier of its PKCE challenge. Otherwise ErrInvalidGrant is returned.
+func (a *Auth) ExchangeCode(ctx context.Context, req CodeExchange) (TokenGrant, error) {
+	const op = "Auth.ExchangeCode"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", req.ClientID),
+	)
+
+	if a.kv == nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w: no store for authorization codes", op, ErrUnavailable)
+	}
+
+	app, err := a.clientApp(ctx, log, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	audience, err := grantAudience(req.Audience, app)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{Type: models.EventTokenIssue, AppID: app.ID}
+
+	code, err := a.redeemAuthorizationCode(ctx, req.Code)
+	if err != nil {
+		if errors.Is(err, ErrInvalidGrant) {
+			log.Warn("authorization code rejected", sl.Err(err))
+			a.auditFailure(ctx, event, reasonInvalidGrant)
+		}
+
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.UserID, event.Email, event.IP, event.UserAgent = code.UserID, code.Email, code.Client.IP, code.Client.UserAgent
+
+	if err := checkAuthorizationCode(code, app, req); err != nil {
+		log.Warn("authorization code rejected", sl.Err(err))
+		a.auditFailure(ctx, event, reasonInvalidGrant)
+
+		return TokenGrant{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidGrant, err)
+	}
+
+	user, err := a.usrProvider.User(ctx, code.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			a.auditFailure(ctx, event, reasonUserNotFound)
+			return TokenGrant{}, fmt.Errorf("%s: %w", op, ErrInvalidGrant)
+		}
+
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.ID != code.UserID {
+		a.auditFailure(ctx, event, reasonInvalidGrant)
+		return TokenGrant{}, fmt.Errorf("%s: %w: user was replaced", op, ErrInvalidGrant)
+	}
+
+	if user.Disabled {
+		a.auditFailure(ctx, event, reasonUserDisabled)
+		return TokenGrant{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidGrant, ErrUserDisabled)
+	}
+
+	// empty scope still limits the token, unlike nil
+	scopes := code.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	authn := authentication{Time: time.Unix(code.AuthTime, 0), Methods: code.AMR}
+
+	token, err := a.issueToken(ctx, log, user, app.ID, code.Client, scopes, audience, authn)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	grant := TokenGrant{AccessToken: token, ExpiresIn: a.tokenTTL, Scopes: scopes}
+
+	if slices.Contains(scopes, ScopeOpenID) {
+		grant.IDToken, err = a.idToken(ctx, user, app, code, token)
+		if err != nil {
+			log.Error("failed to issue ID token", sl.Err(err))
+			return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	event.Success, event.Reason = true, reasonAuthorizationCode
+	a.auditor.Record(ctx, event)
+
+	log.Info("authorization code exchanged", slog.Int64("uid", user.ID))
+
+	return grant, nil
+}
+
+// redeemAuthorizationCode loads code and makes sure it is never redeemed again,
+// even when the same code is presented concurrently
+func (a *Auth) redeemAuthorizationCode(ctx context.Context, code string) (authorizationCode, error) {
+	if code == "" {
+		return authorizationCode{}, fmt.Errorf("%w: code is missing", ErrInvalidGrant)
+	}
+
+	redeemed, err := a.kv.Incr(ctx, authorizationCodeUsedKey(code), authorizationCodeTTL)
+	if err != nil {
+		return authorizationCode{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	if redeemed > 1 {
+		return authorizationCode{}, fmt.Errorf("%w: code was already used", ErrInvalidGrant)
+	}
+
+	value, err := a.kv.Get(ctx, authorizationCodeKey(code))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return authorizationCode{}, fmt.Errorf("%w: code is unknown or expired", ErrInvalidGrant)
+		}
+
+		return authorizationCode{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	if err := a.kv.Delete(ctx, authorizationCodeKey(code)); err != nil {
+		a.log.Warn("failed to delete authorization code", sl.Err(err))
+	}
+
+	var data authorizationCode
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return authorizationCode{}, err
+	}
+
+	return data, nil
+}
+
+// clientApp returns app of OAuth client authenticated by secret. Confidential clients must
+// give their client secret, public clients have none and are authenticated by PKCE or device code.
+func (a *Auth) clientApp(ctx context.Context, log *slog.Logger, clientID int, secret string) (models.App, error) {
+	app, err := a.oauthApp(ctx, clientID)
+	if err != nil {
+		return models.App{}, err
+	}
+
+	// public clients have nothing to compare secret with, so giving one fails too
+	valid := secret == ""
+	if app.Confidential() {
+		valid = oauth.VerifyClientSecret(app.ClientSecretHash, secret)
+	}
+
+	if !valid {
+		log.Warn("invalid client secret", slog.Bool("confidential", app.Confidential()))
+		return models.App{}, ErrInvalidClient
+	}
+
+	return app, nil
+}
+
+// oauthApp returns app of OAuth client without authenticating it
+func (a *Auth) oauthApp(ctx context.Context, clientID int) (models.App, error) {
+	app, err := a.appProvider.App(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.App{}, ErrInvalidClient
+		}
+
+		return models.App{}, err
+	}
+
+	return app, nil
+}
+
+// grantScopes returns scopes of scope parameter app may be granted, all of its scopes when
+// the parameter is empty. It fails with ErrInvalidScope.
+func (a *Auth) grantScopes(scope string, app models.App) ([]string, error) {
+	scopes, err := oauth.ParseScope(scope)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidScope, err)
+	}
+
+	if len(scopes) == 0 {
+		scopes = app.Scopes
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(app.Scopes, scope) {
+			return nil, fmt.Errorf("%w: %q is not allowed for app", ErrInvalidScope, scope)
+		}
+	}
+
+	if slices.Contains(scopes, ScopeOpenID) && a.signingKeys == nil {
+		return nil, fmt.Errorf("%w: OpenID Connect is not enabled", ErrInvalidScope)
+	}
+
+	return scopes, nil
+}
+
+func checkAuthorizationCode(code authorizationCode, app models.App, req CodeExchange) error {
+	if code.AppID != app.ID {
+		return errors.New("code was issued to another client")
+	}
+
+	// redirect_uri has to be repeated only when authorization request had it
+	if code.RedirectURI != req.RedirectURI {
+		return errors.New("redirect_uri does not match")
+	}
+
+	if err := oauth.VerifyChallenge(code.Challenge, req.CodeVerifier); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func authorizationCodeKey(code string) string {
+	return "oauth_code:" + code
+}
+
+func authorizationCodeUsedKey(code string) string {
+	return "oauth_code_used:" + code
+}
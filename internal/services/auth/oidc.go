@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/jwt"
+	"gRPC/internal/lib/keys"
+	"gRPC/internal/storage"
+	"slices"
+	"strconv"
+)
+
+// Standard OpenID Connect scopes
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+var (
+	ErrInsufficientScope = errors.New("insufficient scope")
+	ErrNoSigningKey      = errors.New("no signing key")
+)
+
+// KeyProvider returns service signing keys, the newest first
+type KeyProvider interface {
+	SigningKeys(ctx context.Context) ([]models.SigningKey, error)
+}
+
+// WithOpenID enables OpenID Connect: ID tokens of issuer signed by the newest
+// of signing keys. Without it openid scope is rejected.
+func WithOpenID(issuer string, signingKeys KeyProvider) Option {
+	return func(a *Auth) {
+		a.issuer = issuer
+		a.signingKeys = signingKeys
+	}
+}
+
+// UserInfo returns claims about the caller allowed by scopes of its token,
+// first-party tokens get all of them.
+//
+// Scoped token without openid scope gets ErrInsufficientScope.
+func (a *Auth) UserInfo(ctx context.Context, caller Principal) (map[string]any, error) {
+	const op = "Auth.UserInfo"
+
+	if caller.Scopes != nil && !slices.Contains(caller.Scopes, ScopeOpenID) {
+		return nil, fmt.Errorf("%s: %w", op, ErrInsufficientScope)
+	}
+
+	user, err := a.usrProvider.User(ctx, caller.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.ID != caller.UserID {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	return userClaims(user, caller.Scopes), nil
+}
+
+// JSONWebKeys returns public parts of signing keys ID tokens are verified with
+func (a *Auth) JSONWebKeys(ctx context.Context) ([]keys.JWK, error) {
+	const op = "Auth.JSONWebKeys"
+
+	if a.signingKeys == nil {
+		return []keys.JWK{}, nil
+	}
+
+	signingKeys, err := a.signingKeys.SigningKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	jwks := make([]keys.JWK, 0, len(signingKeys))
+	for _, key := range signingKeys {
+		jwk, err := keys.PublicJWK(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		jwks = append(jwks, jwk)
+	}
+
+	return jwks, nil
+}
+
+// idToken issues ID token for user authenticated by code, accessToken is bound by at_hash
+func (a *Auth) idToken(
+	ctx context.Context, user models.User, app models.App, code authorizationCode, accessToken string,
+) (string, error) {
+	signingKeys, err := a.signingKeys.SigningKeys(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if len(signingKeys) == 0 {
+		return "", ErrNoSigningKey
+	}
+
+	now := a.clock.Now()
+
+	claims := userClaims(user, code.Scopes)
+	claims["iss"] = a.issuer
+	claims["aud"] = strconv.Itoa(app.ID)
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(a.tokenTTL).Unix()
+	claims["auth_time"] = code.AuthTime
+	claims["at_hash"] = accessTokenHash(accessToken)
+
+	if len(code.AMR) > 0 {
+		claims["amr"], claims["acr"] = code.AMR, authentication{Methods: code.AMR}.acr()
+	}
+
+	if code.Nonce != "" {
+		claims["nonce"] = code.Nonce
+	}
+
+	return jwt.CreateIDToken(claims, signingKeys[0])
+}
+
+// userClaims returns standard claims of user allowed by scopes, nil scopes allow all.
+// Profile claims are not kept, so profile scope adds nothing.
+func userClaims(user models.User, scopes []string) map[string]any {
+	claims := map[string]any{
+		"sub": strconv.FormatInt(user.ID, 10),
+	}
+
+	if scopes == nil || slices.Contains(scopes, ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.Verified
+	}
+
+	return claims
+}
+
+// accessTokenHash is at_hash of RS256 signed ID token: left half of SHA-256 of the token
+func accessTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
@@ -0,0 +1,551 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/kv"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/lib/webauthn"
+	"gRPC/internal/storage"
+	"log/slog"
+	"time"
+)
+
+var (
+	ErrPasskeysDisabled = errors.New("passkeys are not enabled")
+	ErrPasskeyNotFound  = errors.New("passkey not found")
+	ErrPasskeyExists    = errors.New("passkey is already registered")
+	ErrInvalidPasskey   = errors.New("invalid passkey response")
+)
+
+const (
+	// defaultPasskeyTTL is how long ceremonies may take unless configured
+	defaultPasskeyTTL = 5 * time.Minute
+
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+
+	reasonPasskey         = "passkey"
+	reasonPasswordPasskey = "password_passkey"
+	reasonInvalidPasskey  = "invalid_passkey"
+	reasonPasskeyCloned   = "passkey_cloned"
+)
+
+// PasskeyStorage keeps passkeys of users
+type PasskeyStorage interface {
+	SavePasskey(ctx context.Context, passkey models.Passkey) (int64, error)
+	Passkey(ctx context.Context, credentialID []byte) (models.Passkey, error)
+	Passkeys(ctx context.Context, userID int64) ([]models.Passkey, error)
+	UpdatePasskeyUsage(ctx context.Context, id int64, signCount uint32, usedAt time.Time) error
+	DeletePasskey(ctx context.Context, id int64) error
+	UserByID(ctx context.Context, id int64) (models.User, error)
+}
+
+// WithPasskeys enables passkeys of relying party rp, ceremonies have to complete
+// within timeout. Passkey calls get ErrPasskeysDisabled without it.
+func WithPasskeys(rp webauthn.RelyingParty, passkeys PasskeyStorage, timeout time.Duration) Option {
+	return func(a *Auth) {
+		a.rp = rp
+		a.passkeys = passkeys
+		if timeout > 0 {
+			a.passkeyTTL = timeout
+		}
+	}
+}
+
+// PasskeyRegistration is response of authenticator to options of BeginPasskeyRegistration
+type PasskeyRegistration struct {
+	Name              string
+	ClientDataJSON    []byte
+	AttestationObject []byte
+}
+
+// PasskeyLogin starts login with passkey into app. Passkey is the first factor when
+// Email is empty, otherwise it is the second one and Password is checked first.
+type PasskeyLogin struct {
+	AppID    int
+	Email    string
+	Password string
+}
+
+// PasskeyAssertion is response of authenticator to options of BeginPasskeyLogin
+type PasskeyAssertion struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+	// UserHandle is returned by discoverable credentials, it may be empty
+	UserHandle []byte
+}
+
+// passkeyChallenge is what is remembered about challenge until its ceremony completes
+type passkeyChallenge struct {
+	Ceremony string `json:"ceremony"`
+	UserID   int64  `json:"user_id,omitempty"`
+	AppID    int    `json:"app_id,omitempty"`
+	// SecondFactor is set when password of the user was checked as the login began
+	SecondFactor bool `json:"second_factor,omitempty"`
+}
+
+// BeginPasskeyRegistration returns options the caller creates new passkey with,
+// authenticators already holding passkey of the caller are excluded
+func (a *Auth) BeginPasskeyRegistration(ctx context.Context, caller Principal) (webauthn.CreationOptions, error) {
+	const op = "Auth.BeginPasskeyRegistration"
+
+	if err := a.checkPasskeyCaller(caller); err != nil {
+		return webauthn.CreationOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passkeys, err := a.passkeys.Passkeys(ctx, caller.UserID)
+	if err != nil {
+		return webauthn.CreationOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge, err := a.newPasskeyChallenge(ctx, passkeyChallenge{Ceremony: ceremonyRegistration, UserID: caller.UserID})
+	if err != nil {
+		return webauthn.CreationOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return a.rp.CreationOptions(challenge, userHandle(caller.UserID), caller.Email,
+		credentialIDs(passkeys), a.passkeyTTL), nil
+}
+
+// FinishPasskeyRegistration checks response to registration options and saves the new passkey.
+//
+// Responses to unknown, expired or used challenges and responses failing the checks
+// get ErrInvalidPasskey, credential registered before gets ErrPasskeyExists.
+func (a *Auth) FinishPasskeyRegistration(
+	ctx context.Context, caller Principal, req PasskeyRegistration,
+) (models.Passkey, error) {
+	const op = "Auth.FinishPasskeyRegistration"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("uid", caller.UserID),
+	)
+
+	if err := a.checkPasskeyCaller(caller); err != nil {
+		return models.Passkey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge, state, err := a.redeemPasskeyChallenge(ctx, req.ClientDataJSON, ceremonyRegistration)
+	if err != nil {
+		return models.Passkey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if state.UserID != caller.UserID {
+		return models.Passkey{}, fmt.Errorf("%s: %w: challenge of another user", op, ErrInvalidPasskey)
+	}
+
+	credential, err := a.rp.VerifyRegistration(challenge, req.ClientDataJSON, req.AttestationObject)
+	if err != nil {
+		log.Info("passkey registration rejected", sl.Err(err))
+		return models.Passkey{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidPasskey, err)
+	}
+
+	passkey := models.Passkey{
+		UserID:       caller.UserID,
+		CredentialID: credential.ID,
+		PublicKey:    credential.PublicKey,
+		SignCount:    credential.SignCount,
+		Name:         req.Name,
+		CreatedAt:    a.clock.Now().UTC().Truncate(time.Second),
+	}
+
+	passkey.ID, err = a.passkeys.SavePasskey(ctx, passkey)
+	if err != nil {
+		if errors.Is(err, storage.ErrPasskeyExists) {
+			return models.Passkey{}, fmt.Errorf("%s: %w", op, ErrPasskeyExists)
+		}
+
+		log.Error("failed to save passkey", sl.Err(err))
+		return models.Passkey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.auditor.Record(ctx, models.AuditEvent{
+		Type:    models.EventPasskeyRegister,
+		UserID:  caller.UserID,
+		Email:   caller.Email,
+		AppID:   caller.AppID,
+		Success: true,
+		Reason:  passkey.Name,
+	})
+
+	log.Info("passkey registered", slog.Int64("passkey_id", passkey.ID))
+
+	return passkey, nil
+}
+
+// ListPasskeys returns passkeys of the caller
+func (a *Auth) ListPasskeys(ctx context.Context, caller Principal) ([]models.Passkey, error) {
+	const op = "Auth.ListPasskeys"
+
+	if err := a.checkPasskeyCaller(caller); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passkeys, err := a.passkeys.Passkeys(ctx, caller.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return passkeys, nil
+}
+
+// DeletePasskey deletes passkey of the caller, passkeys of others are reported as ErrPasskeyNotFound
+func (a *Auth) DeletePasskey(ctx context.Context, caller Principal, id int64) error {
+	const op = "Auth.DeletePasskey"
+
+	if err := a.checkPasskeyCaller(caller); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passkeys, err := a.passkeys.Passkeys(ctx, caller.UserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var passkey *models.Passkey
+	for i := range passkeys {
+		if passkeys[i].ID == id {
+			passkey = &passkeys[i]
+		}
+	}
+
+	if passkey == nil {
+		return fmt.Errorf("%s: %w", op, ErrPasskeyNotFound)
+	}
+
+	if err := a.passkeys.DeletePasskey(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrPasskeyNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrPasskeyNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.auditor.Record(ctx, models.AuditEvent{
+		Type:    models.EventPasskeyDelete,
+		UserID:  caller.UserID,
+		Email:   caller.Email,
+		AppID:   caller.AppID,
+		Success: true,
+		Reason:  passkey.Name,
+	})
+
+	a.log.Info("passkey deleted", slog.String("op", op), slog.Int64("uid", caller.UserID), slog.Int64("passkey_id", id))
+
+	return nil
+}
+
+// BeginPasskeyLogin returns options the user logging into app signs challenge with.
+//
+// As the first factor any discoverable passkey may be used and the authenticator has
+// to verify the user, e.g. by biometrics or PIN. As the second factor the password is
+// checked like Login does and one of the user's passkeys is asked for, the user has
+// to be present only. Users without passkeys get ErrPasskeyNotFound then.
+func (a *Auth) BeginPasskeyLogin(ctx context.Context, req PasskeyLogin, client ClientInfo) (webauthn.RequestOptions, error) {
+	const op = "Auth.BeginPasskeyLogin"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("username", req.Email),
+	)
+
+	if err := a.passkeysEnabled(); err != nil {
+		return webauthn.RequestOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := a.appProvider.App(ctx, req.AppID); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return webauthn.RequestOptions{}, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		return webauthn.RequestOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	state := passkeyChallenge{Ceremony: ceremonyLogin, AppID: req.AppID}
+	userVerification := webauthn.UserVerificationRequired
+
+	var allow [][]byte
+
+	if req.Email != "" {
+		event := models.AuditEvent{
+			Type:      models.EventLogin,
+			Email:     req.Email,
+			AppID:     req.AppID,
+			IP:        client.IP,
+			UserAgent: client.UserAgent,
+		}
+
+		user, err := a.verifyCredentials(ctx, log, event, req.Password)
+		if err != nil {
+			return webauthn.RequestOptions{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		passkeys, err := a.passkeys.Passkeys(ctx, user.ID)
+		if err != nil {
+			return webauthn.RequestOptions{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if len(passkeys) == 0 {
+			return webauthn.RequestOptions{}, fmt.Errorf("%s: %w: user has no passkeys", op, ErrPasskeyNotFound)
+		}
+
+		state.UserID, state.SecondFactor = user.ID, true
+		allow = credentialIDs(passkeys)
+		userVerification = webauthn.UserVerificationPreferred
+	}
+
+	challenge, err := a.newPasskeyChallenge(ctx, state)
+	if err != nil {
+		return webauthn.RequestOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return a.rp.RequestOptions(challenge, allow, userVerification, a.passkeyTTL), nil
+}
+
+// FinishPasskeyLogin checks assertion signed with options of BeginPasskeyLogin, starts
+// session of the passkey owner and returns its token, like Login does.
+//
+// Responses to unknown, expired or used challenges, failing the checks or coming
+// from authenticator whose signature counter went back get ErrInvalidPasskey.
+func (a *Auth) FinishPasskeyLogin(ctx context.Context, req PasskeyAssertion, client ClientInfo) (string, error) {
+	const op = "Auth.FinishPasskeyLogin"
+
+	log := a.log.With(slog.String("op", op))
+
+	if err := a.passkeysEnabled(); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge, state, err := a.redeemPasskeyChallenge(ctx, req.ClientDataJSON, ceremonyLogin)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{
+		Type:      models.EventLogin,
+		AppID:     state.AppID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	passkey, err := a.passkeys.Passkey(ctx, req.CredentialID)
+	if err != nil {
+		if errors.Is(err, storage.ErrPasskeyNotFound) {
+			a.auditFailure(ctx, event, reasonInvalidPasskey)
+			return "", fmt.Errorf("%s: %w: unknown credential", op, ErrInvalidPasskey)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.UserID = passkey.UserID
+
+	if state.SecondFactor && passkey.UserID != state.UserID {
+		a.auditFailure(ctx, event, reasonInvalidPasskey)
+		return "", fmt.Errorf("%s: %w: passkey of another user", op, ErrInvalidPasskey)
+	}
+
+	if len(req.UserHandle) != 0 && !bytes.Equal(req.UserHandle, userHandle(passkey.UserID)) {
+		a.auditFailure(ctx, event, reasonInvalidPasskey)
+		return "", fmt.Errorf("%s: %w: user handle mismatch", op, ErrInvalidPasskey)
+	}
+
+	assertion, err := a.rp.VerifyAssertion(challenge, passkey.PublicKey,
+		req.ClientDataJSON, req.AuthenticatorData, req.Signature)
+	if err != nil {
+		log.Info("passkey assertion rejected", sl.Err(err))
+		a.auditFailure(ctx, event, reasonInvalidPasskey)
+
+		return "", fmt.Errorf("%s: %w: %w", op, ErrInvalidPasskey, err)
+	}
+
+	if !state.SecondFactor && !assertion.UserVerified {
+		a.auditFailure(ctx, event, reasonInvalidPasskey)
+		return "", fmt.Errorf("%s: %w: user is not verified", op, ErrInvalidPasskey)
+	}
+
+	// authenticators without counters always report zero
+	if (assertion.SignCount != 0 || passkey.SignCount != 0) && assertion.SignCount <= passkey.SignCount {
+		log.Warn("passkey signature counter did not grow, authenticator may be cloned",
+			slog.Int64("passkey_id", passkey.ID))
+		a.auditFailure(ctx, event, reasonPasskeyCloned)
+
+		return "", fmt.Errorf("%s: %w: signature counter did not grow", op, ErrInvalidPasskey)
+	}
+
+	user, err := a.passkeys.UserByID(ctx, passkey.UserID)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.Email = user.Email
+
+	if user.Disabled {
+		log.Warn("user is disabled")
+		a.auditFailure(ctx, event, reasonUserDisabled)
+
+		return "", fmt.Errorf("%s: %w", op, ErrUserDisabled)
+	}
+
+	// the counter is raised only when nobody raised it since it was read, concurrent logins lose
+	if err := a.passkeys.UpdatePasskeyUsage(ctx, passkey.ID, assertion.SignCount, a.clock.Now()); err != nil {
+		if errors.Is(err, storage.ErrPasskeyNotFound) {
+			log.Warn("passkey signature counter was already used", slog.Int64("passkey_id", passkey.ID))
+			a.auditFailure(ctx, event, reasonPasskeyCloned)
+
+			return "", fmt.Errorf("%s: %w: signature counter was already used", op, ErrInvalidPasskey)
+		}
+
+		log.Error("failed to update passkey", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// user verification by the authenticator makes passkey alone multi-factor
+	authn := authentication{Methods: []string{AMRHardwareKey, AMRMultiFactor}}
+	if state.SecondFactor {
+		authn.Methods = []string{AMRPassword, AMRHardwareKey, AMRMultiFactor}
+	}
+
+	token, err := a.issueToken(ctx, log, user, state.AppID, client, nil, nil, authn)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.Success, event.Reason = true, reasonPasskey
+	if state.SecondFactor {
+		event.Reason = reasonPasswordPasskey
+	}
+	a.auditor.Record(ctx, event)
+
+	log.Info("Successful logging with passkey", slog.Int64("uid", user.ID))
+
+	return token, nil
+}
+
+func (a *Auth) passkeysEnabled() error {
+	if a.passkeys == nil {
+		return ErrPasskeysDisabled
+	}
+
+	if a.kv == nil {
+		return fmt.Errorf("%w: no store for passkey challenges", ErrUnavailable)
+	}
+
+	return nil
+}
+
+// checkPasskeyCaller lets users manage their passkeys with first-party tokens only,
+// neither apps nor impersonating admins may do it for them
+func (a *Auth) checkPasskeyCaller(caller Principal) error {
+	if err := a.passkeysEnabled(); err != nil {
+		return err
+	}
+
+	if caller.Scopes != nil || caller.ImpersonatorID != 0 {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// newPasskeyChallenge returns new challenge remembering state until the ceremony times out
+func (a *Auth) newPasskeyChallenge(ctx context.Context, state passkeyChallenge) ([]byte, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+
+	key := passkeyChallengeKey(base64.RawURLEncoding.EncodeToString(challenge))
+	if err := a.kv.Set(ctx, key, string(value), a.passkeyTTL); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	return challenge, nil
+}
+
+// redeemPasskeyChallenge returns challenge of ceremony client data was collected for
+// and its state, every challenge is redeemed once
+func (a *Auth) redeemPasskeyChallenge(
+	ctx context.Context, clientDataJSON []byte, ceremony string,
+) ([]byte, passkeyChallenge, error) {
+	clientData, err := webauthn.ParseClientData(clientDataJSON)
+	if err != nil {
+		return nil, passkeyChallenge{}, fmt.Errorf("%w: %w", ErrInvalidPasskey, err)
+	}
+
+	challenge, err := base64.RawURLEncoding.DecodeString(clientData.Challenge)
+	if err != nil || len(challenge) != webauthn.ChallengeLength {
+		return nil, passkeyChallenge{}, fmt.Errorf("%w: malformed challenge", ErrInvalidPasskey)
+	}
+
+	redeemed, err := a.kv.Incr(ctx, passkeyChallengeUsedKey(clientData.Challenge), a.passkeyTTL)
+	if err != nil {
+		return nil, passkeyChallenge{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	if redeemed > 1 {
+		return nil, passkeyChallenge{}, fmt.Errorf("%w: challenge was already used", ErrInvalidPasskey)
+	}
+
+	key := passkeyChallengeKey(clientData.Challenge)
+
+	value, err := a.kv.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return nil, passkeyChallenge{}, fmt.Errorf("%w: challenge is unknown or expired", ErrInvalidPasskey)
+		}
+
+		return nil, passkeyChallenge{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	if err := a.kv.Delete(ctx, key); err != nil {
+		a.log.Warn("failed to delete passkey challenge", sl.Err(err))
+	}
+
+	var state passkeyChallenge
+	if err := json.Unmarshal([]byte(value), &state); err != nil {
+		return nil, passkeyChallenge{}, err
+	}
+
+	if state.Ceremony != ceremony {
+		return nil, passkeyChallenge{}, fmt.Errorf("%w: challenge of %s", ErrInvalidPasskey, state.Ceremony)
+	}
+
+	return challenge, state, nil
+}
+
+// userHandle identifies user to authenticators, it is returned with discoverable credentials
+func userHandle(userID int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(userID))
+}
+
+func credentialIDs(passkeys []models.Passkey) [][]byte {
+	ids := make([][]byte, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		ids = append(ids, passkey.CredentialID)
+	}
+
+	return ids
+}
+
+func passkeyChallengeKey(challenge string) string {
+	return "passkey_challenge:" + challenge
+}
+
+func passkeyChallengeUsedKey(challenge string) string {
+	return "passkey_challenge_used:" + challenge
+}
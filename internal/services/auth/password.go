@@ -0,0 +1,460 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/kv"
+	"gRPC/internal/lib/email"
+	"gRPC/internal/lib/password"
+	"gRPC/internal/lib/random"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	resetSubject = "Password reset code"
+	// resetCodeTTL is how long password reset code stays valid
+	resetCodeTTL = 15 * time.Minute
+
+	// changeTokenPrefix tells tokens issued for expired passwords from access tokens
+	changeTokenPrefix = "pwc_"
+	changeTokenTTL    = 10 * time.Minute
+)
+
+// PasswordChangeRequiredError is returned by Login when password has expired.
+//
+// Token authenticates ChangePassword and nothing else.
+type PasswordChangeRequiredError struct {
+	Token string
+}
+
+func (e *PasswordChangeRequiredError) Error() string {
+	return "password change required"
+}
+
+// ChangePassword replaces password of the caller, who has to know the current one.
+//
+// Other sessions of the caller are revoked. New password breaking the policy
+// or reusing a recent one is rejected with *password.ViolationError.
+func (a *Auth) ChangePassword(ctx context.Context, caller Principal, current string, newPass string) error {
+	const op = "Auth.ChangePassword"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("uid", caller.UserID),
+	)
+
+	if err := checkAccountOwner(caller); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{
+		Type:   models.EventPasswordChange,
+		UserID: caller.UserID,
+		Email:  caller.Email,
+		AppID:  caller.AppID,
+	}
+
+	user, err := a.usrProvider.User(ctx, caller.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.hasher.Verify(user.PassHash, current); err != nil {
+		log.Info("invalid current password", sl.Err(err))
+		a.auditFailure(ctx, event, reasonInvalidPassword)
+
+		return fmt.Errorf("%s: %w", op, InvalidCredentials)
+	}
+
+	if err := a.checkNewPassword(ctx, user, newPass); err != nil {
+		log.Info("password rejected by policy", sl.Err(err))
+		a.auditFailure(ctx, event, reasonWeakPassword)
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.setPassword(ctx, log, user, newPass, caller.SessionID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if caller.changeToken != "" {
+		if err := a.kv.Delete(ctx, changeTokenKey(caller.changeToken)); err != nil {
+			log.Error("failed to delete password change token", sl.Err(err))
+		}
+	}
+
+	event.Success = true
+	a.auditor.Record(ctx, event)
+
+	log.Info("password changed")
+
+	return nil
+}
+
+// RequestPasswordReset emails password reset code to the user.
+//
+// Unknown emails are not reported, so the call cannot be used to find out
+// who has an account.
+func (a *Auth) RequestPasswordReset(ctx context.Context, userEmail string) error {
+	const op = "Auth.RequestPasswordReset"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", userEmail),
+	)
+
+	if a.kv == nil {
+		return fmt.Errorf("%s: %w: no store for reset codes", op, ErrUnavailable)
+	}
+
+	event := models.AuditEvent{Type: models.EventPasswordResetRequest, Email: userEmail}
+
+	if err := a.countAttempt(ctx, resetRequestsKey(userEmail)); err != nil {
+		log.Warn("reset request rejected", sl.Err(err))
+		if errors.Is(err, ErrTooManyAttempts) {
+			a.auditFailure(ctx, event, reasonTooManyAttempts)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.usrProvider.User(ctx, userEmail)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			a.auditFailure(ctx, event, reasonUserNotFound)
+
+			return nil
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.UserID = user.ID
+
+	if user.Disabled {
+		a.auditFailure(ctx, event, reasonUserDisabled)
+
+		return nil
+	}
+
+	code, err := random.Digits(codeLength)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	hashedCode, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.kv.Set(ctx, resetCodeKey(userEmail), string(hashedCode), resetCodeTTL); err != nil {
+		return fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+	}
+
+	err = a.emailSender.Send(ctx, email.Message{
+		To:      userEmail,
+		Subject: resetSubject,
+		Body:    code,
+	})
+	if err != nil {
+		log.Error("failed to send reset code", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.Success = true
+	a.auditor.Record(ctx, event)
+
+	log.Info("password reset code sent")
+
+	return nil
+}
+
+// ResetPassword sets new password of the user who got reset code by email.
+//
+// Every session of the user is revoked. New password breaking the policy
+// or reusing a recent one is rejected with *password.ViolationError and
+// the code stays valid.
+func (a *Auth) ResetPassword(ctx context.Context, userEmail string, code string, newPass string) error {
+	const op = "Auth.ResetPassword"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", userEmail),
+	)
+
+	if a.kv == nil {
+		return fmt.Errorf("%s: %w: no store for reset codes", op, ErrUnavailable)
+	}
+
+	event := models.AuditEvent{Type: models.EventPasswordReset, Email: userEmail}
+
+	if err := a.countAttempt(ctx, resetAttemptsKey(userEmail)); err != nil {
+		log.Warn("reset attempt rejected", sl.Err(err))
+		if errors.Is(err, ErrTooManyAttempts) {
+			a.auditFailure(ctx, event, reasonTooManyAttempts)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	hashedCode, err := a.kv.Get(ctx, resetCodeKey(userEmail))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			a.auditFailure(ctx, event, reasonInvalidCode)
+
+			return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+		}
+
+		return fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hashedCode), []byte(code)); err != nil {
+		log.Info("invalid reset code", sl.Err(err))
+		a.auditFailure(ctx, event, reasonInvalidCode)
+
+		return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+	}
+
+	user, err := a.usrProvider.User(ctx, userEmail)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			a.auditFailure(ctx, event, reasonUserNotFound)
+
+			return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.UserID = user.ID
+
+	if err := a.checkNewPassword(ctx, user, newPass); err != nil {
+		log.Info("password rejected by policy", sl.Err(err))
+		a.auditFailure(ctx, event, reasonWeakPassword)
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.setPassword(ctx, log, user, newPass, ""); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.kv.Delete(ctx, resetCodeKey(userEmail)); err != nil {
+		log.Warn("failed to clean up reset state", sl.Err(err))
+	}
+
+	if err := a.resetAttempts(ctx, resetAttemptsKey(userEmail)); err != nil {
+		log.Warn("failed to clean up reset state", sl.Err(err))
+	}
+
+	event.Success = true
+	a.auditor.Record(ctx, event)
+
+	log.Info("password reset")
+
+	return nil
+}
+
+// checkPassword returns *password.ViolationError when pass of user with given email breaks the policy
+func (a *Auth) checkPassword(pass string, email string) error {
+	if a.policy == nil {
+		return nil
+	}
+
+	return a.policy.Check(pass, email)
+}
+
+// checkNewPassword is checkPassword which also rejects recently used passwords
+func (a *Auth) checkNewPassword(ctx context.Context, user models.User, pass string) error {
+	var violations []string
+
+	if err := a.checkPassword(pass, user.Email); err != nil {
+		var violation *password.ViolationError
+		if !errors.As(err, &violation) {
+			return err
+		}
+
+		violations = violation.Violations
+	}
+
+	reused, err := a.reusesPassword(ctx, user, pass)
+	if err != nil {
+		return err
+	}
+
+	if reused {
+		violations = append(violations, fmt.Sprintf("must differ from the last %d passwords", a.history))
+	}
+
+	if len(violations) > 0 {
+		return &password.ViolationError{Violations: violations}
+	}
+
+	return nil
+}
+
+// reusesPassword reports if pass is one of the latest passwords of user
+func (a *Auth) reusesPassword(ctx context.Context, user models.User, pass string) (bool, error) {
+	if a.history <= 0 {
+		return false, nil
+	}
+
+	history, err := a.usrProvider.PasswordHistory(ctx, user.ID, a.history)
+	if err != nil {
+		return false, err
+	}
+
+	// users registered before the history was kept have the current password only
+	hashes := [][]byte{user.PassHash}
+	if len(history) > 0 {
+		hashes = hashes[:0]
+	}
+
+	for _, entry := range history {
+		hashes = append(hashes, entry.PassHash)
+	}
+
+	for _, hash := range hashes {
+		if a.hasher.Verify(hash, pass) == nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// passwordExpired reports if password of user is too old to log in to app
+func (a *Auth) passwordExpired(ctx context.Context, user models.User, appID int) (bool, error) {
+	if a.maxAge <= 0 || (len(a.expiringApps) > 0 && !slices.Contains(a.expiringApps, appID)) {
+		return false, nil
+	}
+
+	changedAt := user.CreatedAt
+
+	history, err := a.usrProvider.PasswordHistory(ctx, user.ID, 1)
+	if err != nil {
+		return false, err
+	}
+
+	if len(history) > 0 {
+		changedAt = history[0].CreatedAt
+	}
+
+	return a.clock.Now().Sub(changedAt) >= a.maxAge, nil
+}
+
+// issuePasswordChangeToken returns token which authenticates ChangePassword of user only
+func (a *Auth) issuePasswordChangeToken(ctx context.Context, user models.User) (string, error) {
+	if a.kv == nil {
+		return "", fmt.Errorf("%w: no store for password change tokens", ErrUnavailable)
+	}
+
+	secret, err := random.String(sessionIDLength)
+	if err != nil {
+		return "", err
+	}
+
+	token := changeTokenPrefix + secret
+
+	value := strconv.FormatInt(user.ID, 10) + ":" + user.Email
+	if err := a.kv.Set(ctx, changeTokenKey(token), value, changeTokenTTL); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	return token, nil
+}
+
+// AuthenticatePasswordChange is Authenticate which also accepts tokens Login
+// issues for expired passwords, it must guard nothing but ChangePassword.
+// Such token is single use, ChangePassword deletes it once the password is changed.
+func (a *Auth) AuthenticatePasswordChange(ctx context.Context, token string) (Principal, error) {
+	const op = "Auth.AuthenticatePasswordChange"
+
+	if !strings.HasPrefix(token, changeTokenPrefix) {
+		return a.Authenticate(ctx, token)
+	}
+
+	if a.kv == nil {
+		return Principal{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	value, err := a.kv.Get(ctx, changeTokenKey(token))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return Principal{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		return Principal{}, fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+	}
+
+	uid, email, _ := strings.Cut(value, ":")
+
+	userID, err := strconv.ParseInt(uid, 10, 64)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidToken, err)
+	}
+
+	return Principal{UserID: userID, Email: email, changeToken: token}, nil
+}
+
+// recordPassword adds just set password to history, failure is only logged
+// as the password is already saved
+func (a *Auth) recordPassword(ctx context.Context, log *slog.Logger, userID int64, passHash []byte) {
+	err := a.usrSaver.SavePasswordHistory(ctx, models.PasswordHistoryEntry{
+		UserID:    userID,
+		PassHash:  passHash,
+		CreatedAt: a.clock.Now(),
+	})
+	if err != nil {
+		log.Error("failed to save password history", sl.Err(err))
+	}
+}
+
+// setPassword stores hash of pass and revokes sessions of user except keepSessionID
+func (a *Auth) setPassword(ctx context.Context, log *slog.Logger, user models.User, pass string, keepSessionID string) error {
+	passHash, err := a.hasher.Hash(pass)
+	if err != nil {
+		return err
+	}
+
+	if err := a.usrSaver.UpdatePassword(ctx, user.ID, passHash); err != nil {
+		return err
+	}
+
+	a.recordPassword(ctx, log, user.ID, passHash)
+
+	if _, err := a.sessions.RevokeSessions(ctx, user.ID, keepSessionID, a.clock.Now()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func changeTokenKey(token string) string {
+	return "password_change_token:" + token
+}
+
+func resetCodeKey(email string) string {
+	return "password_reset:" + email
+}
+
+func resetAttemptsKey(email string) string {
+	return "password_reset_attempts:" + email
+}
+
+func resetRequestsKey(email string) string {
+	return "password_reset_requests:" + email
+}
@@ -0,0 +1,409 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/kv"
+	"gRPC/internal/lib/email"
+	"gRPC/internal/lib/random"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPasswordlessDisabled = errors.New("passwordless login is disabled for app")
+	ErrInvalidLoginMethod   = errors.New("invalid passwordless login method")
+)
+
+const (
+	// LoginMethodLink emails single-use link, LoginMethodCode emails numeric one-time code
+	LoginMethodLink = "link"
+	LoginMethodCode = "code"
+
+	linkSubject      = "Your login link"
+	loginCodeSubject = "Your login code"
+
+	// defaultLoginCodeTTL is how long login links and codes stay valid unless configured
+	defaultLoginCodeTTL = 15 * time.Minute
+
+	// loginTokenLength is the number of random bytes in login link token
+	loginTokenLength = 32
+
+	reasonPasswordless = "passwordless"
+)
+
+// WithPasswordless sets page of app front end login links point to, with token query
+// parameter, and how long links and codes stay valid. Without the page the token itself is emailed.
+func WithPasswordless(linkURL string, ttl time.Duration) Option {
+	return func(a *Auth) {
+		a.linkURL = linkURL
+		if ttl > 0 {
+			a.loginCodeTTL = ttl
+		}
+	}
+}
+
+// PasswordlessLogin completes login started by StartPasswordlessLogin with Token of emailed
+// link or with Email and Code
+type PasswordlessLogin struct {
+	AppID int
+	Token string
+	Email string
+	Code  string
+}
+
+// StartPasswordlessLogin emails login link or one-time code, by method, to the user
+// logging into app which has passwordless login enabled.
+//
+// Unknown and disabled users are not reported and the email is sent in background,
+// so the call cannot be used to find out who has an account.
+func (a *Auth) StartPasswordlessLogin(ctx context.Context, userEmail string, appID int, method string) error {
+	const op = "Auth.StartPasswordlessLogin"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", userEmail),
+	)
+
+	if method != LoginMethodLink && method != LoginMethodCode {
+		return fmt.Errorf("%s: %w: %q", op, ErrInvalidLoginMethod, method)
+	}
+
+	if a.kv == nil {
+		return fmt.Errorf("%s: %w: no store for login codes", op, ErrUnavailable)
+	}
+
+	if _, err := a.passwordlessApp(ctx, appID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{Type: models.EventPasswordlessRequest, Email: userEmail, AppID: appID}
+
+	if err := a.countAttempt(ctx, loginRequestsKey(userEmail)); err != nil {
+		log.Warn("passwordless request rejected", sl.Err(err))
+		if errors.Is(err, ErrTooManyAttempts) {
+			a.auditFailure(ctx, event, reasonTooManyAttempts)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the user is looked up and emailed in background, so the response time
+	// does not tell whether the account exists
+	go a.sendLoginMessage(context.WithoutCancel(ctx), log, event, method)
+
+	return nil
+}
+
+// sendLoginMessage emails login link or one-time code to the user of event,
+// unknown and disabled users get nothing
+func (a *Auth) sendLoginMessage(ctx context.Context, log *slog.Logger, event models.AuditEvent, method string) {
+	user, err := a.usrProvider.User(ctx, event.Email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			a.auditFailure(ctx, event, reasonUserNotFound)
+			return
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return
+	}
+
+	event.UserID = user.ID
+
+	if user.Disabled {
+		a.auditFailure(ctx, event, reasonUserDisabled)
+		return
+	}
+
+	var msg email.Message
+
+	if method == LoginMethodLink {
+		msg, err = a.loginLink(ctx, user, event.AppID)
+	} else {
+		msg, err = a.loginCode(ctx, user, event.AppID)
+	}
+	if err != nil {
+		log.Error("failed to create login "+method, sl.Err(err))
+		return
+	}
+
+	if err := a.emailSender.Send(ctx, msg); err != nil {
+		log.Error("failed to send login "+method, sl.Err(err))
+		return
+	}
+
+	event.Success, event.Reason = true, method
+	a.auditor.Record(ctx, event)
+
+	log.Info("passwordless login started", slog.String("method", method))
+}
+
+// CompletePasswordlessLogin starts session of the user who got login link or code by email
+// and returns its token, like Login does. Links and codes are accepted once.
+//
+// Unknown, expired and used links and codes get ErrInvalidCode.
+func (a *Auth) CompletePasswordlessLogin(ctx context.Context, req PasswordlessLogin, client ClientInfo) (string, error) {
+	const op = "Auth.CompletePasswordlessLogin"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", req.Email),
+	)
+
+	if a.kv == nil {
+		return "", fmt.Errorf("%s: %w: no store for login codes", op, ErrUnavailable)
+	}
+
+	if _, err := a.passwordlessApp(ctx, req.AppID); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{
+		Type:      models.EventLogin,
+		Email:     req.Email,
+		AppID:     req.AppID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	var (
+		userEmail string
+		err       error
+	)
+
+	if req.Token != "" {
+		userEmail, err = a.redeemLoginLink(ctx, req.Token, req.AppID)
+	} else {
+		userEmail, err = a.redeemLoginCode(ctx, log, event, req.Email, req.Code)
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			a.auditFailure(ctx, event, reasonInvalidCode)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.Email = userEmail
+
+	user, err := a.usrProvider.User(ctx, userEmail)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			a.auditFailure(ctx, event, reasonUserNotFound)
+
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidCode)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.UserID = user.ID
+
+	if user.Disabled {
+		log.Warn("user is disabled")
+		a.auditFailure(ctx, event, reasonUserDisabled)
+
+		return "", fmt.Errorf("%s: %w", op, ErrUserDisabled)
+	}
+
+	token, err := a.issueToken(ctx, log, user, req.AppID, client, nil, nil, authentication{Methods: []string{AMROneTimeCode}})
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.resetAttempts(ctx, loginAttemptsKey(userEmail)); err != nil {
+		log.Warn("failed to clean up login attempts", sl.Err(err))
+	}
+
+	event.Success, event.Reason = true, reasonPasswordless
+	a.auditor.Record(ctx, event)
+
+	log.Info("Successful passwordless logging")
+
+	return token, nil
+}
+
+// passwordlessApp returns app with passwordless login enabled
+func (a *Auth) passwordlessApp(ctx context.Context, appID int) (models.App, error) {
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.App{}, ErrInvalidAppID
+		}
+
+		return models.App{}, err
+	}
+
+	if !app.Passwordless {
+		return models.App{}, ErrPasswordlessDisabled
+	}
+
+	return app, nil
+}
+
+// loginLink stores token of login link for user and app and returns email carrying the link
+func (a *Auth) loginLink(ctx context.Context, user models.User, appID int) (email.Message, error) {
+	token, err := random.String(loginTokenLength)
+	if err != nil {
+		return email.Message{}, err
+	}
+
+	value := strconv.Itoa(appID) + ":" + user.Email
+	if err := a.kv.Set(ctx, loginLinkKey(token), value, a.loginCodeTTL); err != nil {
+		return email.Message{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	body := token
+
+	if a.linkURL != "" {
+		link, err := url.Parse(a.linkURL)
+		if err != nil {
+			return email.Message{}, err
+		}
+
+		query := link.Query()
+		query.Set("token", token)
+		link.RawQuery = query.Encode()
+
+		body = link.String()
+	}
+
+	return email.Message{To: user.Email, Subject: linkSubject, Body: body}, nil
+}
+
+// loginCode stores hash of one-time code for user and app, replacing the previous one,
+// and returns email carrying the code
+func (a *Auth) loginCode(ctx context.Context, user models.User, appID int) (email.Message, error) {
+	code, err := random.Digits(codeLength)
+	if err != nil {
+		return email.Message{}, err
+	}
+
+	hashedCode, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return email.Message{}, err
+	}
+
+	if err := a.kv.Set(ctx, loginCodeKey(appID, user.Email), string(hashedCode), a.loginCodeTTL); err != nil {
+		return email.Message{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	return email.Message{To: user.Email, Subject: loginCodeSubject, Body: code}, nil
+}
+
+// redeemLoginLink returns email of login link token issued for app and makes sure
+// it is never redeemed again, even when the same link is opened concurrently.
+// Link opened in another app is rejected and stays valid for its own one.
+func (a *Auth) redeemLoginLink(ctx context.Context, token string, appID int) (string, error) {
+	value, err := a.kv.Get(ctx, loginLinkKey(token))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return "", fmt.Errorf("%w: link is unknown or expired", ErrInvalidCode)
+		}
+
+		return "", fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	linkAppID, userEmail, _ := strings.Cut(value, ":")
+	if linkAppID != strconv.Itoa(appID) {
+		return "", fmt.Errorf("%w: link is of another app", ErrInvalidCode)
+	}
+
+	redeemed, err := a.kv.Incr(ctx, loginLinkUsedKey(token), a.loginCodeTTL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	if redeemed > 1 {
+		return "", fmt.Errorf("%w: link was already used", ErrInvalidCode)
+	}
+
+	if err := a.kv.Delete(ctx, loginLinkKey(token)); err != nil {
+		a.log.Warn("failed to delete login link", sl.Err(err))
+	}
+
+	return userEmail, nil
+}
+
+// redeemLoginCode checks one-time code of user with userEmail logging into event.AppID,
+// the code is accepted once
+func (a *Auth) redeemLoginCode(
+	ctx context.Context, log *slog.Logger, event models.AuditEvent, userEmail string, code string,
+) (string, error) {
+	if userEmail == "" || code == "" {
+		return "", fmt.Errorf("%w: email and code are required", ErrInvalidCode)
+	}
+
+	if err := a.countAttempt(ctx, loginAttemptsKey(userEmail)); err != nil {
+		log.Warn("login attempt rejected", sl.Err(err))
+		if errors.Is(err, ErrTooManyAttempts) {
+			a.auditFailure(ctx, event, reasonTooManyAttempts)
+		}
+
+		return "", err
+	}
+
+	key := loginCodeKey(event.AppID, userEmail)
+
+	hashedCode, err := a.kv.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return "", fmt.Errorf("%w: code is unknown or expired", ErrInvalidCode)
+		}
+
+		return "", fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hashedCode), []byte(code)); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidCode, err)
+	}
+
+	// every code has its own hash, so it is the key of the code being used
+	redeemed, err := a.kv.Incr(ctx, loginCodeUsedKey(hashedCode), a.loginCodeTTL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	if redeemed > 1 {
+		return "", fmt.Errorf("%w: code was already used", ErrInvalidCode)
+	}
+
+	if err := a.kv.Delete(ctx, key); err != nil {
+		log.Warn("failed to delete login code", sl.Err(err))
+	}
+
+	return userEmail, nil
+}
+
+func loginLinkKey(token string) string {
+	return "login_link:" + token
+}
+
+func loginLinkUsedKey(token string) string {
+	return "login_link_used:" + token
+}
+
+func loginCodeKey(appID int, email string) string {
+	return "login_code:" + strconv.Itoa(appID) + ":" + email
+}
+
+func loginCodeUsedKey(hashedCode string) string {
+	return "login_code_used:" + hashedCode
+}
+
+func loginAttemptsKey(email string) string {
+	return "login_code_attempts:" + email
+}
+
+func loginRequestsKey(email string) string {
+	return "login_code_requests:" + email
+}
@@ -0,0 +1,622 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/kv"
+	"gRPC/internal/lib/random"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/lib/sms"
+	"gRPC/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSMSDisabled       = errors.New("sms codes are not enabled")
+	ErrInvalidPhone      = errors.New("invalid phone number")
+	ErrInvalidChannel    = errors.New("invalid sms channel")
+	ErrPhoneNotVerified  = errors.New("user has no verified phone")
+	ErrNoPendingPhone    = errors.New("no phone verification in progress")
+	ErrPhoneCodeNotFound = errors.New("sms code is unknown or expired")
+)
+
+const (
+	// defaultSMSCodeTTL is how long codes sent to phones stay valid unless configured
+	defaultSMSCodeTTL = 5 * time.Minute
+
+	// smsChallengeLength is the number of random bytes in id of login challenge
+	smsChallengeLength = 32
+
+	smsVerificationText = "Your phone verification code is "
+	smsLoginText        = "Your login code is "
+
+	reasonPasswordSMS   = "password_sms"
+	reasonPasswordVoice = "password_voice"
+)
+
+// SMSSender delivers text and voice messages to phones
+type SMSSender interface {
+	Send(ctx context.Context, msg sms.Message) error
+}
+
+// PhoneStorage keeps phone numbers of users
+type PhoneStorage interface {
+	UpdatePhone(ctx context.Context, userID int64, phone string, verified bool) error
+	UserByID(ctx context.Context, id int64) (models.User, error)
+}
+
+// WithSMS enables phone verification and codes sent to phones as the second factor,
+// codes stay valid for codeTTL. Phone calls get ErrSMSDisabled without it.
+func WithSMS(sender SMSSender, phones PhoneStorage, codeTTL time.Duration) Option {
+	return func(a *Auth) {
+		a.smsSender = sender
+		a.phones = phones
+		if codeTTL > 0 {
+			a.smsCodeTTL = codeTTL
+		}
+	}
+}
+
+// SMSLogin starts login into app with password and code sent over Channel
+// to the verified phone of the user
+type SMSLogin struct {
+	AppID    int
+	Email    string
+	Password string
+	Channel  string
+}
+
+// SMSChallenge is login waiting for the code sent to Phone, which is masked
+type SMSChallenge struct {
+	ID        string
+	Phone     string
+	Channel   string
+	ExpiresAt time.Time
+}
+
+// SecondFactorRequiredError is returned by Login, LoginWithScope and Authorize when the user
+// has verified phone. The login is completed with the code sent to it by FinishSMSLogin,
+// or FinishSMSAuthorization for Authorize.
+type SecondFactorRequiredError struct {
+	Challenge SMSChallenge
+}
+
+func (e *SecondFactorRequiredError) Error() string {
+	return "second factor required"
+}
+
+// smsLoginState is what is remembered about login until the code is entered
+type smsLoginState struct {
+	UserID   int64  `json:"user_id"`
+	AppID    int    `json:"app_id"`
+	Channel  string `json:"channel"`
+	CodeHash string `json:"code_hash"`
+	// SessionID is set when the code raises authentication level of the session instead of login
+	SessionID string `json:"session_id,omitempty"`
+	// Scopes and Audience limit the token like LoginWithScope does, empty scopes
+	// still limit it unlike nil ones, so they are never omitted
+	Scopes   []string `json:"scopes"`
+	Audience []string `json:"audience,omitempty"`
+	// Authorization is set when the code completes OAuth authorization instead of login
+	Authorization *authorizationCode `json:"authorization,omitempty"`
+}
+
+// Phone returns phone number of the caller and whether it is verified,
+// it is empty when the caller has not set any
+func (a *Auth) Phone(ctx context.Context, caller Principal) (string, bool, error) {
+	const op = "Auth.Phone"
+
+	if err := a.checkPhoneCaller(caller); err != nil {
+		return "", false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.phones.UserByID(ctx, caller.UserID)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user.Phone, user.PhoneVerified, nil
+}
+
+// StartPhoneVerification sets phone number of the caller, unverified until VerifyPhone
+// gets the code sent to it over channel. The number replaces the previous one, which
+// stops being the second factor. Codes sent to a number are rate limited.
+func (a *Auth) StartPhoneVerification(ctx context.Context, caller Principal, phone string, channel string) error {
+	const op = "Auth.StartPhoneVerification"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("uid", caller.UserID),
+	)
+
+	if err := a.checkPhoneCaller(caller); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	phone, err := normalizePhone(phone, channel)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.countAttempt(ctx, smsRequestsKey(phone)); err != nil {
+		log.Warn("phone verification rejected", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.phones.UpdatePhone(ctx, caller.UserID, phone, false); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, hashedCode, err := newSMSCode()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the number is kept with the code, so it cannot verify number set later
+	if err := a.kv.Set(ctx, phoneCodeKey(caller.UserID), phone+":"+hashedCode, a.smsCodeTTL); err != nil {
+		return fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+	}
+
+	if err := a.smsSender.Send(ctx, sms.Message{To: phone, Body: smsVerificationText + code, Channel: channel}); err != nil {
+		log.Error("failed to send phone verification code", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("phone verification started", slog.String("channel", channel))
+
+	return nil
+}
+
+// VerifyPhone marks phone number of the caller verified when code is the one sent to it,
+// so it can be used for the second factor. Wrong and expired codes get ErrInvalidCode.
+func (a *Auth) VerifyPhone(ctx context.Context, caller Principal, code string) error {
+	const op = "Auth.VerifyPhone"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("uid", caller.UserID),
+	)
+
+	if err := a.checkPhoneCaller(caller); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{Type: models.EventPhoneVerify, UserID: caller.UserID, Email: caller.Email}
+
+	if err := a.countAttempt(ctx, phoneAttemptsKey(caller.UserID)); err != nil {
+		log.Warn("phone verification rejected", sl.Err(err))
+		if errors.Is(err, ErrTooManyAttempts) {
+			a.auditFailure(ctx, event, reasonTooManyAttempts)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	value, err := a.kv.Get(ctx, phoneCodeKey(caller.UserID))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrNoPendingPhone)
+		}
+
+		return fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+	}
+
+	phone, hashedCode, _ := strings.Cut(value, ":")
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hashedCode), []byte(code)); err != nil {
+		a.auditFailure(ctx, event, reasonInvalidCode)
+		return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+	}
+
+	user, err := a.phones.UserByID(ctx, caller.UserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.Phone != phone {
+		return fmt.Errorf("%s: %w: number was changed", op, ErrNoPendingPhone)
+	}
+
+	if err := a.phones.UpdatePhone(ctx, caller.UserID, phone, true); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.kv.Delete(ctx, phoneCodeKey(caller.UserID)); err != nil {
+		log.Warn("failed to delete phone verification code", sl.Err(err))
+	}
+
+	event.Success, event.Reason = true, sms.MaskPhone(phone)
+	a.auditor.Record(ctx, event)
+
+	log.Info("phone verified")
+
+	return nil
+}
+
+// DeletePhone removes phone number of the caller, it stops being the second factor
+func (a *Auth) DeletePhone(ctx context.Context, caller Principal) error {
+	const op = "Auth.DeletePhone"
+
+	if err := a.checkPhoneCaller(caller); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.phones.UpdatePhone(ctx, caller.UserID, "", false); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.kv.Delete(ctx, phoneCodeKey(caller.UserID)); err != nil {
+		a.log.Warn("failed to delete phone verification code", slog.String("op", op), sl.Err(err))
+	}
+
+	a.auditor.Record(ctx, models.AuditEvent{
+		Type:    models.EventPhoneDelete,
+		UserID:  caller.UserID,
+		Email:   caller.Email,
+		Success: true,
+	})
+
+	return nil
+}
+
+// StartSMSLogin checks password like Login does and sends one-time code to the verified
+// phone of the user over req.Channel, FinishSMSLogin completes the login with it.
+// Users without verified phone get ErrPhoneNotVerified.
+func (a *Auth) StartSMSLogin(ctx context.Context, req SMSLogin, client ClientInfo) (SMSChallenge, error) {
+	const op = "Auth.StartSMSLogin"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("username", req.Email),
+	)
+
+	if err := a.smsEnabled(); err != nil {
+		return SMSChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if req.Channel != sms.ChannelSMS && req.Channel != sms.ChannelVoice {
+		return SMSChallenge{}, fmt.Errorf("%s: %w: %q", op, ErrInvalidChannel, req.Channel)
+	}
+
+	if _, err := a.appProvider.App(ctx, req.AppID); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return SMSChallenge{}, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		return SMSChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{
+		Type:      models.EventLogin,
+		Email:     req.Email,
+		AppID:     req.AppID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	user, err := a.verifyCredentials(ctx, log, event, req.Password)
+	if err != nil {
+		return SMSChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge, err := a.startSMSChallenge(ctx, log, user, smsLoginState{AppID: req.AppID, Channel: req.Channel}, event)
+	if err != nil {
+		return SMSChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("sms login started", slog.String("channel", req.Channel))
+
+	return challenge, nil
+}
+
+// FinishSMSLogin checks code sent for challenge started by StartSMSLogin, Login or LoginWithScope,
+// starts session of the user and returns its token, scoped when LoginWithScope started it.
+// Every code is accepted once.
+//
+// Unknown and expired challenges get ErrPhoneCodeNotFound, wrong codes get ErrInvalidCode.
+func (a *Auth) FinishSMSLogin(ctx context.Context, challengeID string, code string, client ClientInfo) (string, error) {
+	const op = "Auth.FinishSMSLogin"
+
+	log := a.log.With(slog.String("op", op))
+
+	if err := a.smsEnabled(); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.AuditEvent{
+		Type:      models.EventLogin,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	state, user, err := a.finishSMSChallenge(ctx, log, challengeID, code, event)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// codes of OAuth authorization are redeemed for authorization codes only
+	if state.Authorization != nil {
+		return "", fmt.Errorf("%s: %w", op, ErrPhoneCodeNotFound)
+	}
+
+	event.UserID, event.AppID, event.Email = user.ID, state.AppID, user.Email
+
+	authn := authentication{Methods: smsMethods(state.Channel)}
+	token, err := a.issueToken(ctx, log, user, state.AppID, client, state.Scopes, state.Audience, authn)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.Success, event.Reason = true, reasonPasswordSMS
+	if state.Channel == sms.ChannelVoice {
+		event.Reason = reasonPasswordVoice
+	}
+	a.auditor.Record(ctx, event)
+
+	log.Info("Successful logging with sms code", slog.Int64("uid", user.ID))
+
+	return token, nil
+}
+
+// finishSMSChallenge checks code of login challenge and returns its state and the user,
+// who must not have been disabled since the login began
+func (a *Auth) finishSMSChallenge(
+	ctx context.Context, log *slog.Logger, challengeID string, code string, event models.AuditEvent,
+) (smsLoginState, models.User, error) {
+	state, err := a.redeemSMSChallenge(ctx, log, challengeID, code, "", event)
+	if err != nil {
+		return smsLoginState{}, models.User{}, err
+	}
+
+	event.UserID, event.AppID = state.UserID, state.AppID
+
+	user, err := a.phones.UserByID(ctx, state.UserID)
+	if err != nil {
+		return smsLoginState{}, models.User{}, err
+	}
+
+	event.Email = user.Email
+
+	if user.Disabled {
+		log.Warn("user is disabled")
+		a.auditFailure(ctx, event, reasonUserDisabled)
+
+		return smsLoginState{}, models.User{}, ErrUserDisabled
+	}
+
+	return state, user, nil
+}
+
+// requireSecondFactor sends code to the verified phone of user, whose password has just
+// been checked, and returns the challenge as *SecondFactorRequiredError. Users without
+// verified phone need no second factor and get nil.
+func (a *Auth) requireSecondFactor(
+	ctx context.Context, log *slog.Logger, user models.User, state smsLoginState, event models.AuditEvent,
+) error {
+	if user.Phone == "" || !user.PhoneVerified {
+		return nil
+	}
+
+	if err := a.smsEnabled(); err != nil {
+		return err
+	}
+
+	// no code is sent for app the token could not be issued for
+	if _, err := a.appProvider.App(ctx, state.AppID); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			a.auditFailure(ctx, event, reasonInvalidApp)
+			return ErrInvalidAppID
+		}
+
+		return err
+	}
+
+	state.Channel = sms.ChannelSMS
+
+	challenge, err := a.startSMSChallenge(ctx, log, user, state, event)
+	if err != nil {
+		return err
+	}
+
+	log.Info("second factor required", slog.Int64("uid", user.ID))
+
+	return &SecondFactorRequiredError{Challenge: challenge}
+}
+
+// startSMSChallenge sends one-time code to the verified phone of user, whose password
+// has just been checked, and remembers state until the code comes back
+func (a *Auth) startSMSChallenge(
+	ctx context.Context, log *slog.Logger, user models.User, state smsLoginState, event models.AuditEvent,
+) (SMSChallenge, error) {
+	if user.Phone == "" || !user.PhoneVerified {
+		return SMSChallenge{}, ErrPhoneNotVerified
+	}
+
+	if err := a.countAttempt(ctx, smsRequestsKey(user.Phone)); err != nil {
+		log.Warn("sms code rejected", sl.Err(err))
+		if errors.Is(err, ErrTooManyAttempts) {
+			event.UserID = user.ID
+			a.auditFailure(ctx, event, reasonTooManyAttempts)
+		}
+
+		return SMSChallenge{}, err
+	}
+
+	code, hashedCode, err := newSMSCode()
+	if err != nil {
+		return SMSChallenge{}, err
+	}
+
+	id, err := random.String(smsChallengeLength)
+	if err != nil {
+		return SMSChallenge{}, err
+	}
+
+	state.UserID, state.CodeHash = user.ID, hashedCode
+
+	value, err := json.Marshal(state)
+	if err != nil {
+		return SMSChallenge{}, err
+	}
+
+	if err := a.kv.Set(ctx, smsLoginKey(id), string(value), a.smsCodeTTL); err != nil {
+		return SMSChallenge{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	if err := a.smsSender.Send(ctx, sms.Message{To: user.Phone, Body: smsLoginText + code, Channel: state.Channel}); err != nil {
+		log.Error("failed to send login code", sl.Err(err))
+		return SMSChallenge{}, err
+	}
+
+	return SMSChallenge{
+		ID:        id,
+		Phone:     sms.MaskPhone(user.Phone),
+		Channel:   state.Channel,
+		ExpiresAt: a.clock.Now().Add(a.smsCodeTTL),
+	}, nil
+}
+
+// redeemSMSChallenge checks code of challenge started for sessionID, empty for login,
+// and returns its state. Every code is accepted once.
+func (a *Auth) redeemSMSChallenge(
+	ctx context.Context, log *slog.Logger, challengeID string, code string, sessionID string, event models.AuditEvent,
+) (smsLoginState, error) {
+	key := smsLoginKey(challengeID)
+
+	value, err := a.kv.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return smsLoginState{}, ErrPhoneCodeNotFound
+		}
+
+		return smsLoginState{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	var state smsLoginState
+	if err := json.Unmarshal([]byte(value), &state); err != nil {
+		return smsLoginState{}, err
+	}
+
+	// codes of reauthentication must not log in and the other way round
+	if state.SessionID != sessionID {
+		return smsLoginState{}, ErrPhoneCodeNotFound
+	}
+
+	event.UserID, event.AppID = state.UserID, state.AppID
+
+	if err := a.countAttempt(ctx, smsLoginAttemptsKey(challengeID)); err != nil {
+		log.Warn("sms code attempt rejected", sl.Err(err))
+		if errors.Is(err, ErrTooManyAttempts) {
+			a.auditFailure(ctx, event, reasonTooManyAttempts)
+		}
+
+		return smsLoginState{}, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(state.CodeHash), []byte(code)); err != nil {
+		a.auditFailure(ctx, event, reasonInvalidCode)
+		return smsLoginState{}, ErrInvalidCode
+	}
+
+	redeemed, err := a.kv.Incr(ctx, smsLoginUsedKey(challengeID), a.smsCodeTTL)
+	if err != nil {
+		return smsLoginState{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	if redeemed > 1 {
+		return smsLoginState{}, fmt.Errorf("%w: code was already used", ErrPhoneCodeNotFound)
+	}
+
+	if err := a.kv.Delete(ctx, key); err != nil {
+		log.Warn("failed to delete sms challenge", sl.Err(err))
+	}
+
+	return state, nil
+}
+
+func (a *Auth) smsEnabled() error {
+	if a.phones == nil || a.smsSender == nil {
+		return ErrSMSDisabled
+	}
+
+	if a.kv == nil {
+		return fmt.Errorf("%w: no store for sms codes", ErrUnavailable)
+	}
+
+	return nil
+}
+
+// checkPhoneCaller lets users manage their phones with first-party tokens only,
+// neither apps nor impersonating admins may do it for them
+func (a *Auth) checkPhoneCaller(caller Principal) error {
+	if err := a.smsEnabled(); err != nil {
+		return err
+	}
+
+	if caller.Scopes != nil || caller.ImpersonatorID != 0 {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// normalizePhone returns phone in E.164 format after checking that codes can be sent to it over channel
+func normalizePhone(phone string, channel string) (string, error) {
+	if channel != sms.ChannelSMS && channel != sms.ChannelVoice {
+		return "", fmt.Errorf("%w: %q", ErrInvalidChannel, channel)
+	}
+
+	normalized, err := sms.NormalizePhone(phone)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidPhone, err)
+	}
+
+	return normalized, nil
+}
+
+// newSMSCode returns one-time code and its hash
+func newSMSCode() (string, string, error) {
+	code, err := random.Digits(codeLength)
+	if err != nil {
+		return "", "", err
+	}
+
+	hashedCode, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+
+	return code, string(hashedCode), nil
+}
+
+func phoneCodeKey(userID int64) string {
+	return "phone_code:" + strconv.FormatInt(userID, 10)
+}
+
+func phoneAttemptsKey(userID int64) string {
+	return "phone_code_attempts:" + strconv.FormatInt(userID, 10)
+}
+
+func smsLoginKey(id string) string {
+	return "sms_login:" + id
+}
+
+func smsLoginUsedKey(id string) string {
+	return "sms_login_used:" + id
+}
+
+func smsLoginAttemptsKey(id string) string {
+	return "sms_login_attempts:" + id
+}
+
+// smsRequestsKey counts codes sent to phone, whoever asks for them
+func smsRequestsKey(phone string) string {
+	return "sms_requests:" + phone
+}
@@ -0,0 +1,314 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/jwt"
+	"gRPC/internal/lib/keys"
+	"gRPC/internal/lib/oauth"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/storage"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+const (
+	// tokenEndpointPath is where client assertions are presented, they may name it as audience
+	tokenEndpointPath = "/oauth/token"
+
+	// maxAssertionLifetime bounds how far in the future client assertion may expire,
+	// its jti is remembered until then
+	maxAssertionLifetime = 5 * time.Minute
+
+	reasonClientCredentials      = "client_credentials"
+	reasonInvalidClient          = "invalid_client"
+	reasonServiceAccountDisabled = "service_account_disabled"
+)
+
+// ServiceAccountProvider looks up service accounts by their client id
+// and exchange policies they act under
+type ServiceAccountProvider interface {
+	ServiceAccountByClientID(ctx context.Context, clientID string) (models.ServiceAccount, error)
+	ExchangePolicy(ctx context.Context, appID int, actor string, audience string) (models.ExchangePolicy, error)
+}
+
+// WithServiceAccounts enables client_credentials grant of service accounts, it is rejected by default
+func WithServiceAccounts(accounts ServiceAccountProvider) Option {
+	return func(a *Auth) {
+		a.accounts = accounts
+	}
+}
+
+// ClientCredentials is token request of service account with client_credentials grant.
+//
+// The account authenticates with ClientSecret or with Assertion, a JWT signed by its
+// registered key (private_key_jwt, RFC 7523). ClientID may be omitted with Assertion.
+type ClientCredentials struct {
+	ClientID     string
+	ClientSecret string
+	Assertion    string
+	// Scope is a subset of the account scopes, empty scope requests all of them
+	Scope string
+	// Audience is a subset of the app audiences, empty audience requests all of them
+	Audience string
+}
+
+// IssueServiceToken authenticates service account and returns its scoped access token.
+//
+// Unknown, disabled and not authenticated accounts get ErrInvalidClient.
+// Tokens are not bound to sessions, they stay valid until they expire.
+func (a *Auth) IssueServiceToken(ctx context.Context, req ClientCredentials) (TokenGrant, error) {
+	const op = "Auth.IssueServiceToken"
+
+	if a.accounts == nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w: service accounts are not enabled", op, ErrInvalidClient)
+	}
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("client_id", req.clientID()),
+	)
+
+	event := models.AuditEvent{Type: models.EventTokenIssue}
+
+	account, err := a.authenticateServiceAccount(ctx, log, req, event)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.AppID = account.AppID
+
+	scopes, err := oauth.ParseScope(req.Scope)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidScope, err)
+	}
+
+	if len(scopes) == 0 {
+		scopes = account.Scopes
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(account.Scopes, scope) {
+			return TokenGrant{}, fmt.Errorf("%s: %w: %q is not allowed for service account", op, ErrInvalidScope, scope)
+		}
+	}
+
+	// empty scope still limits the token, unlike nil
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	app, err := a.appProvider.App(ctx, account.AppID)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	audience, err := grantAudience(req.Audience, app)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims, err := a.registeredClaims(scopes, audience)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := a.clock.Now()
+
+	token, err := jwt.CreateServiceToken(account, app, claims, now, now.Add(a.tokenTTL))
+	if err != nil {
+		log.Error("failed to generate token", sl.Err(err))
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.Success, event.Reason = true, reasonClientCredentials
+	a.auditor.Record(ctx, event)
+
+	log.Info("service token issued")
+
+	return TokenGrant{AccessToken: token, ExpiresIn: a.tokenTTL, Scopes: scopes}, nil
+}
+
+// tokenServiceAccount returns enabled service account token with claims was issued to.
+// Tokens issued before the account credentials were rotated get ErrInvalidToken.
+func (a *Auth) tokenServiceAccount(ctx context.Context, claims map[string]any) (models.ServiceAccount, error) {
+	clientID, _ := claims["client_id"].(string)
+	if clientID == "" || a.accounts == nil {
+		return models.ServiceAccount{}, fmt.Errorf("%w: client_id claim is missing", ErrInvalidToken)
+	}
+
+	account, err := a.accounts.ServiceAccountByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, storage.ErrServiceAccountNotFound) {
+			return models.ServiceAccount{}, fmt.Errorf("%w: service account not found", ErrInvalidToken)
+		}
+
+		return models.ServiceAccount{}, err
+	}
+
+	if account.Disabled {
+		return models.ServiceAccount{}, fmt.Errorf("%w: service account is disabled", ErrInvalidToken)
+	}
+
+	// iat has seconds precision, so does the rotation time it is compared with
+	iat, _ := claims["iat"].(float64)
+	if time.Unix(int64(iat), 0).Before(account.RotatedAt.Truncate(time.Second)) {
+		return models.ServiceAccount{}, fmt.Errorf("%w: service account credentials were rotated", ErrInvalidToken)
+	}
+
+	return account, nil
+}
+
+// authenticateServiceAccount returns enabled service account authenticated by credentials,
+// failures are audited as event of the account app and reported as ErrInvalidClient
+func (a *Auth) authenticateServiceAccount(
+	ctx context.Context, log *slog.Logger, req ClientCredentials, event models.AuditEvent,
+) (models.ServiceAccount, error) {
+	account, err := a.accounts.ServiceAccountByClientID(ctx, req.clientID())
+	if err != nil {
+		if errors.Is(err, storage.ErrServiceAccountNotFound) {
+			log.Warn("service account not found")
+			return models.ServiceAccount{}, ErrInvalidClient
+		}
+
+		return models.ServiceAccount{}, err
+	}
+
+	event.AppID = account.AppID
+
+	if err := a.authenticateClient(ctx, account, req); err != nil {
+		if errors.Is(err, ErrUnavailable) {
+			return models.ServiceAccount{}, err
+		}
+
+		log.Warn("service account not authenticated", sl.Err(err))
+		a.auditFailure(ctx, event, reasonInvalidClient)
+
+		return models.ServiceAccount{}, fmt.Errorf("%w: %w", ErrInvalidClient, err)
+	}
+
+	if account.Disabled {
+		log.Warn("service account is disabled")
+		a.auditFailure(ctx, event, reasonServiceAccountDisabled)
+
+		return models.ServiceAccount{}, fmt.Errorf("%w: service account is disabled", ErrInvalidClient)
+	}
+
+	return account, nil
+}
+
+// clientID returns client id of request, assertion names it when the request does not
+func (req ClientCredentials) clientID() string {
+	if req.ClientID == "" && req.Assertion != "" {
+		clientID, _ := jwt.UnverifiedIssuer(req.Assertion)
+		return clientID
+	}
+
+	return req.ClientID
+}
+
+// authenticateClient checks the only credential presented by service account
+func (a *Auth) authenticateClient(ctx context.Context, account models.ServiceAccount, req ClientCredentials) error {
+	switch {
+	case req.Assertion != "" && req.ClientSecret != "":
+		return errors.New("more than one authentication method is used")
+	case req.Assertion != "":
+		return a.verifyClientAssertion(ctx, account, req.Assertion)
+	case req.ClientSecret != "":
+		if !oauth.VerifyClientSecret(account.SecretHash, req.ClientSecret) {
+			return errors.New("wrong client secret")
+		}
+
+		return nil
+	default:
+		return errors.New("no client credentials")
+	}
+}
+
+// verifyClientAssertion checks assertion as RFC 7523 section 3 requires and remembers
+// its jti, so the same assertion is never accepted twice
+func (a *Auth) verifyClientAssertion(ctx context.Context, account models.ServiceAccount, assertion string) error {
+	if len(account.PublicKey) == 0 {
+		return errors.New("service account has no public key")
+	}
+
+	if a.issuer == "" {
+		return errors.New("issuer is not configured")
+	}
+
+	if a.kv == nil {
+		return fmt.Errorf("%w: no store for client assertions", ErrUnavailable)
+	}
+
+	publicKey, err := keys.ParsePublicKey(account.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	claims, err := jwt.ParseClientAssertion(assertion, publicKey)
+	if err != nil {
+		return err
+	}
+
+	if claims["iss"] != account.ClientID || claims["sub"] != account.ClientID {
+		return errors.New("iss and sub must be the client id")
+	}
+
+	if !hasAudience(claims["aud"], a.issuer, a.issuer+tokenEndpointPath) {
+		return errors.New("assertion is meant for another audience")
+	}
+
+	now := a.clock.Now()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("exp claim is missing")
+	}
+
+	expiresAt := time.Unix(int64(exp), 0)
+	switch {
+	case !expiresAt.After(now):
+		return errors.New("assertion is expired")
+	case expiresAt.After(now.Add(maxAssertionLifetime)):
+		return errors.New("assertion lives too long")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && time.Unix(int64(nbf), 0).After(now) {
+		return errors.New("assertion is not valid yet")
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return errors.New("jti claim is missing")
+	}
+
+	used, err := a.kv.Incr(ctx, "client_assertion:"+account.ClientID+":"+jti, expiresAt.Sub(now))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	if used > 1 {
+		return errors.New("assertion was already used")
+	}
+
+	return nil
+}
+
+// hasAudience reports whether aud claim, a string or a list of them, names any of audiences
+func hasAudience(aud any, audiences ...string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return slices.Contains(audiences, aud)
+	case []any:
+		for _, v := range aud {
+			if s, ok := v.(string); ok && slices.Contains(audiences, s) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,447 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/jwt"
+	"gRPC/internal/lib/random"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/storage"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSessionNotFound  = errors.New("session not found")
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+const (
+	sessionIDLength = 32
+
+	// touchInterval limits how often last seen time of session is written
+	touchInterval = time.Minute
+
+	// operatorDevice is device name of sessions started by operators
+	operatorDevice = "operator"
+)
+
+type SessionStorage interface {
+	SaveSession(ctx context.Context, session models.Session) error
+	Session(ctx context.Context, id string) (models.Session, error)
+	Sessions(ctx context.Context, userID int64) ([]models.Session, error)
+	TouchSession(ctx context.Context, id string, at time.Time) error
+	AuthenticateSession(ctx context.Context, id string, at time.Time, amr []string) error
+	RevokeSession(ctx context.Context, id string, at time.Time) error
+	RevokeSessions(ctx context.Context, userID int64, exceptID string, at time.Time) (int64, error)
+}
+
+// ClientInfo describes device the request came from
+type ClientInfo struct {
+	DeviceName string
+	UserAgent  string
+	IP         string
+}
+
+// Principal is the caller authenticated by access token
+type Principal struct {
+	UserID    int64
+	Email     string
+	AppID     int
+	SessionID string
+	// Scopes are OAuth scopes granted to the token, nil for first-party tokens
+	Scopes []string
+	// ImpersonatorID is the admin acting as the user, zero for sessions of the user itself
+	ImpersonatorID int64
+	// AuthTime, AMR and ACR tell when and how the user last authenticated in the session.
+	// They are kept with the session, not taken from token claims. AuthTime is zero
+	// for sessions started without authentication of the user, e.g. impersonation.
+	AuthTime time.Time
+	AMR      []string
+	ACR      string
+
+	// changeToken is the password change token the caller authenticated with
+	changeToken string
+}
+
+// authentication returns how the caller authenticated
+func (p Principal) authentication() authentication {
+	return authentication{Time: p.AuthTime, Methods: p.AMR}
+}
+
+// startSession saves new session of user, who has just authenticated by authn
+func (a *Auth) startSession(
+	ctx context.Context, user models.User, app models.App, client ClientInfo, authn authentication,
+) (models.Session, error) {
+	session, err := a.newSession(user, app, client)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	if len(authn.Methods) > 0 {
+		session.AuthTime, session.AMR = authn.Time, authn.Methods
+		if session.AuthTime.IsZero() {
+			session.AuthTime = session.CreatedAt
+		}
+	}
+
+	if err := a.sessions.SaveSession(ctx, session); err != nil {
+		return models.Session{}, err
+	}
+
+	return session, nil
+}
+
+// newSession returns session of user which is not saved yet
+func (a *Auth) newSession(user models.User, app models.App, client ClientInfo) (models.Session, error) {
+	id, err := random.String(sessionIDLength)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	now := a.clock.Now()
+
+	session := models.Session{
+		ID:         id,
+		UserID:     user.ID,
+		AppID:      app.ID,
+		DeviceName: client.DeviceName,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(a.tokenTTL),
+	}
+
+	return session, nil
+}
+
+// Authenticate verifies access token and checks that its session is still active.
+//
+// Tokens issued by token exchange share the session of the subject token, they act for
+// the user at other services and get ErrInvalidToken here.
+func (a *Auth) Authenticate(ctx context.Context, token string) (Principal, error) {
+	const op = "Auth.Authenticate"
+
+	claims, err := a.InspectToken(ctx, token)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	appID, _ := claims["app_id"].(float64)
+	email, _ := claims["email"].(string)
+
+	session, err := a.tokenSession(ctx, claims)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if delegated(claims, session) {
+		return Principal{}, fmt.Errorf("%s: %w: token was issued to another party", op, ErrInvalidToken)
+	}
+
+	now := a.clock.Now()
+
+	if now.Sub(session.LastSeenAt) >= touchInterval {
+		if err := a.sessions.TouchSession(ctx, session.ID, now); err != nil {
+			a.log.Warn("failed to touch session", slog.String("op", op), sl.Err(err))
+		}
+	}
+
+	principal := Principal{
+		UserID:         session.UserID,
+		Email:          email,
+		AppID:          int(appID),
+		SessionID:      session.ID,
+		ImpersonatorID: session.ImpersonatorID,
+		AuthTime:       session.AuthTime,
+		AMR:            session.AMR,
+	}
+
+	if scope, ok := claims["scope"].(string); ok {
+		principal.Scopes = strings.Fields(scope)
+	}
+
+	// auth_time, amr and acr claims are for relying parties, tokens are signed with app secret
+	// known to the app itself, so the level is taken from the session
+	if !session.AuthTime.IsZero() {
+		principal.ACR = principal.authentication().acr()
+	}
+
+	return principal, nil
+}
+
+// tokenSession returns session of token claims, it fails with ErrInvalidToken
+// unless the session is still active
+func (a *Auth) tokenSession(ctx context.Context, claims map[string]any) (models.Session, error) {
+	uid, _ := claims["uid"].(float64)
+	sid, _ := claims["sid"].(string)
+
+	if sid == "" {
+		return models.Session{}, fmt.Errorf("%w: sid claim is missing", ErrInvalidToken)
+	}
+
+	session, err := a.sessions.Session(ctx, sid)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return models.Session{}, ErrInvalidToken
+		}
+
+		return models.Session{}, err
+	}
+
+	if session.UserID != int64(uid) || !session.Active(a.clock.Now()) {
+		return models.Session{}, fmt.Errorf("%w: session is not active", ErrInvalidToken)
+	}
+
+	return session, nil
+}
+
+// ListSessions returns active sessions of the caller, the newest first
+func (a *Auth) ListSessions(ctx context.Context, caller Principal) ([]models.Session, error) {
+	const op = "Auth.ListSessions"
+
+	if err := checkFirstParty(caller); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessions, err := a.activeSessions(ctx, caller.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession revokes session of the caller, it may be the current one
+func (a *Auth) RevokeSession(ctx context.Context, caller Principal, sessionID string) error {
+	const op = "Auth.RevokeSession"
+
+	if err := checkFirstParty(caller); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.revokeSession(ctx, caller.UserID, sessionID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.auditor.Record(ctx, models.AuditEvent{
+		Type:    models.EventSessionRevoke,
+		UserID:  caller.UserID,
+		Email:   caller.Email,
+		Success: true,
+		Reason:  "session " + sessionID,
+	})
+
+	a.log.Info("session revoked",
+		slog.String("op", op),
+		slog.Int64("uid", caller.UserID),
+		slog.String("session_id", sessionID),
+	)
+
+	return nil
+}
+
+// RevokeOtherSessions revokes every session of the caller except the current one
+// and returns the number of revoked sessions
+func (a *Auth) RevokeOtherSessions(ctx context.Context, caller Principal) (int64, error) {
+	const op = "Auth.RevokeOtherSessions"
+
+	if err := checkAccountOwner(caller); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := a.sessions.RevokeSessions(ctx, caller.UserID, caller.SessionID, a.clock.Now())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.auditor.Record(ctx, models.AuditEvent{
+		Type:    models.EventSessionRevoke,
+		UserID:  caller.UserID,
+		Email:   caller.Email,
+		Success: true,
+		Reason:  fmt.Sprintf("%d other sessions", n),
+	})
+
+	a.log.Info("other sessions revoked",
+		slog.String("op", op),
+		slog.Int64("uid", caller.UserID),
+		slog.Int64("count", n),
+	)
+
+	return n, nil
+}
+
+// UserSessions returns active sessions of any user, the caller must be admin
+func (a *Auth) UserSessions(ctx context.Context, caller Principal, userID int64) ([]models.Session, error) {
+	const op = "Auth.UserSessions"
+
+	if err := a.RequireAdmin(ctx, caller); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessions, err := a.activeSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeUserSession revokes session of any user, the caller must be admin
+func (a *Auth) RevokeUserSession(ctx context.Context, caller Principal, userID int64, sessionID string) error {
+	const op = "Auth.RevokeUserSession"
+
+	if err := a.RequireAdmin(ctx, caller); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.revokeSession(ctx, userID, sessionID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.auditor.Record(ctx, models.AuditEvent{
+		Type:    models.EventSessionRevoke,
+		UserID:  userID,
+		ActorID: caller.UserID,
+		Success: true,
+		Reason:  "session " + sessionID,
+	})
+
+	a.log.Info("session revoked by admin",
+		slog.String("op", op),
+		slog.Int64("admin_uid", caller.UserID),
+		slog.Int64("uid", userID),
+		slog.String("session_id", sessionID),
+	)
+
+	return nil
+}
+
+// RevokeUserSessions revokes all sessions of any user, the caller must be admin
+func (a *Auth) RevokeUserSessions(ctx context.Context, caller Principal, userID int64) (int64, error) {
+	const op = "Auth.RevokeUserSessions"
+
+	if err := a.RequireAdmin(ctx, caller); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := a.sessions.RevokeSessions(ctx, userID, "", a.clock.Now())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.auditor.Record(ctx, models.AuditEvent{
+		Type:    models.EventSessionRevoke,
+		UserID:  userID,
+		ActorID: caller.UserID,
+		Success: true,
+		Reason:  fmt.Sprintf("%d sessions", n),
+	})
+
+	a.log.Info("sessions revoked by admin",
+		slog.String("op", op),
+		slog.Int64("admin_uid", caller.UserID),
+		slog.Int64("uid", userID),
+		slog.Int64("count", n),
+	)
+
+	return n, nil
+}
+
+func (a *Auth) activeSessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	sessions, err := a.sessions.Sessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := a.clock.Now()
+
+	active := make([]models.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.Active(now) {
+			active = append(active, session)
+		}
+	}
+
+	return active, nil
+}
+
+// revokeSession revokes session if it belongs to user,
+// sessions of other users are reported as not found
+func (a *Auth) revokeSession(ctx context.Context, userID int64, sessionID string) error {
+	session, err := a.sessions.Session(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return ErrSessionNotFound
+		}
+
+		return err
+	}
+
+	if session.UserID != userID {
+		return ErrSessionNotFound
+	}
+
+	return a.sessions.RevokeSession(ctx, sessionID, a.clock.Now())
+}
+
+// RequireAdmin returns ErrPermissionDenied unless the caller is admin using first-party token
+func (a *Auth) RequireAdmin(ctx context.Context, caller Principal) error {
+	if err := checkFirstParty(caller); err != nil {
+		return err
+	}
+
+	isAdmin, err := a.usrProvider.IsAdmin(ctx, caller.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return ErrPermissionDenied
+		}
+
+		return err
+	}
+
+	if !isAdmin {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// delegated reports whether token with claims acts for the user of session on behalf of another party.
+// The only actor allowed is the admin who impersonates the user in session.
+func delegated(claims map[string]any, session models.Session) bool {
+	actor := jwt.ParseActor(claims)
+	if actor == nil {
+		return false
+	}
+
+	return session.ImpersonatorID == 0 ||
+		!slices.Equal(actor.Chain(), []string{strconv.FormatInt(session.ImpersonatorID, 10)})
+}
+
+// checkFirstParty returns ErrPermissionDenied for tokens issued to third-party apps,
+// they are good for what their scopes grant only, not for managing the account
+func checkFirstParty(caller Principal) error {
+	if caller.Scopes != nil {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// checkAccountOwner is checkFirstParty which also refuses impersonation sessions,
+// admins acting as the user may not take the account over or escape the session limit
+func checkAccountOwner(caller Principal) error {
+	if caller.ImpersonatorID != 0 {
+		return ErrPermissionDenied
+	}
+
+	return checkFirstParty(caller)
+}
@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/jwt"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/lib/sms"
+	"gRPC/internal/storage"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrStepUpRequired = errors.New("recent or stronger authentication is required")
+
+const (
+	// Authentication methods of amr claim, as registered by RFC 8176
+	AMRPassword    = "pwd"
+	AMROneTimeCode = "otp"
+	AMRSMS         = "sms"
+	AMRPhoneCall   = "tel"
+	AMRHardwareKey = "hwk"
+	AMRMultiFactor = "mfa"
+
+	// Authentication levels of acr claim, NIST assurance levels. A higher level
+	// meets requirements for the lower one.
+	ACRSingleFactor = "aal1"
+	ACRMultiFactor  = "aal2"
+)
+
+// acrLevels are levels from the lowest one
+var acrLevels = []string{ACRSingleFactor, ACRMultiFactor}
+
+// IsACR reports whether acr is a level tokens are issued with
+func IsACR(acr string) bool {
+	return slices.Contains(acrLevels, acr)
+}
+
+// authentication is how the user proved identity when token is issued
+type authentication struct {
+	// Time is when the user did it, zero stands for now
+	Time time.Time
+	// Methods go to amr claim, tokens without them carry no authentication claims at all
+	Methods []string
+}
+
+// passwordAuthentication is authentication by password alone, at the moment of issuing token
+var passwordAuthentication = authentication{Methods: []string{AMRPassword}}
+
+// claims sets auth_time, amr and acr claims of authentication issued at now
+func (authn authentication) claims(registered *jwt.Claims, now time.Time) {
+	if len(authn.Methods) == 0 {
+		return
+	}
+
+	registered.AuthTime = authn.Time
+	if registered.AuthTime.IsZero() {
+		registered.AuthTime = now
+	}
+
+	registered.AMR, registered.ACR = authn.Methods, authn.acr()
+}
+
+// acr returns level reached by authentication
+func (authn authentication) acr() string {
+	if slices.Contains(authn.Methods, AMRMultiFactor) {
+		return ACRMultiFactor
+	}
+
+	return ACRSingleFactor
+}
+
+// Reauthentication proves identity of the caller again. Password alone gives single-factor
+// level. With Channel set, a code is sent to the verified phone of the caller as well and
+// the call is repeated with ChallengeID and Code to reach multi-factor level.
+type Reauthentication struct {
+	Password    string
+	Channel     string
+	ChallengeID string
+	Code        string
+}
+
+// Reauthenticated is either Token of the same session with raised level,
+// or Challenge waiting for the code sent to phone
+type Reauthenticated struct {
+	Token     string
+	Challenge SMSChallenge
+}
+
+// CheckAuthLevel returns ErrStepUpRequired unless the caller authenticated within maxAge
+// and reached minACR level, either requirement is skipped when it is not set
+func (a *Auth) CheckAuthLevel(caller Principal, maxAge time.Duration, minACR string) error {
+	const op = "Auth.CheckAuthLevel"
+
+	if caller.AuthTime.IsZero() {
+		return fmt.Errorf("%s: %w: session has no authentication time", op, ErrStepUpRequired)
+	}
+
+	if maxAge > 0 && a.clock.Now().Sub(caller.AuthTime) > maxAge {
+		return fmt.Errorf("%s: %w: authenticated too long ago", op, ErrStepUpRequired)
+	}
+
+	if minACR != "" && slices.Index(acrLevels, caller.ACR) < slices.Index(acrLevels, minACR) {
+		return fmt.Errorf("%s: %w: level %q is below %q", op, ErrStepUpRequired, caller.ACR, minACR)
+	}
+
+	return nil
+}
+
+// Reauthenticate checks identity of the caller again, records it with the session and returns
+// new token of the same session with fresh auth_time and level of the methods used, see Reauthentication.
+//
+// Only first-party tokens of the user itself may be raised, others get ErrPermissionDenied.
+func (a *Auth) Reauthenticate(
+	ctx context.Context, caller Principal, req Reauthentication, client ClientInfo,
+) (Reauthenticated, error) {
+	const op = "Auth.Reauthenticate"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("uid", caller.UserID),
+	)
+
+	if caller.Scopes != nil || caller.ImpersonatorID != 0 {
+		return Reauthenticated{}, fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	event := models.AuditEvent{
+		Type:      models.EventReauthenticate,
+		UserID:    caller.UserID,
+		Email:     caller.Email,
+		AppID:     caller.AppID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	var authn authentication
+
+	if req.ChallengeID != "" {
+		if err := a.smsEnabled(); err != nil {
+			return Reauthenticated{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		state, err := a.redeemSMSChallenge(ctx, log, req.ChallengeID, req.Code, caller.SessionID, event)
+		if err != nil {
+			return Reauthenticated{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		authn.Methods = smsMethods(state.Channel)
+	} else {
+		if req.Channel != "" {
+			if err := a.smsEnabled(); err != nil {
+				return Reauthenticated{}, fmt.Errorf("%s: %w", op, err)
+			}
+
+			if req.Channel != sms.ChannelSMS && req.Channel != sms.ChannelVoice {
+				return Reauthenticated{}, fmt.Errorf("%s: %w: %q", op, ErrInvalidChannel, req.Channel)
+			}
+		}
+
+		user, err := a.verifyCredentials(ctx, log, event, req.Password)
+		if err != nil {
+			return Reauthenticated{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if req.Channel != "" {
+			challenge, err := a.startSMSChallenge(ctx, log, user, smsLoginState{
+				AppID:     caller.AppID,
+				Channel:   req.Channel,
+				SessionID: caller.SessionID,
+			}, event)
+			if err != nil {
+				return Reauthenticated{}, fmt.Errorf("%s: %w", op, err)
+			}
+
+			return Reauthenticated{Challenge: challenge}, nil
+		}
+
+		authn = passwordAuthentication
+	}
+
+	token, err := a.reissueToken(ctx, caller, authn)
+	if err != nil {
+		return Reauthenticated{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.Success, event.Reason = true, strings.Join(authn.Methods, " ")
+	a.auditor.Record(ctx, event)
+
+	log.Info("user reauthenticated", slog.String("session_id", caller.SessionID))
+
+	return Reauthenticated{Token: token}, nil
+}
+
+// reissueToken records that the caller has just authenticated by authn in its session
+// and returns new token of the session
+func (a *Auth) reissueToken(ctx context.Context, caller Principal, authn authentication) (string, error) {
+	session, err := a.sessions.Session(ctx, caller.SessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return "", ErrInvalidToken
+		}
+
+		return "", err
+	}
+
+	now := a.clock.Now()
+
+	if session.UserID != caller.UserID || !session.Active(now) {
+		return "", fmt.Errorf("%w: session is not active", ErrInvalidToken)
+	}
+
+	app, err := a.appProvider.App(ctx, session.AppID)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := a.usrProvider.User(ctx, caller.Email)
+	if err != nil {
+		return "", err
+	}
+
+	if user.Disabled {
+		return "", ErrUserDisabled
+	}
+
+	claims, err := a.registeredClaims(nil, app.Audiences)
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.sessions.AuthenticateSession(ctx, session.ID, now, authn.Methods); err != nil {
+		return "", err
+	}
+
+	authn.claims(&claims, now)
+
+	token, err := jwt.CreateSessionToken(user, app, session.ID, claims, now, session.ExpiresAt)
+	if err != nil {
+		a.log.Error("failed to generate token", sl.Err(err))
+		return "", err
+	}
+
+	return token, nil
+}
+
+// smsMethods are amr of password and code sent over channel
+func smsMethods(channel string) []string {
+	if channel == sms.ChannelVoice {
+		return []string{AMRPassword, AMRPhoneCall, AMRMultiFactor}
+	}
+
+	return []string{AMRPassword, AMRSMS, AMRMultiFactor}
+}
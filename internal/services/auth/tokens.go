@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/jwt"
+	"gRPC/internal/lib/oauth"
+	"gRPC/internal/lib/random"
+	"gRPC/internal/storage"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidAudience is returned for audience app may not request
+// and for token which is not meant for the resource server validating it
+var ErrInvalidAudience = errors.New("invalid audience")
+
+// tokenIDLength is the number of random bytes in jti claim
+const tokenIDLength = 16
+
+// LoginRequest is Login asking for token limited to scopes and audiences of app
+type LoginRequest struct {
+	Email    string
+	Password string
+	AppID    int
+	// Scope is a subset of the app scopes, empty scope requests all of them
+	Scope string
+	// Audience is a subset of the app audiences, empty audience requests all of them
+	Audience string
+}
+
+// TokenInfo is access token verified for resource server
+type TokenInfo struct {
+	ID string
+	// UserID and Email are empty for tokens of service accounts, which have ClientID
+	UserID   int64
+	Email    string
+	ClientID string
+	AppID    int
+	// Scopes are nil for first-party tokens
+	Scopes   []string
+	Audience []string
+	// Actors are parties acting on behalf of the user, the current one first
+	Actors []string
+	// ImpersonatorID is the admin impersonating the user, zero for tokens of the user itself
+	ImpersonatorID int64
+	IssuedAt       time.Time
+	ExpiresAt      time.Time
+}
+
+// LoginWithScope is Login returning token limited to requested scopes and audiences
+// of app instead of first-party one. Users with verified phone get *SecondFactorRequiredError.
+func (a *Auth) LoginWithScope(ctx context.Context, req LoginRequest, client ClientInfo) (TokenGrant, error) {
+	const op = "Auth.LoginWithScope"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("username", req.Email),
+	)
+
+	event := models.AuditEvent{
+		Type:      models.EventLogin,
+		Email:     req.Email,
+		AppID:     req.AppID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+
+	app, err := a.appProvider.App(ctx, req.AppID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			a.auditFailure(ctx, event, reasonInvalidApp)
+			return TokenGrant{}, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	scopes, err := a.grantScopes(req.Scope, app)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	audience, err := grantAudience(req.Audience, app)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.verifyCredentials(ctx, log, event, req.Password)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.UserID = user.ID
+
+	// empty scope still limits the token, unlike nil
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	state := smsLoginState{AppID: app.ID, Scopes: scopes, Audience: audience}
+	if err := a.requireSecondFactor(ctx, log, user, state, event); err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := a.issueToken(ctx, log, user, app.ID, client, scopes, audience, passwordAuthentication)
+	if err != nil {
+		return TokenGrant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.Success = true
+	a.auditor.Record(ctx, event)
+
+	log.Info("Successful logging")
+
+	return TokenGrant{AccessToken: token, ExpiresIn: a.tokenTTL, Scopes: scopes}, nil
+}
+
+// ValidateToken verifies token for resource server of audience requiring scopes.
+//
+// Token of ended session or of disabled or rotated service account gets ErrInvalidToken, token meant for another audience gets
+// ErrInvalidAudience and token missing any of scopes gets ErrInsufficientScope.
+// First-party tokens have no scopes, so they satisfy no scope requirement.
+// Empty audience is not checked.
+func (a *Auth) ValidateToken(ctx context.Context, token string, audience string, scopes []string) (TokenInfo, error) {
+	const op = "Auth.ValidateToken"
+
+	claims, err := a.InspectToken(ctx, token)
+	if err != nil {
+		return TokenInfo{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	info := tokenInfo(claims)
+
+	// tokens of service accounts are not bound to sessions, they are bound to the account instead
+	if _, ok := claims["sid"]; ok {
+		session, err := a.tokenSession(ctx, claims)
+		if err != nil {
+			return TokenInfo{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		info.ImpersonatorID = session.ImpersonatorID
+	} else if _, err := a.tokenServiceAccount(ctx, claims); err != nil {
+		return TokenInfo{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if audience != "" && !hasAudience(claims["aud"], audience) {
+		return TokenInfo{}, fmt.Errorf("%s: %w: token is not meant for %q", op, ErrInvalidAudience, audience)
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(info.Scopes, scope) {
+			return TokenInfo{}, fmt.Errorf("%s: %w: %q is required", op, ErrInsufficientScope, scope)
+		}
+	}
+
+	return info, nil
+}
+
+// registeredClaims returns claims of new access token with fresh jti
+func (a *Auth) registeredClaims(scopes []string, audience []string) (jwt.Claims, error) {
+	id, err := random.String(tokenIDLength)
+	if err != nil {
+		return jwt.Claims{}, err
+	}
+
+	return jwt.Claims{Issuer: a.issuer, Audience: audience, Scopes: scopes, ID: id}, nil
+}
+
+// grantAudience returns audiences of audience parameter app may be granted, all of its
+// audiences when the parameter is empty. It fails with ErrInvalidAudience.
+func grantAudience(audience string, app models.App) ([]string, error) {
+	audiences, err := oauth.ParseAudience(audience)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidAudience, err)
+	}
+
+	if len(audiences) == 0 {
+		return app.Audiences, nil
+	}
+
+	for _, audience := range audiences {
+		if !app.AllowsAudience(audience) {
+			return nil, fmt.Errorf("%w: %q is not allowed for app", ErrInvalidAudience, audience)
+		}
+	}
+
+	return audiences, nil
+}
+
+func tokenInfo(claims map[string]any) TokenInfo {
+	uid, _ := claims["uid"].(float64)
+	appID, _ := claims["app_id"].(float64)
+	iat, _ := claims["iat"].(float64)
+	exp, _ := claims["exp"].(float64)
+
+	info := TokenInfo{
+		UserID:    int64(uid),
+		AppID:     int(appID),
+		IssuedAt:  time.Unix(int64(iat), 0),
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}
+	info.ID, _ = claims["jti"].(string)
+	info.Email, _ = claims["email"].(string)
+	info.ClientID, _ = claims["client_id"].(string)
+	info.Actors = jwt.ParseActor(claims).Chain()
+
+	if scope, ok := claims["scope"].(string); ok {
+		info.Scopes = strings.Fields(scope)
+	}
+
+	switch aud := claims["aud"].(type) {
+	case string:
+		info.Audience = []string{aud}
+	case []any:
+		for _, v := range aud {
+			if s, ok := v.(string); ok {
+				info.Audience = append(info.Audience, s)
+			}
+		}
+	}
+
+	return info
+}
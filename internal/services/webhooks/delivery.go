@@ -0,0 +1,188 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/sl"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of delivery requests
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Payload is JSON body of delivery request
+type Payload struct {
+	// ID identifies the event, it is the same for redeliveries
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      PayloadData `json:"data"`
+}
+
+type PayloadData struct {
+	UserID int64  `json:"user_id,omitempty"`
+	Email  string `json:"email,omitempty"`
+	AppID  int    `json:"app_id,omitempty"`
+}
+
+// Sign returns value of signature header: "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">"
+func Sign(secret string, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+
+	return "t=" + timestamp + ",v1=" + signature(secret, timestamp, body)
+}
+
+// VerifySignature checks signature header made by Sign,
+// signatures older than maxAge are rejected to prevent replays
+func VerifySignature(secret string, header string, body []byte, now time.Time, maxAge time.Duration) error {
+	var timestamp, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			timestamp = v
+		case "v1":
+			sig = v
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+
+	if now.Sub(time.Unix(unix, 0)).Abs() > maxAge {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func signature(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// DeliverDue sends deliveries which are due and returns how many were attempted
+func (w *Webhooks) DeliverDue(ctx context.Context) (int, error) {
+	const op = "Webhooks.DeliverDue"
+
+	now := w.clock.Now()
+
+	// claimed deliveries are retried by anyone after the lease, if this instance dies while sending
+	lease := 2 * w.client.Timeout
+	if lease == 0 {
+		lease = 2 * defaultTimeout
+	}
+
+	deliveries, err := w.storage.ClaimWebhookDeliveries(ctx, now, now.Add(lease), claimBatch)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, delivery := range deliveries {
+		webhook, err := w.storage.Webhook(ctx, delivery.WebhookID)
+		if err != nil {
+			// deleted webhook takes its deliveries with it
+			w.log.Warn("failed to get webhook of delivery",
+				slog.String("op", op), slog.Int64("delivery_id", delivery.ID), sl.Err(err))
+			continue
+		}
+
+		delivery = w.attempt(ctx, webhook, delivery)
+
+		if err := w.storage.UpdateWebhookDelivery(ctx, delivery); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// attempt sends delivery once and returns it updated with the outcome
+func (w *Webhooks) attempt(ctx context.Context, webhook models.Webhook, delivery models.WebhookDelivery) models.WebhookDelivery {
+	delivery.Attempts++
+	delivery.ResponseCode = 0
+	delivery.LastError = ""
+
+	code, err := w.send(ctx, webhook, delivery)
+	delivery.ResponseCode = code
+
+	now := w.clock.Now()
+
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliveryDelivered
+		delivery.DeliveredAt = now
+	case delivery.Attempts >= w.maxAttempts:
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(backoff(delivery.Attempts))
+	}
+
+	return delivery
+}
+
+func (w *Webhooks) send(ctx context.Context, webhook models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, w.clock.Now(), delivery.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// the body is not used, reading it lets the connection be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns delay after given number of failed attempts
+func backoff(attempts int) time.Duration {
+	d := minBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+
+	return min(d, maxBackoff)
+}
@@ -0,0 +1,82 @@
+package webhooks
+
+import (
+	"context"
+	"gRPC/internal/lib/sl"
+	"log/slog"
+	"time"
+)
+
+// Dispatcher sends due webhook deliveries in background
+type Dispatcher struct {
+	log      *slog.Logger
+	webhooks *Webhooks
+	interval time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewDispatcher returns Dispatcher polling for due deliveries every interval,
+// new deliveries are sent right away
+func NewDispatcher(log *slog.Logger, webhooks *Webhooks, interval time.Duration) *Dispatcher {
+	return &Dispatcher{
+		log:      log,
+		webhooks: webhooks,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Run sends deliveries until Stop is called
+func (d *Dispatcher) Run() {
+	const op = "webhooks.Dispatcher.Run"
+
+	defer close(d.done)
+
+	log := d.log.With(slog.String("op", op))
+	log.Info("webhook dispatcher is running", slog.Duration("interval", d.interval))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// in-flight requests are abandoned on stop, their deliveries are retried after the lease
+	go func() {
+		<-d.stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.webhooks.kick:
+		}
+
+		// a full batch means more deliveries may be due
+		for {
+			n, err := d.webhooks.DeliverDue(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Error("failed to deliver webhooks", sl.Err(err))
+				}
+				break
+			}
+
+			if n < claimBatch {
+				break
+			}
+		}
+	}
+}
+
+// Stop stops Run and waits for it to return
+func (d *Dispatcher) Stop() {
+	close(d.stop)
+	<-d.done
+}
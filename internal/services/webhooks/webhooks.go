@@ -0,0 +1,409 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/clock"
+	"gRPC/internal/lib/random"
+	"gRPC/internal/lib/sl"
+	"gRPC/internal/storage"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrAppNotFound      = errors.New("app not found")
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrInvalidURL       = errors.New("webhook url must be absolute http or https url")
+	ErrUnknownEvent     = errors.New("unknown webhook event")
+)
+
+// Events are webhook event types apps may subscribe to
+var Events = []string{
+	models.WebhookUserRegistered,
+	models.WebhookUserVerified,
+	models.WebhookUserDeleted,
+	models.WebhookPasswordChanged,
+}
+
+const (
+	// secretSize is the number of random bytes in generated webhook secret
+	secretSize = 32
+
+	defaultMaxAttempts = 8
+	defaultTimeout     = 10 * time.Second
+
+	// claimBatch is how many due deliveries are sent at once
+	claimBatch = 50
+	// first retry waits minBackoff, every next one twice as long up to maxBackoff
+	minBackoff = 30 * time.Second
+	maxBackoff = time.Hour
+)
+
+// Webhooks notifies apps about user events with signed HTTP requests
+type Webhooks struct {
+	log         *slog.Logger
+	storage     Storage
+	client      *http.Client
+	clock       clock.Clock
+	maxAttempts int
+
+	// kick wakes dispatcher up when new deliveries are saved
+	kick chan struct{}
+}
+
+type Storage interface {
+	App(ctx context.Context, appID int) (models.App, error)
+	SaveWebhook(ctx context.Context, webhook models.Webhook) (int64, error)
+	Webhook(ctx context.Context, id int64) (models.Webhook, error)
+	Webhooks(ctx context.Context, appID int) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	SaveWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) (int64, error)
+	WebhookDelivery(ctx context.Context, id int64) (models.WebhookDelivery, error)
+	WebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]models.WebhookDelivery, error)
+	ClaimWebhookDeliveries(ctx context.Context, now time.Time, until time.Time, limit int) ([]models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+}
+
+// Option configures optional dependencies of Webhooks
+type Option func(w *Webhooks)
+
+// WithHTTPClient sets client deliveries are sent with
+func WithHTTPClient(client *http.Client) Option {
+	return func(w *Webhooks) {
+		w.client = client
+	}
+}
+
+// WithMaxAttempts sets how many times delivery is tried before it is marked failed
+func WithMaxAttempts(n int) Option {
+	return func(w *Webhooks) {
+		w.maxAttempts = n
+	}
+}
+
+// New returns new instance of Webhooks service.
+func New(log *slog.Logger, storage Storage, clk clock.Clock, opts ...Option) *Webhooks {
+	w := &Webhooks{
+		log:         log,
+		storage:     storage,
+		client:      &http.Client{Timeout: defaultTimeout},
+		clock:       clk,
+		maxAttempts: defaultMaxAttempts,
+		kick:        make(chan struct{}, 1),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Create registers webhook of app and generates its signing secret
+func (w *Webhooks) Create(ctx context.Context, appID int, rawURL string, events []string) (models.Webhook, error) {
+	const op = "Webhooks.Create"
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, ErrInvalidURL)
+	}
+
+	if len(events) == 0 {
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, ErrUnknownEvent)
+	}
+	for _, event := range events {
+		if !slices.Contains(Events, event) {
+			return models.Webhook{}, fmt.Errorf("%s: %w: %s", op, ErrUnknownEvent, event)
+		}
+	}
+
+	if _, err := w.storage.App(ctx, appID); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.Webhook{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := random.String(secretSize)
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	events = slices.Clone(events)
+	slices.Sort(events)
+
+	webhook := models.Webhook{
+		AppID:     appID,
+		URL:       u.String(),
+		Secret:    secret,
+		Events:    slices.Compact(events),
+		CreatedAt: w.clock.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	webhook.ID, err = w.storage.SaveWebhook(ctx, webhook)
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return webhook, nil
+}
+
+// List returns webhooks of app
+func (w *Webhooks) List(ctx context.Context, appID int) ([]models.Webhook, error) {
+	const op = "Webhooks.List"
+
+	webhooks, err := w.storage.Webhooks(ctx, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return webhooks, nil
+}
+
+// Delete deletes webhook of app with its delivery log
+func (w *Webhooks) Delete(ctx context.Context, appID int, webhookID int64) error {
+	const op = "Webhooks.Delete"
+
+	if _, err := w.appWebhook(ctx, appID, webhookID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := w.storage.DeleteWebhook(ctx, webhookID); err != nil {
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrWebhookNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Deliveries returns up to limit latest deliveries of webhook
+func (w *Webhooks) Deliveries(ctx context.Context, webhookID int64, limit int) ([]models.WebhookDelivery, error) {
+	const op = "Webhooks.Deliveries"
+
+	if _, err := w.webhook(ctx, webhookID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := w.storage.WebhookDeliveries(ctx, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver queues the payload of given delivery once more as a new delivery
+func (w *Webhooks) Redeliver(ctx context.Context, deliveryID int64) (models.WebhookDelivery, error) {
+	const op = "Webhooks.Redeliver"
+
+	delivery, err := w.storage.WebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		if errors.Is(err, storage.ErrDeliveryNotFound) {
+			return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, ErrDeliveryNotFound)
+		}
+
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	redelivery, err := w.enqueue(ctx, delivery.WebhookID, delivery.EventType, delivery.Payload)
+	if err != nil {
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return redelivery, nil
+}
+
+// Test sends ping to webhook right away and returns the outcome,
+// it lets app owners check their receiver and signature verification
+func (w *Webhooks) Test(ctx context.Context, webhookID int64) (models.WebhookDelivery, error) {
+	const op = "Webhooks.Test"
+
+	webhook, err := w.webhook(ctx, webhookID)
+	if err != nil {
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := w.clock.Now()
+
+	body, err := json.Marshal(Payload{
+		ID:        "ping-" + strconv.FormatInt(now.UnixNano(), 10),
+		Type:      models.WebhookPing,
+		CreatedAt: now.UTC(),
+		Data:      PayloadData{AppID: webhook.AppID},
+	})
+	if err != nil {
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	delivery := models.WebhookDelivery{
+		WebhookID: webhookID,
+		EventType: models.WebhookPing,
+		Payload:   body,
+		Status:    models.DeliveryPending,
+		// the dispatcher does not pick it up, the test sends it itself
+		NextAttemptAt: now.Add(maxBackoff),
+		CreatedAt:     now,
+	}
+
+	delivery.ID, err = w.storage.SaveWebhookDelivery(ctx, delivery)
+	if err != nil {
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	delivery = w.attempt(ctx, webhook, delivery)
+
+	// ping is not retried
+	if delivery.Status == models.DeliveryPending {
+		delivery.Status = models.DeliveryFailed
+	}
+
+	if err := w.storage.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return delivery, nil
+}
+
+// Publish queues deliveries of audit event to every webhook subscribed to it.
+//
+// Events of an app, e.g. logins and password changes, go to webhooks of that app only.
+// Users are shared by all apps, so events of no particular app, e.g. registration,
+// go to webhooks of every app.
+//
+// It is called by audit service for every recorded event, most of them are not delivered.
+func (w *Webhooks) Publish(event models.AuditEvent) {
+	const op = "Webhooks.Publish"
+
+	eventType := webhookEvent(event)
+	if eventType == "" {
+		return
+	}
+
+	log := w.log.With(slog.String("op", op), slog.String("event", eventType))
+
+	// the audited action is done, its caller must not wait for slow storage for long
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	// app id 0 selects webhooks of every app
+	webhooks, err := w.storage.Webhooks(ctx, event.AppID)
+	if err != nil {
+		log.Error("failed to get webhooks", sl.Err(err))
+		return
+	}
+
+	body, err := json.Marshal(Payload{
+		ID:        strconv.FormatInt(event.ID, 10),
+		Type:      eventType,
+		CreatedAt: event.CreatedAt,
+		Data: PayloadData{
+			UserID: event.UserID,
+			Email:  event.Email,
+			AppID:  event.AppID,
+		},
+	})
+	if err != nil {
+		log.Error("failed to encode payload", sl.Err(err))
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if !slices.Contains(webhook.Events, eventType) {
+			continue
+		}
+
+		if _, err := w.enqueue(ctx, webhook.ID, eventType, body); err != nil {
+			log.Error("failed to queue webhook delivery", slog.Int64("webhook_id", webhook.ID), sl.Err(err))
+		}
+	}
+}
+
+// webhookEvent maps audit event to webhook event type, empty for events not delivered to webhooks
+func webhookEvent(event models.AuditEvent) string {
+	if !event.Success {
+		return ""
+	}
+
+	switch event.Type {
+	case models.EventRegister, models.EventUserCreate:
+		return models.WebhookUserRegistered
+	case models.EventCodeValidation:
+		return models.WebhookUserVerified
+	case models.EventUserDisable:
+		return models.WebhookUserDeleted
+	case models.EventPasswordChange, models.EventPasswordReset:
+		return models.WebhookPasswordChanged
+	default:
+		return ""
+	}
+}
+
+func (w *Webhooks) enqueue(ctx context.Context, webhookID int64, eventType string, payload []byte) (models.WebhookDelivery, error) {
+	now := w.clock.Now()
+
+	delivery := models.WebhookDelivery{
+		WebhookID:     webhookID,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+
+	id, err := w.storage.SaveWebhookDelivery(ctx, delivery)
+	if err != nil {
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			return models.WebhookDelivery{}, ErrWebhookNotFound
+		}
+
+		return models.WebhookDelivery{}, err
+	}
+	delivery.ID = id
+
+	select {
+	case w.kick <- struct{}{}:
+	default:
+	}
+
+	return delivery, nil
+}
+
+func (w *Webhooks) webhook(ctx context.Context, id int64) (models.Webhook, error) {
+	webhook, err := w.storage.Webhook(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			return models.Webhook{}, ErrWebhookNotFound
+		}
+
+		return models.Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+// appWebhook returns webhook only if it belongs to app
+func (w *Webhooks) appWebhook(ctx context.Context, appID int, id int64) (models.Webhook, error) {
+	webhook, err := w.webhook(ctx, id)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+
+	if webhook.AppID != appID {
+		return models.Webhook{}, ErrWebhookNotFound
+	}
+
+	return webhook, nil
+}
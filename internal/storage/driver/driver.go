@@ -0,0 +1,65 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"gRPC/internal/migrator"
+	"gRPC/internal/storage"
+	"gRPC/internal/storage/memory"
+	"gRPC/internal/storage/postgres"
+	"gRPC/internal/storage/sqlite"
+)
+
+// Names of storage drivers accepted in config
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+	Memory   = "memory"
+)
+
+var (
+	ErrUnknownDriver = errors.New("unknown storage driver")
+	ErrNoMigrations  = errors.New("storage driver does not support migrations")
+)
+
+// Migratable is implemented by storages with SQL schema
+type Migratable interface {
+	Migrator() *migrator.Migrator
+}
+
+// Open returns storage backend selected by driver name
+func Open(driver string, storagePath string) (storage.Storage, error) {
+	const op = "storage.driver.Open"
+
+	var (
+		s   storage.Storage
+		err error
+	)
+
+	switch driver {
+	case Postgres, "":
+		s, err = postgres.New(storagePath)
+	case SQLite:
+		s, err = sqlite.New(storagePath)
+	case Memory:
+		s = memory.New()
+	default:
+		return nil, fmt.Errorf("%s: %w: %q", op, ErrUnknownDriver, driver)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+// Migrator returns migrator of s or ErrNoMigrations
+func Migrator(s storage.Storage) (*migrator.Migrator, error) {
+	m, ok := s.(Migratable)
+	if !ok {
+		return nil, ErrNoMigrations
+	}
+
+	return m.Migrator(), nil
+}
@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+)
+
+// SaveAuditEvent appends event returned by seal to audit log and returns its id
+func (s *Storage) SaveAuditEvent(_ context.Context, seal func(prevHash string) models.AuditEvent) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var prevHash string
+	if n := len(s.auditEvents); n > 0 {
+		prevHash = s.auditEvents[n-1].Hash
+	}
+
+	event := seal(prevHash)
+	event.ID = int64(len(s.auditEvents)) + 1
+	s.auditEvents = append(s.auditEvents, event)
+
+	return event.ID, nil
+}
+
+// AuditEvents returns events matching filter in order they were saved
+func (s *Storage) AuditEvents(_ context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []models.AuditEvent
+	for _, e := range s.auditEvents {
+		if filter.Limit > 0 && len(events) == filter.Limit {
+			break
+		}
+
+		if e.ID <= filter.AfterID ||
+			filter.UserID != 0 && e.UserID != filter.UserID ||
+			filter.Type != "" && e.Type != filter.Type ||
+			!filter.Since.IsZero() && e.CreatedAt.Before(filter.Since) ||
+			!filter.Until.IsZero() && !e.CreatedAt.Before(filter.Until) {
+			continue
+		}
+
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// LastAuditEvent returns the most recently saved event
+func (s *Storage) LastAuditEvent(_ context.Context) (models.AuditEvent, error) {
+	const op = "storage.memory.LastAuditEvent"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.auditEvents) == 0 {
+		return models.AuditEvent{}, fmt.Errorf("%s: %w", op, storage.ErrAuditEventNotFound)
+	}
+
+	return s.auditEvents[len(s.auditEvents)-1], nil
+}
+
+// SaveAuditCheckpoint saves signed checkpoint of audit chain
+func (s *Storage) SaveAuditCheckpoint(_ context.Context, checkpoint models.AuditCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoint.ID = int64(len(s.auditCheckpoints)) + 1
+	s.auditCheckpoints = append(s.auditCheckpoints, checkpoint)
+
+	return nil
+}
+
+// AuditCheckpoints returns all checkpoints in order they were saved
+func (s *Storage) AuditCheckpoints(_ context.Context) ([]models.AuditCheckpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]models.AuditCheckpoint(nil), s.auditCheckpoints...), nil
+}
+
+// TamperAuditEvent replaces saved event with the same id, bypassing the chain.
+// It lets tests check that tampering is detected.
+func (s *Storage) TamperAuditEvent(event models.AuditEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auditEvents[event.ID-1] = event
+}
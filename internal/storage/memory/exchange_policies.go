@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+	"slices"
+	"sort"
+)
+
+// SaveExchangePolicy saves new token exchange policy and returns its id
+func (s *Storage) SaveExchangePolicy(_ context.Context, policy models.ExchangePolicy) (int64, error) {
+	const op = "storage.memory.SaveExchangePolicy"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.apps[policy.AppID]; !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	for _, p := range s.exchangePolicies {
+		if p.AppID == policy.AppID && p.Actor == policy.Actor && p.Audience == policy.Audience {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrExchangePolicyExists)
+		}
+	}
+
+	s.lastExchangePolicyID++
+	policy.ID = s.lastExchangePolicyID
+	s.exchangePolicies[policy.ID] = copyExchangePolicy(policy)
+
+	return policy.ID, nil
+}
+
+// ExchangePolicy returns policy of app letting actor exchange tokens for audience
+func (s *Storage) ExchangePolicy(
+	_ context.Context, appID int, actor string, audience string,
+) (models.ExchangePolicy, error) {
+	const op = "storage.memory.ExchangePolicy"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, policy := range s.exchangePolicies {
+		if policy.AppID == appID && policy.Actor == actor && policy.Audience == audience {
+			return copyExchangePolicy(policy), nil
+		}
+	}
+
+	return models.ExchangePolicy{}, fmt.Errorf("%s: %w", op, storage.ErrExchangePolicyNotFound)
+}
+
+// ExchangePolicies returns token exchange policies of app ordered by id
+func (s *Storage) ExchangePolicies(_ context.Context, appID int) ([]models.ExchangePolicy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var policies []models.ExchangePolicy
+	for _, policy := range s.exchangePolicies {
+		if policy.AppID == appID {
+			policies = append(policies, copyExchangePolicy(policy))
+		}
+	}
+
+	sort.Slice(policies, func(i, j int) bool { return policies[i].ID < policies[j].ID })
+
+	return policies, nil
+}
+
+// DeleteExchangePolicy deletes token exchange policy
+func (s *Storage) DeleteExchangePolicy(_ context.Context, id int64) error {
+	const op = "storage.memory.DeleteExchangePolicy"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.exchangePolicies[id]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrExchangePolicyNotFound)
+	}
+
+	delete(s.exchangePolicies, id)
+
+	return nil
+}
+
+func copyExchangePolicy(policy models.ExchangePolicy) models.ExchangePolicy {
+	policy.Scopes = nilIfEmpty(slices.Clone(policy.Scopes))
+
+	return policy
+}
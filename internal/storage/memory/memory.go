@@ -0,0 +1,444 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+type user struct {
+	models.User
+	code string
+	// history is oldest first
+	history []models.PasswordHistoryEntry
+}
+
+// Storage keeps everything in process memory, it is meant for tests and local runs
+type Storage struct {
+	mu sync.RWMutex
+
+	users       map[int64]*user
+	userByEmail map[string]int64
+	lastUserID  int64
+
+	apps      map[int]models.App
+	lastAppID int
+
+	signingKeys []models.SigningKey
+
+	sessions map[string]models.Session
+
+	auditEvents      []models.AuditEvent
+	auditCheckpoints []models.AuditCheckpoint
+
+	lastPasswordID int64
+
+	webhooks       map[int64]models.Webhook
+	lastWebhookID  int64
+	deliveries     map[int64]models.WebhookDelivery
+	lastDeliveryID int64
+
+	serviceAccounts      map[int64]models.ServiceAccount
+	lastServiceAccountID int64
+
+	exchangePolicies     map[int64]models.ExchangePolicy
+	lastExchangePolicyID int64
+
+	passkeys      map[int64]models.Passkey
+	lastPasskeyID int64
+}
+
+var _ storage.Storage = (*Storage)(nil)
+
+func New() *Storage {
+	return &Storage{
+		users:       make(map[int64]*user),
+		userByEmail: make(map[string]int64),
+		apps:        make(map[int]models.App),
+		sessions:    make(map[string]models.Session),
+		webhooks:    make(map[int64]models.Webhook),
+		deliveries:  make(map[int64]models.WebhookDelivery),
+
+		serviceAccounts:  make(map[int64]models.ServiceAccount),
+		exchangePolicies: make(map[int64]models.ExchangePolicy),
+		passkeys:         make(map[int64]models.Passkey),
+	}
+}
+
+// SaveUser saves user to memory
+func (s *Storage) SaveUser(_ context.Context, email string, passHash []byte, hashCode []byte) (int64, error) {
+	const op = "storage.memory.SaveUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.userByEmail[email]; ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+	}
+
+	s.lastUserID++
+	s.users[s.lastUserID] = &user{
+		User: models.User{
+			ID:        s.lastUserID,
+			Email:     email,
+			PassHash:  clone(passHash),
+			CreatedAt: time.Now().UTC(),
+		},
+		code: string(hashCode),
+	}
+	s.userByEmail[email] = s.lastUserID
+
+	return s.lastUserID, nil
+}
+
+// User returns user by email
+func (s *Storage) User(_ context.Context, email string) (models.User, error) {
+	const op = "storage.memory.User"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.byEmail(email)
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return copyUser(u.User), nil
+}
+
+// UserByID returns user by id
+func (s *Storage) UserByID(_ context.Context, id int64) (models.User, error) {
+	const op = "storage.memory.UserByID"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return copyUser(u.User), nil
+}
+
+// Users returns page of users ordered by id
+func (s *Storage) Users(_ context.Context, limit int, offset int) ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]int64, 0, len(s.users))
+	for id := range s.users {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var users []models.User
+	for i := offset; i < len(ids) && len(users) < limit; i++ {
+		users = append(users, copyUser(s.users[ids[i]].User))
+	}
+
+	return users, nil
+}
+
+// IsAdmin returns admin status by user id
+func (s *Storage) IsAdmin(_ context.Context, userID int64) (bool, error) {
+	const op = "storage.memory.IsAdmin"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return u.IsAdmin, nil
+}
+
+// SetAdmin grants or revokes admin status
+func (s *Storage) SetAdmin(_ context.Context, userID int64, isAdmin bool) error {
+	const op = "storage.memory.SetAdmin"
+
+	return s.updateUser(op, userID, func(u *user) { u.IsAdmin = isAdmin })
+}
+
+// DisableUser forbids user to log in
+func (s *Storage) DisableUser(_ context.Context, userID int64) error {
+	const op = "storage.memory.DisableUser"
+
+	return s.updateUser(op, userID, func(u *user) { u.Disabled = true })
+}
+
+// UpdatePassword replaces password hash of user
+func (s *Storage) UpdatePassword(_ context.Context, userID int64, passHash []byte) error {
+	const op = "storage.memory.UpdatePassword"
+
+	return s.updateUser(op, userID, func(u *user) { u.PassHash = clone(passHash) })
+}
+
+// UpdatePhone sets phone number of user and whether it is verified
+func (s *Storage) UpdatePhone(_ context.Context, userID int64, phone string, verified bool) error {
+	const op = "storage.memory.UpdatePhone"
+
+	return s.updateUser(op, userID, func(u *user) { u.Phone, u.PhoneVerified = phone, verified })
+}
+
+// ValidateCode returns confirmation code by email
+func (s *Storage) ValidateCode(_ context.Context, email string) (string, error) {
+	const op = "storage.memory.ValidateCode"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.byEmail(email)
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return u.code, nil
+}
+
+// AcceptCode marks user email as verified
+func (s *Storage) AcceptCode(_ context.Context, email string) error {
+	const op = "storage.memory.AcceptCode"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.byEmail(email)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	u.Verified = true
+
+	return nil
+}
+
+// App returns app by id
+func (s *Storage) App(_ context.Context, appID int) (models.App, error) {
+	const op = "storage.memory.App"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	app, ok := s.apps[appID]
+	if !ok {
+		return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	return copyApp(app), nil
+}
+
+// Apps returns all apps ordered by id
+func (s *Storage) Apps(_ context.Context) ([]models.App, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	apps := make([]models.App, 0, len(s.apps))
+	for _, app := range s.apps {
+		apps = append(apps, copyApp(app))
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].ID < apps[j].ID })
+
+	return apps, nil
+}
+
+// SaveApp saves new app and returns its id
+func (s *Storage) SaveApp(_ context.Context, name string, secret string) (int, error) {
+	const op = "storage.memory.SaveApp"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, app := range s.apps {
+		if app.Name == name || app.Secret == secret {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+	}
+
+	s.lastAppID++
+	s.apps[s.lastAppID] = models.App{ID: s.lastAppID, Name: name, Secret: secret}
+
+	return s.lastAppID, nil
+}
+
+// UpdateAppSecret replaces secret used to sign app tokens
+func (s *Storage) UpdateAppSecret(_ context.Context, appID int, secret string) error {
+	const op = "storage.memory.UpdateAppSecret"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, ok := s.apps[appID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	app.Secret = secret
+	s.apps[appID] = app
+
+	return nil
+}
+
+// UpdateAppOAuth replaces redirect URIs and scopes of app as OAuth client
+func (s *Storage) UpdateAppOAuth(_ context.Context, appID int, redirectURIs []string, scopes []string) error {
+	const op = "storage.memory.UpdateAppOAuth"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, ok := s.apps[appID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	app.RedirectURIs, app.Scopes = nilIfEmpty(redirectURIs), nilIfEmpty(scopes)
+	s.apps[appID] = copyApp(app)
+
+	return nil
+}
+
+// UpdateAppAudiences replaces resource servers tokens of app may be issued for
+func (s *Storage) UpdateAppAudiences(_ context.Context, appID int, audiences []string) error {
+	const op = "storage.memory.UpdateAppAudiences"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, ok := s.apps[appID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	app.Audiences = nilIfEmpty(audiences)
+	s.apps[appID] = copyApp(app)
+
+	return nil
+}
+
+// UpdateAppClientSecret replaces digest of OAuth client secret of app, nil makes app public client
+func (s *Storage) UpdateAppClientSecret(_ context.Context, appID int, secretHash []byte) error {
+	const op = "storage.memory.UpdateAppClientSecret"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, ok := s.apps[appID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	app.ClientSecretHash = slices.Clone(secretHash)
+	s.apps[appID] = app
+
+	return nil
+}
+
+// UpdateAppPasswordless enables or disables passwordless login to app
+func (s *Storage) UpdateAppPasswordless(_ context.Context, appID int, enabled bool) error {
+	const op = "storage.memory.UpdateAppPasswordless"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, ok := s.apps[appID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	app.Passwordless = enabled
+	s.apps[appID] = app
+
+	return nil
+}
+
+// SaveSigningKey saves new signing key, which becomes the active one
+func (s *Storage) SaveSigningKey(_ context.Context, key models.SigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key.PrivateKey = clone(key.PrivateKey)
+	s.signingKeys = append(s.signingKeys, key)
+
+	return nil
+}
+
+// SigningKeys returns all signing keys, the newest first
+func (s *Storage) SigningKeys(_ context.Context) ([]models.SigningKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	signingKeys := make([]models.SigningKey, 0, len(s.signingKeys))
+	for i := len(s.signingKeys) - 1; i >= 0; i-- {
+		signingKeys = append(signingKeys, s.signingKeys[i])
+	}
+	sort.SliceStable(signingKeys, func(i, j int) bool {
+		return signingKeys[i].CreatedAt.After(signingKeys[j].CreatedAt)
+	})
+
+	return signingKeys, nil
+}
+
+func (s *Storage) Stop() error {
+	return nil
+}
+
+func (s *Storage) byEmail(email string) (*user, bool) {
+	id, ok := s.userByEmail[email]
+	if !ok {
+		return nil, false
+	}
+
+	return s.users[id], true
+}
+
+func (s *Storage) updateUser(op string, userID int64, update func(u *user)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	update(u)
+
+	return nil
+}
+
+func copyUser(u models.User) models.User {
+	u.PassHash = clone(u.PassHash)
+
+	return u
+}
+
+func copyApp(app models.App) models.App {
+	app.RedirectURIs = slices.Clone(app.RedirectURIs)
+	app.Scopes = slices.Clone(app.Scopes)
+	app.Audiences = slices.Clone(app.Audiences)
+
+	return app
+}
+
+// nilIfEmpty keeps apps without OAuth settings equal to the ones read back by sql backends
+func nilIfEmpty(list []string) []string {
+	if len(list) == 0 {
+		return nil
+	}
+
+	return list
+}
+
+func clone(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+
+	return append([]byte(nil), b...)
+}
@@ -0,0 +1,14 @@
+package memory_test
+
+import (
+	"gRPC/internal/storage"
+	"gRPC/internal/storage/memory"
+	"gRPC/internal/storage/storagetest"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return memory.New()
+	})
+}
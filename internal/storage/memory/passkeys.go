@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+	"sort"
+	"time"
+)
+
+// SavePasskey saves new passkey and returns its id
+func (s *Storage) SavePasskey(_ context.Context, passkey models.Passkey) (int64, error) {
+	const op = "storage.memory.SavePasskey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[passkey.UserID]; !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	for _, p := range s.passkeys {
+		if bytes.Equal(p.CredentialID, passkey.CredentialID) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrPasskeyExists)
+		}
+	}
+
+	s.lastPasskeyID++
+	passkey.ID = s.lastPasskeyID
+	s.passkeys[passkey.ID] = copyPasskey(passkey)
+
+	return passkey.ID, nil
+}
+
+// Passkey returns passkey by credential id of its authenticator
+func (s *Storage) Passkey(_ context.Context, credentialID []byte) (models.Passkey, error) {
+	const op = "storage.memory.Passkey"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, passkey := range s.passkeys {
+		if bytes.Equal(passkey.CredentialID, credentialID) {
+			return copyPasskey(passkey), nil
+		}
+	}
+
+	return models.Passkey{}, fmt.Errorf("%s: %w", op, storage.ErrPasskeyNotFound)
+}
+
+// Passkeys returns passkeys of user ordered by id
+func (s *Storage) Passkeys(_ context.Context, userID int64) ([]models.Passkey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var passkeys []models.Passkey
+	for _, passkey := range s.passkeys {
+		if passkey.UserID == userID {
+			passkeys = append(passkeys, copyPasskey(passkey))
+		}
+	}
+
+	sort.Slice(passkeys, func(i, j int) bool { return passkeys[i].ID < passkeys[j].ID })
+
+	return passkeys, nil
+}
+
+// UpdatePasskeyUsage records signature counter of the latest login with passkey, only when
+// it grows or both are zero, so concurrent logins cannot reuse a counter. Otherwise
+// ErrPasskeyNotFound is returned, as for unknown passkey.
+func (s *Storage) UpdatePasskeyUsage(_ context.Context, id int64, signCount uint32, usedAt time.Time) error {
+	const op = "storage.memory.UpdatePasskeyUsage"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	passkey, ok := s.passkeys[id]
+	if !ok || (passkey.SignCount >= signCount && (signCount != 0 || passkey.SignCount != 0)) {
+		return fmt.Errorf("%s: %w", op, storage.ErrPasskeyNotFound)
+	}
+
+	passkey.SignCount, passkey.LastUsedAt = signCount, usedAt
+	s.passkeys[id] = passkey
+
+	return nil
+}
+
+// DeletePasskey deletes passkey
+func (s *Storage) DeletePasskey(_ context.Context, id int64) error {
+	const op = "storage.memory.DeletePasskey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.passkeys[id]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrPasskeyNotFound)
+	}
+
+	delete(s.passkeys, id)
+
+	return nil
+}
+
+func copyPasskey(passkey models.Passkey) models.Passkey {
+	passkey.CredentialID = clone(passkey.CredentialID)
+	passkey.PublicKey = clone(passkey.PublicKey)
+
+	return passkey
+}
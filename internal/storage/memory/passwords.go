@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+)
+
+// SavePasswordHistory appends password to history of its user
+func (s *Storage) SavePasswordHistory(_ context.Context, entry models.PasswordHistoryEntry) error {
+	const op = "storage.memory.SavePasswordHistory"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[entry.UserID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	s.lastPasswordID++
+	entry.ID = s.lastPasswordID
+	entry.PassHash = clone(entry.PassHash)
+	u.history = append(u.history, entry)
+
+	return nil
+}
+
+// PasswordHistory returns up to limit latest passwords of user, newest first
+func (s *Storage) PasswordHistory(_ context.Context, userID int64, limit int) ([]models.PasswordHistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return nil, nil
+	}
+
+	var history []models.PasswordHistoryEntry
+	for i := len(u.history) - 1; i >= 0 && len(history) < limit; i-- {
+		entry := u.history[i]
+		entry.PassHash = clone(entry.PassHash)
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
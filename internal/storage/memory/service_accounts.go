@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+	"slices"
+	"sort"
+	"time"
+)
+
+// SaveServiceAccount saves new service account and returns its id
+func (s *Storage) SaveServiceAccount(_ context.Context, account models.ServiceAccount) (int64, error) {
+	const op = "storage.memory.SaveServiceAccount"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.apps[account.AppID]; !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	for _, a := range s.serviceAccounts {
+		if a.ClientID == account.ClientID || (a.AppID == account.AppID && a.Name == account.Name) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrServiceAccountExists)
+		}
+	}
+
+	s.lastServiceAccountID++
+	account.ID = s.lastServiceAccountID
+	s.serviceAccounts[account.ID] = copyServiceAccount(account)
+
+	return account.ID, nil
+}
+
+// ServiceAccount returns service account by id
+func (s *Storage) ServiceAccount(_ context.Context, id int64) (models.ServiceAccount, error) {
+	const op = "storage.memory.ServiceAccount"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	account, ok := s.serviceAccounts[id]
+	if !ok {
+		return models.ServiceAccount{}, fmt.Errorf("%s: %w", op, storage.ErrServiceAccountNotFound)
+	}
+
+	return copyServiceAccount(account), nil
+}
+
+// ServiceAccountByClientID returns service account by its client id
+func (s *Storage) ServiceAccountByClientID(_ context.Context, clientID string) (models.ServiceAccount, error) {
+	const op = "storage.memory.ServiceAccountByClientID"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, account := range s.serviceAccounts {
+		if account.ClientID == clientID {
+			return copyServiceAccount(account), nil
+		}
+	}
+
+	return models.ServiceAccount{}, fmt.Errorf("%s: %w", op, storage.ErrServiceAccountNotFound)
+}
+
+// ServiceAccounts returns service accounts of app ordered by id
+func (s *Storage) ServiceAccounts(_ context.Context, appID int) ([]models.ServiceAccount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var accounts []models.ServiceAccount
+	for _, account := range s.serviceAccounts {
+		if account.AppID == appID {
+			accounts = append(accounts, copyServiceAccount(account))
+		}
+	}
+
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+
+	return accounts, nil
+}
+
+// UpdateServiceAccountCredentials replaces secret hash and public key of service account rotated at rotatedAt
+func (s *Storage) UpdateServiceAccountCredentials(
+	_ context.Context, id int64, secretHash []byte, publicKey []byte, rotatedAt time.Time,
+) error {
+	const op = "storage.memory.UpdateServiceAccountCredentials"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.serviceAccounts[id]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrServiceAccountNotFound)
+	}
+
+	account.SecretHash, account.PublicKey = clone(secretHash), clone(publicKey)
+	account.RotatedAt = rotatedAt
+	s.serviceAccounts[id] = account
+
+	return nil
+}
+
+// DisableServiceAccount marks service account disabled
+func (s *Storage) DisableServiceAccount(_ context.Context, id int64) error {
+	const op = "storage.memory.DisableServiceAccount"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.serviceAccounts[id]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrServiceAccountNotFound)
+	}
+
+	account.Disabled = true
+	s.serviceAccounts[id] = account
+
+	return nil
+}
+
+func copyServiceAccount(account models.ServiceAccount) models.ServiceAccount {
+	account.SecretHash = clone(account.SecretHash)
+	account.PublicKey = clone(account.PublicKey)
+	account.Scopes = nilIfEmpty(slices.Clone(account.Scopes))
+
+	return account
+}
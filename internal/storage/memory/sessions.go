@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+	"sort"
+	"time"
+)
+
+// SaveSession saves new session
+func (s *Storage) SaveSession(_ context.Context, session models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.ID] = session
+
+	return nil
+}
+
+// Session returns session by id, revoked sessions are returned as well
+func (s *Storage) Session(_ context.Context, id string) (models.Session, error) {
+	const op = "storage.memory.Session"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+
+	return session, nil
+}
+
+// Sessions returns not revoked sessions of user, the newest first
+func (s *Storage) Sessions(_ context.Context, userID int64) ([]models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sessions []models.Session
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt.IsZero() {
+			sessions = append(sessions, session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+
+	return sessions, nil
+}
+
+// TouchSession updates last seen time of session
+func (s *Storage) TouchSession(_ context.Context, id string, at time.Time) error {
+	return s.updateSession("storage.memory.TouchSession", id, func(session *models.Session) {
+		session.LastSeenAt = at
+	})
+}
+
+// AuthenticateSession records that the user proved identity in session at by amr methods
+func (s *Storage) AuthenticateSession(_ context.Context, id string, at time.Time, amr []string) error {
+	return s.updateSession("storage.memory.AuthenticateSession", id, func(session *models.Session) {
+		session.AuthTime, session.AMR = at, amr
+	})
+}
+
+// RevokeSession revokes session, revoking it again keeps the first revocation time
+func (s *Storage) RevokeSession(_ context.Context, id string, at time.Time) error {
+	return s.updateSession("storage.memory.RevokeSession", id, func(session *models.Session) {
+		if session.RevokedAt.IsZero() {
+			session.RevokedAt = at
+		}
+	})
+}
+
+// RevokeSessions revokes all sessions of user except the one with exceptID
+// and returns the number of revoked sessions
+func (s *Storage) RevokeSessions(_ context.Context, userID int64, exceptID string, at time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for id, session := range s.sessions {
+		if session.UserID != userID || id == exceptID || !session.RevokedAt.IsZero() {
+			continue
+		}
+
+		session.RevokedAt = at
+		s.sessions[id] = session
+		n++
+	}
+
+	return n, nil
+}
+
+func (s *Storage) updateSession(op string, id string, update func(session *models.Session)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+
+	update(&session)
+	s.sessions[id] = session
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+	"slices"
+	"sort"
+	"time"
+)
+
+// SaveWebhook saves new webhook and returns its id
+func (s *Storage) SaveWebhook(_ context.Context, webhook models.Webhook) (int64, error) {
+	const op = "storage.memory.SaveWebhook"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.apps[webhook.AppID]; !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	s.lastWebhookID++
+	webhook.ID = s.lastWebhookID
+	webhook.Events = slices.Clone(webhook.Events)
+	s.webhooks[webhook.ID] = webhook
+
+	return webhook.ID, nil
+}
+
+// Webhook returns webhook by id
+func (s *Storage) Webhook(_ context.Context, id int64) (models.Webhook, error) {
+	const op = "storage.memory.Webhook"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	webhook, ok := s.webhooks[id]
+	if !ok {
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, storage.ErrWebhookNotFound)
+	}
+
+	return webhook, nil
+}
+
+// Webhooks returns webhooks of app ordered by id, zero appID returns webhooks of all apps
+func (s *Storage) Webhooks(_ context.Context, appID int) ([]models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var webhooks []models.Webhook
+	for _, webhook := range s.webhooks {
+		if appID == 0 || webhook.AppID == appID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes webhook together with its deliveries
+func (s *Storage) DeleteWebhook(_ context.Context, id int64) error {
+	const op = "storage.memory.DeleteWebhook"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.webhooks[id]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebhookNotFound)
+	}
+
+	delete(s.webhooks, id)
+	for deliveryID, d := range s.deliveries {
+		if d.WebhookID == id {
+			delete(s.deliveries, deliveryID)
+		}
+	}
+
+	return nil
+}
+
+// SaveWebhookDelivery saves new delivery and returns its id
+func (s *Storage) SaveWebhookDelivery(_ context.Context, delivery models.WebhookDelivery) (int64, error) {
+	const op = "storage.memory.SaveWebhookDelivery"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.webhooks[delivery.WebhookID]; !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrWebhookNotFound)
+	}
+
+	s.lastDeliveryID++
+	delivery.ID = s.lastDeliveryID
+	s.deliveries[delivery.ID] = delivery
+
+	return delivery.ID, nil
+}
+
+// WebhookDelivery returns delivery by id
+func (s *Storage) WebhookDelivery(_ context.Context, id int64) (models.WebhookDelivery, error) {
+	const op = "storage.memory.WebhookDelivery"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	delivery, ok := s.deliveries[id]
+	if !ok {
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, storage.ErrDeliveryNotFound)
+	}
+
+	return delivery, nil
+}
+
+// WebhookDeliveries returns up to limit deliveries of webhook, the newest first
+func (s *Storage) WebhookDeliveries(_ context.Context, webhookID int64, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var deliveries []models.WebhookDelivery
+	for _, d := range s.deliveries {
+		if d.WebhookID == webhookID {
+			deliveries = append(deliveries, d)
+		}
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+
+	return deliveries[:min(limit, len(deliveries))], nil
+}
+
+// ClaimWebhookDeliveries returns pending deliveries due at now and postpones them until
+func (s *Storage) ClaimWebhookDeliveries(_ context.Context, now time.Time, until time.Time, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []models.WebhookDelivery
+	for _, d := range s.deliveries {
+		if d.Status == models.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+
+		return due[i].ID < due[j].ID
+	})
+
+	due = due[:min(limit, len(due))]
+	for i := range due {
+		due[i].NextAttemptAt = until
+		s.deliveries[due[i].ID] = due[i]
+	}
+
+	return due, nil
+}
+
+// UpdateWebhookDelivery saves the outcome of delivery attempt
+func (s *Storage) UpdateWebhookDelivery(_ context.Context, delivery models.WebhookDelivery) error {
+	const op = "storage.memory.UpdateWebhookDelivery"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved, ok := s.deliveries[delivery.ID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrDeliveryNotFound)
+	}
+
+	saved.Status = delivery.Status
+	saved.Attempts = delivery.Attempts
+	saved.NextAttemptAt = delivery.NextAttemptAt
+	saved.ResponseCode = delivery.ResponseCode
+	saved.LastError = delivery.LastError
+	saved.DeliveredAt = delivery.DeliveredAt
+	s.deliveries[delivery.ID] = saved
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+	"github.com/lib/pq"
+	"strings"
+)
+
+// exchangePolicyColumns are read by scanExchangePolicy, scopes are stored space separated
+const exchangePolicyColumns = "id, app_id, actor, audience, scopes, created_at"
+
+// SaveExchangePolicy saves new token exchange policy and returns its id
+func (s *Storage) SaveExchangePolicy(ctx context.Context, policy models.ExchangePolicy) (int64, error) {
+	const op = "storage.postgres.SaveExchangePolicy"
+
+	var id int64
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO exchange_policies(app_id, actor, audience, scopes, created_at)
+		VALUES($1, $2, $3, $4, $5) RETURNING id`,
+		policy.AppID, policy.Actor, policy.Audience, strings.Join(policy.Scopes, " "), policy.CreatedAt.UTC()).Scan(&id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrExchangePolicyExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// ExchangePolicy returns policy of app letting actor exchange tokens for audience
+func (s *Storage) ExchangePolicy(
+	ctx context.Context, appID int, actor string, audience string,
+) (models.ExchangePolicy, error) {
+	const op = "storage.postgres.ExchangePolicy"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+exchangePolicyColumns+
+		" FROM exchange_policies WHERE app_id = $1 AND actor = $2 AND audience = $3", appID, actor, audience)
+
+	policy, err := scanExchangePolicy(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ExchangePolicy{}, fmt.Errorf("%s: %w", op, storage.ErrExchangePolicyNotFound)
+		}
+
+		return models.ExchangePolicy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return policy, nil
+}
+
+// ExchangePolicies returns token exchange policies of app ordered by id
+func (s *Storage) ExchangePolicies(ctx context.Context, appID int) ([]models.ExchangePolicy, error) {
+	const op = "storage.postgres.ExchangePolicies"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+exchangePolicyColumns+" FROM exchange_policies WHERE app_id = $1 ORDER BY id", appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var policies []models.ExchangePolicy
+	for rows.Next() {
+		policy, err := scanExchangePolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		policies = append(policies, policy)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return policies, nil
+}
+
+// DeleteExchangePolicy deletes token exchange policy
+func (s *Storage) DeleteExchangePolicy(ctx context.Context, id int64) error {
+	const op = "storage.postgres.DeleteExchangePolicy"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM exchange_policies WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrExchangePolicyNotFound)
+}
+
+func scanExchangePolicy(row scanner) (models.ExchangePolicy, error) {
+	var policy models.ExchangePolicy
+	var scopes string
+
+	err := row.Scan(&policy.ID, &policy.AppID, &policy.Actor, &policy.Audience, &scopes, &policy.CreatedAt)
+	if err != nil {
+		return models.ExchangePolicy{}, err
+	}
+
+	policy.Scopes = splitList(scopes)
+
+	return policy, nil
+}
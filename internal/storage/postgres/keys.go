@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"gRPC/internal/domain/models"
+)
+
+// SaveSigningKey saves new signing key, which becomes the active one
+func (s *Storage) SaveSigningKey(ctx context.Context, key models.SigningKey) error {
+	const op = "storage.postgres.SaveSigningKey"
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO signing_keys(kid, private_key, created_at) VALUES($1, $2, $3)",
+		key.ID, string(key.PrivateKey), key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SigningKeys returns all signing keys, the newest first
+func (s *Storage) SigningKeys(ctx context.Context) ([]models.SigningKey, error) {
+	const op = "storage.postgres.SigningKeys"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT kid, private_key, created_at FROM signing_keys ORDER BY created_at DESC, id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var keys []models.SigningKey
+	for rows.Next() {
+		var (
+			key        models.SigningKey
+			privateKey string
+		)
+		if err := rows.Scan(&key.ID, &privateKey, &key.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		key.PrivateKey = []byte(privateKey)
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"gRPC/internal/migrator"
+	"gRPC/migrations"
+)
+
+// advisoryLockID is a key of postgres advisory lock held while migrating,
+// so replicas started at the same time do not apply migrations twice
+const advisoryLockID int64 = 0x73736f5f6d6967
+
+const versionSchema = `CREATE TABLE IF NOT EXISTS goose_db_version (
+    id SERIAL PRIMARY KEY,
+    version_id BIGINT NOT NULL,
+    is_applied BOOLEAN NOT NULL,
+    tstamp TIMESTAMP DEFAULT NOW()
+)`
+
+type advisoryLocker struct{}
+
+func (advisoryLocker) Lock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID)
+	return err
+}
+
+func (advisoryLocker) Unlock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", advisoryLockID)
+	return err
+}
+
+// Migrator returns migrator of embedded migrations for this storage
+func (s *Storage) Migrator() *migrator.Migrator {
+	return migrator.New(s.db, migrations.FS, advisoryLocker{}, versionSchema)
+}
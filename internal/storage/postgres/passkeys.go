@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+	"github.com/lib/pq"
+	"time"
+)
+
+const passkeyColumns = "id, user_id, credential_id, public_key, sign_count, name, created_at, last_used_at"
+
+// SavePasskey saves new passkey and returns its id
+func (s *Storage) SavePasskey(ctx context.Context, passkey models.Passkey) (int64, error) {
+	const op = "storage.postgres.SavePasskey"
+
+	var id int64
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO passkeys(user_id, credential_id, public_key, sign_count, name, created_at)
+		VALUES($1, $2, $3, $4, $5, $6) RETURNING id`,
+		passkey.UserID, passkey.CredentialID, passkey.PublicKey, int64(passkey.SignCount), passkey.Name,
+		passkey.CreatedAt.UTC()).Scan(&id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrPasskeyExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Passkey returns passkey by credential id of its authenticator
+func (s *Storage) Passkey(ctx context.Context, credentialID []byte) (models.Passkey, error) {
+	const op = "storage.postgres.Passkey"
+
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+passkeyColumns+" FROM passkeys WHERE credential_id = $1", credentialID)
+
+	passkey, err := scanPasskey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Passkey{}, fmt.Errorf("%s: %w", op, storage.ErrPasskeyNotFound)
+		}
+
+		return models.Passkey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return passkey, nil
+}
+
+// Passkeys returns passkeys of user ordered by id
+func (s *Storage) Passkeys(ctx context.Context, userID int64) ([]models.Passkey, error) {
+	const op = "storage.postgres.Passkeys"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+passkeyColumns+" FROM passkeys WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var passkeys []models.Passkey
+	for rows.Next() {
+		passkey, err := scanPasskey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		passkeys = append(passkeys, passkey)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return passkeys, nil
+}
+
+// UpdatePasskeyUsage records signature counter of the latest login with passkey, only when
+// it grows or both are zero, so concurrent logins cannot reuse a counter. Otherwise
+// ErrPasskeyNotFound is returned, as for unknown passkey.
+func (s *Storage) UpdatePasskeyUsage(ctx context.Context, id int64, signCount uint32, usedAt time.Time) error {
+	const op = "storage.postgres.UpdatePasskeyUsage"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE passkeys SET sign_count = $1, last_used_at = $2 WHERE id = $3 AND (sign_count < $1 OR sign_count = 0 AND $1 = 0)",
+		int64(signCount), usedAt.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrPasskeyNotFound)
+}
+
+// DeletePasskey deletes passkey
+func (s *Storage) DeletePasskey(ctx context.Context, id int64) error {
+	const op = "storage.postgres.DeletePasskey"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM passkeys WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrPasskeyNotFound)
+}
+
+func scanPasskey(row scanner) (models.Passkey, error) {
+	var (
+		passkey    models.Passkey
+		signCount  int64
+		lastUsedAt sql.NullTime
+	)
+
+	err := row.Scan(&passkey.ID, &passkey.UserID, &passkey.CredentialID, &passkey.PublicKey, &signCount,
+		&passkey.Name, &passkey.CreatedAt, &lastUsedAt)
+	if err != nil {
+		return models.Passkey{}, err
+	}
+
+	passkey.SignCount = uint32(signCount)
+	if lastUsedAt.Valid {
+		passkey.LastUsedAt = lastUsedAt.Time
+	}
+
+	return passkey, nil
+}
@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"gRPC/internal/domain/models"
+)
+
+// SavePasswordHistory appends password to history of its user
+func (s *Storage) SavePasswordHistory(ctx context.Context, entry models.PasswordHistoryEntry) error {
+	const op = "storage.postgres.SavePasswordHistory"
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO password_history(user_id, hash, created_at) VALUES($1, $2, $3)",
+		entry.UserID, entry.PassHash, entry.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PasswordHistory returns up to limit latest passwords of user, newest first
+func (s *Storage) PasswordHistory(ctx context.Context, userID int64, limit int) ([]models.PasswordHistoryEntry, error) {
+	const op = "storage.postgres.PasswordHistory"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, user_id, hash, created_at FROM password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2",
+		userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var history []models.PasswordHistoryEntry
+	for rows.Next() {
+		var entry models.PasswordHistoryEntry
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.PassHash, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		history = append(history, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return history, nil
+}
@@ -7,15 +7,19 @@ import (
 	"fmt"
 	"gRPC/internal/domain/models"
 	"gRPC/internal/storage"
-	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
-	"os"
+	"github.com/lib/pq"
+	"strings"
 )
 
+// uniqueViolation is postgres error code for unique constraint violation
+const uniqueViolation = "23505"
+
 type Storage struct {
 	db *sql.DB
 }
 
+var _ storage.Storage = (*Storage)(nil)
+
 func New(storagePath string) (*Storage, error) {
 	const op = "storage.postgresql.New"
 
@@ -38,7 +42,8 @@ func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte, h
 
 	_, err = stmt.ExecContext(ctx, email, passHash, hashCode)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
 			return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
 		}
 		return 0, fmt.Errorf("%s: %w", op, err)
@@ -59,25 +64,119 @@ func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte, h
 func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	const op = "storage.postgres.User"
 
-	stmt, err := s.db.Prepare("SELECT id, email, hash FROM user_profile WHERE email = $1")
+	stmt, err := s.db.Prepare("SELECT " + userColumns + " FROM user_profile WHERE email = $1")
 	if err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	row := stmt.QueryRowContext(ctx, email)
 
-	var user models.User
-	err = row.Scan(&user.ID, &user.PassHash, &user.PassHash)
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// UserByID returns user by id
+func (s *Storage) UserByID(ctx context.Context, id int64) (models.User, error) {
+	const op = "storage.postgres.UserByID"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM user_profile WHERE id = $1", id)
+
+	user, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
 		}
+
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return user, nil
 }
 
+// Users returns page of users ordered by id
+func (s *Storage) Users(ctx context.Context, limit int, offset int) ([]models.User, error) {
+	const op = "storage.postgres.Users"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+userColumns+" FROM user_profile ORDER BY id LIMIT $1 OFFSET $2", limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// DisableUser forbids user to log in
+func (s *Storage) DisableUser(ctx context.Context, userID int64) error {
+	const op = "storage.postgres.DisableUser"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE user_profile SET disabled = true WHERE id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrUserNotFound)
+}
+
+// SetAdmin grants or revokes admin status
+func (s *Storage) SetAdmin(ctx context.Context, userID int64, isAdmin bool) error {
+	const op = "storage.postgres.SetAdmin"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE user_profile SET isadmin = $2 WHERE id = $1", userID, isAdmin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrUserNotFound)
+}
+
+// UpdatePassword replaces password hash of user
+func (s *Storage) UpdatePassword(ctx context.Context, userID int64, passHash []byte) error {
+	const op = "storage.postgres.UpdatePassword"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE user_profile SET hash = $2 WHERE id = $1", userID, passHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrUserNotFound)
+}
+
+// UpdatePhone sets phone number of user and whether it is verified
+func (s *Storage) UpdatePhone(ctx context.Context, userID int64, phone string, verified bool) error {
+	const op = "storage.postgres.UpdatePhone"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE user_profile SET phone = $2, phone_verified = $3 WHERE id = $1", userID, phone, verified)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrUserNotFound)
+}
+
 // ValidateCode returns confirmation code by email
 func (s *Storage) ValidateCode(ctx context.Context, email string) (string, error) {
 	const op = "storage.postgres.ValidateCode"
@@ -129,15 +228,12 @@ func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 func (s *Storage) App(ctx context.Context, id int) (models.App, error) {
 	const op = "storage.postgres.App"
 
-	stmt, err := s.db.Prepare("SELECT id, name, secret FROM apps WHERE id = $1")
+	stmt, err := s.db.Prepare("SELECT " + appColumns + " FROM apps WHERE id = $1")
 	if err != nil {
 		return models.App{}, err
 	}
 
-	row := stmt.QueryRowContext(ctx, id)
-
-	var app models.App
-	err = row.Scan(&app.ID, &app.Name, &app.Secret)
+	app, err := scanApp(stmt.QueryRowContext(ctx, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
@@ -150,27 +246,186 @@ func (s *Storage) App(ctx context.Context, id int) (models.App, error) {
 	return app, nil
 }
 
-func AcceptCode(email string) error {
-	const op = "storage.postgres.AcceptCode"
-	err := godotenv.Load()
+// SaveApp saves new app and returns its id
+func (s *Storage) SaveApp(ctx context.Context, name string, secret string) (int, error) {
+	const op = "storage.postgres.SaveApp"
+
+	var id int
+	err := s.db.QueryRowContext(ctx,
+		"INSERT INTO apps(name, secret) VALUES($1, $2) RETURNING id", name, secret).Scan(&id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Apps returns all apps ordered by id
+func (s *Storage) Apps(ctx context.Context) ([]models.App, error) {
+	const op = "storage.postgres.Apps"
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+appColumns+" FROM apps ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var apps []models.App
+	for rows.Next() {
+		app, err := scanApp(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		apps = append(apps, app)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return apps, nil
+}
+
+// UpdateAppSecret replaces secret used to sign app tokens
+func (s *Storage) UpdateAppSecret(ctx context.Context, appID int, secret string) error {
+	const op = "storage.postgres.UpdateAppSecret"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE apps SET secret = $2 WHERE id = $1", appID, secret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrAppNotFound)
+}
+
+// UpdateAppOAuth replaces redirect URIs and scopes of app as OAuth client
+func (s *Storage) UpdateAppOAuth(ctx context.Context, appID int, redirectURIs []string, scopes []string) error {
+	const op = "storage.postgres.UpdateAppOAuth"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE apps SET redirect_uris = $2, scopes = $3 WHERE id = $1",
+		appID, strings.Join(redirectURIs, " "), strings.Join(scopes, " "))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrAppNotFound)
+}
+
+// UpdateAppAudiences replaces resource servers tokens of app may be issued for
+func (s *Storage) UpdateAppAudiences(ctx context.Context, appID int, audiences []string) error {
+	const op = "storage.postgres.UpdateAppAudiences"
 
-	db, err := sql.Open("postgres", os.Getenv("StoragePath"))
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE apps SET audiences = $2 WHERE id = $1", appID, strings.Join(audiences, " "))
 	if err != nil {
-		return err
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	stmt, err := db.Prepare("UPDATE user_profile SET verified = true WHERE email = $1")
+	return affectedOne(op, res, storage.ErrAppNotFound)
+}
+
+// UpdateAppClientSecret replaces digest of OAuth client secret of app, nil makes app public client
+func (s *Storage) UpdateAppClientSecret(ctx context.Context, appID int, secretHash []byte) error {
+	const op = "storage.postgres.UpdateAppClientSecret"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE apps SET client_secret_hash = $2 WHERE id = $1", appID, secretHash)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = stmt.Exec(email)
+	return affectedOne(op, res, storage.ErrAppNotFound)
+}
+
+// UpdateAppPasswordless enables or disables passwordless login to app
+func (s *Storage) UpdateAppPasswordless(ctx context.Context, appID int, enabled bool) error {
+	const op = "storage.postgres.UpdateAppPasswordless"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE apps SET passwordless = $2 WHERE id = $1", appID, enabled)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	return nil
+
+	return affectedOne(op, res, storage.ErrAppNotFound)
+}
+
+// AcceptCode marks user email as verified
+func (s *Storage) AcceptCode(ctx context.Context, email string) error {
+	const op = "storage.postgres.AcceptCode"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE user_profile SET verified = true WHERE email = $1", email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrUserNotFound)
 }
 
 func (s *Storage) Stop() error {
 	return s.db.Close()
 }
+
+const userColumns = `id, email, hash, COALESCE(isadmin, false), COALESCE(verified, false),
+	COALESCE(disabled, false), created_at, phone, phone_verified`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// appColumns are read by scanApp, lists are stored space separated
+const appColumns = "id, name, secret, redirect_uris, scopes, audiences, passwordless, client_secret_hash"
+
+func scanApp(row scanner) (models.App, error) {
+	var (
+		app                             models.App
+		redirectURIs, scopes, audiences string
+	)
+	if err := row.Scan(&app.ID, &app.Name, &app.Secret, &redirectURIs, &scopes, &audiences,
+		&app.Passwordless, &app.ClientSecretHash); err != nil {
+		return models.App{}, err
+	}
+
+	app.RedirectURIs, app.Scopes = splitList(redirectURIs), splitList(scopes)
+	app.Audiences = splitList(audiences)
+
+	return app, nil
+}
+
+// splitList returns nil for empty list, so apps without OAuth settings compare equal
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Fields(s)
+}
+
+func scanUser(row scanner) (models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Email, &user.PassHash, &user.IsAdmin, &user.Verified,
+		&user.Disabled, &user.CreatedAt, &user.Phone, &user.PhoneVerified)
+
+	return user, err
+}
+
+// affectedOne returns notFound if statement did not change any row
+func affectedOne(op string, res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, notFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package postgres_test
+
+import (
+	"context"
+	"gRPC/internal/storage"
+	"gRPC/internal/storage/postgres"
+	"gRPC/internal/storage/storagetest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// dsnKey names environment variable with DSN of a disposable test database
+const dsnKey = "POSTGRES_TEST_DSN"
+
+func TestStorage(t *testing.T) {
+	dsn := os.Getenv(dsnKey)
+	if dsn == "" {
+		t.Skipf("%s is not set", dsnKey)
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		s, err := postgres.New(dsn)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = s.Stop() })
+
+		_, err = s.Migrator().Up(context.Background())
+		require.NoError(t, err)
+
+		return s
+	})
+}
@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+// serviceAccountColumns are read by scanServiceAccount, scopes are stored space separated
+const serviceAccountColumns = "id, app_id, name, client_id, secret_hash, public_key, scopes, disabled, created_at, rotated_at"
+
+// SaveServiceAccount saves new service account and returns its id
+func (s *Storage) SaveServiceAccount(ctx context.Context, account models.ServiceAccount) (int64, error) {
+	const op = "storage.postgres.SaveServiceAccount"
+
+	var id int64
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO service_accounts(app_id, name, client_id, secret_hash, public_key, scopes, created_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		account.AppID, account.Name, account.ClientID, account.SecretHash, account.PublicKey,
+		strings.Join(account.Scopes, " "), account.CreatedAt.UTC()).Scan(&id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrServiceAccountExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// ServiceAccount returns service account by id
+func (s *Storage) ServiceAccount(ctx context.Context, id int64) (models.ServiceAccount, error) {
+	const op = "storage.postgres.ServiceAccount"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+serviceAccountColumns+" FROM service_accounts WHERE id = $1", id)
+
+	account, err := scanServiceAccount(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ServiceAccount{}, fmt.Errorf("%s: %w", op, storage.ErrServiceAccountNotFound)
+		}
+
+		return models.ServiceAccount{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return account, nil
+}
+
+// ServiceAccountByClientID returns service account by its client id
+func (s *Storage) ServiceAccountByClientID(ctx context.Context, clientID string) (models.ServiceAccount, error) {
+	const op = "storage.postgres.ServiceAccountByClientID"
+
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+serviceAccountColumns+" FROM service_accounts WHERE client_id = $1", clientID)
+
+	account, err := scanServiceAccount(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ServiceAccount{}, fmt.Errorf("%s: %w", op, storage.ErrServiceAccountNotFound)
+		}
+
+		return models.ServiceAccount{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return account, nil
+}
+
+// ServiceAccounts returns service accounts of app ordered by id
+func (s *Storage) ServiceAccounts(ctx context.Context, appID int) ([]models.ServiceAccount, error) {
+	const op = "storage.postgres.ServiceAccounts"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+serviceAccountColumns+" FROM service_accounts WHERE app_id = $1 ORDER BY id", appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var accounts []models.ServiceAccount
+	for rows.Next() {
+		account, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return accounts, nil
+}
+
+// UpdateServiceAccountCredentials replaces secret hash and public key of service account rotated at rotatedAt
+func (s *Storage) UpdateServiceAccountCredentials(
+	ctx context.Context, id int64, secretHash []byte, publicKey []byte, rotatedAt time.Time,
+) error {
+	const op = "storage.postgres.UpdateServiceAccountCredentials"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE service_accounts SET secret_hash = $1, public_key = $2, rotated_at = $3 WHERE id = $4",
+		secretHash, publicKey, rotatedAt.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrServiceAccountNotFound)
+}
+
+// DisableServiceAccount marks service account disabled
+func (s *Storage) DisableServiceAccount(ctx context.Context, id int64) error {
+	const op = "storage.postgres.DisableServiceAccount"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE service_accounts SET disabled = TRUE WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrServiceAccountNotFound)
+}
+
+func scanServiceAccount(row scanner) (models.ServiceAccount, error) {
+	var (
+		account   models.ServiceAccount
+		scopes    string
+		rotatedAt sql.NullTime
+	)
+
+	err := row.Scan(&account.ID, &account.AppID, &account.Name, &account.ClientID, &account.SecretHash,
+		&account.PublicKey, &scopes, &account.Disabled, &account.CreatedAt, &rotatedAt)
+	if err != nil {
+		return models.ServiceAccount{}, err
+	}
+
+	account.Scopes = splitList(scopes)
+	if rotatedAt.Valid {
+		account.RotatedAt = rotatedAt.Time
+	}
+
+	return account, nil
+}
@@ -0,0 +1,176 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+	"strings"
+)
+
+const auditColumns = `id, type, user_id, email, app_id, actor_id, ip, user_agent, success, reason, created_at, prev_hash, hash`
+
+// SaveAuditEvent appends event returned by seal to audit log and returns its id
+func (s *Storage) SaveAuditEvent(ctx context.Context, seal func(prevHash string) models.AuditEvent) (int64, error) {
+	const op = "storage.sqlite.SaveAuditEvent"
+
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var prevHash string
+	err = tx.QueryRowContext(ctx, "SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1").Scan(&prevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := seal(prevHash)
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO audit_events(type, user_id, email, app_id, actor_id, ip, user_agent, success, reason, created_at, prev_hash, hash)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.Type, event.UserID, event.Email, event.AppID, event.ActorID, event.IP, event.UserAgent,
+		event.Success, event.Reason, event.CreatedAt.UTC(), event.PrevHash, event.Hash)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// AuditEvents returns events matching filter in order they were saved
+func (s *Storage) AuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	const op = "storage.sqlite.AuditEvents"
+
+	where := []string{"id > ?"}
+	args := []any{filter.AfterID}
+
+	if filter.UserID != 0 {
+		where = append(where, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, filter.Type)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.Until.UTC())
+	}
+
+	query := "SELECT " + auditColumns + " FROM audit_events WHERE " + strings.Join(where, " AND ") + " ORDER BY id"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// LastAuditEvent returns the most recently saved event
+func (s *Storage) LastAuditEvent(ctx context.Context) (models.AuditEvent, error) {
+	const op = "storage.sqlite.LastAuditEvent"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+auditColumns+" FROM audit_events ORDER BY id DESC LIMIT 1")
+
+	e, err := scanAuditEvent(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.AuditEvent{}, fmt.Errorf("%s: %w", op, storage.ErrAuditEventNotFound)
+		}
+
+		return models.AuditEvent{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return e, nil
+}
+
+// SaveAuditCheckpoint saves signed checkpoint of audit chain
+func (s *Storage) SaveAuditCheckpoint(ctx context.Context, checkpoint models.AuditCheckpoint) error {
+	const op = "storage.sqlite.SaveAuditCheckpoint"
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO audit_checkpoints(event_id, hash, kid, signature, created_at) VALUES(?, ?, ?, ?, ?)",
+		checkpoint.EventID, checkpoint.Hash, checkpoint.KeyID, checkpoint.Signature, checkpoint.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AuditCheckpoints returns all checkpoints in order they were saved
+func (s *Storage) AuditCheckpoints(ctx context.Context) ([]models.AuditCheckpoint, error) {
+	const op = "storage.sqlite.AuditCheckpoints"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, event_id, hash, kid, signature, created_at FROM audit_checkpoints ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var checkpoints []models.AuditCheckpoint
+	for rows.Next() {
+		var c models.AuditCheckpoint
+		if err := rows.Scan(&c.ID, &c.EventID, &c.Hash, &c.KeyID, &c.Signature, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		checkpoints = append(checkpoints, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return checkpoints, nil
+}
+
+func scanAuditEvent(row scanner) (models.AuditEvent, error) {
+	var e models.AuditEvent
+	err := row.Scan(&e.ID, &e.Type, &e.UserID, &e.Email, &e.AppID, &e.ActorID, &e.IP, &e.UserAgent,
+		&e.Success, &e.Reason, &e.CreatedAt, &e.PrevHash, &e.Hash)
+
+	return e, err
+}
@@ -0,0 +1,154 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+	"strings"
+	"time"
+)
+
+// serviceAccountColumns are read by scanServiceAccount, scopes are stored space separated
+const serviceAccountColumns = "id, app_id, name, client_id, secret_hash, public_key, scopes, disabled, created_at, rotated_at"
+
+// SaveServiceAccount saves new service account and returns its id
+func (s *Storage) SaveServiceAccount(ctx context.Context, account models.ServiceAccount) (int64, error) {
+	const op = "storage.sqlite.SaveServiceAccount"
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO service_accounts(app_id, name, client_id, secret_hash, public_key, scopes, created_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?)`,
+		account.AppID, account.Name, account.ClientID, account.SecretHash, account.PublicKey,
+		strings.Join(account.Scopes, " "), account.CreatedAt.UTC())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrServiceAccountExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// ServiceAccount returns service account by id
+func (s *Storage) ServiceAccount(ctx context.Context, id int64) (models.ServiceAccount, error) {
+	const op = "storage.sqlite.ServiceAccount"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+serviceAccountColumns+" FROM service_accounts WHERE id = ?", id)
+
+	account, err := scanServiceAccount(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ServiceAccount{}, fmt.Errorf("%s: %w", op, storage.ErrServiceAccountNotFound)
+		}
+
+		return models.ServiceAccount{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return account, nil
+}
+
+// ServiceAccountByClientID returns service account by its client id
+func (s *Storage) ServiceAccountByClientID(ctx context.Context, clientID string) (models.ServiceAccount, error) {
+	const op = "storage.sqlite.ServiceAccountByClientID"
+
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+serviceAccountColumns+" FROM service_accounts WHERE client_id = ?", clientID)
+
+	account, err := scanServiceAccount(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ServiceAccount{}, fmt.Errorf("%s: %w", op, storage.ErrServiceAccountNotFound)
+		}
+
+		return models.ServiceAccount{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return account, nil
+}
+
+// ServiceAccounts returns service accounts of app ordered by id
+func (s *Storage) ServiceAccounts(ctx context.Context, appID int) ([]models.ServiceAccount, error) {
+	const op = "storage.sqlite.ServiceAccounts"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+serviceAccountColumns+" FROM service_accounts WHERE app_id = ? ORDER BY id", appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var accounts []models.ServiceAccount
+	for rows.Next() {
+		account, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return accounts, nil
+}
+
+// UpdateServiceAccountCredentials replaces secret hash and public key of service account rotated at rotatedAt
+func (s *Storage) UpdateServiceAccountCredentials(
+	ctx context.Context, id int64, secretHash []byte, publicKey []byte, rotatedAt time.Time,
+) error {
+	const op = "storage.sqlite.UpdateServiceAccountCredentials"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE service_accounts SET secret_hash = ?, public_key = ?, rotated_at = ? WHERE id = ?",
+		secretHash, publicKey, rotatedAt.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrServiceAccountNotFound)
+}
+
+// DisableServiceAccount marks service account disabled
+func (s *Storage) DisableServiceAccount(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.DisableServiceAccount"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE service_accounts SET disabled = TRUE WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrServiceAccountNotFound)
+}
+
+func scanServiceAccount(row scanner) (models.ServiceAccount, error) {
+	var (
+		account   models.ServiceAccount
+		scopes    string
+		rotatedAt sql.NullTime
+	)
+
+	err := row.Scan(&account.ID, &account.AppID, &account.Name, &account.ClientID, &account.SecretHash,
+		&account.PublicKey, &scopes, &account.Disabled, &account.CreatedAt, &rotatedAt)
+	if err != nil {
+		return models.ServiceAccount{}, err
+	}
+
+	account.Scopes = splitList(scopes)
+	if rotatedAt.Valid {
+		account.RotatedAt = rotatedAt.Time
+	}
+
+	return account, nil
+}
@@ -0,0 +1,165 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+	"strings"
+	"time"
+)
+
+const sessionColumns = `id, user_id, app_id, device_name, user_agent, ip,
+	created_at, last_seen_at, expires_at, revoked_at, impersonator_id, auth_time, amr`
+
+// SaveSession saves new session
+func (s *Storage) SaveSession(ctx context.Context, session models.Session) error {
+	const op = "storage.sqlite.SaveSession"
+
+	var authTime sql.NullTime
+	if !session.AuthTime.IsZero() {
+		authTime = sql.NullTime{Time: session.AuthTime.UTC(), Valid: true}
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO sessions(id, user_id, app_id, device_name, user_agent, ip, created_at, last_seen_at, expires_at,
+			impersonator_id, auth_time, amr)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.ID, session.UserID, session.AppID, session.DeviceName, session.UserAgent, session.IP,
+		session.CreatedAt.UTC(), session.LastSeenAt.UTC(), session.ExpiresAt.UTC(), session.ImpersonatorID,
+		authTime, strings.Join(session.AMR, " "))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Session returns session by id, revoked sessions are returned as well
+func (s *Storage) Session(ctx context.Context, id string) (models.Session, error) {
+	const op = "storage.sqlite.Session"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id)
+
+	session, err := scanSession(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+		}
+
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, nil
+}
+
+// Sessions returns not revoked sessions of user, the newest first
+func (s *Storage) Sessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	const op = "storage.sqlite.Sessions"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? AND revoked_at IS NULL ORDER BY created_at DESC",
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+// TouchSession updates last seen time of session
+func (s *Storage) TouchSession(ctx context.Context, id string, at time.Time) error {
+	const op = "storage.sqlite.TouchSession"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE sessions SET last_seen_at = ? WHERE id = ?", at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrSessionNotFound)
+}
+
+// AuthenticateSession records that the user proved identity in session at by amr methods
+func (s *Storage) AuthenticateSession(ctx context.Context, id string, at time.Time, amr []string) error {
+	const op = "storage.sqlite.AuthenticateSession"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE sessions SET auth_time = ?, amr = ? WHERE id = ?", at.UTC(), strings.Join(amr, " "), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrSessionNotFound)
+}
+
+// RevokeSession revokes session, revoking it again keeps the first revocation time
+func (s *Storage) RevokeSession(ctx context.Context, id string, at time.Time) error {
+	const op = "storage.sqlite.RevokeSession"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?", at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrSessionNotFound)
+}
+
+// RevokeSessions revokes all sessions of user except the one with exceptID
+// and returns the number of revoked sessions
+func (s *Storage) RevokeSessions(ctx context.Context, userID int64, exceptID string, at time.Time) (int64, error) {
+	const op = "storage.sqlite.RevokeSessions"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND id <> ? AND revoked_at IS NULL",
+		at.UTC(), userID, exceptID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+func scanSession(row scanner) (models.Session, error) {
+	var (
+		session   models.Session
+		revokedAt sql.NullTime
+		authTime  sql.NullTime
+		amr       string
+	)
+
+	err := row.Scan(&session.ID, &session.UserID, &session.AppID, &session.DeviceName, &session.UserAgent,
+		&session.IP, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &revokedAt,
+		&session.ImpersonatorID, &authTime, &amr)
+	if revokedAt.Valid {
+		session.RevokedAt = revokedAt.Time
+	}
+	if authTime.Valid {
+		session.AuthTime = authTime.Time
+	}
+	session.AMR = splitList(amr)
+
+	return session, err
+}
@@ -0,0 +1,490 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/migrator"
+	"gRPC/internal/storage"
+	"gRPC/migrations"
+	"github.com/mattn/go-sqlite3"
+	"strings"
+	"sync"
+)
+
+// defaultOptions are appended to storage path without options
+const defaultOptions = "_busy_timeout=5000&_foreign_keys=on"
+
+const versionSchema = `CREATE TABLE IF NOT EXISTS goose_db_version (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    version_id INTEGER NOT NULL,
+    is_applied BOOLEAN NOT NULL,
+    tstamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`
+
+type Storage struct {
+	db *sql.DB
+
+	// auditMu serializes appends to audit chain, sqlite has no row locks to do that
+	auditMu sync.Mutex
+}
+
+var _ storage.Storage = (*Storage)(nil)
+
+func New(storagePath string) (*Storage, error) {
+	const op = "storage.sqlite.New"
+
+	dsn := storagePath
+	if !strings.Contains(dsn, "?") {
+		dsn += "?" + defaultOptions
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// every connection to in-memory database opens a new empty database
+	if strings.Contains(storagePath, ":memory:") {
+		db.SetMaxOpenConns(1)
+	}
+
+	return &Storage{db: db}, nil
+}
+
+// Migrator returns migrator of embedded migrations for this storage
+func (s *Storage) Migrator() *migrator.Migrator {
+	return migrator.New(s.db, migrations.SQLite, noLocker{}, versionSchema)
+}
+
+// noLocker relies on sqlite own file locking, there are no replicas sharing the file
+type noLocker struct{}
+
+func (noLocker) Lock(context.Context, *sql.Conn) error   { return nil }
+func (noLocker) Unlock(context.Context, *sql.Conn) error { return nil }
+
+// SaveUser saves user to db
+func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte, hashCode []byte) (int64, error) {
+	const op = "storage.sqlite.SaveUser"
+
+	res, err := s.db.ExecContext(ctx,
+		"INSERT INTO user_profile(email, hash, code) VALUES(?, ?, ?)", email, passHash, string(hashCode))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// User returns user by email
+func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
+	const op = "storage.sqlite.User"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM user_profile WHERE email = ?", email)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// UserByID returns user by id
+func (s *Storage) UserByID(ctx context.Context, id int64) (models.User, error) {
+	const op = "storage.sqlite.UserByID"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM user_profile WHERE id = ?", id)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// Users returns page of users ordered by id
+func (s *Storage) Users(ctx context.Context, limit int, offset int) ([]models.User, error) {
+	const op = "storage.sqlite.Users"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+userColumns+" FROM user_profile ORDER BY id LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// IsAdmin returns admin status by user id
+func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+	const op = "storage.sqlite.IsAdmin"
+
+	var isAdmin bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COALESCE(isadmin, false) FROM user_profile WHERE id = ?", userID).Scan(&isAdmin)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return isAdmin, nil
+}
+
+// SetAdmin grants or revokes admin status
+func (s *Storage) SetAdmin(ctx context.Context, userID int64, isAdmin bool) error {
+	const op = "storage.sqlite.SetAdmin"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE user_profile SET isadmin = ? WHERE id = ?", isAdmin, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrUserNotFound)
+}
+
+// DisableUser forbids user to log in
+func (s *Storage) DisableUser(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.DisableUser"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE user_profile SET disabled = true WHERE id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrUserNotFound)
+}
+
+// UpdatePassword replaces password hash of user
+func (s *Storage) UpdatePassword(ctx context.Context, userID int64, passHash []byte) error {
+	const op = "storage.sqlite.UpdatePassword"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE user_profile SET hash = ? WHERE id = ?", passHash, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrUserNotFound)
+}
+
+// UpdatePhone sets phone number of user and whether it is verified
+func (s *Storage) UpdatePhone(ctx context.Context, userID int64, phone string, verified bool) error {
+	const op = "storage.sqlite.UpdatePhone"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE user_profile SET phone = ?, phone_verified = ? WHERE id = ?", phone, verified, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrUserNotFound)
+}
+
+// ValidateCode returns confirmation code by email
+func (s *Storage) ValidateCode(ctx context.Context, email string) (string, error) {
+	const op = "storage.sqlite.ValidateCode"
+
+	var code string
+	err := s.db.QueryRowContext(ctx, "SELECT code FROM user_profile WHERE email = ?", email).Scan(&code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return code, nil
+}
+
+// AcceptCode marks user email as verified
+func (s *Storage) AcceptCode(ctx context.Context, email string) error {
+	const op = "storage.sqlite.AcceptCode"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE user_profile SET verified = true WHERE email = ?", email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrUserNotFound)
+}
+
+// App returns app by id
+func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
+	const op = "storage.sqlite.App"
+
+	app, err := scanApp(s.db.QueryRowContext(ctx, "SELECT "+appColumns+" FROM apps WHERE id = ?", appID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app, nil
+}
+
+// Apps returns all apps ordered by id
+func (s *Storage) Apps(ctx context.Context) ([]models.App, error) {
+	const op = "storage.sqlite.Apps"
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+appColumns+" FROM apps ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var apps []models.App
+	for rows.Next() {
+		app, err := scanApp(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		apps = append(apps, app)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return apps, nil
+}
+
+// SaveApp saves new app and returns its id
+func (s *Storage) SaveApp(ctx context.Context, name string, secret string) (int, error) {
+	const op = "storage.sqlite.SaveApp"
+
+	res, err := s.db.ExecContext(ctx, "INSERT INTO apps(name, secret) VALUES(?, ?)", name, secret)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(id), nil
+}
+
+// UpdateAppSecret replaces secret used to sign app tokens
+func (s *Storage) UpdateAppSecret(ctx context.Context, appID int, secret string) error {
+	const op = "storage.sqlite.UpdateAppSecret"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE apps SET secret = ? WHERE id = ?", secret, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrAppNotFound)
+}
+
+// UpdateAppOAuth replaces redirect URIs and scopes of app as OAuth client
+func (s *Storage) UpdateAppOAuth(ctx context.Context, appID int, redirectURIs []string, scopes []string) error {
+	const op = "storage.sqlite.UpdateAppOAuth"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE apps SET redirect_uris = ?, scopes = ? WHERE id = ?",
+		strings.Join(redirectURIs, " "), strings.Join(scopes, " "), appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrAppNotFound)
+}
+
+// UpdateAppAudiences replaces resource servers tokens of app may be issued for
+func (s *Storage) UpdateAppAudiences(ctx context.Context, appID int, audiences []string) error {
+	const op = "storage.sqlite.UpdateAppAudiences"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE apps SET audiences = ? WHERE id = ?", strings.Join(audiences, " "), appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrAppNotFound)
+}
+
+// UpdateAppClientSecret replaces digest of OAuth client secret of app, nil makes app public client
+func (s *Storage) UpdateAppClientSecret(ctx context.Context, appID int, secretHash []byte) error {
+	const op = "storage.sqlite.UpdateAppClientSecret"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE apps SET client_secret_hash = ? WHERE id = ?", secretHash, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrAppNotFound)
+}
+
+// UpdateAppPasswordless enables or disables passwordless login to app
+func (s *Storage) UpdateAppPasswordless(ctx context.Context, appID int, enabled bool) error {
+	const op = "storage.sqlite.UpdateAppPasswordless"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE apps SET passwordless = ? WHERE id = ?", enabled, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrAppNotFound)
+}
+
+// SaveSigningKey saves new signing key, which becomes the active one
+func (s *Storage) SaveSigningKey(ctx context.Context, key models.SigningKey) error {
+	const op = "storage.sqlite.SaveSigningKey"
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO signing_keys(kid, private_key, created_at) VALUES(?, ?, ?)",
+		key.ID, string(key.PrivateKey), key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SigningKeys returns all signing keys, the newest first
+func (s *Storage) SigningKeys(ctx context.Context) ([]models.SigningKey, error) {
+	const op = "storage.sqlite.SigningKeys"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT kid, private_key, created_at FROM signing_keys ORDER BY created_at DESC, id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var signingKeys []models.SigningKey
+	for rows.Next() {
+		var (
+			key        models.SigningKey
+			privateKey string
+		)
+		if err := rows.Scan(&key.ID, &privateKey, &key.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		key.PrivateKey = []byte(privateKey)
+		signingKeys = append(signingKeys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return signingKeys, nil
+}
+
+func (s *Storage) Stop() error {
+	return s.db.Close()
+}
+
+const userColumns = `id, email, hash, COALESCE(isadmin, false), COALESCE(verified, false),
+	COALESCE(disabled, false), created_at, phone, phone_verified`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// appColumns are read by scanApp, lists are stored space separated
+const appColumns = "id, name, secret, redirect_uris, scopes, audiences, passwordless, client_secret_hash"
+
+func scanApp(row scanner) (models.App, error) {
+	var (
+		app                             models.App
+		redirectURIs, scopes, audiences string
+	)
+	if err := row.Scan(&app.ID, &app.Name, &app.Secret, &redirectURIs, &scopes, &audiences,
+		&app.Passwordless, &app.ClientSecretHash); err != nil {
+		return models.App{}, err
+	}
+
+	app.RedirectURIs, app.Scopes = splitList(redirectURIs), splitList(scopes)
+	app.Audiences = splitList(audiences)
+
+	return app, nil
+}
+
+// splitList returns nil for empty list, so apps without OAuth settings compare equal
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Fields(s)
+}
+
+func scanUser(row scanner) (models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Email, &user.PassHash, &user.IsAdmin, &user.Verified,
+		&user.Disabled, &user.CreatedAt, &user.Phone, &user.PhoneVerified)
+
+	return user, err
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// affectedOne returns notFound if statement did not change any row
+func affectedOne(op string, res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, notFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package sqlite_test
+
+import (
+	"context"
+	"gRPC/internal/storage"
+	"gRPC/internal/storage/sqlite"
+	"gRPC/internal/storage/storagetest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		s, err := sqlite.New(filepath.Join(t.TempDir(), "sso.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = s.Stop() })
+
+		_, err = s.Migrator().Up(context.Background())
+		require.NoError(t, err)
+
+		return s
+	})
+}
@@ -0,0 +1,249 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/storage"
+	"strings"
+	"time"
+)
+
+const (
+	webhookColumns  = `id, app_id, url, secret, events, created_at`
+	deliveryColumns = `id, webhook_id, event_type, payload, status, attempts, next_attempt_at,
+	response_code, last_error, created_at, delivered_at`
+)
+
+// SaveWebhook saves new webhook and returns its id
+func (s *Storage) SaveWebhook(ctx context.Context, webhook models.Webhook) (int64, error) {
+	const op = "storage.sqlite.SaveWebhook"
+
+	res, err := s.db.ExecContext(ctx,
+		"INSERT INTO webhooks(app_id, url, secret, events, created_at) VALUES(?, ?, ?, ?, ?)",
+		webhook.AppID, webhook.URL, webhook.Secret, strings.Join(webhook.Events, ","), webhook.CreatedAt.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Webhook returns webhook by id
+func (s *Storage) Webhook(ctx context.Context, id int64) (models.Webhook, error) {
+	const op = "storage.sqlite.Webhook"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id)
+
+	webhook, err := scanWebhook(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Webhook{}, fmt.Errorf("%s: %w", op, storage.ErrWebhookNotFound)
+		}
+
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return webhook, nil
+}
+
+// Webhooks returns webhooks of app ordered by id, zero appID returns webhooks of all apps
+func (s *Storage) Webhooks(ctx context.Context, appID int) ([]models.Webhook, error) {
+	const op = "storage.sqlite.Webhooks"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+webhookColumns+" FROM webhooks WHERE ? = 0 OR app_id = ? ORDER BY id", appID, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes webhook together with its deliveries
+func (s *Storage) DeleteWebhook(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.DeleteWebhook"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrWebhookNotFound)
+}
+
+// SaveWebhookDelivery saves new delivery and returns its id
+func (s *Storage) SaveWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) (int64, error) {
+	const op = "storage.sqlite.SaveWebhookDelivery"
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries(webhook_id, event_type, payload, status, next_attempt_at, created_at)
+		VALUES(?, ?, ?, ?, ?, ?)`,
+		delivery.WebhookID, delivery.EventType, delivery.Payload, delivery.Status,
+		delivery.NextAttemptAt.UTC(), delivery.CreatedAt.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// WebhookDelivery returns delivery by id
+func (s *Storage) WebhookDelivery(ctx context.Context, id int64) (models.WebhookDelivery, error) {
+	const op = "storage.sqlite.WebhookDelivery"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = ?", id)
+
+	delivery, err := scanDelivery(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, storage.ErrDeliveryNotFound)
+		}
+
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return delivery, nil
+}
+
+// WebhookDeliveries returns up to limit deliveries of webhook, the newest first
+func (s *Storage) WebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]models.WebhookDelivery, error) {
+	const op = "storage.sqlite.WebhookDeliveries"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?",
+		webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// ClaimWebhookDeliveries returns pending deliveries due at now and postpones them until
+func (s *Storage) ClaimWebhookDeliveries(ctx context.Context, now time.Time, until time.Time, limit int) ([]models.WebhookDelivery, error) {
+	const op = "storage.sqlite.ClaimWebhookDeliveries"
+
+	rows, err := s.db.QueryContext(ctx,
+		`UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at, id
+			LIMIT ?
+		)
+		RETURNING `+deliveryColumns,
+		until.UTC(), models.DeliveryPending, now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// UpdateWebhookDelivery saves the outcome of delivery attempt
+func (s *Storage) UpdateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	const op = "storage.sqlite.UpdateWebhookDelivery"
+
+	var deliveredAt sql.NullTime
+	if !delivery.DeliveredAt.IsZero() {
+		deliveredAt = sql.NullTime{Time: delivery.DeliveredAt.UTC(), Valid: true}
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, next_attempt_at = ?, response_code = ?, last_error = ?, delivered_at = ?
+		WHERE id = ?`,
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt.UTC(), delivery.ResponseCode, delivery.LastError,
+		deliveredAt, delivery.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affectedOne(op, res, storage.ErrDeliveryNotFound)
+}
+
+func scanWebhook(row scanner) (models.Webhook, error) {
+	var (
+		webhook models.Webhook
+		events  string
+	)
+	if err := row.Scan(&webhook.ID, &webhook.AppID, &webhook.URL, &webhook.Secret, &events, &webhook.CreatedAt); err != nil {
+		return models.Webhook{}, err
+	}
+
+	webhook.Events = strings.Split(events, ",")
+
+	return webhook, nil
+}
+
+func scanDelivery(row scanner) (models.WebhookDelivery, error) {
+	var (
+		d           models.WebhookDelivery
+		deliveredAt sql.NullTime
+	)
+	err := row.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&d.ResponseCode, &d.LastError, &d.CreatedAt, &deliveredAt)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	if deliveredAt.Valid {
+		d.DeliveredAt = deliveredAt.Time
+	}
+
+	return d, nil
+}
+
+func scanDeliveries(rows *sql.Rows) ([]models.WebhookDelivery, error) {
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
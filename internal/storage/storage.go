@@ -1,9 +1,119 @@
 package storage
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"gRPC/internal/domain/models"
+	"time"
+)
 
 var (
 	ErrUserNotFound = errors.New("user not found")
 	ErrUserExists   = errors.New("user already exists")
 	ErrAppNotFound  = errors.New("app not found")
+	ErrAppExists    = errors.New("app already exists")
+
+	ErrSessionNotFound = errors.New("session not found")
+
+	ErrAuditEventNotFound = errors.New("audit event not found")
+
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+	ErrServiceAccountNotFound = errors.New("service account not found")
+	ErrServiceAccountExists   = errors.New("service account already exists")
+
+	ErrExchangePolicyNotFound = errors.New("exchange policy not found")
+	ErrExchangePolicyExists   = errors.New("exchange policy already exists")
+
+	ErrPasskeyNotFound = errors.New("passkey not found")
+	ErrPasskeyExists   = errors.New("passkey already exists")
 )
+
+// Storage is implemented by every storage backend.
+//
+// Services depend on small parts of it only, the whole set is
+// what a backend has to provide and what storagetest checks.
+type Storage interface {
+	SaveUser(ctx context.Context, email string, passHash []byte, verCode []byte) (uid int64, err error)
+	User(ctx context.Context, email string) (models.User, error)
+	UserByID(ctx context.Context, id int64) (models.User, error)
+	Users(ctx context.Context, limit int, offset int) ([]models.User, error)
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+	SetAdmin(ctx context.Context, userID int64, isAdmin bool) error
+	DisableUser(ctx context.Context, userID int64) error
+	UpdatePassword(ctx context.Context, userID int64, passHash []byte) error
+	UpdatePhone(ctx context.Context, userID int64, phone string, verified bool) error
+	SavePasswordHistory(ctx context.Context, entry models.PasswordHistoryEntry) error
+	// PasswordHistory returns up to limit latest passwords of user, newest first
+	PasswordHistory(ctx context.Context, userID int64, limit int) ([]models.PasswordHistoryEntry, error)
+
+	ValidateCode(ctx context.Context, email string) (code string, err error)
+	AcceptCode(ctx context.Context, email string) error
+
+	App(ctx context.Context, appID int) (models.App, error)
+	Apps(ctx context.Context) ([]models.App, error)
+	SaveApp(ctx context.Context, name string, secret string) (int, error)
+	UpdateAppSecret(ctx context.Context, appID int, secret string) error
+	UpdateAppOAuth(ctx context.Context, appID int, redirectURIs []string, scopes []string) error
+	UpdateAppAudiences(ctx context.Context, appID int, audiences []string) error
+	UpdateAppPasswordless(ctx context.Context, appID int, enabled bool) error
+	UpdateAppClientSecret(ctx context.Context, appID int, secretHash []byte) error
+
+	SaveSigningKey(ctx context.Context, key models.SigningKey) error
+	SigningKeys(ctx context.Context) ([]models.SigningKey, error)
+
+	SaveSession(ctx context.Context, session models.Session) error
+	Session(ctx context.Context, id string) (models.Session, error)
+	Sessions(ctx context.Context, userID int64) ([]models.Session, error)
+	TouchSession(ctx context.Context, id string, at time.Time) error
+	AuthenticateSession(ctx context.Context, id string, at time.Time, amr []string) error
+	RevokeSession(ctx context.Context, id string, at time.Time) error
+	RevokeSessions(ctx context.Context, userID int64, exceptID string, at time.Time) (int64, error)
+
+	// SaveAuditEvent appends event returned by seal, which gets Hash of the last saved event.
+	// Appends are serialized, so every event links to its actual predecessor.
+	SaveAuditEvent(ctx context.Context, seal func(prevHash string) models.AuditEvent) (int64, error)
+	AuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+	LastAuditEvent(ctx context.Context) (models.AuditEvent, error)
+	SaveAuditCheckpoint(ctx context.Context, checkpoint models.AuditCheckpoint) error
+	AuditCheckpoints(ctx context.Context) ([]models.AuditCheckpoint, error)
+
+	SaveWebhook(ctx context.Context, webhook models.Webhook) (int64, error)
+	Webhook(ctx context.Context, id int64) (models.Webhook, error)
+	Webhooks(ctx context.Context, appID int) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	SaveWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) (int64, error)
+	WebhookDelivery(ctx context.Context, id int64) (models.WebhookDelivery, error)
+	WebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]models.WebhookDelivery, error)
+	// ClaimWebhookDeliveries returns pending deliveries due at now and postpones them until,
+	// so other instances do not pick them while they are being sent
+	ClaimWebhookDeliveries(ctx context.Context, now time.Time, until time.Time, limit int) ([]models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+
+	SaveServiceAccount(ctx context.Context, account models.ServiceAccount) (int64, error)
+	ServiceAccount(ctx context.Context, id int64) (models.ServiceAccount, error)
+	ServiceAccountByClientID(ctx context.Context, clientID string) (models.ServiceAccount, error)
+	ServiceAccounts(ctx context.Context, appID int) ([]models.ServiceAccount, error)
+	UpdateServiceAccountCredentials(
+		ctx context.Context, id int64, secretHash []byte, publicKey []byte, rotatedAt time.Time,
+	) error
+	DisableServiceAccount(ctx context.Context, id int64) error
+
+	SaveExchangePolicy(ctx context.Context, policy models.ExchangePolicy) (int64, error)
+	// ExchangePolicy returns policy of app letting actor exchange tokens for audience
+	ExchangePolicy(ctx context.Context, appID int, actor string, audience string) (models.ExchangePolicy, error)
+	ExchangePolicies(ctx context.Context, appID int) ([]models.ExchangePolicy, error)
+	DeleteExchangePolicy(ctx context.Context, id int64) error
+
+	SavePasskey(ctx context.Context, passkey models.Passkey) (int64, error)
+	// Passkey returns passkey by credential id of its authenticator
+	Passkey(ctx context.Context, credentialID []byte) (models.Passkey, error)
+	Passkeys(ctx context.Context, userID int64) ([]models.Passkey, error)
+	// UpdatePasskeyUsage records signature counter of the latest login with passkey,
+	// fails with ErrPasskeyNotFound unless the counter grows or both are zero
+	UpdatePasskeyUsage(ctx context.Context, id int64, signCount uint32, usedAt time.Time) error
+	DeletePasskey(ctx context.Context, id int64) error
+
+	Stop() error
+}
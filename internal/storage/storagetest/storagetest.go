@@ -0,0 +1,731 @@
+// Package storagetest is a conformance suite every storage backend has to pass.
+package storagetest
+
+import (
+	"context"
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/random"
+	"gRPC/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns empty or shared storage, the suite never relies on it being empty
+type Factory func(t *testing.T) storage.Storage
+
+// Run runs the whole suite against storage returned by newStorage
+func Run(t *testing.T, newStorage Factory) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, newStorage(t)) })
+	t.Run("PasswordHistory", func(t *testing.T) { testPasswordHistory(t, newStorage(t)) })
+	t.Run("Codes", func(t *testing.T) { testCodes(t, newStorage(t)) })
+	t.Run("Apps", func(t *testing.T) { testApps(t, newStorage(t)) })
+	t.Run("SigningKeys", func(t *testing.T) { testSigningKeys(t, newStorage(t)) })
+	t.Run("Sessions", func(t *testing.T) { testSessions(t, newStorage(t)) })
+	t.Run("AuditEvents", func(t *testing.T) { testAuditEvents(t, newStorage(t)) })
+	t.Run("AuditCheckpoints", func(t *testing.T) { testAuditCheckpoints(t, newStorage(t)) })
+	t.Run("Webhooks", func(t *testing.T) { testWebhooks(t, newStorage(t)) })
+	t.Run("ServiceAccounts", func(t *testing.T) { testServiceAccounts(t, newStorage(t)) })
+	t.Run("ExchangePolicies", func(t *testing.T) { testExchangePolicies(t, newStorage(t)) })
+	t.Run("Passkeys", func(t *testing.T) { testPasskeys(t, newStorage(t)) })
+}
+
+func testUsers(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	email := uniqueEmail(t)
+	passHash := []byte("pass-hash")
+
+	id, err := s.SaveUser(ctx, email, passHash, []byte("code-hash"))
+	require.NoError(t, err)
+	require.NotZero(t, id)
+
+	_, err = s.SaveUser(ctx, email, passHash, []byte("code-hash"))
+	require.ErrorIs(t, err, storage.ErrUserExists)
+
+	user, err := s.User(ctx, email)
+	require.NoError(t, err)
+	assert.Equal(t, id, user.ID)
+	assert.Equal(t, email, user.Email)
+	assert.Equal(t, passHash, user.PassHash)
+	assert.False(t, user.IsAdmin)
+	assert.False(t, user.Verified)
+	assert.False(t, user.Disabled)
+
+	_, err = s.User(ctx, uniqueEmail(t))
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	byID, err := s.UserByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, user, byID)
+
+	_, err = s.UserByID(ctx, 1<<40)
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	isAdmin, err := s.IsAdmin(ctx, id)
+	require.NoError(t, err)
+	assert.False(t, isAdmin)
+
+	require.NoError(t, s.SetAdmin(ctx, id, true))
+
+	isAdmin, err = s.IsAdmin(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+
+	require.NoError(t, s.DisableUser(ctx, id))
+
+	user, err = s.User(ctx, email)
+	require.NoError(t, err)
+	assert.True(t, user.Disabled)
+
+	require.NoError(t, s.UpdatePassword(ctx, id, []byte("new-hash")))
+
+	user, err = s.User(ctx, email)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new-hash"), user.PassHash)
+	assert.Empty(t, user.Phone)
+	assert.False(t, user.PhoneVerified)
+
+	require.NoError(t, s.UpdatePhone(ctx, id, "+15551234567", true))
+
+	user, err = s.UserByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "+15551234567", user.Phone)
+	assert.True(t, user.PhoneVerified)
+
+	const missingID = int64(1 << 40)
+
+	_, err = s.IsAdmin(ctx, missingID)
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+	require.ErrorIs(t, s.SetAdmin(ctx, missingID, true), storage.ErrUserNotFound)
+	require.ErrorIs(t, s.DisableUser(ctx, missingID), storage.ErrUserNotFound)
+	require.ErrorIs(t, s.UpdatePassword(ctx, missingID, passHash), storage.ErrUserNotFound)
+	require.ErrorIs(t, s.UpdatePhone(ctx, missingID, "", false), storage.ErrUserNotFound)
+
+	secondID, err := s.SaveUser(ctx, uniqueEmail(t), passHash, []byte("code-hash"))
+	require.NoError(t, err)
+
+	users, err := s.Users(ctx, 1<<20, 0)
+	require.NoError(t, err)
+	assert.Contains(t, userIDs(users), id)
+	assert.Contains(t, userIDs(users), secondID)
+
+	page, err := s.Users(ctx, 1, 0)
+	require.NoError(t, err)
+	assert.Len(t, page, 1)
+}
+
+func testPasswordHistory(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	userID, err := s.SaveUser(ctx, uniqueEmail(t), []byte("pass-hash"), []byte("code-hash"))
+	require.NoError(t, err)
+	otherID, err := s.SaveUser(ctx, uniqueEmail(t), []byte("pass-hash"), []byte("code-hash"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for i, hash := range []string{"first", "second", "third"} {
+		require.NoError(t, s.SavePasswordHistory(ctx, models.PasswordHistoryEntry{
+			UserID:    userID,
+			PassHash:  []byte(hash),
+			CreatedAt: now.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+	require.NoError(t, s.SavePasswordHistory(ctx, models.PasswordHistoryEntry{
+		UserID:    otherID,
+		PassHash:  []byte("other"),
+		CreatedAt: now,
+	}))
+
+	history, err := s.PasswordHistory(ctx, userID, 2)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, []byte("third"), history[0].PassHash)
+	assert.Equal(t, []byte("second"), history[1].PassHash)
+	assert.Equal(t, userID, history[0].UserID)
+	assert.True(t, now.Add(2*time.Minute).Equal(history[0].CreatedAt))
+
+	history, err = s.PasswordHistory(ctx, otherID, 10)
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	history, err = s.PasswordHistory(ctx, int64(1<<40), 10)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func testCodes(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	email := uniqueEmail(t)
+
+	_, err := s.SaveUser(ctx, email, []byte("pass-hash"), []byte("code-hash"))
+	require.NoError(t, err)
+
+	code, err := s.ValidateCode(ctx, email)
+	require.NoError(t, err)
+	assert.Equal(t, "code-hash", code)
+
+	require.NoError(t, s.AcceptCode(ctx, email))
+
+	user, err := s.User(ctx, email)
+	require.NoError(t, err)
+	assert.True(t, user.Verified)
+
+	_, err = s.ValidateCode(ctx, uniqueEmail(t))
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+	require.ErrorIs(t, s.AcceptCode(ctx, uniqueEmail(t)), storage.ErrUserNotFound)
+}
+
+func testApps(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	name, secret := uniqueString(t), uniqueString(t)
+
+	id, err := s.SaveApp(ctx, name, secret)
+	require.NoError(t, err)
+	require.NotZero(t, id)
+
+	_, err = s.SaveApp(ctx, name, uniqueString(t))
+	require.ErrorIs(t, err, storage.ErrAppExists)
+
+	app, err := s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, models.App{ID: id, Name: name, Secret: secret}, app)
+
+	newSecret := uniqueString(t)
+	require.NoError(t, s.UpdateAppSecret(ctx, id, newSecret))
+
+	app, err = s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, newSecret, app.Secret)
+
+	redirectURIs := []string{"https://example.com/callback", "http://localhost:8080/cb?x=1"}
+	require.NoError(t, s.UpdateAppOAuth(ctx, id, redirectURIs, []string{"openid", "profile"}))
+
+	app, err = s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, redirectURIs, app.RedirectURIs)
+	assert.Equal(t, []string{"openid", "profile"}, app.Scopes)
+
+	apps, err := s.Apps(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, apps, app)
+
+	require.NoError(t, s.UpdateAppOAuth(ctx, id, nil, nil))
+
+	app, err = s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, app.RedirectURIs)
+	assert.Empty(t, app.Scopes)
+
+	audiences := []string{"https://api.example.com", "billing"}
+	require.NoError(t, s.UpdateAppAudiences(ctx, id, audiences))
+
+	app, err = s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, audiences, app.Audiences)
+
+	require.NoError(t, s.UpdateAppAudiences(ctx, id, nil))
+
+	app, err = s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, app.Audiences)
+	assert.False(t, app.Passwordless)
+
+	require.NoError(t, s.UpdateAppPasswordless(ctx, id, true))
+
+	app, err = s.App(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, app.Passwordless)
+	assert.False(t, app.Confidential())
+
+	secretHash := []byte(uniqueString(t))
+	require.NoError(t, s.UpdateAppClientSecret(ctx, id, secretHash))
+
+	app, err = s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, secretHash, app.ClientSecretHash)
+	assert.True(t, app.Confidential())
+
+	require.NoError(t, s.UpdateAppClientSecret(ctx, id, nil))
+
+	app, err = s.App(ctx, id)
+	require.NoError(t, err)
+	assert.False(t, app.Confidential())
+
+	const missingID = 1 << 30
+
+	_, err = s.App(ctx, missingID)
+	require.ErrorIs(t, err, storage.ErrAppNotFound)
+	require.ErrorIs(t, s.UpdateAppSecret(ctx, missingID, uniqueString(t)), storage.ErrAppNotFound)
+	require.ErrorIs(t, s.UpdateAppOAuth(ctx, missingID, nil, nil), storage.ErrAppNotFound)
+	require.ErrorIs(t, s.UpdateAppAudiences(ctx, missingID, nil), storage.ErrAppNotFound)
+	require.ErrorIs(t, s.UpdateAppPasswordless(ctx, missingID, true), storage.ErrAppNotFound)
+	require.ErrorIs(t, s.UpdateAppClientSecret(ctx, missingID, nil), storage.ErrAppNotFound)
+}
+
+func testSigningKeys(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	// far in the future, so they are the newest keys even in shared storage
+	now := time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	older := models.SigningKey{ID: uniqueString(t), PrivateKey: []byte("older"), CreatedAt: now}
+	newer := models.SigningKey{ID: uniqueString(t), PrivateKey: []byte("newer"), CreatedAt: now.Add(time.Hour)}
+
+	require.NoError(t, s.SaveSigningKey(ctx, older))
+	require.NoError(t, s.SaveSigningKey(ctx, newer))
+
+	signingKeys, err := s.SigningKeys(ctx)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(signingKeys), 2)
+
+	assert.Equal(t, newer.ID, signingKeys[0].ID)
+	assert.Equal(t, newer.PrivateKey, signingKeys[0].PrivateKey)
+	assert.True(t, newer.CreatedAt.Equal(signingKeys[0].CreatedAt))
+	assert.Equal(t, older.ID, signingKeys[1].ID)
+}
+
+func testSessions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	userID, err := s.SaveUser(ctx, uniqueEmail(t), []byte("pass-hash"), []byte("code-hash"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	newSession := func(createdAt time.Time) models.Session {
+		return models.Session{
+			ID:         uniqueString(t),
+			UserID:     userID,
+			AppID:      1,
+			DeviceName: "laptop",
+			UserAgent:  "test-agent",
+			IP:         "127.0.0.1",
+			CreatedAt:  createdAt,
+			LastSeenAt: createdAt,
+			ExpiresAt:  createdAt.Add(time.Hour),
+		}
+	}
+
+	older, newer, other := newSession(now), newSession(now.Add(time.Minute)), newSession(now.Add(2*time.Minute))
+	newer.ImpersonatorID = userID
+	older.AuthTime, older.AMR = now, []string{"pwd"}
+	for _, session := range []models.Session{older, newer, other} {
+		require.NoError(t, s.SaveSession(ctx, session))
+	}
+
+	got, err := s.Session(ctx, older.ID)
+	require.NoError(t, err)
+	assert.Equal(t, older.ID, got.ID)
+	assert.Equal(t, userID, got.UserID)
+	assert.Equal(t, older.DeviceName, got.DeviceName)
+	assert.Equal(t, older.UserAgent, got.UserAgent)
+	assert.Equal(t, older.IP, got.IP)
+	assert.True(t, older.ExpiresAt.Equal(got.ExpiresAt))
+	assert.True(t, got.RevokedAt.IsZero())
+	assert.Zero(t, got.ImpersonatorID)
+	assert.True(t, now.Equal(got.AuthTime))
+	assert.Equal(t, []string{"pwd"}, got.AMR)
+
+	got, err = s.Session(ctx, newer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, userID, got.ImpersonatorID)
+	assert.True(t, got.AuthTime.IsZero())
+	assert.Empty(t, got.AMR)
+
+	authTime := now.Add(20 * time.Minute)
+	require.NoError(t, s.AuthenticateSession(ctx, newer.ID, authTime, []string{"pwd", "sms", "mfa"}))
+
+	got, err = s.Session(ctx, newer.ID)
+	require.NoError(t, err)
+	assert.True(t, authTime.Equal(got.AuthTime))
+	assert.Equal(t, []string{"pwd", "sms", "mfa"}, got.AMR)
+
+	_, err = s.Session(ctx, uniqueString(t))
+	require.ErrorIs(t, err, storage.ErrSessionNotFound)
+
+	seenAt := now.Add(30 * time.Minute)
+	require.NoError(t, s.TouchSession(ctx, older.ID, seenAt))
+
+	got, err = s.Session(ctx, older.ID)
+	require.NoError(t, err)
+	assert.True(t, seenAt.Equal(got.LastSeenAt))
+
+	sessions, err := s.Sessions(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+	assert.Equal(t, other.ID, sessions[0].ID)
+
+	revokedAt := now.Add(40 * time.Minute)
+	require.NoError(t, s.RevokeSession(ctx, other.ID, revokedAt))
+	require.NoError(t, s.RevokeSession(ctx, other.ID, revokedAt.Add(time.Minute)))
+
+	got, err = s.Session(ctx, other.ID)
+	require.NoError(t, err)
+	assert.True(t, revokedAt.Equal(got.RevokedAt))
+
+	n, err := s.RevokeSessions(ctx, userID, newer.ID, revokedAt)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	sessions, err = s.Sessions(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, newer.ID, sessions[0].ID)
+
+	require.ErrorIs(t, s.TouchSession(ctx, uniqueString(t), now), storage.ErrSessionNotFound)
+	require.ErrorIs(t, s.AuthenticateSession(ctx, uniqueString(t), now, nil), storage.ErrSessionNotFound)
+	require.ErrorIs(t, s.RevokeSession(ctx, uniqueString(t), now), storage.ErrSessionNotFound)
+}
+
+func testAuditEvents(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	// unique user id keeps filtered results independent of other events in shared storage
+	userID := time.Now().UnixNano()
+
+	saved := []models.AuditEvent{
+		{Type: models.EventRegister, UserID: userID, Email: "user@example.com", Success: true, CreatedAt: now},
+		{Type: models.EventLogin, UserID: userID, AppID: 1, IP: "127.0.0.1", UserAgent: "agent",
+			Success: false, Reason: "invalid_password", CreatedAt: now.Add(time.Minute)},
+		{Type: models.EventLogin, UserID: userID, AppID: 1, Success: true, CreatedAt: now.Add(2 * time.Minute)},
+	}
+
+	var lastID int64
+	for i := range saved {
+		id, err := s.SaveAuditEvent(ctx, func(prevHash string) models.AuditEvent {
+			if i > 0 {
+				assert.Equal(t, saved[i-1].Hash, prevHash)
+			}
+
+			saved[i].PrevHash, saved[i].Hash = prevHash, uniqueString(t)
+			return saved[i]
+		})
+		require.NoError(t, err)
+		require.Greater(t, id, lastID)
+
+		saved[i].ID, lastID = id, id
+	}
+
+	last, err := s.LastAuditEvent(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, saved[2].ID, last.ID)
+	assert.Equal(t, saved[2].Hash, last.Hash)
+	assert.Equal(t, saved[1].Hash, last.PrevHash)
+
+	events, err := s.AuditEvents(ctx, models.AuditFilter{UserID: userID})
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, saved[1].ID, events[1].ID)
+	assert.Equal(t, saved[1].Reason, events[1].Reason)
+	assert.Equal(t, saved[1].IP, events[1].IP)
+	assert.False(t, events[1].Success)
+	assert.True(t, saved[1].CreatedAt.Equal(events[1].CreatedAt))
+
+	events, err = s.AuditEvents(ctx, models.AuditFilter{UserID: userID, Type: models.EventLogin})
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	events, err = s.AuditEvents(ctx, models.AuditFilter{UserID: userID, Since: now.Add(time.Minute), Until: now.Add(2 * time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, saved[1].ID, events[0].ID)
+
+	events, err = s.AuditEvents(ctx, models.AuditFilter{UserID: userID, AfterID: saved[0].ID, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, saved[1].ID, events[0].ID)
+}
+
+func testAuditCheckpoints(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	checkpoint := models.AuditCheckpoint{
+		// unique event id finds the checkpoint among others in shared storage
+		EventID:   time.Now().UnixNano(),
+		Hash:      uniqueString(t),
+		KeyID:     uniqueString(t),
+		Signature: []byte("signature"),
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	require.NoError(t, s.SaveAuditCheckpoint(ctx, checkpoint))
+
+	checkpoints, err := s.AuditCheckpoints(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, checkpoints)
+
+	got := checkpoints[len(checkpoints)-1]
+	assert.NotZero(t, got.ID)
+	assert.Equal(t, checkpoint.EventID, got.EventID)
+	assert.Equal(t, checkpoint.Hash, got.Hash)
+	assert.Equal(t, checkpoint.KeyID, got.KeyID)
+	assert.Equal(t, checkpoint.Signature, got.Signature)
+	assert.True(t, checkpoint.CreatedAt.Equal(got.CreatedAt))
+}
+
+func testWebhooks(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	appID, err := s.SaveApp(ctx, uniqueString(t), "secret")
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	webhook := models.Webhook{
+		AppID:     appID,
+		URL:       "https://example.com/hook",
+		Secret:    uniqueString(t),
+		Events:    []string{models.WebhookUserRegistered, models.WebhookUserVerified},
+		CreatedAt: now,
+	}
+	webhook.ID, err = s.SaveWebhook(ctx, webhook)
+	require.NoError(t, err)
+
+	got, err := s.Webhook(ctx, webhook.ID)
+	require.NoError(t, err)
+	assert.Equal(t, webhook.URL, got.URL)
+	assert.Equal(t, webhook.Secret, got.Secret)
+	assert.Equal(t, webhook.Events, got.Events)
+
+	webhooks, err := s.Webhooks(ctx, appID)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+	assert.Equal(t, webhook.ID, webhooks[0].ID)
+
+	newDelivery := func(nextAttemptAt time.Time) int64 {
+		id, err := s.SaveWebhookDelivery(ctx, models.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			EventType:     models.WebhookUserRegistered,
+			Payload:       []byte(`{"type":"user.registered"}`),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: nextAttemptAt,
+			CreatedAt:     now,
+		})
+		require.NoError(t, err)
+
+		return id
+	}
+	dueID, laterID := newDelivery(now), newDelivery(now.Add(time.Hour))
+
+	deliveries, err := s.WebhookDeliveries(ctx, webhook.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, laterID, deliveries[0].ID)
+	assert.Equal(t, []byte(`{"type":"user.registered"}`), deliveries[0].Payload)
+
+	// other deliveries may be due in shared storage
+	claimedIDs := func() []int64 {
+		claimed, err := s.ClaimWebhookDeliveries(ctx, now, now.Add(time.Minute), 1000)
+		require.NoError(t, err)
+
+		var ids []int64
+		for _, d := range claimed {
+			if d.WebhookID == webhook.ID {
+				ids = append(ids, d.ID)
+			}
+		}
+
+		return ids
+	}
+	assert.Equal(t, []int64{dueID}, claimedIDs())
+	assert.Empty(t, claimedIDs())
+
+	delivery, err := s.WebhookDelivery(ctx, dueID)
+	require.NoError(t, err)
+	assert.True(t, now.Add(time.Minute).Equal(delivery.NextAttemptAt))
+	assert.True(t, delivery.DeliveredAt.IsZero())
+
+	delivery.Status, delivery.Attempts, delivery.ResponseCode = models.DeliveryDelivered, 1, 204
+	delivery.DeliveredAt = now.Add(time.Second)
+	require.NoError(t, s.UpdateWebhookDelivery(ctx, delivery))
+
+	delivery, err = s.WebhookDelivery(ctx, dueID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeliveryDelivered, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, 204, delivery.ResponseCode)
+	assert.True(t, now.Add(time.Second).Equal(delivery.DeliveredAt))
+
+	require.NoError(t, s.DeleteWebhook(ctx, webhook.ID))
+
+	_, err = s.Webhook(ctx, webhook.ID)
+	require.ErrorIs(t, err, storage.ErrWebhookNotFound)
+	_, err = s.WebhookDelivery(ctx, dueID)
+	require.ErrorIs(t, err, storage.ErrDeliveryNotFound)
+	require.ErrorIs(t, s.DeleteWebhook(ctx, webhook.ID), storage.ErrWebhookNotFound)
+}
+
+func testServiceAccounts(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	appID, err := s.SaveApp(ctx, uniqueString(t), "secret")
+	require.NoError(t, err)
+
+	account := models.ServiceAccount{
+		AppID:      appID,
+		Name:       "billing",
+		ClientID:   "sa_" + uniqueString(t),
+		SecretHash: []byte("hash"),
+		Scopes:     []string{"read", "write"},
+		CreatedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+	account.ID, err = s.SaveServiceAccount(ctx, account)
+	require.NoError(t, err)
+
+	got, err := s.ServiceAccount(ctx, account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, account, got)
+
+	got, err = s.ServiceAccountByClientID(ctx, account.ClientID)
+	require.NoError(t, err)
+	assert.Equal(t, account.ID, got.ID)
+
+	duplicate := account
+	duplicate.ClientID = "sa_" + uniqueString(t)
+	_, err = s.SaveServiceAccount(ctx, duplicate)
+	require.ErrorIs(t, err, storage.ErrServiceAccountExists)
+
+	rotatedAt := account.CreatedAt.Add(time.Hour)
+	require.NoError(t, s.UpdateServiceAccountCredentials(ctx, account.ID, []byte("new hash"), []byte("key"), rotatedAt))
+	require.NoError(t, s.DisableServiceAccount(ctx, account.ID))
+
+	accounts, err := s.ServiceAccounts(ctx, appID)
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, []byte("new hash"), accounts[0].SecretHash)
+	assert.Equal(t, []byte("key"), accounts[0].PublicKey)
+	assert.Equal(t, rotatedAt, accounts[0].RotatedAt)
+	assert.True(t, accounts[0].Disabled)
+
+	_, err = s.ServiceAccountByClientID(ctx, "sa_unknown")
+	require.ErrorIs(t, err, storage.ErrServiceAccountNotFound)
+	require.ErrorIs(t, s.DisableServiceAccount(ctx, 1<<40), storage.ErrServiceAccountNotFound)
+}
+
+func testExchangePolicies(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	appID, err := s.SaveApp(ctx, uniqueString(t), "secret")
+	require.NoError(t, err)
+
+	policy := models.ExchangePolicy{
+		AppID:     appID,
+		Actor:     "sa_" + uniqueString(t),
+		Audience:  "https://billing.example.com",
+		Scopes:    []string{"invoices:read"},
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	policy.ID, err = s.SaveExchangePolicy(ctx, policy)
+	require.NoError(t, err)
+
+	got, err := s.ExchangePolicy(ctx, appID, policy.Actor, policy.Audience)
+	require.NoError(t, err)
+	assert.Equal(t, policy, got)
+
+	_, err = s.SaveExchangePolicy(ctx, policy)
+	require.ErrorIs(t, err, storage.ErrExchangePolicyExists)
+
+	other := policy
+	other.Audience, other.Scopes = "reports", nil
+	other.ID, err = s.SaveExchangePolicy(ctx, other)
+	require.NoError(t, err)
+
+	policies, err := s.ExchangePolicies(ctx, appID)
+	require.NoError(t, err)
+	assert.Equal(t, []models.ExchangePolicy{policy, other}, policies)
+
+	_, err = s.ExchangePolicy(ctx, appID, "sa_unknown", policy.Audience)
+	require.ErrorIs(t, err, storage.ErrExchangePolicyNotFound)
+
+	require.NoError(t, s.DeleteExchangePolicy(ctx, policy.ID))
+	require.ErrorIs(t, s.DeleteExchangePolicy(ctx, policy.ID), storage.ErrExchangePolicyNotFound)
+
+	_, err = s.ExchangePolicy(ctx, appID, policy.Actor, policy.Audience)
+	require.ErrorIs(t, err, storage.ErrExchangePolicyNotFound)
+}
+
+func testPasskeys(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	userID, err := s.SaveUser(ctx, uniqueEmail(t), []byte("pass-hash"), []byte("code-hash"))
+	require.NoError(t, err)
+
+	passkey := models.Passkey{
+		UserID:       userID,
+		CredentialID: []byte(uniqueString(t)),
+		PublicKey:    []byte("cose-key"),
+		SignCount:    1,
+		Name:         "laptop",
+		CreatedAt:    time.Now().UTC().Truncate(time.Second),
+	}
+	passkey.ID, err = s.SavePasskey(ctx, passkey)
+	require.NoError(t, err)
+
+	got, err := s.Passkey(ctx, passkey.CredentialID)
+	require.NoError(t, err)
+	assert.Equal(t, passkey, got)
+
+	_, err = s.SavePasskey(ctx, passkey)
+	require.ErrorIs(t, err, storage.ErrPasskeyExists)
+
+	other := passkey
+	other.CredentialID, other.Name, other.SignCount = []byte(uniqueString(t)), "phone", 0
+	other.ID, err = s.SavePasskey(ctx, other)
+	require.NoError(t, err)
+
+	usedAt := passkey.CreatedAt.Add(time.Minute)
+	require.NoError(t, s.UpdatePasskeyUsage(ctx, passkey.ID, 1<<31, usedAt))
+	passkey.SignCount, passkey.LastUsedAt = 1<<31, usedAt
+
+	// the counter only grows, a concurrent login with the same one loses
+	require.ErrorIs(t, s.UpdatePasskeyUsage(ctx, passkey.ID, 1<<31, usedAt), storage.ErrPasskeyNotFound)
+	require.ErrorIs(t, s.UpdatePasskeyUsage(ctx, passkey.ID, 7, usedAt), storage.ErrPasskeyNotFound)
+
+	passkeys, err := s.Passkeys(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, passkeys, 2)
+	assert.Equal(t, passkey.SignCount, passkeys[0].SignCount)
+	assert.True(t, usedAt.Equal(passkeys[0].LastUsedAt))
+	assert.Equal(t, other, passkeys[1])
+
+	// authenticators without counters always report zero
+	require.NoError(t, s.UpdatePasskeyUsage(ctx, other.ID, 0, usedAt))
+
+	_, err = s.Passkey(ctx, []byte("unknown"))
+	require.ErrorIs(t, err, storage.ErrPasskeyNotFound)
+
+	require.NoError(t, s.DeletePasskey(ctx, passkey.ID))
+	require.ErrorIs(t, s.DeletePasskey(ctx, passkey.ID), storage.ErrPasskeyNotFound)
+	require.ErrorIs(t, s.UpdatePasskeyUsage(ctx, passkey.ID, 2, usedAt), storage.ErrPasskeyNotFound)
+
+	_, err = s.Passkey(ctx, passkey.CredentialID)
+	require.ErrorIs(t, err, storage.ErrPasskeyNotFound)
+}
+
+func uniqueEmail(t *testing.T) string {
+	return uniqueString(t) + "@example.com"
+}
+
+func uniqueString(t *testing.T) string {
+	t.Helper()
+
+	s, err := random.String(12)
+	require.NoError(t, err)
+
+	return s
+}
+
+func userIDs(users []models.User) []int64 {
+	ids := make([]int64, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+
+	return ids
+}
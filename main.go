@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"gRPC/internal/app"
+	"gRPC/internal/cli"
 	"gRPC/internal/config"
 	"log/slog"
 	"os"
@@ -18,27 +21,58 @@ const (
 
 func main() {
 	cfg := config.MustLoad()
-	fmt.Println(cfg)
+
+	if args := flag.Args(); len(args) > 0 {
+		os.Exit(runCommand(cfg, args))
+	}
 
 	log := setupLogger(cfg.Env)
 	log.Info("starting application", slog.Any("cfg", cfg))
 
-	application := app.New(log, cfg.GRPC.Port, cfg.StoragePath, cfg.TokenTTL)
+	application := app.New(log, cfg)
 
 	go func() {
 		application.GRPCServer.MustRun()
 	}()
+
+	go func() {
+		application.HTTPServer.MustRun()
+	}()
+
+	go application.AuditCheckpointer.Run()
+	go application.WebhookDispatcher.Run()
 	//Graceful shutdown
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
 	<-stop
 
+	// watch streams never end on their own
+	application.EventBus.Close()
+	application.HTTPServer.Stop()
 	application.GRPCServer.Stop()
+	application.AuditCheckpointer.Stop()
+	application.WebhookDispatcher.Stop()
 
 	log.Info("Application stopped")
 }
 
+// runCommand executes administrative command given after flags and returns exit code
+func runCommand(cfg *config.Config, args []string) int {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	// command output goes to stdout, so logs are written to stderr
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	if err := cli.Run(ctx, log, cfg, os.Stdout, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
 func setupLogger(env string) *slog.Logger {
 	var log *slog.Logger
 	switch env {
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE audit_events
+    ADD COLUMN PREV_HASH TEXT NOT NULL DEFAULT '',
+    ADD COLUMN HASH TEXT NOT NULL DEFAULT '';
+CREATE TABLE audit_checkpoints(
+    ID BIGSERIAL PRIMARY KEY,
+    EVENT_ID BIGINT NOT NULL,
+    HASH TEXT NOT NULL,
+    KID TEXT NOT NULL,
+    SIGNATURE BYTEA NOT NULL,
+    CREATED_AT TIMESTAMP NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_checkpoints;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS PREV_HASH, DROP COLUMN IF EXISTS HASH;
+-- +goose StatementEnd
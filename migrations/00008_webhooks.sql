@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE webhooks(
+    ID BIGSERIAL PRIMARY KEY,
+    APP_ID INTEGER NOT NULL REFERENCES apps(ID) ON DELETE CASCADE,
+    URL TEXT NOT NULL,
+    SECRET TEXT NOT NULL,
+    EVENTS TEXT NOT NULL,
+    CREATED_AT TIMESTAMP NOT NULL
+);
+CREATE INDEX webhooks_app_id_idx ON webhooks(APP_ID);
+CREATE TABLE webhook_deliveries(
+    ID BIGSERIAL PRIMARY KEY,
+    WEBHOOK_ID BIGINT NOT NULL REFERENCES webhooks(ID) ON DELETE CASCADE,
+    EVENT_TYPE TEXT NOT NULL,
+    PAYLOAD BYTEA NOT NULL,
+    STATUS TEXT NOT NULL,
+    ATTEMPTS INTEGER NOT NULL DEFAULT 0,
+    NEXT_ATTEMPT_AT TIMESTAMP NOT NULL,
+    RESPONSE_CODE INTEGER NOT NULL DEFAULT 0,
+    LAST_ERROR TEXT NOT NULL DEFAULT '',
+    CREATED_AT TIMESTAMP NOT NULL,
+    DELIVERED_AT TIMESTAMP
+);
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries(WEBHOOK_ID);
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries(STATUS, NEXT_ATTEMPT_AT);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+-- +goose StatementEnd
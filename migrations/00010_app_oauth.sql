@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE apps ADD COLUMN REDIRECT_URIS TEXT NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN SCOPES TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE apps DROP COLUMN SCOPES;
+ALTER TABLE apps DROP COLUMN REDIRECT_URIS;
+-- +goose StatementEnd
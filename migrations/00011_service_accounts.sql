@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE service_accounts(
+    ID BIGSERIAL PRIMARY KEY,
+    APP_ID INTEGER NOT NULL REFERENCES apps(ID) ON DELETE CASCADE,
+    NAME TEXT NOT NULL,
+    CLIENT_ID TEXT NOT NULL UNIQUE,
+    SECRET_HASH BYTEA NOT NULL,
+    PUBLIC_KEY BYTEA,
+    SCOPES TEXT NOT NULL DEFAULT '',
+    DISABLED BOOLEAN NOT NULL DEFAULT FALSE,
+    CREATED_AT TIMESTAMP NOT NULL,
+    UNIQUE (APP_ID, NAME)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS service_accounts;
+-- +goose StatementEnd
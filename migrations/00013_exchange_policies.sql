@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE exchange_policies(
+    ID BIGSERIAL PRIMARY KEY,
+    APP_ID INTEGER NOT NULL REFERENCES apps(ID) ON DELETE CASCADE,
+    ACTOR TEXT NOT NULL,
+    AUDIENCE TEXT NOT NULL,
+    SCOPES TEXT NOT NULL DEFAULT '',
+    CREATED_AT TIMESTAMP NOT NULL,
+    UNIQUE (APP_ID, ACTOR, AUDIENCE)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS exchange_policies;
+-- +goose StatementEnd
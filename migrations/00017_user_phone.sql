@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_profile ADD COLUMN PHONE TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE user_profile ADD COLUMN PHONE_VERIFIED BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_profile DROP COLUMN PHONE_VERIFIED;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE user_profile DROP COLUMN PHONE;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE service_accounts ADD COLUMN ROTATED_AT TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE service_accounts DROP COLUMN ROTATED_AT;
+-- +goose StatementEnd
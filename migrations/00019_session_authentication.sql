@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE sessions ADD COLUMN AUTH_TIME TIMESTAMP;
+ALTER TABLE sessions ADD COLUMN AMR TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sessions DROP COLUMN AMR;
+ALTER TABLE sessions DROP COLUMN AUTH_TIME;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// FS holds goose formatted SQL migrations of the postgres storage
+//
+//go:embed *.sql
+var FS embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFS embed.FS
+
+// SQLite holds migrations of the sqlite storage, they mirror the postgres ones
+var SQLite = mustSub(sqliteFS, "sqlite")
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+
+	return sub
+}
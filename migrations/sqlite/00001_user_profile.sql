@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE user_profile
+(
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    EMAIL TEXT UNIQUE NOT NULL,
+    HASH BLOB NOT NULL,
+    CODE TEXT NOT NULL,
+    VERIFIED BOOLEAN DEFAULT FALSE,
+    IsAdmin BOOLEAN DEFAULT FALSE,
+    CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_profile;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE signing_keys(
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    KID TEXT NOT NULL UNIQUE,
+    PRIVATE_KEY TEXT NOT NULL,
+    CREATED_AT TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS signing_keys;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE sessions(
+    ID TEXT PRIMARY KEY,
+    USER_ID INTEGER NOT NULL REFERENCES user_profile(ID) ON DELETE CASCADE,
+    APP_ID INTEGER NOT NULL,
+    DEVICE_NAME TEXT NOT NULL DEFAULT '',
+    USER_AGENT TEXT NOT NULL DEFAULT '',
+    IP TEXT NOT NULL DEFAULT '',
+    CREATED_AT TIMESTAMP NOT NULL,
+    LAST_SEEN_AT TIMESTAMP NOT NULL,
+    EXPIRES_AT TIMESTAMP NOT NULL,
+    REVOKED_AT TIMESTAMP
+);
+CREATE INDEX sessions_user_id_idx ON sessions(USER_ID);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS sessions;
+-- +goose StatementEnd
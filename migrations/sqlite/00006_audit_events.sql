@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE audit_events(
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    TYPE TEXT NOT NULL,
+    USER_ID BIGINT NOT NULL DEFAULT 0,
+    EMAIL TEXT NOT NULL DEFAULT '',
+    APP_ID INTEGER NOT NULL DEFAULT 0,
+    ACTOR_ID BIGINT NOT NULL DEFAULT 0,
+    IP TEXT NOT NULL DEFAULT '',
+    USER_AGENT TEXT NOT NULL DEFAULT '',
+    SUCCESS BOOLEAN NOT NULL,
+    REASON TEXT NOT NULL DEFAULT '',
+    CREATED_AT TIMESTAMP NOT NULL
+);
+CREATE INDEX audit_events_user_id_idx ON audit_events(USER_ID);
+CREATE INDEX audit_events_created_at_idx ON audit_events(CREATED_AT);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_events;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE audit_events ADD COLUMN PREV_HASH TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN HASH TEXT NOT NULL DEFAULT '';
+CREATE TABLE audit_checkpoints(
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    EVENT_ID BIGINT NOT NULL,
+    HASH TEXT NOT NULL,
+    KID TEXT NOT NULL,
+    SIGNATURE BLOB NOT NULL,
+    CREATED_AT TIMESTAMP NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_checkpoints;
+ALTER TABLE audit_events DROP COLUMN HASH;
+ALTER TABLE audit_events DROP COLUMN PREV_HASH;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE password_history(
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    USER_ID INTEGER NOT NULL REFERENCES user_profile(ID) ON DELETE CASCADE,
+    HASH BLOB NOT NULL,
+    CREATED_AT TIMESTAMP NOT NULL
+);
+CREATE INDEX password_history_user_id_idx ON password_history(USER_ID);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS password_history;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE passkeys(
+    ID INTEGER PRIMARY KEY AUTOINCREMENT,
+    USER_ID INTEGER NOT NULL REFERENCES user_profile(ID) ON DELETE CASCADE,
+    CREDENTIAL_ID BLOB NOT NULL UNIQUE,
+    PUBLIC_KEY BLOB NOT NULL,
+    SIGN_COUNT INTEGER NOT NULL DEFAULT 0,
+    NAME TEXT NOT NULL DEFAULT '',
+    CREATED_AT TIMESTAMP NOT NULL,
+    LAST_USED_AT TIMESTAMP
+);
+CREATE INDEX passkeys_user_id_idx ON passkeys(USER_ID);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS passkeys;
+-- +goose StatementEnd
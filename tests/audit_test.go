@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"gRPC/internal/domain/models"
+	"gRPC/internal/lib/keys"
+	"gRPC/internal/services/audit"
+	"gRPC/tests/suite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudit_LoginEvents(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+
+	resp, _ := postJSON(t, st, "/v1/auth/login", map[string]any{
+		"email":    email,
+		"password": randomFakePassword(),
+		"app_id":   appID,
+	})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	token := loginHTTP(t, st, email, pass, "laptop")
+
+	resp, _ = doJSON(t, st, http.MethodGet, "/v1/audit/events", token, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	user, err := st.Storage.User(ctx, email)
+	require.NoError(t, err)
+	require.NoError(t, st.Storage.SetAdmin(ctx, user.ID, true))
+
+	query := "?type=login&user_id=" + strconv.FormatInt(user.ID, 10)
+
+	resp, body := doJSON(t, st, http.MethodGet, "/v1/audit/events"+query+"&limit=1", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	events := body["events"].([]any)
+	require.Len(t, events, 1)
+
+	failed := events[0].(map[string]any)
+	assert.Equal(t, false, failed["success"])
+	assert.Equal(t, "invalid_password", failed["reason"])
+
+	cursor := body["next_cursor"].(string)
+	require.NotEmpty(t, cursor)
+
+	resp, body = doJSON(t, st, http.MethodGet, "/v1/audit/events"+query+"&cursor="+cursor, token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	events = body["events"].([]any)
+	require.Len(t, events, 1)
+	assert.Equal(t, true, events[0].(map[string]any)["success"])
+	assert.Empty(t, body["next_cursor"])
+
+	req, err := http.NewRequest(http.MethodGet, st.HTTPURL+"/v1/audit/events:export?user_id="+strconv.FormatInt(user.ID, 10), nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	exportResp, err := st.HTTPClient.Do(req)
+	require.NoError(t, err)
+	defer exportResp.Body.Close()
+
+	require.Equal(t, http.StatusOK, exportResp.StatusCode)
+
+	var types []string
+	scanner := bufio.NewScanner(exportResp.Body)
+	for scanner.Scan() {
+		var event map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+
+		types = append(types, event["type"].(string))
+	}
+	require.NoError(t, scanner.Err())
+
+	assert.Equal(t, []string{"register", "login", "login"}, types)
+}
+
+func TestAudit_VerifyChain(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+	token := loginHTTP(t, st, email, pass, "laptop")
+
+	resp, _ := doJSON(t, st, http.MethodPost, "/v1/audit/verify", token, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	user, err := st.Storage.User(ctx, email)
+	require.NoError(t, err)
+	require.NoError(t, st.Storage.SetAdmin(ctx, user.ID, true))
+
+	key, err := keys.Generate(st.Clock.Now())
+	require.NoError(t, err)
+	require.NoError(t, st.Storage.SaveSigningKey(ctx, key))
+
+	// the server signs checkpoints periodically, here it is done right away
+	auditService := audit.New(slog.New(slog.NewTextHandler(io.Discard, nil)), st.Storage, st.Clock)
+	checkpoint, err := auditService.Checkpoint(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, checkpoint.KeyID)
+
+	loginHTTP(t, st, email, pass, "phone")
+
+	resp, body := doJSON(t, st, http.MethodPost, "/v1/audit/verify", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, true, body["valid"])
+	assert.EqualValues(t, 3, body["events"])
+	assert.EqualValues(t, 1, body["checkpoints"])
+
+	events, err := st.Storage.AuditEvents(ctx, models.AuditFilter{})
+	require.NoError(t, err)
+
+	tampered := events[1]
+	tampered.Success = false
+	st.Storage.TamperAuditEvent(tampered)
+
+	resp, body = doJSON(t, st, http.MethodPost, "/v1/audit/verify", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, false, body["valid"])
+	assert.EqualValues(t, tampered.ID, body["broken_at"])
+	assert.Equal(t, "event does not match its hash", body["reason"])
+}
@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"testing"
+
+	"gRPC/tests/suite"
+
+	ssov5 "github.com/LeeAntonV/Protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const maxCodeAttempts = 5
+
+func TestValidCode_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+
+	_, err := st.AuthClient.Register(ctx, &ssov5.RegisterRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	msg, ok := st.Outbox.Last(email)
+	require.True(t, ok)
+
+	resp, err := st.AuthClient.ValidCode(ctx, &ssov5.CodeRequest{
+		Email: email,
+		Code:  msg.Body,
+	})
+	require.NoError(t, err)
+	assert.True(t, resp.GetValidCode())
+}
+
+func TestValidCode_TooManyAttempts(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+
+	_, err := st.AuthClient.Register(ctx, &ssov5.RegisterRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	msg, ok := st.Outbox.Last(email)
+	require.True(t, ok)
+
+	for range maxCodeAttempts {
+		_, err := st.AuthClient.ValidCode(ctx, &ssov5.CodeRequest{Email: email, Code: "wrong"})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+
+	// even the right code is rejected until the window is over
+	_, err = st.AuthClient.ValidCode(ctx, &ssov5.CodeRequest{Email: email, Code: msg.Body})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -45,7 +45,7 @@ func TestRegisterLogin_Login_HappyPath(t *testing.T) {
 	token := respLogin.GetToken()
 	require.NotEmpty(t, token)
 
-	loginTime := time.Now()
+	loginTime := st.Clock.Now()
 
 	tokenParsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
 		return []byte(appSecret), nil
@@ -65,6 +65,39 @@ func TestRegisterLogin_Login_HappyPath(t *testing.T) {
 	assert.InDelta(t, loginTime.Add(st.Cfg.TokenTTL).Unix(), claims["exp"].(float64), deltaSeconds)
 }
 
+func TestRegisterLogin_TokenFollowsClock(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov5.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	msg, ok := st.Outbox.Last(email)
+	require.True(t, ok)
+	assert.Len(t, msg.Body, 6)
+
+	st.Clock.Advance(24 * time.Hour)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov5.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{}
+	_, _ = jwt.ParseWithClaims(respLogin.GetToken(), claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(appSecret), nil
+	})
+
+	assert.Equal(t, st.Clock.Now().Add(st.Cfg.TokenTTL).Unix(), int64(claims["exp"].(float64)))
+}
+
 func TestRegisterLogin_DuplicatedRegistration(t *testing.T) {
 	ctx, st := suite.New(t)
 
@@ -195,6 +228,7 @@ func TestLogin_FailCases(t *testing.T) {
 	}
 }
 
+// randomFakePassword returns random password with every character class the test policy requires
 func randomFakePassword() string {
-	return gofakeit.Password(true, true, true, true, false, passDefaultLen)
+	return gofakeit.Password(true, true, true, true, false, passDefaultLen) + "aZ7"
 }
@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"gRPC/internal/cli"
+	"gRPC/tests/suite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCLI_IssueAndInspectToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, _ := registerHTTP(t, st)
+
+	run := func(args ...string) map[string]any {
+		var out bytes.Buffer
+		log := slog.New(slog.NewTextHandler(io.Discard, nil))
+		require.NoError(t, cli.Run(ctx, log, st.Cfg, &out, args, cli.WithStorage(st.Storage)))
+
+		var result map[string]any
+		require.NoError(t, json.Unmarshal(out.Bytes(), &result), out.String())
+
+		return result
+	}
+
+	issued := run("token", "issue", "--output=json", "--email="+email, "--app="+strconv.Itoa(appID))
+	token, ok := issued["token"].(string)
+	require.True(t, ok, issued)
+
+	claims := run("token", "inspect", "--output=json", token)
+	assert.Equal(t, st.Cfg.OIDC.Issuer, claims["iss"])
+	assert.Equal(t, email, claims["email"])
+
+	// the server accepts tokens issued by operators
+	resp, body := doJSON(t, st, http.MethodPost, "/v1/tokens:validate", "", map[string]any{"token": token})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, email, body["email"])
+}
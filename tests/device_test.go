@@ -0,0 +1,247 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"gRPC/tests/suite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevice_ApproveOnPage(t *testing.T) {
+	_, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+
+	resp, device := postDeviceAuthorization(t, st, url.Values{"client_id": {"1"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode, device)
+	assert.Equal(t, st.HTTPURL+"/oauth/device", device["verification_uri"])
+	assert.EqualValues(t, 5, device["interval"])
+
+	poll := url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"client_id":   {"1"},
+		"device_code": {device["device_code"].(string)},
+	}
+
+	resp, body := postToken(t, st, poll)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "authorization_pending", body["error"])
+
+	resp, body = postToken(t, st, poll)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "slow_down", body["error"])
+
+	resp, err := st.HTTPClient.Get(device["verification_uri_complete"].(string))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = st.HTTPClient.PostForm(st.HTTPURL+"/oauth/device", url.Values{
+		"user_code": {device["user_code"].(string)},
+		"email":     {email},
+		"password":  {pass},
+		"decision":  {"allow"},
+	})
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	st.Clock.Advance(6 * time.Second)
+
+	resp, body = postToken(t, st, poll)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	resp, body = doJSON(t, st, http.MethodPost, "/v1/tokens:validate", "", map[string]any{"token": body["access_token"]})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, email, body["email"])
+
+	st.Clock.Advance(6 * time.Second)
+
+	resp, body = postToken(t, st, poll)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "expired_token", body["error"])
+}
+
+func TestDevice_DenyOnPageAndSlowDown(t *testing.T) {
+	_, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+
+	resp, device := postDeviceAuthorization(t, st, url.Values{"client_id": {"1"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode, device)
+
+	poll := func() (*http.Response, map[string]any) {
+		return postToken(t, st, url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"client_id":   {"1"},
+			"device_code": {device["device_code"].(string)},
+		})
+	}
+
+	deny := func(email string, pass string) int {
+		resp, err := st.HTTPClient.PostForm(st.HTTPURL+"/oauth/device", url.Values{
+			"user_code": {device["user_code"].(string)},
+			"email":     {email},
+			"password":  {pass},
+			"decision":  {"deny"},
+		})
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	resp, body := poll()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "authorization_pending", body["error"])
+
+	// every slow_down adds 5 seconds to the interval
+	resp, body = poll()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "slow_down", body["error"])
+
+	st.Clock.Advance(6 * time.Second)
+
+	_, body = poll()
+	assert.Equal(t, "authorization_pending", body["error"])
+
+	st.Clock.Advance(6 * time.Second)
+
+	_, body = poll()
+	assert.Equal(t, "slow_down", body["error"], "the interval is 10 seconds now")
+
+	// whoever sees the code may not deny it without credentials
+	assert.Equal(t, http.StatusUnauthorized, deny("", ""))
+	assert.Equal(t, http.StatusUnauthorized, deny(email, "wrong"+pass))
+
+	st.Clock.Advance(16 * time.Second)
+
+	_, body = poll()
+	assert.Equal(t, "authorization_pending", body["error"])
+
+	require.Equal(t, http.StatusOK, deny(email, pass))
+
+	st.Clock.Advance(16 * time.Second)
+
+	resp, body = poll()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "access_denied", body["error"])
+}
+
+func TestDevice_UserCodeGuessing(t *testing.T) {
+	_, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+
+	resp, device := postDeviceAuthorization(t, st, url.Values{"client_id": {"1"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode, device)
+
+	decide := func(userCode string, email string, pass string) int {
+		resp, err := st.HTTPClient.PostForm(st.HTTPURL+"/oauth/device", url.Values{
+			"user_code": {userCode},
+			"email":     {email},
+			"password":  {pass},
+			"decision":  {"allow"},
+		})
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	lookup := func(userCode string) int {
+		resp, err := st.HTTPClient.Get(st.HTTPURL + "/oauth/device?user_code=" + userCode)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	// the form does not tell pending codes from unknown ones
+	assert.Equal(t, http.StatusUnauthorized, decide("BBBB-BBBB", email, pass))
+	assert.Equal(t, http.StatusUnauthorized, decide(device["user_code"].(string), email, "wrong"+pass))
+
+	// guesses are counted per client whatever email is given
+	for _, code := range []string{"CCCC-CCCC", "DDDD-DDDD", "FFFF-FFFF"} {
+		assert.Equal(t, http.StatusBadRequest, lookup(code))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, lookup(device["user_code"].(string)))
+	assert.Equal(t, http.StatusTooManyRequests, decide(device["user_code"].(string), "other"+email, pass))
+}
+
+func TestDevice_ApproveWithGateway(t *testing.T) {
+	_, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+	token := loginHTTP(t, st, email, pass, "laptop")
+
+	start := func() map[string]any {
+		resp, device := doJSON(t, st, http.MethodPost, "/v1/device/authorize", "", map[string]any{"client_id": 1})
+		require.Equal(t, http.StatusOK, resp.StatusCode, device)
+
+		return device
+	}
+
+	t.Run("approved", func(t *testing.T) {
+		device := start()
+
+		// users type codes as they like
+		resp, body := doJSON(t, st, http.MethodPost, "/v1/device/approve", token, map[string]any{
+			"user_code": "  " + device["user_code"].(string)[:4] + device["user_code"].(string)[5:],
+			"approve":   true,
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+		resp, body = doJSON(t, st, http.MethodPost, "/v1/device/token", "", map[string]any{
+			"client_id":   1,
+			"device_code": device["device_code"],
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.NotEmpty(t, body["access_token"])
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		device := start()
+
+		resp, body := doJSON(t, st, http.MethodPost, "/v1/device/approve", token, map[string]any{
+			"user_code": device["user_code"],
+			"approve":   false,
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+		resp, body = postToken(t, st, url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"client_id":   {"1"},
+			"device_code": {device["device_code"].(string)},
+		})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "access_denied", body["error"])
+	})
+
+	t.Run("unknown user code", func(t *testing.T) {
+		resp, _ := doJSON(t, st, http.MethodPost, "/v1/device/approve", token, map[string]any{
+			"user_code": "BBBB-BBBB",
+			"approve":   true,
+		})
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func postDeviceAuthorization(t *testing.T, st *suite.Suite, form url.Values) (*http.Response, map[string]any) {
+	t.Helper()
+
+	resp, err := st.HTTPClient.PostForm(st.HTTPURL+"/oauth/device_authorization", form)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp, body
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gRPC/tests/suite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents_WatchReplaysAndFollows(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminEmail, pass := registerHTTP(t, st)
+	token := loginHTTP(t, st, adminEmail, pass, "laptop")
+
+	admin, err := st.Storage.User(ctx, adminEmail)
+	require.NoError(t, err)
+	require.NoError(t, st.Storage.SetAdmin(ctx, admin.ID, true))
+
+	next := watchEvents(t, st, token, "types=register")
+
+	first := next()
+	assert.Equal(t, "register", first["type"])
+	assert.Equal(t, adminEmail, first["email"])
+
+	email, _ := registerHTTP(t, st)
+
+	second := next()
+	assert.Equal(t, "register", second["type"])
+	assert.Equal(t, email, second["email"])
+
+	// reconnecting watcher resumes after the last seen event
+	next = watchEvents(t, st, token, "types=register&cursor="+first["cursor"].(string))
+	assert.Equal(t, email, next()["email"])
+
+	resp, body := doJSON(t, st, http.MethodGet, "/v1/events:watch?types=login", token, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "unknown event type", body["message"])
+}
+
+// watchEvents opens event stream and returns function reading its next event
+func watchEvents(t *testing.T, st *suite.Suite, token string, query string) func() map[string]any {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, st.HTTPURL+"/v1/events:watch?"+query, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := st.HTTPClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	scanner := bufio.NewScanner(resp.Body)
+
+	return func() map[string]any {
+		t.Helper()
+
+		require.True(t, scanner.Scan(), "stream ended: %v", scanner.Err())
+
+		var event map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		require.Nil(t, event["error"])
+
+		return event
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	authgrpc "gRPC/internal/grpc/auth"
+	"gRPC/tests/suite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestExtensions_ServedOverGRPC(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+	token := loginHTTP(t, st, email, pass, "laptop")
+	loginHTTP(t, st, email, pass, "phone")
+
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+
+	var sessions authgrpc.ListSessionsResponse
+	err := invokeExtension(ctx, st, "ListSessions", &authgrpc.ListSessionsRequest{}, &sessions)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	err = invokeExtension(authCtx, st, "ListSessions", &authgrpc.ListSessionsRequest{}, &sessions)
+	require.NoError(t, err)
+	assert.Len(t, sessions.Sessions, 2)
+
+	// step-up policy applies to gRPC calls the same way it does to the gateway
+	st.Clock.Advance(11 * time.Minute)
+
+	var revoked authgrpc.RevokeSessionsResponse
+	err = invokeExtension(authCtx, st, "RevokeOtherSessions", &authgrpc.RevokeOtherSessionsRequest{}, &revoked)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	var fresh authgrpc.ReauthenticateResponse
+	err = invokeExtension(authCtx, st, "Reauthenticate", &authgrpc.ReauthenticateRequest{Password: pass}, &fresh)
+	require.NoError(t, err)
+
+	freshCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+fresh.Token)
+	err = invokeExtension(freshCtx, st, "RevokeOtherSessions", &authgrpc.RevokeOtherSessionsRequest{}, &revoked)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, revoked.Revoked)
+
+	t.Run("stream", func(t *testing.T) {
+		admin, err := st.Storage.User(ctx, email)
+		require.NoError(t, err)
+		require.NoError(t, st.Storage.SetAdmin(ctx, admin.ID, true))
+
+		watch := func(ctx context.Context) grpc.ClientStream {
+			stream, err := st.Conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true},
+				"/"+authgrpc.ExtensionsService+"/WatchAuthEvents", grpc.CallContentSubtype(authgrpc.JSONCodecName))
+			require.NoError(t, err)
+			require.NoError(t, stream.SendMsg(&authgrpc.WatchAuthEventsRequest{Types: []string{"register"}}))
+			require.NoError(t, stream.CloseSend())
+
+			return stream
+		}
+
+		streamCtx, cancel := context.WithCancel(freshCtx)
+		defer cancel()
+
+		st.Clock.Advance(11 * time.Minute)
+
+		stream := watch(streamCtx)
+		assert.Equal(t, codes.Unauthenticated, status.Code(stream.RecvMsg(&authgrpc.AuthEvent{})))
+
+		err = invokeExtension(freshCtx, st, "Reauthenticate", &authgrpc.ReauthenticateRequest{Password: pass}, &fresh)
+		require.NoError(t, err)
+
+		// the session is raised, so are all of its tokens
+		stream = watch(streamCtx)
+
+		var event authgrpc.AuthEvent
+		require.NoError(t, stream.RecvMsg(&event))
+		assert.Equal(t, "register", event.Type)
+		assert.Equal(t, email, event.Email)
+	})
+}
+
+// invokeExtension calls unary RPC of the extensions service
+func invokeExtension(ctx context.Context, st *suite.Suite, method string, req, resp any) error {
+	return st.Conn.Invoke(ctx, "/"+authgrpc.ExtensionsService+"/"+method, req, resp,
+		grpc.CallContentSubtype(authgrpc.JSONCodecName))
+}
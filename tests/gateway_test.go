@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gRPC/tests/suite"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGateway_RegisterLogin_HappyPath(t *testing.T) {
+	_, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	resp, body := postJSON(t, st, "/v1/auth/register", map[string]any{
+		"email":    email,
+		"password": pass,
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, body["user_id"])
+
+	resp, body = postJSON(t, st, "/v1/auth/login", map[string]any{
+		"email":    email,
+		"password": pass,
+		"app_id":   appID,
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, body["token"])
+}
+
+func TestGateway_StatusMapping(t *testing.T) {
+	_, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	resp, _ := postJSON(t, st, "/v1/auth/register", map[string]any{"email": email, "password": pass})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	tests := []struct {
+		name           string
+		path           string
+		body           map[string]any
+		expectedStatus int
+		expectedErr    string
+	}{
+		{
+			name:           "Register with Empty Email",
+			path:           "/v1/auth/register",
+			body:           map[string]any{"password": pass},
+			expectedStatus: http.StatusBadRequest,
+			expectedErr:    "email is required",
+		},
+		{
+			name:           "Duplicated Registration",
+			path:           "/v1/auth/register",
+			body:           map[string]any{"email": email, "password": pass},
+			expectedStatus: http.StatusConflict,
+			expectedErr:    "user already exists",
+		},
+		{
+			name:           "Login with Wrong Password",
+			path:           "/v1/auth/login",
+			body:           map[string]any{"email": email, "password": randomFakePassword(), "app_id": appID},
+			expectedStatus: http.StatusBadRequest,
+			expectedErr:    "invalid email or password",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := postJSON(t, st, tt.path, tt.body)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Contains(t, body["message"], tt.expectedErr)
+		})
+	}
+}
+
+func postJSON(t *testing.T, st *suite.Suite, path string, payload map[string]any) (*http.Response, map[string]any) {
+	t.Helper()
+
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	resp, err := st.HTTPClient.Post(st.HTTPURL+path, "application/json", bytes.NewReader(data))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp, body
+}
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"gRPC/tests/suite"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImpersonation_AdminActsAsUser(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminEmail, pass := registerHTTP(t, st)
+	adminToken := loginHTTP(t, st, adminEmail, pass, "laptop")
+
+	admin, err := st.Storage.User(ctx, adminEmail)
+	require.NoError(t, err)
+	require.NoError(t, st.Storage.SetAdmin(ctx, admin.ID, true))
+
+	email, pass := registerHTTP(t, st)
+	userToken := loginHTTP(t, st, email, pass, "phone")
+
+	user, err := st.Storage.User(ctx, email)
+	require.NoError(t, err)
+
+	impersonate := func(token string, email string, reason string) (*http.Response, map[string]any) {
+		return doJSON(t, st, http.MethodPost, "/v1/users:impersonate", token, map[string]any{
+			"email":  email,
+			"app_id": appID,
+			"reason": reason,
+		})
+	}
+
+	resp, body := impersonate(adminToken, email, "ticket 42")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.LessOrEqual(t, body["expires_in"], float64(15*60))
+
+	token := body["token"].(string)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return []byte(suite.AppSecret), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, email, claims["email"])
+	assert.Equal(t, float64(admin.ID), claims["impersonator"])
+	assert.Equal(t, map[string]any{"sub": strconv.FormatInt(admin.ID, 10)}, claims["act"])
+
+	t.Run("detected by validation", func(t *testing.T) {
+		resp, body := doJSON(t, st, http.MethodPost, "/v1/tokens:validate", "", map[string]any{"token": token})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.Equal(t, float64(user.ID), body["user_id"])
+		assert.Equal(t, float64(admin.ID), body["impersonator_id"])
+
+		resp, body = doJSON(t, st, http.MethodPost, "/v1/tokens:validate", "", map[string]any{"token": userToken})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.NotContains(t, body, "impersonator_id")
+	})
+
+	t.Run("visible to the user", func(t *testing.T) {
+		resp, body := doJSON(t, st, http.MethodGet, "/v1/sessions", userToken, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+		sessions := body["sessions"].([]any)
+		require.Len(t, sessions, 2)
+
+		for _, s := range sessions {
+			session := s.(map[string]any)
+			if session["current"] == true {
+				assert.NotContains(t, session, "impersonator_id")
+			} else {
+				assert.Equal(t, float64(admin.ID), session["impersonator_id"])
+			}
+		}
+	})
+
+	t.Run("recorded in audit log", func(t *testing.T) {
+		resp, body := doJSON(t, st, http.MethodGet, "/v1/audit/events?type=impersonate", adminToken, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+		events := body["events"].([]any)
+		require.Len(t, events, 1)
+
+		event := events[0].(map[string]any)
+		assert.Equal(t, float64(user.ID), event["user_id"])
+		assert.Equal(t, float64(admin.ID), event["actor_id"])
+		assert.Equal(t, "ticket 42", event["reason"])
+	})
+
+	t.Run("admins may not be impersonated", func(t *testing.T) {
+		otherEmail, _ := registerHTTP(t, st)
+		other, err := st.Storage.User(ctx, otherEmail)
+		require.NoError(t, err)
+		require.NoError(t, st.Storage.SetAdmin(ctx, other.ID, true))
+
+		resp, _ := impersonate(adminToken, otherEmail, "ticket 43")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("only admins impersonate", func(t *testing.T) {
+		resp, _ := impersonate(userToken, adminEmail, "ticket 44")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp, _ = impersonate(token, email, "ticket 45")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("account may not be taken over", func(t *testing.T) {
+		resp, device := doJSON(t, st, http.MethodPost, "/v1/device/authorize", "", map[string]any{"client_id": appID})
+		require.Equal(t, http.StatusOK, resp.StatusCode, device)
+
+		resp, _ = doJSON(t, st, http.MethodPost, "/v1/device/approve", token, map[string]any{
+			"user_code": device["user_code"],
+			"approve":   true,
+		})
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		// impersonation sessions carry no auth time, step-up policy refuses them before the service does
+		resp, _ = doJSON(t, st, http.MethodPost, "/v1/sessions/revoke-others", token, nil)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp, _ = doJSON(t, st, http.MethodPost, "/v1/auth/password/change", token,
+			map[string]any{"current_password": pass, "new_password": randomFakePassword()})
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp, _ = doJSON(t, st, http.MethodGet, "/v1/sessions", userToken, nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("reason is required", func(t *testing.T) {
+		resp, _ := impersonate(adminToken, email, "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
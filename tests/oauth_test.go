@@ -0,0 +1,286 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gRPC/internal/lib/oauth"
+	"gRPC/tests/suite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const oauthRedirectURI = "https://client.example/callback"
+
+func TestOAuth_AuthorizationCodeFlow(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	require.NoError(t, st.Storage.UpdateAppOAuth(ctx, appID, []string{oauthRedirectURI}, []string{"profile", "email"}))
+
+	email, pass := registerHTTP(t, st)
+
+	verifier := strings.Repeat("v", 43)
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"1"},
+		"redirect_uri":          {oauthRedirectURI},
+		"scope":                 {"profile"},
+		"state":                 {"xyz"},
+		"code_challenge":        {oauth.Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	resp, err := st.HTTPClient.Get(st.HTTPURL + "/oauth/authorize?" + params.Encode())
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "DENY", resp.Header.Get("X-Frame-Options"))
+
+	// wrong password shows the form again
+	resp = postConsent(t, st, params, email, "wrong"+pass, "allow")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = postConsent(t, st, params, email, pass, "allow")
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "client.example", location.Host)
+	assert.Equal(t, "xyz", location.Query().Get("state"))
+
+	code := location.Query().Get("code")
+	require.NotEmpty(t, code)
+
+	exchange := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"1"},
+		"code":          {code},
+		"redirect_uri":  {oauthRedirectURI},
+		"code_verifier": {verifier},
+	}
+
+	resp, body := postToken(t, st, exchange)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	assert.Equal(t, "Bearer", body["token_type"])
+	assert.Equal(t, "profile", body["scope"])
+	assert.EqualValues(t, st.Cfg.TokenTTL.Seconds(), body["expires_in"])
+
+	// the token is good for what its scopes grant, not for managing the account
+	resp, _ = doJSON(t, st, http.MethodGet, "/v1/sessions", body["access_token"].(string), nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	t.Run("code is single use", func(t *testing.T) {
+		resp, body := postToken(t, st, exchange)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_grant", body["error"])
+	})
+}
+
+func TestOAuth_ScopedTokenOfAdmin(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	require.NoError(t, st.Storage.UpdateAppOAuth(ctx, appID, []string{oauthRedirectURI}, []string{"profile", "email"}))
+
+	adminEmail, pass := registerHTTP(t, st)
+	admin, err := st.Storage.User(ctx, adminEmail)
+	require.NoError(t, err)
+	require.NoError(t, st.Storage.SetAdmin(ctx, admin.ID, true))
+
+	email, _ := registerHTTP(t, st)
+
+	verifier := strings.Repeat("v", 43)
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"1"},
+		"redirect_uri":          {oauthRedirectURI},
+		"scope":                 {"profile"},
+		"code_challenge":        {oauth.Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	resp, body := postToken(t, st, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"1"},
+		"code":          {oauthCode(t, st, params, adminEmail, pass)},
+		"redirect_uri":  {oauthRedirectURI},
+		"code_verifier": {verifier},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	token := body["access_token"].(string)
+
+	resp, _ = doJSON(t, st, http.MethodPost, "/v1/users:impersonate", token, map[string]any{
+		"email":  email,
+		"app_id": appID,
+		"reason": "ticket 42",
+	})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, _ = doJSON(t, st, http.MethodGet, "/v1/audit/events", token, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, _ = doJSON(t, st, http.MethodGet, "/v1/users/"+strconv.FormatInt(admin.ID, 10)+"/sessions", token, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, _ = doJSON(t, st, http.MethodPost, "/v1/auth/password/change", token,
+		map[string]any{"current_password": pass, "new_password": randomFakePassword()})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestOAuth_Errors(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	require.NoError(t, st.Storage.UpdateAppOAuth(ctx, appID, []string{oauthRedirectURI}, []string{"profile"}))
+
+	email, pass := registerHTTP(t, st)
+	verifier := strings.Repeat("w", 64)
+
+	valid := func() url.Values {
+		return url.Values{
+			"response_type":         {"code"},
+			"client_id":             {"1"},
+			"redirect_uri":          {oauthRedirectURI},
+			"code_challenge":        {oauth.Challenge(verifier)},
+			"code_challenge_method": {"S256"},
+		}
+	}
+
+	t.Run("unregistered redirect uri is not followed", func(t *testing.T) {
+		params := valid()
+		params.Set("redirect_uri", "https://evil.example/callback")
+
+		resp := getAuthorize(t, st, params)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Location"))
+	})
+
+	tests := []struct {
+		name          string
+		modify        func(params url.Values)
+		expectedError string
+	}{
+		{
+			name:          "Plain PKCE",
+			modify:        func(params url.Values) { params.Set("code_challenge_method", "plain") },
+			expectedError: "invalid_request",
+		},
+		{
+			name:          "No PKCE",
+			modify:        func(params url.Values) { params.Del("code_challenge") },
+			expectedError: "invalid_request",
+		},
+		{
+			name:          "Scope not allowed for app",
+			modify:        func(params url.Values) { params.Set("scope", "admin") },
+			expectedError: "invalid_scope",
+		},
+		{
+			name:          "Implicit flow",
+			modify:        func(params url.Values) { params.Set("response_type", "token") },
+			expectedError: "unsupported_response_type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := valid()
+			tt.modify(params)
+
+			resp := getAuthorize(t, st, params)
+			require.Equal(t, http.StatusFound, resp.StatusCode)
+
+			location, err := url.Parse(resp.Header.Get("Location"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedError, location.Query().Get("error"))
+		})
+	}
+
+	t.Run("denied consent", func(t *testing.T) {
+		resp := postConsent(t, st, valid(), email, pass, "deny")
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Location"), "error=access_denied")
+	})
+
+	t.Run("wrong verifier", func(t *testing.T) {
+		resp := postConsent(t, st, valid(), email, pass, "allow")
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+
+		location, err := url.Parse(resp.Header.Get("Location"))
+		require.NoError(t, err)
+
+		resp, body := postToken(t, st, url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {"1"},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {oauthRedirectURI},
+			"code_verifier": {strings.Repeat("x", 64)},
+		})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_grant", body["error"])
+	})
+
+	t.Run("wrong client secret", func(t *testing.T) {
+		resp, body := postToken(t, st, url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {"1"},
+			"client_secret": {"wrong"},
+			"code":          {"code"},
+			"code_verifier": {verifier},
+		})
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, "invalid_client", body["error"])
+	})
+}
+
+// noRedirects returns client of the suite which does not follow redirects
+func noRedirects(st *suite.Suite) *http.Client {
+	client := *st.HTTPClient
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &client
+}
+
+func getAuthorize(t *testing.T, st *suite.Suite, params url.Values) *http.Response {
+	t.Helper()
+
+	resp, err := noRedirects(st).Get(st.HTTPURL + "/oauth/authorize?" + params.Encode())
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	return resp
+}
+
+func postConsent(t *testing.T, st *suite.Suite, params url.Values, email string, pass string, decision string) *http.Response {
+	t.Helper()
+
+	form := url.Values{"email": {email}, "password": {pass}, "decision": {decision}}
+	for name, values := range params {
+		form[name] = values
+	}
+
+	resp, err := noRedirects(st).PostForm(st.HTTPURL+"/oauth/authorize", form)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	return resp
+}
+
+func postToken(t *testing.T, st *suite.Suite, form url.Values) (*http.Response, map[string]any) {
+	t.Helper()
+
+	resp, err := st.HTTPClient.PostForm(st.HTTPURL+"/oauth/token", form)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp, body
+}
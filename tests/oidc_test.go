@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gRPC/internal/lib/keys"
+	"gRPC/internal/lib/oauth"
+	"gRPC/tests/suite"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDC_LoginWithIDToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	key, err := keys.Generate(time.Now())
+	require.NoError(t, err)
+	require.NoError(t, st.Storage.SaveSigningKey(ctx, key))
+
+	require.NoError(t, st.Storage.UpdateAppOAuth(ctx, appID, []string{oauthRedirectURI}, []string{"openid", "profile", "email"}))
+
+	const clientSecret = "client-secret-of-confidential-app"
+	require.NoError(t, st.Storage.UpdateAppClientSecret(ctx, appID, oauth.HashClientSecret(clientSecret)))
+
+	var discovery map[string]any
+	getJSON(t, st, "/.well-known/openid-configuration", &discovery)
+	assert.Equal(t, st.HTTPURL, discovery["issuer"])
+	assert.Equal(t, st.HTTPURL+"/oauth/token", discovery["token_endpoint"])
+	assert.Contains(t, discovery["scopes_supported"], "openid")
+
+	email, pass := registerHTTP(t, st)
+	user, err := st.Storage.User(ctx, email)
+	require.NoError(t, err)
+
+	verifier := strings.Repeat("n", 50)
+	code := oauthCode(t, st, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"1"},
+		"redirect_uri":          {oauthRedirectURI},
+		"scope":                 {"openid email"},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+		"code_challenge":        {oauth.Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}, email, pass)
+
+	exchange := func(secret string) (*http.Response, map[string]any) {
+		return postToken(t, st, url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {"1"},
+			"client_secret": {secret},
+			"code":          {code},
+			"redirect_uri":  {oauthRedirectURI},
+			"code_verifier": {verifier},
+		})
+	}
+
+	// confidential client may neither skip its secret nor authenticate with the token signing key
+	for _, secret := range []string{"", suite.AppSecret} {
+		resp, body := exchange(secret)
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode, body)
+		assert.Equal(t, "invalid_client", body["error"])
+	}
+
+	resp, body := exchange(clientSecret)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	idToken, ok := body["id_token"].(string)
+	require.True(t, ok, body)
+
+	var jwks struct {
+		Keys []keys.JWK `json:"keys"`
+	}
+	getJSON(t, st, discovery["jwks_uri"].(string)[len(st.HTTPURL):], &jwks)
+	require.Len(t, jwks.Keys, 1)
+
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (any, error) {
+		assert.Equal(t, "RS256", token.Header["alg"])
+		assert.Equal(t, jwks.Keys[0].KeyID, token.Header["kid"])
+
+		return publicKey(t, jwks.Keys[0]), nil
+	})
+	require.NoError(t, err)
+
+	claims := token.Claims.(jwt.MapClaims)
+	assert.Equal(t, st.HTTPURL, claims["iss"])
+	assert.Equal(t, "1", claims["aud"])
+	assert.Equal(t, strconv.FormatInt(user.ID, 10), claims["sub"])
+	assert.Equal(t, "n-0S6_WzA2Mj", claims["nonce"])
+	assert.Equal(t, email, claims["email"])
+	assert.Equal(t, false, claims["email_verified"])
+	assert.InDelta(t, st.Clock.Now().Unix(), claims["auth_time"], 1)
+	assert.NotEmpty(t, claims["at_hash"])
+
+	accessToken := body["access_token"].(string)
+
+	t.Run("userinfo", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, st.HTTPURL+"/oauth/userinfo", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := st.HTTPClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var info map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+		assert.Equal(t, map[string]any{
+			"sub":            strconv.FormatInt(user.ID, 10),
+			"email":          email,
+			"email_verified": false,
+		}, info)
+	})
+
+	t.Run("gateway userinfo", func(t *testing.T) {
+		resp, info := doJSON(t, st, http.MethodGet, "/v1/userinfo", accessToken, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, strconv.FormatInt(user.ID, 10), info["sub"])
+		assert.Equal(t, email, info["email"])
+	})
+
+	t.Run("userinfo needs openid scope", func(t *testing.T) {
+		verifier := strings.Repeat("m", 50)
+		code := oauthCode(t, st, url.Values{
+			"response_type":         {"code"},
+			"client_id":             {"1"},
+			"scope":                 {"profile"},
+			"code_challenge":        {oauth.Challenge(verifier)},
+			"code_challenge_method": {"S256"},
+		}, email, pass)
+
+		resp, body := postToken(t, st, url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {"1"},
+			"client_secret": {clientSecret},
+			"code":          {code},
+			"code_verifier": {verifier},
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.Nil(t, body["id_token"])
+
+		req, err := http.NewRequest(http.MethodGet, st.HTTPURL+"/oauth/userinfo", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+body["access_token"].(string))
+
+		infoResp, err := st.HTTPClient.Do(req)
+		require.NoError(t, err)
+		_ = infoResp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, infoResp.StatusCode)
+		assert.Contains(t, infoResp.Header.Get("WWW-Authenticate"), "insufficient_scope")
+	})
+}
+
+// oauthCode runs authorization request with consent of the user and returns the code
+func oauthCode(t *testing.T, st *suite.Suite, params url.Values, email string, pass string) string {
+	t.Helper()
+
+	resp := postConsent(t, st, params, email, pass, "allow")
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	code := location.Query().Get("code")
+	require.NotEmpty(t, code, location.String())
+
+	return code
+}
+
+func getJSON(t *testing.T, st *suite.Suite, path string, v any) {
+	t.Helper()
+
+	resp, err := st.HTTPClient.Get(st.HTTPURL + path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+}
+
+func publicKey(t *testing.T, jwk keys.JWK) *rsa.PublicKey {
+	t.Helper()
+
+	n, err := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+	require.NoError(t, err)
+	e, err := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+	require.NoError(t, err)
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gRPC/tests/suite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const passkeyOrigin = "http://localhost:3000"
+
+func TestPasskeys_RegisterAndLogin(t *testing.T) {
+	_, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+	token := loginHTTP(t, st, email, pass, "laptop")
+
+	authenticator := suite.NewAuthenticator(passkeyOrigin)
+
+	resp, body := doJSON(t, st, http.MethodPost, "/v1/passkeys/registration/begin", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	options := body["public_key"].(map[string]any)
+	assert.Empty(t, options["excludeCredentials"])
+
+	finish := authenticator.Create(t, options)
+	finish["name"] = "laptop"
+
+	resp, body = doJSON(t, st, http.MethodPost, "/v1/passkeys/registration/finish", token, finish)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	passkeyID := body["passkey_id"]
+	require.NotEmpty(t, passkeyID)
+
+	resp, _ = doJSON(t, st, http.MethodPost, "/v1/passkeys/registration/finish", token, finish)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "challenge must be single use")
+
+	resp, body = doJSON(t, st, http.MethodPost, "/v1/passkeys/registration/begin", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Len(t, body["public_key"].(map[string]any)["excludeCredentials"], 1)
+
+	beginLogin := func(payload map[string]any) (*http.Response, map[string]any) {
+		payload["app_id"] = appID
+		return postJSON(t, st, "/v1/auth/passkey/begin", payload)
+	}
+
+	login := func(authenticator *suite.Authenticator, payload map[string]any) (*http.Response, map[string]any) {
+		resp, body := beginLogin(payload)
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+		return postJSON(t, st, "/v1/auth/passkey/finish", authenticator.Get(t, body["public_key"].(map[string]any)))
+	}
+
+	t.Run("first factor", func(t *testing.T) {
+		resp, body := beginLogin(map[string]any{})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		options := body["public_key"].(map[string]any)
+		assert.Equal(t, "required", options["userVerification"])
+		assert.Empty(t, options["allowCredentials"])
+
+		assertion := authenticator.Get(t, options)
+
+		resp, body = postJSON(t, st, "/v1/auth/passkey/finish", assertion)
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		require.NotEmpty(t, body["token"])
+
+		resp, _ = doJSON(t, st, http.MethodGet, "/v1/sessions", body["token"].(string), nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, _ = postJSON(t, st, "/v1/auth/passkey/finish", assertion)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "assertion must not be replayed")
+	})
+
+	t.Run("second factor", func(t *testing.T) {
+		resp, _ := beginLogin(map[string]any{"email": email, "password": "wrong-password"})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, body := beginLogin(map[string]any{"email": email, "password": pass})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		options := body["public_key"].(map[string]any)
+		assert.Len(t, options["allowCredentials"], 1)
+
+		authenticator.UserVerified = false
+		defer func() { authenticator.UserVerified = true }()
+
+		resp, body = postJSON(t, st, "/v1/auth/passkey/finish", authenticator.Get(t, options))
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.NotEmpty(t, body["token"])
+
+		resp, _ = login(authenticator, map[string]any{})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "first factor requires user verification")
+	})
+
+	t.Run("user without passkeys", func(t *testing.T) {
+		email, pass := registerHTTP(t, st)
+
+		resp, _ := beginLogin(map[string]any{"email": email, "password": pass})
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("cloned authenticator", func(t *testing.T) {
+		clone := authenticator.Clone()
+
+		resp, body := login(authenticator, map[string]any{})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+		resp, _ = login(clone, map[string]any{})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "signature counter must grow")
+	})
+
+	t.Run("list and delete", func(t *testing.T) {
+		resp, body := doJSON(t, st, http.MethodGet, "/v1/passkeys", token, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		passkeys := body["passkeys"].([]any)
+		require.Len(t, passkeys, 1)
+		assert.Equal(t, "laptop", passkeys[0].(map[string]any)["name"])
+		assert.NotEqual(t, "0001-01-01T00:00:00Z", passkeys[0].(map[string]any)["last_used_at"])
+
+		otherEmail, otherPass := registerHTTP(t, st)
+		other := loginHTTP(t, st, otherEmail, otherPass, "phone")
+		path := fmt.Sprintf("/v1/passkeys/%v", passkeyID)
+
+		resp, _ = doJSON(t, st, http.MethodDelete, path, other, nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, _ = doJSON(t, st, http.MethodDelete, path, token, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, body = beginLogin(map[string]any{})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+		resp, _ = postJSON(t, st, "/v1/auth/passkey/finish", authenticator.Get(t, body["public_key"].(map[string]any)))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
@@ -0,0 +1,250 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"gRPC/tests/suite"
+
+	ssov5 "github.com/LeeAntonV/Protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPassword_RehashOnLogin(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, pass := gofakeit.Email(), randomFakePassword()
+
+	// account created before argon2id became the default
+	legacyHash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	_, err = st.Storage.SaveUser(ctx, email, legacyHash, []byte("code-hash"))
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.Login(ctx, &ssov5.LoginRequest{Email: email, Password: pass, AppId: appID})
+	require.NoError(t, err)
+
+	user, err := st.Storage.User(ctx, email)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(user.PassHash), "$argon2id$v=19$m=19456,t=2,p=1$"), string(user.PassHash))
+
+	_, err = st.AuthClient.Login(ctx, &ssov5.LoginRequest{Email: email, Password: pass, AppId: appID})
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.Login(ctx, &ssov5.LoginRequest{Email: email, Password: "wrong" + pass, AppId: appID})
+	require.Error(t, err)
+
+	rehashed, err := st.Storage.User(ctx, email)
+	require.NoError(t, err)
+	assert.Equal(t, user.PassHash, rehashed.PassHash)
+}
+
+func TestPassword_Policy(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := "Jane.Doe42@example.com"
+
+	tests := []struct {
+		name       string
+		password   string
+		violations []string
+	}{
+		{
+			name:       "Short and common",
+			password:   "letmein",
+			violations: []string{"must be at least 8 characters long", "must contain an uppercase letter", "must contain a digit", "is too easy to guess"},
+		},
+		{
+			name:       "Email",
+			password:   email,
+			violations: []string{"must not be the email address"},
+		},
+		{
+			name:       "Breached",
+			password:   "Winter2024!x",
+			violations: []string{"has appeared in a data breach"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := st.AuthClient.Register(ctx, &ssov5.RegisterRequest{Email: email, Password: tt.password})
+			require.Error(t, err)
+
+			s, ok := status.FromError(err)
+			require.True(t, ok)
+			assert.Equal(t, codes.InvalidArgument, s.Code())
+
+			var violations []string
+			for _, d := range s.Details() {
+				for _, v := range d.(*errdetails.BadRequest).GetFieldViolations() {
+					assert.Equal(t, "password", v.GetField())
+					violations = append(violations, v.GetDescription())
+				}
+			}
+			assert.Equal(t, tt.violations, violations)
+		})
+	}
+
+	resp, body := postJSON(t, st, "/v1/auth/register", map[string]any{"email": email, "password": "letmein"})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Len(t, body["field_violations"], 4)
+}
+
+func TestPassword_Change(t *testing.T) {
+	_, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+	laptop := loginHTTP(t, st, email, pass, "laptop")
+	phone := loginHTTP(t, st, email, pass, "phone")
+
+	newPass := randomFakePassword()
+
+	resp, _ := doJSON(t, st, http.MethodPost, "/v1/auth/password/change", laptop,
+		map[string]any{"current_password": "wrong" + pass, "new_password": newPass})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, body := doJSON(t, st, http.MethodPost, "/v1/auth/password/change", laptop,
+		map[string]any{"current_password": pass, "new_password": "Password1"})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "new_password", body["field_violations"].([]any)[0].(map[string]any)["field"])
+
+	resp, _ = doJSON(t, st, http.MethodPost, "/v1/auth/password/change", laptop,
+		map[string]any{"current_password": pass, "new_password": newPass})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = doJSON(t, st, http.MethodGet, "/v1/sessions", phone, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = doJSON(t, st, http.MethodGet, "/v1/sessions", laptop, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	loginHTTP(t, st, email, newPass, "laptop")
+}
+
+func TestPassword_Reset(t *testing.T) {
+	_, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+	laptop := loginHTTP(t, st, email, pass, "laptop")
+
+	resp, _ := postJSON(t, st, "/v1/auth/password/forgot", map[string]any{"email": gofakeit.Email()})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = postJSON(t, st, "/v1/auth/password/forgot", map[string]any{"email": email})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	msg, ok := st.Outbox.Last(email)
+	require.True(t, ok)
+	code := msg.Body
+
+	newPass := randomFakePassword()
+
+	resp, _ = postJSON(t, st, "/v1/auth/password/reset",
+		map[string]any{"email": email, "code": "000000" + code, "new_password": newPass})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, body := postJSON(t, st, "/v1/auth/password/reset",
+		map[string]any{"email": email, "code": code, "new_password": "Summer#Breach99"})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.NotEmpty(t, body["field_violations"])
+
+	resp, _ = postJSON(t, st, "/v1/auth/password/reset",
+		map[string]any{"email": email, "code": code, "new_password": newPass})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = doJSON(t, st, http.MethodGet, "/v1/sessions", laptop, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	loginHTTP(t, st, email, newPass, "laptop")
+
+	resp, _ = postJSON(t, st, "/v1/auth/password/reset",
+		map[string]any{"email": email, "code": code, "new_password": randomFakePassword()})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestPassword_History(t *testing.T) {
+	_, st := suite.New(t)
+
+	email, first := registerHTTP(t, st)
+	token := loginHTTP(t, st, email, first, "laptop")
+
+	second := randomFakePassword()
+
+	resp, _ := doJSON(t, st, http.MethodPost, "/v1/auth/password/change", token,
+		map[string]any{"current_password": first, "new_password": second})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	for _, reused := range []string{first, second} {
+		resp, body := doJSON(t, st, http.MethodPost, "/v1/auth/password/change", token,
+			map[string]any{"current_password": second, "new_password": reused})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		violation := body["field_violations"].([]any)[0].(map[string]any)
+		assert.Equal(t, "must differ from the last 3 passwords", violation["description"])
+	}
+
+	resp, _ = doJSON(t, st, http.MethodPost, "/v1/auth/password/change", token,
+		map[string]any{"current_password": second, "new_password": randomFakePassword()})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestPassword_Expiry(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	// passwords expire on this app only, see local_tests.yaml
+	expiringAppID, err := st.Storage.SaveApp(ctx, "expiring", "expiring-secret")
+	require.NoError(t, err)
+	require.Equal(t, 2, expiringAppID)
+
+	email, pass := registerHTTP(t, st)
+
+	login := func(appID int) (*http.Response, map[string]any) {
+		return postJSON(t, st, "/v1/auth/login", map[string]any{"email": email, "password": pass, "app_id": appID})
+	}
+
+	resp, _ := login(expiringAppID)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	st.Clock.Advance(st.Cfg.Password.Expiry.MaxAge)
+
+	resp, _ = login(appID)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = st.AuthClient.Login(ctx, &ssov5.LoginRequest{Email: email, Password: pass, AppId: int32(expiringAppID)})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	resp, body := login(expiringAppID)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "PASSWORD_CHANGE_REQUIRED", body["reason"])
+
+	changeToken := body["metadata"].(map[string]any)["change_token"].(string)
+	require.NotEmpty(t, changeToken)
+
+	resp, _ = doJSON(t, st, http.MethodGet, "/v1/sessions", changeToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	newPass := randomFakePassword()
+
+	resp, _ = doJSON(t, st, http.MethodPost, "/v1/auth/password/change", changeToken,
+		map[string]any{"current_password": pass, "new_password": newPass})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = doJSON(t, st, http.MethodPost, "/v1/auth/password/change", changeToken,
+		map[string]any{"current_password": newPass, "new_password": randomFakePassword()})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "change token must be single use")
+
+	pass = newPass
+
+	resp, body = login(expiringAppID)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, body["token"])
+}
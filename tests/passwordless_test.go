@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"gRPC/internal/lib/email"
+	"gRPC/tests/suite"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordless_LinkAndCode(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, _ := registerHTTP(t, st)
+
+	start := func(email string, method string) *http.Response {
+		return startPasswordless(t, st, appID, email, method)
+	}
+
+	complete := func(payload map[string]any) (*http.Response, map[string]any) {
+		payload["app_id"] = appID
+		return postJSON(t, st, "/v1/auth/passwordless/complete", payload)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, start(email, "link").StatusCode)
+
+	require.NoError(t, st.Storage.UpdateAppPasswordless(ctx, appID, true))
+
+	t.Run("link", func(t *testing.T) {
+		sent := len(mailsTo(st, email))
+		require.Equal(t, http.StatusOK, start(email, "link").StatusCode)
+
+		msg := awaitMail(t, st, email, sent)
+
+		link, err := url.Parse(msg.Body)
+		require.NoError(t, err)
+		token := link.Query().Get("token")
+		require.NotEmpty(t, token)
+
+		resp, body := complete(map[string]any{"token": token})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.NotEmpty(t, body["token"])
+
+		resp, _ = complete(map[string]any{"token": token})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("code", func(t *testing.T) {
+		sent := len(mailsTo(st, email))
+		require.Equal(t, http.StatusOK, start(email, "code").StatusCode)
+
+		msg := awaitMail(t, st, email, sent)
+
+		resp, _ := complete(map[string]any{"email": email, "code": "000000x"})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, body := complete(map[string]any{"email": email, "code": msg.Body})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+		resp, _ = doJSON(t, st, http.MethodGet, "/v1/sessions", body["token"].(string), nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, _ = complete(map[string]any{"email": email, "code": msg.Body})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		sent := len(mailsTo(st, email))
+		require.Equal(t, http.StatusOK, start(email, "code").StatusCode)
+
+		msg := awaitMail(t, st, email, sent)
+
+		st.Clock.Advance(st.Cfg.Passwordless.TTL)
+
+		resp, _ := complete(map[string]any{"email": email, "code": msg.Body})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("unknown email looks the same", func(t *testing.T) {
+		unknown := gofakeit.Email()
+
+		assert.Equal(t, http.StatusOK, start(unknown, "code").StatusCode)
+
+		assert.Never(t, func() bool { return len(mailsTo(st, unknown)) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+	})
+}
+
+func TestPasswordless_LinkOfAnotherApp(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, _ := registerHTTP(t, st)
+
+	otherAppID, err := st.Storage.SaveApp(ctx, "other-app", "other-app-secret")
+	require.NoError(t, err)
+
+	for _, id := range []int{appID, otherAppID} {
+		require.NoError(t, st.Storage.UpdateAppPasswordless(ctx, id, true))
+	}
+
+	sent := len(mailsTo(st, email))
+	require.Equal(t, http.StatusOK, startPasswordless(t, st, appID, email, "link").StatusCode)
+
+	link, err := url.Parse(awaitMail(t, st, email, sent).Body)
+	require.NoError(t, err)
+	token := link.Query().Get("token")
+
+	complete := func(appID int) int {
+		resp, _ := postJSON(t, st, "/v1/auth/passwordless/complete", map[string]any{"token": token, "app_id": appID})
+		return resp.StatusCode
+	}
+
+	// opening the link in another app does not use it up
+	assert.Equal(t, http.StatusBadRequest, complete(otherAppID))
+	assert.Equal(t, http.StatusOK, complete(appID))
+}
+
+func startPasswordless(t *testing.T, st *suite.Suite, appID int, email string, method string) *http.Response {
+	t.Helper()
+
+	resp, _ := postJSON(t, st, "/v1/auth/passwordless/start", map[string]any{
+		"email":  email,
+		"app_id": appID,
+		"method": method,
+	})
+
+	return resp
+}
+
+// mailsTo returns emails sent to address so far
+func mailsTo(st *suite.Suite, to string) []email.Message {
+	var msgs []email.Message
+	for _, msg := range st.Outbox.Messages() {
+		if msg.To == to {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	return msgs
+}
+
+// awaitMail waits for email sent to address after the first sent ones, login emails are sent in background
+func awaitMail(t *testing.T, st *suite.Suite, to string, sent int) email.Message {
+	t.Helper()
+
+	require.Eventually(t, func() bool { return len(mailsTo(st, to)) > sent }, time.Second, 10*time.Millisecond)
+
+	return mailsTo(st, to)[sent]
+}
@@ -0,0 +1,305 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gRPC/internal/lib/oauth"
+	"gRPC/internal/lib/sms"
+	"gRPC/tests/suite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhone_VerifyAndLogin(t *testing.T) {
+	_, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+	token := loginHTTP(t, st, email, pass, "laptop")
+
+	const phone = "+15550102030"
+
+	beginLogin := func(channel string) (*http.Response, map[string]any) {
+		return postJSON(t, st, "/v1/auth/sms/begin", map[string]any{
+			"app_id":   appID,
+			"email":    email,
+			"password": pass,
+			"channel":  channel,
+		})
+	}
+
+	resp, _ := beginLogin(sms.ChannelSMS)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "user has no phone yet")
+
+	resp, _ = doJSON(t, st, http.MethodPut, "/v1/phone", token, map[string]any{"phone": "555-0102"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = doJSON(t, st, http.MethodPut, "/v1/phone", token, map[string]any{"phone": phone, "channel": "pigeon"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, body := doJSON(t, st, http.MethodPut, "/v1/phone", token, map[string]any{"phone": "+1 (555) 010-2030"})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	resp, body = doJSON(t, st, http.MethodGet, "/v1/phone", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, phone, body["phone"])
+	assert.Equal(t, false, body["verified"])
+
+	resp, _ = beginLogin(sms.ChannelSMS)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "phone is not verified yet")
+
+	resp, _ = doJSON(t, st, http.MethodPost, "/v1/phone/verify", token, map[string]any{"code": "000000x"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	msg, ok := st.SMS.Last(phone)
+	require.True(t, ok)
+	assert.Equal(t, sms.ChannelSMS, msg.Channel)
+
+	resp, body = doJSON(t, st, http.MethodPost, "/v1/phone/verify", token, map[string]any{"code": smsCode(msg)})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, phone, body["phone"])
+	assert.Equal(t, true, body["verified"])
+
+	t.Run("login", func(t *testing.T) {
+		resp, body := beginLogin(sms.ChannelVoice)
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.Equal(t, sms.MaskPhone(phone), body["phone"])
+		assert.NotContains(t, body["phone"], "0102")
+		challengeID := body["challenge_id"]
+
+		msg, ok := st.SMS.Last(phone)
+		require.True(t, ok)
+		assert.Equal(t, sms.ChannelVoice, msg.Channel)
+
+		finish := func(code string) (*http.Response, map[string]any) {
+			return postJSON(t, st, "/v1/auth/sms/finish", map[string]any{"challenge_id": challengeID, "code": code})
+		}
+
+		resp, _ = finish("000000x")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, body = finish(smsCode(msg))
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		require.NotEmpty(t, body["token"])
+
+		resp, _ = doJSON(t, st, http.MethodGet, "/v1/sessions", body["token"].(string), nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, _ = finish(smsCode(msg))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "code must be single use")
+
+		resp, _ = postJSON(t, st, "/v1/auth/sms/begin", map[string]any{
+			"app_id":   appID,
+			"email":    email,
+			"password": "wrong-password",
+		})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("provider down", func(t *testing.T) {
+		st.SMS.SetDown(true)
+		defer st.SMS.SetDown(false)
+
+		resp, _ := beginLogin(sms.ChannelSMS)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		resp, body := doJSON(t, st, http.MethodDelete, "/v1/phone", token, nil)
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode, "deleting phone needs the second factor")
+		assert.Equal(t, "INSUFFICIENT_USER_AUTHENTICATION", body["reason"])
+
+		resp, body = doJSON(t, st, http.MethodPost, "/v1/auth/reauthenticate", token, map[string]any{
+			"password": pass,
+			"channel":  sms.ChannelSMS,
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		require.NotEmpty(t, body["challenge_id"])
+
+		msg, ok := st.SMS.Last(phone)
+		require.True(t, ok)
+
+		resp, _ = postJSON(t, st, "/v1/auth/sms/finish", map[string]any{"challenge_id": body["challenge_id"], "code": smsCode(msg)})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "reauthentication code does not log in")
+
+		resp, body = doJSON(t, st, http.MethodPost, "/v1/auth/reauthenticate", token, map[string]any{
+			"challenge_id": body["challenge_id"],
+			"code":         smsCode(msg),
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		token := body["token"].(string)
+
+		resp, _ = doJSON(t, st, http.MethodDelete, "/v1/phone", token, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, body = doJSON(t, st, http.MethodGet, "/v1/phone", token, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.Empty(t, body["phone"])
+
+		resp, _ = beginLogin(sms.ChannelSMS)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestPhone_RateLimitedPerNumber(t *testing.T) {
+	_, st := suite.New(t)
+
+	const phone = "+15550109999"
+
+	// codes sent to the number count whoever asks for them
+	for i := 0; i < 5; i++ {
+		email, pass := registerHTTP(t, st)
+		token := loginHTTP(t, st, email, pass, "laptop")
+
+		resp, body := doJSON(t, st, http.MethodPut, "/v1/phone", token, map[string]any{"phone": phone})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	}
+
+	email, pass := registerHTTP(t, st)
+	token := loginHTTP(t, st, email, pass, "laptop")
+
+	resp, _ := doJSON(t, st, http.MethodPut, "/v1/phone", token, map[string]any{"phone": phone})
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	resp, body := doJSON(t, st, http.MethodPut, "/v1/phone", token, map[string]any{"phone": "+15550108888"})
+	assert.Equal(t, http.StatusOK, resp.StatusCode, body)
+}
+
+func TestPhone_SecondFactor(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	require.NoError(t, st.Storage.UpdateAppOAuth(ctx, appID, []string{oauthRedirectURI}, []string{"invoices:read"}))
+
+	email, pass := registerHTTP(t, st)
+	token := loginHTTP(t, st, email, pass, "laptop")
+
+	const phone = "+15550104040"
+
+	resp, body := doJSON(t, st, http.MethodPut, "/v1/phone", token, map[string]any{"phone": phone})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	msg, ok := st.SMS.Last(phone)
+	require.True(t, ok)
+
+	resp, body = doJSON(t, st, http.MethodPost, "/v1/phone/verify", token, map[string]any{"code": smsCode(msg)})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	// challengeID checks that password alone got a challenge instead of a token
+	challengeID := func(t *testing.T, resp *http.Response, body map[string]any) any {
+		t.Helper()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+		assert.Nil(t, body["token"])
+		assert.Equal(t, "SECOND_FACTOR_REQUIRED", body["reason"])
+
+		metadata, _ := body["metadata"].(map[string]any)
+		require.NotEmpty(t, metadata["challenge_id"], body)
+		assert.Equal(t, sms.MaskPhone(phone), metadata["phone"])
+
+		return metadata["challenge_id"]
+	}
+
+	finish := func(t *testing.T, challengeID any) map[string]any {
+		t.Helper()
+
+		msg, ok := st.SMS.Last(phone)
+		require.True(t, ok)
+
+		resp, _ := postJSON(t, st, "/v1/auth/sms/finish", map[string]any{"challenge_id": challengeID, "code": "000000x"})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, body := postJSON(t, st, "/v1/auth/sms/finish", map[string]any{"challenge_id": challengeID, "code": smsCode(msg)})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		require.NotEmpty(t, body["token"])
+
+		return tokenClaims(t, body["token"].(string))
+	}
+
+	t.Run("login", func(t *testing.T) {
+		resp, body := postJSON(t, st, "/v1/auth/login", map[string]any{"email": email, "password": pass, "app_id": appID})
+		id := challengeID(t, resp, body)
+
+		claims := finish(t, id)
+		assert.Equal(t, []any{"pwd", "sms", "mfa"}, claims["amr"])
+	})
+
+	t.Run("scoped login", func(t *testing.T) {
+		resp, body := doJSON(t, st, http.MethodPost, "/v1/auth/login:scoped", "", map[string]any{
+			"email":    email,
+			"password": pass,
+			"app_id":   appID,
+			"scope":    "invoices:read",
+		})
+		id := challengeID(t, resp, body)
+
+		claims := finish(t, id)
+		assert.Equal(t, "invoices:read", claims["scope"])
+	})
+
+	t.Run("authorize", func(t *testing.T) {
+		verifier := strings.Repeat("v", 43)
+		params := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {"1"},
+			"redirect_uri":          {oauthRedirectURI},
+			"scope":                 {"invoices:read"},
+			"state":                 {"xyz"},
+			"code_challenge":        {oauth.Challenge(verifier)},
+			"code_challenge_method": {"S256"},
+		}
+
+		form := url.Values{"email": {email}, "password": {pass}, "decision": {"allow"}}
+		for name, values := range params {
+			form[name] = values
+		}
+
+		resp, err := noRedirects(st).PostForm(st.HTTPURL+"/oauth/authorize", form)
+		require.NoError(t, err)
+		page, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode, "password alone does not issue a code")
+
+		match := regexp.MustCompile(`name="challenge_id" value="([^"]+)"`).FindSubmatch(page)
+		require.NotNil(t, match, string(page))
+
+		msg, ok := st.SMS.Last(phone)
+		require.True(t, ok)
+
+		params.Set("challenge_id", string(match[1]))
+
+		params.Set("code", "000000x")
+		resp = postConsent(t, st, params, "", "", "allow")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		params.Set("code", smsCode(msg))
+		resp = postConsent(t, st, params, "", "", "allow")
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+
+		location, err := url.Parse(resp.Header.Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "xyz", location.Query().Get("state"))
+		require.NotEmpty(t, location.Query().Get("code"), location.String())
+
+		resp, body := postToken(t, st, url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {"1"},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {oauthRedirectURI},
+			"code_verifier": {verifier},
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.Equal(t, "invoices:read", body["scope"])
+	})
+}
+
+// smsCode returns one-time code ending the message
+func smsCode(msg sms.Message) string {
+	fields := strings.Fields(msg.Body)
+	return fields[len(fields)-1]
+}
@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"gRPC/tests/suite"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceAccounts_ClientCredentials(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminEmail, pass := registerHTTP(t, st)
+	token := loginHTTP(t, st, adminEmail, pass, "laptop")
+
+	admin, err := st.Storage.User(ctx, adminEmail)
+	require.NoError(t, err)
+	require.NoError(t, st.Storage.SetAdmin(ctx, admin.ID, true))
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	accountsPath := "/v1/apps/" + strconv.Itoa(appID) + "/service-accounts"
+
+	resp, body := doJSON(t, st, http.MethodPost, accountsPath, token, map[string]any{
+		"name":       "billing",
+		"scopes":     []string{"invoices:read", "invoices:write"},
+		"public_key": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	account := body["service_account"].(map[string]any)
+	clientID := account["client_id"].(string)
+	secret := body["client_secret"].(string)
+	assert.Equal(t, true, account["has_public_key"])
+
+	resp, _ = doJSON(t, st, http.MethodPost, accountsPath, token, map[string]any{"name": "billing"})
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp, body = doJSON(t, st, http.MethodGet, accountsPath, "", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, body)
+
+	t.Run("client secret", func(t *testing.T) {
+		resp, body := postToken(t, st, url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {clientID},
+			"client_secret": {secret},
+			"scope":         {"invoices:read"},
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.Equal(t, "invoices:read", body["scope"])
+
+		claims := jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(body["access_token"].(string), claims, func(*jwt.Token) (any, error) {
+			return []byte(suite.AppSecret), nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, clientID, claims["sub"])
+		assert.Equal(t, "invoices:read", claims["scope"])
+		assert.NotContains(t, claims, "uid")
+		assert.NotContains(t, claims, "sid")
+
+		// the token does not belong to a user session
+		resp, _ = doJSON(t, st, http.MethodGet, "/v1/sessions", body["access_token"].(string), nil)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("scope not granted to account", func(t *testing.T) {
+		resp, body := postToken(t, st, url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {clientID},
+			"client_secret": {secret},
+			"scope":         {"admin"},
+		})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_scope", body["error"])
+	})
+
+	t.Run("private key assertion", func(t *testing.T) {
+		assertion := clientAssertion(t, st, key, clientID, "jti-1")
+		form := url.Values{
+			"grant_type":            {"client_credentials"},
+			"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+			"client_assertion":      {assertion},
+		}
+
+		resp, body := postToken(t, st, form)
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.Equal(t, "invoices:read invoices:write", body["scope"])
+
+		resp, body = postToken(t, st, form)
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, "invalid_client", body["error"])
+	})
+
+	issueToken := func(secret string) string {
+		resp, body := doJSON(t, st, http.MethodPost, "/v1/service-accounts/token", "", map[string]any{
+			"client_id":     clientID,
+			"client_secret": secret,
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		require.NotEmpty(t, body["access_token"])
+
+		return body["access_token"].(string)
+	}
+
+	validate := func(serviceToken string) int {
+		resp, _ := doJSON(t, st, http.MethodPost, "/v1/tokens:validate", "", map[string]any{"token": serviceToken})
+		return resp.StatusCode
+	}
+
+	oldToken := issueToken(secret)
+	require.Equal(t, http.StatusOK, validate(oldToken))
+
+	id := strconv.FormatInt(int64(account["id"].(float64)), 10)
+
+	st.Clock.Advance(time.Second)
+
+	resp, body = doJSON(t, st, http.MethodPost, "/v1/service-accounts/"+id+"/rotate", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	newSecret := body["client_secret"].(string)
+
+	assert.Equal(t, http.StatusUnauthorized, validate(oldToken), "rotation invalidates issued tokens")
+
+	newToken := issueToken(newSecret)
+	require.Equal(t, http.StatusOK, validate(newToken))
+
+	resp, _ = postToken(t, st, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {secret},
+	})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, body = doJSON(t, st, http.MethodPost, "/v1/service-accounts/"+id+"/disable", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	assert.Equal(t, http.StatusUnauthorized, validate(newToken), "disabling invalidates issued tokens")
+
+	resp, body = postToken(t, st, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {newSecret},
+	})
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "invalid_client", body["error"])
+
+	resp, body = doJSON(t, st, http.MethodGet, accountsPath, token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	accounts := body["service_accounts"].([]any)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, true, accounts[0].(map[string]any)["disabled"])
+}
+
+// clientAssertion returns private_key_jwt assertion of client signed by key
+func clientAssertion(t *testing.T, st *suite.Suite, key *rsa.PrivateKey, clientID string, jti string) string {
+	t.Helper()
+
+	now := st.Clock.Now()
+
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": clientID,
+		"sub": clientID,
+		"aud": st.HTTPURL + "/oauth/token",
+		"jti": jti,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Minute).Unix(),
+	}).SignedString(key)
+	require.NoError(t, err)
+
+	return assertion
+}
@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"gRPC/tests/suite"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions_ListAndRevoke(t *testing.T) {
+	_, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+
+	laptop := loginHTTP(t, st, email, pass, "laptop")
+	phone := loginHTTP(t, st, email, pass, "phone")
+
+	resp, body := doJSON(t, st, http.MethodGet, "/v1/sessions", laptop, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	sessions := body["sessions"].([]any)
+	require.Len(t, sessions, 2)
+
+	var laptopSessionID string
+	for _, s := range sessions {
+		session := s.(map[string]any)
+		if session["current"].(bool) {
+			assert.Equal(t, "laptop", session["device_name"])
+			laptopSessionID = session["session_id"].(string)
+		}
+	}
+	require.NotEmpty(t, laptopSessionID)
+
+	resp, body = doJSON(t, st, http.MethodPost, "/v1/sessions/revoke-others", laptop, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 1, body["revoked"])
+
+	resp, _ = doJSON(t, st, http.MethodGet, "/v1/sessions", phone, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = doJSON(t, st, http.MethodDelete, "/v1/sessions/"+laptopSessionID, laptop, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = doJSON(t, st, http.MethodGet, "/v1/sessions", laptop, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestSessions_Admin(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+	token := loginHTTP(t, st, email, pass, "laptop")
+
+	resp, _ := doJSON(t, st, http.MethodGet, "/v1/sessions", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	user, err := st.Storage.User(ctx, email)
+	require.NoError(t, err)
+
+	resp, _ = doJSON(t, st, http.MethodDelete, "/v1/users/1/sessions", token, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	require.NoError(t, st.Storage.SetAdmin(ctx, user.ID, true))
+
+	otherEmail, otherPass := registerHTTP(t, st)
+	loginHTTP(t, st, otherEmail, otherPass, "phone")
+
+	other, err := st.Storage.User(ctx, otherEmail)
+	require.NoError(t, err)
+
+	path := "/v1/users/" + strconv.FormatInt(other.ID, 10) + "/sessions"
+
+	resp, body := doJSON(t, st, http.MethodGet, path, token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, body["sessions"], 1)
+
+	resp, body = doJSON(t, st, http.MethodDelete, path, token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 1, body["revoked"])
+}
+
+func registerHTTP(t *testing.T, st *suite.Suite) (string, string) {
+	t.Helper()
+
+	email, pass := gofakeit.Email(), randomFakePassword()
+
+	resp, _ := postJSON(t, st, "/v1/auth/register", map[string]any{"email": email, "password": pass})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	return email, pass
+}
+
+func loginHTTP(t *testing.T, st *suite.Suite, email string, pass string, device string) string {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{"email": email, "password": pass, "app_id": appID})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, st.HTTPURL+"/v1/auth/login", bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header.Set("X-Device-Name", device)
+
+	resp, err := st.HTTPClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return body.Token
+}
+
+func doJSON(t *testing.T, st *suite.Suite, method string, path string, token string, payload map[string]any) (*http.Response, map[string]any) {
+	t.Helper()
+
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(method, st.HTTPURL+path, bytes.NewReader(data))
+	require.NoError(t, err)
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := st.HTTPClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp, body
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"gRPC/internal/config"
+	authgrpc "gRPC/internal/grpc/auth"
+	"gRPC/tests/suite"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepUp_MaxAgeAndReauthenticate(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+	laptop := loginHTTP(t, st, email, pass, "laptop")
+	loginHTTP(t, st, email, pass, "phone")
+
+	claims := tokenClaims(t, laptop)
+	assert.Equal(t, []any{"pwd"}, claims["amr"])
+	assert.Equal(t, "aal1", claims["acr"])
+	assert.Equal(t, float64(st.Clock.Now().Unix()), claims["auth_time"])
+
+	st.Clock.Advance(11 * time.Minute)
+
+	resp, body := doJSON(t, st, http.MethodPost, "/v1/sessions/revoke-others", laptop, nil)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode, body)
+	assert.Equal(t, "INSUFFICIENT_USER_AUTHENTICATION", body["reason"])
+	assert.Equal(t, map[string]any{"max_age": "600"}, body["metadata"])
+
+	resp, _ = doJSON(t, st, http.MethodGet, "/v1/sessions", laptop, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "other methods do not need recent authentication")
+
+	// the app secret signing tokens is known to the app, claims do not raise the session
+	forged := forgeToken(t, laptop, jwt.MapClaims{"auth_time": st.Clock.Now().Unix()})
+	resp, _ = doJSON(t, st, http.MethodPost, "/v1/sessions/revoke-others", forged, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	user, err := st.Storage.User(ctx, email)
+	require.NoError(t, err)
+	require.NoError(t, st.Storage.SetAdmin(ctx, user.ID, true))
+
+	resp, _ = doJSON(t, st, http.MethodGet, "/v1/audit/events:export", laptop, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "streams follow the policy as well")
+
+	resp, _ = doJSON(t, st, http.MethodPost, "/v1/auth/reauthenticate", laptop, map[string]any{"password": "wrong-password"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, body = doJSON(t, st, http.MethodPost, "/v1/auth/reauthenticate", laptop, map[string]any{"password": pass})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	fresh := body["token"].(string)
+
+	freshClaims := tokenClaims(t, fresh)
+	assert.Equal(t, claims["sid"], freshClaims["sid"], "the session stays the same")
+	assert.Equal(t, float64(st.Clock.Now().Unix()), freshClaims["auth_time"])
+	assert.Equal(t, []any{"pwd"}, freshClaims["amr"])
+
+	resp, body = doJSON(t, st, http.MethodPost, "/v1/sessions/revoke-others", fresh, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.EqualValues(t, 1, body["revoked"])
+
+	resp, _ = doJSON(t, st, http.MethodGet, "/v1/audit/events:export", fresh, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	t.Run("second factor is required", func(t *testing.T) {
+		resp, body := doJSON(t, st, http.MethodDelete, "/v1/phone", fresh, nil)
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode, body)
+		assert.Equal(t, map[string]any{"max_age": "600", "acr_values": "aal2"}, body["metadata"])
+
+		forged := forgeToken(t, fresh, jwt.MapClaims{"acr": "aal2", "amr": []string{"pwd", "sms", "mfa"}})
+		resp, _ = doJSON(t, st, http.MethodDelete, "/v1/phone", forged, nil)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp, _ = doJSON(t, st, http.MethodPost, "/v1/auth/reauthenticate", fresh, map[string]any{
+			"password": pass,
+			"channel":  "sms",
+		})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "user has no verified phone")
+	})
+
+	t.Run("access token is required", func(t *testing.T) {
+		resp, _ := doJSON(t, st, http.MethodPost, "/v1/auth/reauthenticate", "", map[string]any{"password": pass})
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+// tokenClaims returns claims of access token issued by the server, times are not checked
+// since the server clock is fake
+func TestStepUp_LocalConfigCoversSensitiveMethods(t *testing.T) {
+	cfg := config.MustLoadPath("../config/local.yaml")
+
+	// admin RPCs and the ones changing credentials of the caller
+	methods := []string{
+		"Impersonate",
+		"CreateServiceAccount",
+		"RotateServiceAccountSecret",
+		"DisableServiceAccount",
+		"ChangePassword",
+		"RevokeOtherSessions",
+		"RevokeUserSession",
+		"RevokeUserSessions",
+		"CreateWebhook",
+		"DeleteWebhook",
+		"RedeliverWebhook",
+		"ExportAuditEvents",
+		"VerifyAuditChain",
+		"BeginPasskeyRegistration",
+		"DeletePasskey",
+		"StartPhoneVerification",
+		"DeletePhone",
+	}
+
+	for _, method := range methods {
+		rule, ok := cfg.StepUp.Methods["/"+authgrpc.ExtensionsService+"/"+method]
+		if assert.True(t, ok, method) {
+			assert.NotZero(t, rule.MaxAge, method)
+		}
+	}
+}
+
+func tokenClaims(t *testing.T, token string) jwt.MapClaims {
+	t.Helper()
+
+	parser := jwt.Parser{SkipClaimsValidation: true}
+
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return []byte(suite.AppSecret), nil
+	})
+	require.NoError(t, err)
+
+	return claims
+}
+
+// forgeToken returns token with claims of token replaced by the given ones,
+// signed with the app secret the way the app itself could do it
+func forgeToken(t *testing.T, token string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	forged := tokenClaims(t, token)
+	for k, v := range claims {
+		forged[k] = v
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, forged).SignedString([]byte(suite.AppSecret))
+	require.NoError(t, err)
+
+	return signed
+}
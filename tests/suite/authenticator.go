@@ -0,0 +1,253 @@
+package suite
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// Authenticator is software WebAuthn authenticator holding ES256 passkeys.
+// It answers public_key options of passkey RPCs with request fields of their
+// finishing RPCs, the way browsers and platform authenticators do.
+type Authenticator struct {
+	// Origin is the page the ceremonies run on
+	Origin string
+	// UserVerified tells whether the authenticator verifies user, e.g. by PIN
+	UserVerified bool
+
+	mu          sync.Mutex
+	credentials []*softCredential
+}
+
+type softCredential struct {
+	id         []byte
+	key        *ecdsa.PrivateKey
+	rpID       string
+	userHandle []byte
+	signCount  uint32
+}
+
+// NewAuthenticator returns authenticator verifying users, used on origin
+func NewAuthenticator(origin string) *Authenticator {
+	return &Authenticator{Origin: origin, UserVerified: true}
+}
+
+// Create answers options of BeginPasskeyRegistration with new passkey
+func (a *Authenticator) Create(t *testing.T, options map[string]any) map[string]any {
+	t.Helper()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rp := options["rp"].(map[string]any)
+	user := options["user"].(map[string]any)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate passkey: %v", err)
+	}
+
+	credential := &softCredential{
+		id:         randomBytes(t, 16),
+		key:        key,
+		rpID:       rp["id"].(string),
+		userHandle: decode(t, user["id"].(string)),
+	}
+	a.credentials = append(a.credentials, credential)
+
+	x, y := make([]byte, 32), make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+
+	coseKey := cborMap(5)
+	coseKey = append(coseKey, cborInt(1)...)
+	coseKey = append(coseKey, cborInt(2)...)
+	coseKey = append(coseKey, cborInt(3)...)
+	coseKey = append(coseKey, cborInt(-7)...)
+	coseKey = append(coseKey, cborInt(-1)...)
+	coseKey = append(coseKey, cborInt(1)...)
+	coseKey = append(coseKey, cborInt(-2)...)
+	coseKey = append(coseKey, cborBytes(x)...)
+	coseKey = append(coseKey, cborInt(-3)...)
+	coseKey = append(coseKey, cborBytes(y)...)
+
+	authData := a.authData(credential, 0x40)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(credential.id)))
+	authData = append(authData, credential.id...)
+	authData = append(authData, coseKey...)
+
+	attestation := cborMap(3)
+	attestation = append(attestation, cborText("fmt")...)
+	attestation = append(attestation, cborText("none")...)
+	attestation = append(attestation, cborText("attStmt")...)
+	attestation = append(attestation, cborMap(0)...)
+	attestation = append(attestation, cborText("authData")...)
+	attestation = append(attestation, cborBytes(authData)...)
+
+	return map[string]any{
+		"client_data_json":   encode(a.clientData(t, "webauthn.create", options["challenge"].(string))),
+		"attestation_object": encode(attestation),
+	}
+}
+
+// Get answers options of BeginPasskeyLogin with assertion of allowed passkey,
+// or of any passkey of the relying party when none are listed
+func (a *Authenticator) Get(t *testing.T, options map[string]any) map[string]any {
+	t.Helper()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rpID := options["rpId"].(string)
+
+	var allowed [][]byte
+	for _, descriptor := range options["allowCredentials"].([]any) {
+		allowed = append(allowed, decode(t, descriptor.(map[string]any)["id"].(string)))
+	}
+
+	var credential *softCredential
+	for _, c := range a.credentials {
+		if c.rpID != rpID {
+			continue
+		}
+
+		if len(allowed) == 0 || slices.ContainsFunc(allowed, func(id []byte) bool { return string(id) == string(c.id) }) {
+			credential = c
+			break
+		}
+	}
+
+	if credential == nil {
+		t.Fatalf("authenticator has no passkey for %s", rpID)
+	}
+
+	credential.signCount++
+
+	authData := a.authData(credential, 0)
+	clientData := a.clientData(t, "webauthn.get", options["challenge"].(string))
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(slices.Clone(authData), clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, credential.key, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign assertion: %v", err)
+	}
+
+	return map[string]any{
+		"credential_id":      encode(credential.id),
+		"client_data_json":   encode(clientData),
+		"authenticator_data": encode(authData),
+		"signature":          encode(signature),
+		"user_handle":        encode(credential.userHandle),
+	}
+}
+
+// Clone returns authenticator holding copies of the passkeys, with the same signature counters
+func (a *Authenticator) Clone() *Authenticator {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	clone := &Authenticator{Origin: a.Origin, UserVerified: a.UserVerified}
+	for _, c := range a.credentials {
+		copied := *c
+		clone.credentials = append(clone.credentials, &copied)
+	}
+
+	return clone
+}
+
+func (a *Authenticator) authData(credential *softCredential, flags byte) []byte {
+	// user is always present
+	flags |= 0x01
+	if a.UserVerified {
+		flags |= 0x04
+	}
+
+	rpIDHash := sha256.Sum256([]byte(credential.rpID))
+
+	authData := append(rpIDHash[:], flags)
+
+	return binary.BigEndian.AppendUint32(authData, credential.signCount)
+}
+
+func (a *Authenticator) clientData(t *testing.T, ceremony string, challenge string) []byte {
+	t.Helper()
+
+	clientData, err := json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		t.Fatalf("failed to encode client data: %v", err)
+	}
+
+	return clientData
+}
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	default:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	}
+}
+
+func cborInt(n int) []byte {
+	if n < 0 {
+		return cborHead(1, uint64(-1-n))
+	}
+
+	return cborHead(0, uint64(n))
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, uint64(len(b))), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, uint64(len(s))), s...)
+}
+
+func cborMap(pairs int) []byte {
+	return cborHead(5, uint64(pairs))
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("failed to read random bytes: %v", err)
+	}
+
+	return b
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid base64url %q: %v", s, err)
+	}
+
+	return b
+}
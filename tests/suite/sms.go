@@ -0,0 +1,85 @@
+package suite
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"gRPC/internal/lib/sms"
+)
+
+const (
+	// SMSToken and SMSFrom are what the server sends SMS provider with every message
+	SMSToken = "test-sms-token"
+	SMSFrom  = "SSO"
+)
+
+// SMSProvider is fake SMS provider the server sends messages to with its http driver
+type SMSProvider struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	messages []sms.Message
+	down     bool
+}
+
+func newSMSProvider(t *testing.T) *SMSProvider {
+	t.Helper()
+
+	p := &SMSProvider{}
+	p.Server = httptest.NewServer(http.HandlerFunc(p.receive))
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func (p *SMSProvider) receive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer "+SMSToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var msg struct {
+		sms.Message
+		From string `json:"from"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || msg.From != SMSFrom {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	p.messages = append(p.messages, msg.Message)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// SetDown makes the provider reject messages until it is set up again
+func (p *SMSProvider) SetDown(down bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.down = down
+}
+
+// Last returns the latest message sent to phone
+func (p *SMSProvider) Last(to string) (sms.Message, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := len(p.messages) - 1; i >= 0; i-- {
+		if p.messages[i].To == to {
+			return p.messages[i], true
+		}
+	}
+
+	return sms.Message{}, false
+}
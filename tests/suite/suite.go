@@ -2,33 +2,66 @@ package suite
 
 import (
 	"context"
+	"io"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
-	"strconv"
 	"testing"
+	"time"
 
+	"gRPC/internal/app"
 	"gRPC/internal/config"
+	"gRPC/internal/lib/clock"
+	"gRPC/internal/lib/email"
+	"gRPC/internal/lib/sms"
+	"gRPC/internal/storage/driver"
+	"gRPC/internal/storage/memory"
 
 	ssov5 "github.com/LeeAntonV/Protos/gen/go/sso"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 type Suite struct {
 	*testing.T
 	Cfg        *config.Config
 	AuthClient ssov5.AuthClient
+	// Conn is the gRPC connection AuthClient uses, it also calls the extensions service
+	Conn       *grpc.ClientConn
+	HTTPClient *http.Client
+	HTTPURL    string
+
+	// Clock is used by the server for token timestamps
+	Clock *clock.Fake
+	// Outbox captures emails sent by the server
+	Outbox *email.Outbox
+	// SMS receives text and voice messages sent by the server
+	SMS *SMSProvider
+	// Storage is the in-memory storage the server works with
+	Storage *memory.Storage
 }
 
 const (
-	grpcHost = "localhost"
+	// AppName and AppSecret describe app seeded into storage, it gets id 1
+	AppName   = "test"
+	AppSecret = "test-secret"
+
+	bufSize = 1024 * 1024
 )
 
+// New boots the whole application in-process with in-memory storage,
+// so tests need neither database nor network access.
 func New(t *testing.T) (context.Context, *Suite) {
 	t.Helper()
 	t.Parallel()
 
 	cfg := config.MustLoadPath(configPath())
+	cfg.StorageDriver = driver.Memory
+	cfg.AutoMigrate = false
+	cfg.KV.Driver = config.KVDriverMemory
+	cfg.Webhooks.PollInterval = 100 * time.Millisecond
 
 	ctx, cancelCtx := context.WithTimeout(context.Background(), cfg.GRPC.Timeout)
 
@@ -37,17 +70,74 @@ func New(t *testing.T) (context.Context, *Suite) {
 		cancelCtx()
 	})
 
-	cc, err := grpc.DialContext(context.Background(),
-		grpcAddress(cfg),
+	storage := memory.New()
+	if _, err := storage.SaveApp(ctx, AppName, AppSecret); err != nil {
+		t.Fatalf("failed to seed app: %v", err)
+	}
+
+	clk := clock.NewFake(time.Now())
+	outbox := &email.Outbox{}
+	smsProvider := newSMSProvider(t)
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	httpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	// OpenID Connect clients expect issuer to match the URL they talk to
+	cfg.OIDC.Issuer = "http://" + httpListener.Addr().String()
+
+	application := app.New(log, cfg,
+		app.WithStorage(storage),
+		app.WithClock(clk),
+		app.WithEmailSender(outbox),
+		app.WithSMSSender(sms.NewHTTPSender(smsProvider.URL, SMSToken, SMSFrom, cfg.SMS.Timeout)),
+	)
+
+	grpcListener := bufconn.Listen(bufSize)
+	go func() {
+		_ = application.GRPCServer.Serve(grpcListener)
+	}()
+
+	go func() {
+		_ = application.HTTPServer.Serve(httpListener)
+	}()
+
+	go application.WebhookDispatcher.Run()
+
+	t.Cleanup(func() {
+		application.EventBus.Close()
+		application.HTTPServer.Stop()
+		application.GRPCServer.Stop()
+		application.WebhookDispatcher.Stop()
+	})
+
+	cc, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return grpcListener.DialContext(ctx)
+		}),
 		grpc.WithTransportCredentials(insecure.NewCredentials())) // Используем insecure-коннект для тестов
 	if err != nil {
 		t.Fatalf("grpc server connection failed: %v", err)
 	}
 
+	t.Cleanup(func() {
+		_ = cc.Close()
+	})
+
 	return ctx, &Suite{
 		T:          t,
 		Cfg:        cfg,
 		AuthClient: ssov5.NewAuthClient(cc),
+		Conn:       cc,
+		HTTPClient: &http.Client{Timeout: cfg.HTTP.Timeout},
+		HTTPURL:    cfg.OIDC.Issuer,
+		Clock:      clk,
+		Outbox:     outbox,
+		SMS:        smsProvider,
+		Storage:    storage,
 	}
 }
 
@@ -60,7 +150,3 @@ func configPath() string {
 
 	return "../config/local_tests.yaml"
 }
-
-func grpcAddress(cfg *config.Config) string {
-	return net.JoinHostPort(grpcHost, strconv.Itoa(cfg.GRPC.Port))
-}
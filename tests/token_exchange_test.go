@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"gRPC/internal/domain/models"
+	"gRPC/tests/suite"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	tokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+func TestTokenExchange_OnBehalfOfUser(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	require.NoError(t, st.Storage.UpdateAppOAuth(ctx, appID, nil, []string{"invoices:read", "invoices:write"}))
+	require.NoError(t, st.Storage.UpdateAppAudiences(ctx, appID, []string{"gateway", billingAudience}))
+
+	adminEmail, pass := registerHTTP(t, st)
+	adminToken := loginHTTP(t, st, adminEmail, pass, "laptop")
+
+	admin, err := st.Storage.User(ctx, adminEmail)
+	require.NoError(t, err)
+	require.NoError(t, st.Storage.SetAdmin(ctx, admin.ID, true))
+
+	resp, body := doJSON(t, st, http.MethodPost, "/v1/apps/"+strconv.Itoa(appID)+"/service-accounts", adminToken,
+		map[string]any{"name": "gateway"})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	clientID := body["service_account"].(map[string]any)["client_id"].(string)
+	secret := body["client_secret"].(string)
+
+	_, err = st.Storage.SaveExchangePolicy(ctx, models.ExchangePolicy{
+		AppID:    appID,
+		Actor:    clientID,
+		Audience: billingAudience,
+		Scopes:   []string{"invoices:read"},
+	})
+	require.NoError(t, err)
+
+	email, pass := registerHTTP(t, st)
+
+	resp, body = doJSON(t, st, http.MethodPost, "/v1/auth/login:scoped", "", map[string]any{
+		"email":    email,
+		"password": pass,
+		"app_id":   appID,
+		"audience": "gateway",
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	subjectToken := body["token"].(string)
+
+	exchange := func(audience string, scope string) (*http.Response, map[string]any) {
+		return postToken(t, st, url.Values{
+			"grant_type":         {grantTypeTokenExchange},
+			"client_id":          {clientID},
+			"client_secret":      {secret},
+			"subject_token":      {subjectToken},
+			"subject_token_type": {tokenTypeAccessToken},
+			"audience":           {audience},
+			"scope":              {scope},
+		})
+	}
+
+	resp, body = exchange(billingAudience, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, tokenTypeAccessToken, body["issued_token_type"])
+	assert.Equal(t, "invoices:read", body["scope"])
+
+	exchanged := body["access_token"].(string)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(exchanged, claims, func(*jwt.Token) (any, error) {
+		return []byte(suite.AppSecret), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, billingAudience, claims["aud"])
+	assert.Equal(t, email, claims["email"])
+	assert.Equal(t, map[string]any{"sub": clientID}, claims["act"])
+
+	resp, body = doJSON(t, st, http.MethodPost, "/v1/tokens:validate", "", map[string]any{
+		"token":    exchanged,
+		"audience": billingAudience,
+		"scopes":   []string{"invoices:read"},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, []any{clientID}, body["actors"])
+
+	t.Run("not accepted by the SSO", func(t *testing.T) {
+		user, err := st.Storage.User(ctx, email)
+		require.NoError(t, err)
+		require.NoError(t, st.Storage.SetAdmin(ctx, user.ID, true))
+		defer func() { require.NoError(t, st.Storage.SetAdmin(ctx, user.ID, false)) }()
+
+		for _, path := range []string{"/v1/sessions", "/v1/audit/events"} {
+			resp, _ := doJSON(t, st, http.MethodGet, path, exchanged, nil)
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "exchanged token at %s", path)
+
+			resp, _ = doJSON(t, st, http.MethodGet, path, subjectToken, nil)
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode, "scoped token at %s", path)
+		}
+
+		resp, _ := doJSON(t, st, http.MethodPost, "/v1/users:impersonate", subjectToken, map[string]any{
+			"email":  adminEmail,
+			"app_id": appID,
+			"reason": "ticket 42",
+		})
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("audience without policy", func(t *testing.T) {
+		resp, body := exchange("gateway", "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_target", body["error"])
+	})
+
+	t.Run("scope beyond policy", func(t *testing.T) {
+		resp, body := exchange(billingAudience, "invoices:write")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_scope", body["error"])
+	})
+
+	t.Run("actor token", func(t *testing.T) {
+		resp, body := postToken(t, st, url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {clientID},
+			"client_secret": {secret},
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+		resp, body = doJSON(t, st, http.MethodPost, "/v1/tokens:exchange", "", map[string]any{
+			"subject_token": subjectToken,
+			"actor_token":   body["access_token"],
+			"audience":      billingAudience,
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.Equal(t, "invoices:read", body["scope"])
+	})
+
+	t.Run("ended session", func(t *testing.T) {
+		sid := claims["sid"].(string)
+
+		// scoped tokens do not manage sessions, the user does it with a first-party token
+		resp, body := doJSON(t, st, http.MethodDelete, "/v1/sessions/"+sid, loginHTTP(t, st, email, pass, "laptop"), nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+		resp, body = exchange(billingAudience, "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_request", body["error"])
+	})
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"gRPC/tests/suite"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const billingAudience = "https://billing.example.com"
+
+func TestTokens_ScopesAndAudience(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	require.NoError(t, st.Storage.UpdateAppOAuth(ctx, appID, nil, []string{"invoices:read", "invoices:write"}))
+	require.NoError(t, st.Storage.UpdateAppAudiences(ctx, appID, []string{billingAudience, "reports"}))
+
+	email, pass := registerHTTP(t, st)
+
+	resp, body := doJSON(t, st, http.MethodPost, "/v1/auth/login:scoped", "", map[string]any{
+		"email":    email,
+		"password": pass,
+		"app_id":   appID,
+		"scope":    "invoices:read",
+		"audience": billingAudience,
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, "invoices:read", body["scope"])
+
+	token := body["token"].(string)
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return []byte(suite.AppSecret), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, st.HTTPURL, claims["iss"])
+	assert.Equal(t, billingAudience, claims["aud"])
+	assert.Equal(t, "invoices:read", claims["scope"])
+	assert.NotEmpty(t, claims["jti"])
+	assert.Equal(t, claims["iat"], claims["nbf"])
+
+	validate := func(token string, audience string, scopes ...string) (*http.Response, map[string]any) {
+		return doJSON(t, st, http.MethodPost, "/v1/tokens:validate", "", map[string]any{
+			"token":    token,
+			"audience": audience,
+			"scopes":   scopes,
+		})
+	}
+
+	t.Run("valid for resource server", func(t *testing.T) {
+		resp, body := validate(token, billingAudience, "invoices:read")
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.Equal(t, claims["jti"], body["jti"])
+		assert.Equal(t, email, body["email"])
+		assert.Equal(t, []any{billingAudience}, body["audience"])
+	})
+
+	t.Run("missing scope", func(t *testing.T) {
+		resp, _ := validate(token, billingAudience, "invoices:write")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("another audience", func(t *testing.T) {
+		resp, _ := validate(token, "reports")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("first-party token has no scopes", func(t *testing.T) {
+		firstParty := loginHTTP(t, st, email, pass, "laptop")
+
+		resp, body := validate(firstParty, "reports")
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		assert.NotContains(t, body, "scope")
+		assert.ElementsMatch(t, []any{billingAudience, "reports"}, body["audience"])
+
+		resp, _ = validate(firstParty, "", "invoices:read")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("audience not allowed for app", func(t *testing.T) {
+		resp, _ := doJSON(t, st, http.MethodPost, "/v1/auth/login:scoped", "", map[string]any{
+			"email":    email,
+			"password": pass,
+			"app_id":   appID,
+			"audience": "https://evil.example.com",
+		})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	// expiration follows the clock of the service
+	st.Clock.Advance(st.Cfg.TokenTTL)
+
+	resp, _ = validate(token, billingAudience)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gRPC/internal/cli"
+	"gRPC/internal/services/webhooks"
+	"gRPC/tests/suite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func TestWebhooks_DeliverRetryAndRedeliver(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminEmail, pass := registerHTTP(t, st)
+	token := loginHTTP(t, st, adminEmail, pass, "laptop")
+
+	admin, err := st.Storage.User(ctx, adminEmail)
+	require.NoError(t, err)
+	require.NoError(t, st.Storage.SetAdmin(ctx, admin.ID, true))
+
+	received := make(chan webhookRequest, 10)
+	var status atomic.Int32
+	status.Store(http.StatusNoContent)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := int(status.Load())
+
+		body, _ := io.ReadAll(r.Body)
+		received <- webhookRequest{header: r.Header, body: body}
+
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(receiver.Close)
+
+	hooksPath := "/v1/apps/" + strconv.Itoa(appID) + "/webhooks"
+
+	resp, body := doJSON(t, st, http.MethodPost, hooksPath, token, map[string]any{
+		"url":    receiver.URL,
+		"events": []string{"user.registered", "no.such.event"},
+	})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, body = doJSON(t, st, http.MethodPost, hooksPath, token, map[string]any{
+		"url":    receiver.URL,
+		"events": []string{"user.registered"},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	secret := body["secret"].(string)
+	require.NotEmpty(t, secret)
+	webhookID := strconv.FormatInt(int64(body["webhook"].(map[string]any)["id"].(float64)), 10)
+
+	// test mode sends ping right away
+	resp, body = doJSON(t, st, http.MethodPost, "/v1/webhooks/"+webhookID+"/test", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "delivered", body["delivery"].(map[string]any)["status"])
+
+	ping := receiveWebhook(t, st, received, secret)
+	assert.Equal(t, "webhook.ping", ping["type"])
+
+	deliveries := func() []any {
+		t.Helper()
+
+		resp, body := doJSON(t, st, http.MethodGet, "/v1/webhooks/"+webhookID+"/deliveries", token, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		return body["deliveries"].([]any)
+	}
+
+	// failed delivery is retried with backoff
+	status.Store(http.StatusInternalServerError)
+
+	email, _ := registerHTTP(t, st)
+
+	first := receiveWebhook(t, st, received, secret)
+	assert.Equal(t, "user.registered", first["type"])
+	assert.Equal(t, email, first["data"].(map[string]any)["email"])
+
+	var delivery map[string]any
+	require.Eventually(t, func() bool {
+		delivery = deliveries()[0].(map[string]any)
+		return delivery["attempts"] == 1.0
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "pending", delivery["status"])
+	assert.EqualValues(t, http.StatusInternalServerError, delivery["response_code"])
+
+	status.Store(http.StatusOK)
+	st.Clock.Advance(time.Minute)
+
+	retry := receiveWebhook(t, st, received, secret)
+	assert.Equal(t, first["id"], retry["id"])
+
+	require.Eventually(t, func() bool {
+		delivery = deliveries()[0].(map[string]any)
+		return delivery["status"] == "delivered"
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.EqualValues(t, 2, delivery["attempts"])
+
+	deliveryID := strconv.FormatInt(int64(delivery["id"].(float64)), 10)
+
+	resp, _ = doJSON(t, st, http.MethodPost, "/v1/webhook-deliveries/"+deliveryID+"/redeliver", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	redelivered := receiveWebhook(t, st, received, secret)
+	assert.Equal(t, first["id"], redelivered["id"])
+}
+
+// receiveWebhook waits for request to receiver, checks its signature and returns decoded payload
+func receiveWebhook(t *testing.T, st *suite.Suite, received <-chan webhookRequest, secret string) map[string]any {
+	t.Helper()
+
+	var req webhookRequest
+	select {
+	case req = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+
+	err := webhooks.VerifySignature(secret, req.header.Get(webhooks.HeaderSignature), req.body, st.Clock.Now(), 5*time.Minute)
+	require.NoError(t, err)
+
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(req.body, &payload))
+	assert.Equal(t, payload["type"], req.header.Get(webhooks.HeaderEvent))
+
+	return payload
+}
+
+func TestWebhooks_EventsOfAppGoToItsWebhooksOnly(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email, pass := registerHTTP(t, st)
+	token := loginHTTP(t, st, email, pass, "laptop")
+
+	user, err := st.Storage.User(ctx, email)
+	require.NoError(t, err)
+	require.NoError(t, st.Storage.SetAdmin(ctx, user.ID, true))
+
+	otherAppID, err := st.Storage.SaveApp(ctx, "other", "other-secret")
+	require.NoError(t, err)
+
+	received := make(chan webhookRequest, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- webhookRequest{header: r.Header, body: body}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(receiver.Close)
+
+	createWebhook := func(appID int) (string, string) {
+		resp, body := doJSON(t, st, http.MethodPost, "/v1/apps/"+strconv.Itoa(appID)+"/webhooks", token, map[string]any{
+			"url":    receiver.URL,
+			"events": []string{"password.changed"},
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+		return strconv.FormatInt(int64(body["webhook"].(map[string]any)["id"].(float64)), 10), body["secret"].(string)
+	}
+
+	_, secret := createWebhook(appID)
+	otherID, _ := createWebhook(otherAppID)
+
+	resp, _ := doJSON(t, st, http.MethodPost, "/v1/auth/password/change", token,
+		map[string]any{"current_password": pass, "new_password": randomFakePassword()})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	changed := receiveWebhook(t, st, received, secret)
+	assert.Equal(t, "password.changed", changed["type"])
+	assert.EqualValues(t, appID, changed["data"].(map[string]any)["app_id"])
+
+	resp, body := doJSON(t, st, http.MethodGet, "/v1/webhooks/"+otherID+"/deliveries", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, body["deliveries"])
+}
+
+func TestWebhooks_UserDeleted(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	adminEmail, pass := registerHTTP(t, st)
+	token := loginHTTP(t, st, adminEmail, pass, "laptop")
+
+	admin, err := st.Storage.User(ctx, adminEmail)
+	require.NoError(t, err)
+	require.NoError(t, st.Storage.SetAdmin(ctx, admin.ID, true))
+
+	received := make(chan webhookRequest, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- webhookRequest{header: r.Header, body: body}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(receiver.Close)
+
+	resp, body := doJSON(t, st, http.MethodPost, "/v1/apps/"+strconv.Itoa(appID)+"/webhooks", token, map[string]any{
+		"url":    receiver.URL,
+		"events": []string{"user.deleted"},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	secret := body["secret"].(string)
+
+	email, _ := registerHTTP(t, st)
+
+	// users are disabled by operators, the delivery is queued by CLI and sent by the server
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	err = cli.Run(ctx, log, st.Cfg, io.Discard, []string{"user", "disable", email}, cli.WithStorage(st.Storage))
+	require.NoError(t, err)
+
+	st.Clock.Advance(time.Minute)
+
+	deleted := receiveWebhook(t, st, received, secret)
+	assert.Equal(t, "user.deleted", deleted["type"])
+	assert.Equal(t, email, deleted["data"].(map[string]any)["email"])
+}